@@ -0,0 +1,97 @@
+package proxyd
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	lru "github.com/hashicorp/golang-lru"
+)
+
+const affinityCacheSize = 10000
+
+// backendAffinity sends calls to a method with the same first param, such as
+// an address, to the same backend. The backend is the first one available in
+// the order pinBackends gives for the param, and is kept for the TTL of the
+// method even if a backend ranked before comes back meanwhile, so that keys
+// don't flap between backends.
+type backendAffinity struct {
+	ttls   map[string]time.Duration
+	pinned *lru.Cache
+}
+
+type affinityEntry struct {
+	backend   string
+	expiresAt time.Time
+}
+
+func newBackendAffinity(ttls map[string]time.Duration) *backendAffinity {
+	pinned, _ := lru.New(affinityCacheSize)
+	return &backendAffinity{
+		ttls:   ttls,
+		pinned: pinned,
+	}
+}
+
+// key returns the first param of req if its method has an affinity TTL.
+func (a *backendAffinity) key(req *RPCReq) (string, bool) {
+	if _, ok := a.ttls[req.Method]; !ok {
+		return "", false
+	}
+	var params []json.RawMessage
+	if err := json.Unmarshal(req.Params, &params); err != nil || len(params) == 0 {
+		return "", false
+	}
+	var key string
+	if err := json.Unmarshal(params[0], &key); err != nil || key == "" {
+		return "", false
+	}
+	return strings.ToLower(key), true
+}
+
+// order moves the backend req has affinity with to the front of backends.
+func (a *backendAffinity) order(req *RPCReq, backends []*Backend) []*Backend {
+	key, ok := a.key(req)
+	if !ok {
+		return backends
+	}
+	available := func(be *Backend) bool {
+		return be.IsHealthy() && !be.IsBackingOff()
+	}
+
+	now := time.Now()
+	pinnedKey := req.Method + ":" + key
+	var target *Backend
+	if v, ok := a.pinned.Get(pinnedKey); ok {
+		entry := v.(affinityEntry)
+		if now.Before(entry.expiresAt) {
+			for _, be := range backends {
+				if be.Name == entry.backend && available(be) {
+					target = be
+					break
+				}
+			}
+		}
+	}
+	if target == nil {
+		for _, be := range pinBackends(backends, key) {
+			if available(be) {
+				target = be
+				break
+			}
+		}
+		if target == nil {
+			return backends
+		}
+		a.pinned.Add(pinnedKey, affinityEntry{backend: target.Name, expiresAt: now.Add(a.ttls[req.Method])})
+	}
+
+	ordered := make([]*Backend, 0, len(backends))
+	ordered = append(ordered, target)
+	for _, be := range backends {
+		if be != target {
+			ordered = append(ordered, be)
+		}
+	}
+	return ordered
+}
@@ -0,0 +1,78 @@
+package proxyd
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPinBackendsMinimalMovement(t *testing.T) {
+	var backends []*Backend
+	for i := 1; i <= 4; i++ {
+		backends = append(backends, NewBackend(fmt.Sprintf("node%d", i), "http://node", "", nil, nil))
+	}
+	shrunk := []*Backend{backends[0], backends[2], backends[3]}
+
+	const numKeys = 10000
+	owned := make(map[string]int)
+	moved := 0
+	for i := 0; i < numKeys; i++ {
+		key := fmt.Sprintf("0x%040x", i)
+		before := pinBackends(backends, key)
+		require.Len(t, before, len(backends))
+		owned[before[0].Name]++
+
+		after := pinBackends(shrunk, key)[0]
+		if before[0].Name == "node2" {
+			// keys of the removed backend go to the next one in their order
+			require.Equal(t, before[1], after)
+			moved++
+			continue
+		}
+		require.Equal(t, before[0], after)
+	}
+
+	require.Equal(t, owned["node2"], moved)
+	for _, be := range backends {
+		require.InDelta(t, numKeys/len(backends), owned[be.Name], numKeys/10, be.Name)
+	}
+}
+
+func TestBackendAffinityTTL(t *testing.T) {
+	backends := []*Backend{
+		NewBackend("node1", "http://node1", "", nil, nil),
+		NewBackend("node2", "http://node2", "", nil, nil),
+	}
+	ttl := 200 * time.Millisecond
+	affinity := newBackendAffinity(map[string]time.Duration{"eth_getBalance": ttl})
+	req := &RPCReq{
+		JSONRPC: JSONRPCVersion,
+		Method:  "eth_getBalance",
+		Params:  []byte(`["0x0000000000000000000000000000000000000001", "latest"]`),
+	}
+	owner := pinBackends(backends, "0x0000000000000000000000000000000000000001")[0].Name
+	byName := map[string]*Backend{"node1": backends[0], "node2": backends[1]}
+	other := "node1"
+	if owner == "node1" {
+		other = "node2"
+	}
+
+	require.Equal(t, owner, affinity.order(req, backends)[0].Name)
+	require.Equal(t, owner, affinity.order(req, []*Backend{backends[1], backends[0]})[0].Name)
+
+	// methods without affinity keep their order
+	unpinned := &RPCReq{JSONRPC: JSONRPCVersion, Method: "eth_call", Params: req.Params}
+	require.Equal(t, backends, affinity.order(unpinned, backends))
+
+	// the owner becoming unavailable moves the key to the other backend,
+	// which keeps it for the ttl after the owner comes back
+	byName[owner].backOffFor(time.Minute)
+	require.Equal(t, other, affinity.order(req, backends)[0].Name)
+	byName[owner].backOffUntil.Store(0)
+	require.Equal(t, other, affinity.order(req, backends)[0].Name)
+
+	time.Sleep(ttl)
+	require.Equal(t, owner, affinity.order(req, backends)[0].Name)
+}
@@ -7,14 +7,16 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
+	"hash/fnv"
 	"math"
 	"math/rand"
 	"net/http"
+	"net/url"
 	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/ethereum/go-ethereum/common"
@@ -34,6 +36,19 @@ const (
 	notFoundRpcError     = -32601
 )
 
+// Version is the proxyd version sent in the default User-Agent of requests
+// to backends. It is set by the proxyd command from its build info.
+var Version = ""
+
+// DefaultUserAgent returns the User-Agent of requests to backends that
+// configure none.
+func DefaultUserAgent() string {
+	if Version == "" {
+		return "proxyd"
+	}
+	return "proxyd/" + Version
+}
+
 var (
 	ErrParseErr = &RPCErr{
 		Code:          -32700,
@@ -112,6 +127,107 @@ var (
 		HTTPErrorCode: 500,
 	}
 
+	ErrTooManyMethodCallsInBatch = &RPCErr{
+		Code:    JSONRPCErrorInternal - 23,
+		Message: "too many calls to method in batch request",
+	}
+
+	ErrTooManyWSConnections = &RPCErr{
+		Code:          JSONRPCErrorInternal - 22,
+		Message:       "too many websocket connections",
+		HTTPErrorCode: 503,
+	}
+
+	ErrTxUnderpriced = &RPCErr{
+		Code:          JSONRPCErrorInternal - 24,
+		Message:       "transaction gas price below the minimum",
+		HTTPErrorCode: 400,
+	}
+
+	ErrRejectedByWASMHook = &RPCErr{
+		Code:          JSONRPCErrorInternal - 25,
+		Message:       "request rejected",
+		HTTPErrorCode: 403,
+	}
+
+	ErrWASMHookFailed = &RPCErr{
+		Code:          JSONRPCErrorInternal - 26,
+		Message:       "request could not be processed",
+		HTTPErrorCode: 500,
+	}
+
+	ErrRequestHeadersTooLarge = &RPCErr{
+		Code:          JSONRPCErrorInternal - 27,
+		Message:       "request headers too large",
+		HTTPErrorCode: 431,
+	}
+
+	ErrRequestURITooLong = &RPCErr{
+		Code:          JSONRPCErrorInternal - 28,
+		Message:       "request url too long",
+		HTTPErrorCode: 414,
+	}
+
+	ErrQueueTimeout = &RPCErr{
+		Code:          JSONRPCErrorInternal - 29,
+		Message:       "timed out waiting for an rpc slot",
+		HTTPErrorCode: 503,
+	}
+
+	ErrUnsupportedContentType = &RPCErr{
+		Code:          JSONRPCErrorInternal - 30,
+		Message:       "content type must be application/json",
+		HTTPErrorCode: 415,
+	}
+
+	ErrAdminUnauthorized = &RPCErr{
+		Code:          JSONRPCErrorInternal - 31,
+		Message:       "admin authorization required",
+		HTTPErrorCode: 401,
+	}
+
+	ErrMethodConcurrencyLimit = &RPCErr{
+		Code:          JSONRPCErrorInternal - 32,
+		Message:       "too many concurrent calls to method",
+		HTTPErrorCode: 429,
+	}
+
+	ErrGroupOverrideNotAllowed = &RPCErr{
+		Code:          JSONRPCErrorInternal - 33,
+		Message:       "backend group override not allowed",
+		HTTPErrorCode: 403,
+	}
+
+	ErrInvalidGroupOverride = &RPCErr{
+		Code:          JSONRPCErrorInternal - 34,
+		Message:       "invalid backend group override",
+		HTTPErrorCode: 400,
+	}
+
+	ErrOverIPConcurrencyLimit = &RPCErr{
+		Code:          JSONRPCErrorInternal - 35,
+		Message:       "too many concurrent requests from client IP",
+		HTTPErrorCode: 429,
+	}
+
+	ErrMaxHopsExceeded = &RPCErr{
+		Code:          JSONRPCErrorInternal - 36,
+		Message:       "request forwarded through too many proxyd instances",
+		HTTPErrorCode: http.StatusLoopDetected,
+	}
+
+	ErrOverSpendingLimit = &RPCErr{
+		Code:          JSONRPCErrorInternal - 37,
+		Message:       "over spending limit",
+		HTTPErrorCode: 429,
+	}
+
+	ErrSendRawTxInProgress = &RPCErr{
+		Code:          JSONRPCErrorInternal - 38,
+		Message:       "the same transaction is being sent by another request",
+		HTTPErrorCode: http.StatusConflict,
+	}
+
 	ErrBackendUnexpectedJSONRPC = errors.New("backend returned an unexpected JSON-RPC response")
 
 	ErrConsensusGetReceiptsCantBeBatched = errors.New("consensus_getReceipts cannot be batched")
@@ -142,6 +258,9 @@ type Backend struct {
 	authUsername         string
 	authPassword         string
 	headers              map[string]string
+	hostHeader           string
+	userAgent            string
+	bodyBuffers          *bufferPool
 	client               *LimitedHTTPClient
 	consensusSemaphore   *semaphore.Weighted
 	dialer               *websocket.Dialer
@@ -153,6 +272,19 @@ type Backend struct {
 	stripTrailingXFF     bool
 	proxydIP             string
 
+	// rpsLimiter caps outbound requests at maxRPS when enforceMaxRPS, with
+	// bursts of up to maxRPSBurst. Requests over it wait for a token if
+	// maxRPSWait, or skip the backend.
+	rpsLimiter    *tokenBucket
+	enforceMaxRPS bool
+	maxRPSBurst   int
+	maxRPSWait    bool
+
+	// backOffUntil is when the backend may be tried again after a 429 with a
+	// Retry-After header, in unix nanoseconds, capped at maxRetryAfter.
+	backOffUntil  atomic.Int64
+	maxRetryAfter time.Duration
+
 	skipPeerCountCheck bool
 	forcedCandidate    bool
 
@@ -164,7 +296,20 @@ type Backend struct {
 	networkRequestsSlidingWindow    *sw.AvgSlidingWindow
 	intermittentErrorsSlidingWindow *sw.AvgSlidingWindow
 
+	// inFlight counts the requests sent to the backend awaiting a response.
+	inFlight atomic.Int64
+
 	weight int
+
+	excludedMethods map[string]bool
+
+	rewriteRequestIDs    bool
+	jsonRPCMode          JSONRPCMode
+	rejectMissingJSONRPC bool
+
+	// idleConns is set when a backend group prefers backends with a warm
+	// connection.
+	idleConns *idleConnTracker
 }
 
 type BackendOpt func(b *Backend)
@@ -212,12 +357,54 @@ func WithMaxRPS(maxRPS int) BackendOpt {
 	}
 }
 
+func WithMaxRetryAfter(maxRetryAfter time.Duration) BackendOpt {
+	return func(b *Backend) {
+		b.maxRetryAfter = maxRetryAfter
+	}
+}
+
+func WithEnforceMaxRPS() BackendOpt {
+	return func(b *Backend) {
+		b.enforceMaxRPS = true
+	}
+}
+
+func WithMaxRPSBurst(burst int) BackendOpt {
+	return func(b *Backend) {
+		b.maxRPSBurst = burst
+	}
+}
+
+func WithMaxRPSWait() BackendOpt {
+	return func(b *Backend) {
+		b.maxRPSWait = true
+	}
+}
+
 func WithMaxWSConns(maxConns int) BackendOpt {
 	return func(b *Backend) {
 		b.maxWSConns = maxConns
 	}
 }
 
+func WithHostHeader(host string) BackendOpt {
+	return func(b *Backend) {
+		b.hostHeader = host
+	}
+}
+
+func WithBodyBufferPool(pool *bufferPool) BackendOpt {
+	return func(b *Backend) {
+		b.bodyBuffers = pool
+	}
+}
+
+func WithUserAgent(userAgent string) BackendOpt {
+	return func(b *Backend) {
+		b.userAgent = userAgent
+	}
+}
+
 func WithTLSConfig(tlsConfig *tls.Config) BackendOpt {
 	return func(b *Backend) {
 		if b.client.Transport == nil {
@@ -227,6 +414,18 @@ func WithTLSConfig(tlsConfig *tls.Config) BackendOpt {
 	}
 }
 
+// WithProxy sends requests to the backend through an HTTP, HTTPS or SOCKS5
+// proxy. Websocket connections only support HTTP and SOCKS5 proxies.
+func WithProxy(proxyURL *url.URL) BackendOpt {
+	return func(b *Backend) {
+		if b.client.Transport == nil {
+			b.client.Transport = &http.Transport{}
+		}
+		b.client.Transport.(*http.Transport).Proxy = http.ProxyURL(proxyURL)
+		b.dialer.Proxy = http.ProxyURL(proxyURL)
+	}
+}
+
 func WithStrippedTrailingXFF() BackendOpt {
 	return func(b *Backend) {
 		b.stripTrailingXFF = true
@@ -257,6 +456,15 @@ func WithWeight(weight int) BackendOpt {
 	}
 }
 
+func WithExcludedMethods(methods []string) BackendOpt {
+	return func(b *Backend) {
+		b.excludedMethods = make(map[string]bool, len(methods))
+		for _, method := range methods {
+			b.excludedMethods[method] = true
+		}
+	}
+}
+
 func WithMaxDegradedLatencyThreshold(maxDegradedLatencyThreshold time.Duration) BackendOpt {
 	return func(b *Backend) {
 		b.maxDegradedLatencyThreshold = maxDegradedLatencyThreshold
@@ -281,6 +489,27 @@ func WithConsensusReceiptTarget(receiptsTarget string) BackendOpt {
 	}
 }
 
+func WithRequestIDRewriting() BackendOpt {
+	return func(b *Backend) {
+		b.rewriteRequestIDs = true
+	}
+}
+
+func WithJSONRPCMode(mode JSONRPCMode, rejectMissing bool) BackendOpt {
+	return func(b *Backend) {
+		b.jsonRPCMode = mode
+		b.rejectMissingJSONRPC = rejectMissing
+	}
+}
+
+// WithPrioritySemaphore admits requests sharing the rpc semaphore by their
+// priority level instead of in arrival order.
+func WithPrioritySemaphore(sem *PrioritySemaphore) BackendOpt {
+	return func(b *Backend) {
+		b.client.prioritySem = sem
+	}
+}
+
 func WithIntermittentNetworkErrorSlidingWindow(sw *sw.AvgSlidingWindow) BackendOpt {
 	return func(b *Backend) {
 		b.intermittentErrorsSlidingWindow = sw
@@ -325,6 +554,7 @@ func NewBackend(
 		Name:            name,
 		rpcURL:          rpcURL,
 		wsURL:           wsURL,
+		userAgent:       DefaultUserAgent(),
 		maxResponseSize: math.MaxInt64,
 		client: &LimitedHTTPClient{
 			Client:      http.Client{Timeout: 5 * time.Second},
@@ -337,6 +567,9 @@ func NewBackend(
 		maxLatencyThreshold:         10 * time.Second,
 		maxDegradedLatencyThreshold: 5 * time.Second,
 		maxErrorRateThreshold:       0.5,
+		maxRetryAfter:               defaultMaxRetryAfter,
+
+		jsonRPCMode: JSONRPCModeStrict,
 
 		latencySlidingWindow:            sw.NewSlidingWindow(),
 		networkRequestsSlidingWindow:    sw.NewSlidingWindow(),
@@ -345,6 +578,14 @@ func NewBackend(
 
 	backend.Override(opts...)
 
+	if backend.enforceMaxRPS && backend.maxRPS > 0 {
+		burst := backend.maxRPSBurst
+		if burst == 0 {
+			burst = backend.maxRPS
+		}
+		backend.rpsLimiter = newTokenBucket(float64(backend.maxRPS), burst)
+	}
+
 	if !backend.stripTrailingXFF && backend.proxydIP == "" {
 		log.Warn("proxied requests' XFF header will not contain the proxyd ip address")
 	}
@@ -358,11 +599,39 @@ func (b *Backend) Override(opts ...BackendOpt) {
 	}
 }
 
+// IdleConns returns the number of idle connections to the backend, if they
+// are tracked.
+func (b *Backend) IdleConns() int {
+	if b.idleConns == nil {
+		return 0
+	}
+	return b.idleConns.Idle()
+}
+
+// TrackIdleConns starts tracking the idle connections to the backend. It must
+// be called before the backend serves requests.
+func (b *Backend) TrackIdleConns() {
+	if b.idleConns != nil {
+		return
+	}
+	if b.client.Transport == nil {
+		b.client.Transport = &http.Transport{}
+	}
+	b.idleConns = newIdleConnTracker()
+	b.idleConns.install(b.client.Transport.(*http.Transport))
+}
+
 func (b *Backend) Forward(ctx context.Context, reqs []*RPCReq, isBatch bool) ([]*RPCRes, error) {
 	var lastError error
 	// <= to account for the first attempt not technically being
 	// a retry
 	for i := 0; i <= b.maxRetries; i++ {
+		if b.IsBackingOff() {
+			return nil, ErrBackendOverCapacity
+		}
+		if err := b.waitForRPSToken(ctx); err != nil {
+			return nil, err
+		}
 		RecordBatchRPCForward(ctx, b.Name, reqs, RPCRequestSourceHTTP)
 		metricLabelMethod := reqs[0].Method
 		if isBatch {
@@ -384,7 +653,11 @@ func (b *Backend) Forward(ctx context.Context, reqs []*RPCReq, isBatch bool) ([]
 			"max_attempts", b.maxRetries+1,
 			"method", metricLabelMethod,
 		)
-		res, err := b.doForward(ctx, reqs, isBatch, nil)
+		doneBackendTime := trackBackendTime(ctx)
+		spanCtx, span := startBackendSpan(ctx, b.Name, reqs, isBatch)
+		res, err := b.doForward(spanCtx, reqs, isBatch, nil)
+		endSpan(span, err)
+		doneBackendTime()
 		switch err {
 		case nil: // do nothing
 		case ErrBackendResponseTooLarge:
@@ -396,6 +669,26 @@ func (b *Backend) Forward(ctx context.Context, reqs []*RPCReq, isBatch bool) ([]
 				"method", metricLabelMethod,
 			)
 			RecordBatchRPCError(ctx, b.Name, reqs, err)
+		case ErrQueueTimeout:
+			log.Warn(
+				"timed out waiting for an rpc slot",
+				"name", b.Name,
+				"req_id", GetReqID(ctx),
+				"method", metricLabelMethod,
+			)
+			RecordBatchRPCError(ctx, b.Name, reqs, err)
+		case ErrMaxHopsExceeded:
+			log.Warn(
+				"backend rejected request as looping",
+				"name", b.Name,
+				"req_id", GetReqID(ctx),
+				"hop", GetHop(ctx),
+				"method", metricLabelMethod,
+			)
+			RecordBatchRPCError(ctx, b.Name, reqs, err)
+		case ErrBackendOverCapacity:
+			// the backend asked to retry later, so don't retry it right away
+			RecordBatchRPCError(ctx, b.Name, reqs, err)
 		case ErrConsensusGetReceiptsCantBeBatched:
 			log.Warn(
 				"Received unsupported batch request for consensus_getReceipts",
@@ -447,7 +740,8 @@ func (b *Backend) Forward(ctx context.Context, reqs []*RPCReq, isBatch bool) ([]
 }
 
 func (b *Backend) ProxyWS(clientConn *websocket.Conn, methodWhitelist *StringSet) (*WSProxier, error) {
-	backendConn, _, err := b.dialer.Dial(b.wsURL, nil) // nolint:bodyclose
+	header := http.Header{"User-Agent": []string{b.userAgent}}
+	backendConn, _, err := b.dialer.Dial(b.wsURL, header) // nolint:bodyclose
 	if err != nil {
 		return nil, wrapErr(err, "error dialing backend")
 	}
@@ -491,6 +785,8 @@ func (b *Backend) ForwardRPC(ctx context.Context, res *RPCRes, id string, method
 func (b *Backend) doForward(ctx context.Context, rpcReqs []*RPCReq, isBatch bool, sem *semaphore.Weighted) ([]*RPCRes, error) {
 	// we are concerned about network error rates, so we record 1 request independently of how many are in the batch
 	b.networkRequestsSlidingWindow.Incr()
+	b.inFlight.Add(1)
+	defer b.inFlight.Add(-1)
 
 	translatedReqs := make(map[string]*RPCReq, len(rpcReqs))
 	// translate consensus_getReceipts to receipts target
@@ -547,15 +843,36 @@ func (b *Backend) doForward(ctx context.Context, rpcReqs []*RPCReq, isBatch bool
 
 	isSingleElementBatch := len(rpcReqs) == 1
 
+	// outReqs are the requests as the backend sees them. When request ID
+	// rewriting is enabled they carry proxyd-assigned IDs, which are mapped
+	// back to the client's IDs once the response has been parsed.
+	outReqs := rpcReqs
+	var originalIDs map[string]json.RawMessage
+	if b.rewriteRequestIDs {
+		outReqs, originalIDs = rewriteRequestIDs(rpcReqs)
+	}
+
 	// Single element batches are unwrapped before being sent
 	// since Alchemy handles single requests better than batches.
 	var body []byte
-	if isSingleElementBatch {
-		body = mustMarshalJSON(rpcReqs[0])
-	} else {
-		body = mustMarshalJSON(rpcReqs)
+	switch {
+	case b.jsonRPCMode == JSONRPCModeOmit && isSingleElementBatch:
+		body = mustMarshalJSON(newVersionlessRPCReq(outReqs[0]))
+	case b.jsonRPCMode == JSONRPCModeOmit:
+		versionless := make([]*versionlessRPCReq, len(outReqs))
+		for i, req := range outReqs {
+			versionless[i] = newVersionlessRPCReq(req)
+		}
+		body = mustMarshalJSON(versionless)
+	case isSingleElementBatch:
+		body = mustMarshalJSON(outReqs[0])
+	default:
+		body = mustMarshalJSON(outReqs)
 	}
 
+	if b.idleConns != nil {
+		ctx = b.idleConns.withTrace(ctx)
+	}
 	httpReq, err := http.NewRequestWithContext(ctx, "POST", b.rpcURL, bytes.NewReader(body))
 	if err != nil {
 		b.intermittentErrorsSlidingWindow.Incr()
@@ -581,10 +898,16 @@ func (b *Backend) doForward(ctx context.Context, rpcReqs []*RPCReq, isBatch bool
 
 	httpReq.Header.Set("content-type", "application/json")
 	httpReq.Header.Set("X-Forwarded-For", xForwardedFor)
+	httpReq.Header.Set("User-Agent", b.userAgent)
+	httpReq.Header.Set(proxydHopHdr, strconv.Itoa(GetHop(ctx)+1))
+	injectTraceContext(ctx, httpReq.Header)
 
 	for name, value := range b.headers {
 		httpReq.Header.Set(name, value)
 	}
+	if b.hostHeader != "" {
+		httpReq.Host = b.hostHeader
+	}
 
 	txSource := GetTxSource(ctx)
 	if txSource != "" {
@@ -593,6 +916,9 @@ func (b *Backend) doForward(ctx context.Context, rpcReqs []*RPCReq, isBatch bool
 
 	start := time.Now()
 	httpRes, err := b.client.DoWithSemaphore(httpReq, sem)
+	if errors.Is(err, ErrQueueTimeout) {
+		return nil, ErrQueueTimeout
+	}
 	if err != nil {
 		b.intermittentErrorsSlidingWindow.Incr()
 		RecordBackendNetworkErrorRateSlidingWindow(b, b.ErrorRate())
@@ -611,15 +937,37 @@ func (b *Backend) doForward(ctx context.Context, rpcReqs []*RPCReq, isBatch bool
 		strconv.FormatBool(isBatch),
 	).Inc()
 
+	// a downstream proxyd rejected the request as looping, which isn't an
+	// error of the backend itself
+	if httpRes.StatusCode == http.StatusLoopDetected {
+		return nil, ErrMaxHopsExceeded
+	}
+
 	// Alchemy returns a 400 on bad JSONs, so handle that case
 	if httpRes.StatusCode != 200 && httpRes.StatusCode != 400 {
 		b.intermittentErrorsSlidingWindow.Incr()
 		RecordBackendNetworkErrorRateSlidingWindow(b, b.ErrorRate())
+		if httpRes.StatusCode == http.StatusTooManyRequests {
+			if retryAfter := parseRetryAfter(httpRes.Header.Get("Retry-After"), time.Now()); retryAfter > 0 {
+				b.backOffFor(retryAfter)
+				return nil, ErrBackendOverCapacity
+			}
+		}
 		return nil, fmt.Errorf("response code %d", httpRes.StatusCode)
 	}
 
 	defer httpRes.Body.Close()
-	resB, err := io.ReadAll(LimitReader(httpRes.Body, b.maxResponseSize))
+	resBody := LimitReader(httpRes.Body, b.maxResponseSize)
+	if stream := getResponseStream(ctx); stream != nil && len(translatedReqs) == 0 && b.canStreamResponses(rpcReqs, isBatch) {
+		var streamed bool
+		resBody, streamed = stream.copy(ctx, b.Name, resBody)
+		if streamed {
+			return []*RPCRes{streamedRes(rpcReqs[0])}, nil
+		}
+	}
+	resBuf, err := b.bodyBuffers.readAll(resBody)
+	defer b.bodyBuffers.put(resBuf)
+	resB := resBuf.Bytes()
 	if errors.Is(err, ErrLimitReaderOverLimit) {
 		return nil, ErrBackendResponseTooLarge
 	}
@@ -652,6 +1000,18 @@ func (b *Backend) doForward(ctx context.Context, rpcReqs []*RPCReq, isBatch bool
 		}
 	}
 
+	for _, res := range rpcRes {
+		if res.JSONRPC == JSONRPCVersion {
+			continue
+		}
+		if b.rejectMissingJSONRPC {
+			b.intermittentErrorsSlidingWindow.Incr()
+			RecordBackendNetworkErrorRateSlidingWindow(b, b.ErrorRate())
+			return nil, ErrBackendBadResponse
+		}
+		res.JSONRPC = JSONRPCVersion
+	}
+
 	if len(rpcReqs) != len(rpcRes) {
 		b.intermittentErrorsSlidingWindow.Incr()
 		RecordBackendNetworkErrorRateSlidingWindow(b, b.ErrorRate())
@@ -664,12 +1024,24 @@ func (b *Backend) doForward(ctx context.Context, rpcReqs []*RPCReq, isBatch bool
 		for _, res := range rpcRes {
 			res.Error.HTTPErrorCode = httpRes.StatusCode
 		}
+	} else if ttl, ok := backendCacheTTL(httpRes.Header.Get("Cache-Control")); ok {
+		for _, res := range rpcRes {
+			res.cacheTTL = ttl
+		}
 	}
 	duration := time.Since(start)
 	b.latencySlidingWindow.Add(float64(duration))
 	RecordBackendNetworkLatencyAverageSlidingWindow(b, time.Duration(b.latencySlidingWindow.Avg()))
 	RecordBackendNetworkErrorRateSlidingWindow(b, b.ErrorRate())
 
+	sortBatchRPCResponse(outReqs, rpcRes)
+
+	if originalIDs != nil {
+		if err := restoreResponseIDs(rpcRes, originalIDs); err != nil {
+			return nil, err
+		}
+	}
+
 	// enrich the response with the actual request method
 	for _, res := range rpcRes {
 		translatedReq, exist := translatedReqs[string(res.ID)]
@@ -681,8 +1053,6 @@ func (b *Backend) doForward(ctx context.Context, rpcReqs []*RPCReq, isBatch bool
 		}
 	}
 
-	sortBatchRPCResponse(rpcReqs, rpcRes)
-
 	return rpcRes, nil
 }
 
@@ -720,22 +1090,74 @@ func responseIsNotBatched(b []byte) bool {
 	return json.Unmarshal(b, &r) == nil
 }
 
-// sortBatchRPCResponse sorts the RPCRes slice according to the position of its corresponding ID in the RPCReq slice
+// sortBatchRPCResponse sorts the RPCRes slice according to the position of its corresponding ID in the RPCReq slice,
+// and gives each response the exact ID of its request, in case the backend re-encoded it.
 func sortBatchRPCResponse(req []*RPCReq, res []*RPCRes) {
 	pos := make(map[string]int, len(req))
 	for i, r := range req {
-		key := string(r.ID)
+		key := idKey(r.ID)
 		if _, ok := pos[key]; ok {
 			panic("bug! detected requests with duplicate IDs")
 		}
 		pos[key] = i
 	}
 
+	keys := make(map[*RPCRes]string, len(res))
+	for _, r := range res {
+		keys[r] = idKey(r.ID)
+	}
 	sort.Slice(res, func(i, j int) bool {
-		l := res[i].ID
-		r := res[j].ID
-		return pos[string(l)] < pos[string(r)]
+		return pos[keys[res[i]]] < pos[keys[res[j]]]
 	})
+
+	for i, r := range res {
+		if i < len(req) && keys[r] == idKey(req[i].ID) {
+			r.ID = req[i].ID
+		}
+	}
+}
+
+// nextUpstreamRequestID is the source of the proxyd-assigned IDs used
+// when request ID rewriting is enabled.
+var nextUpstreamRequestID atomic.Uint64
+
+// rewriteRequestIDs returns copies of reqs carrying process-unique IDs along
+// with a mapping from the assigned IDs back to the original ones. Requests
+// without an ID (notifications) are passed through untouched.
+func rewriteRequestIDs(reqs []*RPCReq) ([]*RPCReq, map[string]json.RawMessage) {
+	out := make([]*RPCReq, len(reqs))
+	originalIDs := make(map[string]json.RawMessage, len(reqs))
+	for i, req := range reqs {
+		if isNotification(req) {
+			out[i] = req
+			continue
+		}
+		rewritten := *req
+		rewritten.ID = json.RawMessage(strconv.FormatUint(nextUpstreamRequestID.Add(1), 10))
+		originalIDs[string(rewritten.ID)] = req.ID
+		out[i] = &rewritten
+	}
+	return out, originalIDs
+}
+
+// restoreResponseIDs replaces the proxyd-assigned IDs in res with the
+// client's original IDs.
+func restoreResponseIDs(res []*RPCRes, originalIDs map[string]json.RawMessage) error {
+	for _, r := range res {
+		if len(r.ID) == 0 || string(r.ID) == "null" {
+			continue
+		}
+		id, ok := originalIDs[string(r.ID)]
+		if !ok {
+			return ErrBackendUnexpectedJSONRPC
+		}
+		r.ID = id
+	}
+	return nil
+}
+
+func isNotification(req *RPCReq) bool {
+	return len(req.ID) == 0 || string(req.ID) == "null"
 }
 
 type BackendGroup struct {
@@ -746,6 +1168,27 @@ type BackendGroup struct {
 	FallbackBackends       map[string]bool
 	routingStrategy        RoutingStrategy
 	multicallRPCErrorCheck bool
+	spilloverGroup         string
+	spilloverPercent       int
+	replica                *replicaRouter
+	txDedup                *txDedup
+	selector               BackendSelector
+	balanceSnapshot        *balanceSnapshot
+	DriftSampler           *DriftSampler
+	pinNonceReads          bool
+	queueTimeout           time.Duration
+	sloTimeout             time.Duration
+	methodLimiter          *methodLimiter
+	warmConnTie            func(a, b *Backend) bool
+	canary                 *canary
+	outageCache            *outageCache
+	cacheOnlyOnOutage      bool
+	cacheOnBackoff         bool
+	affinity               *backendAffinity
+	batchFanoutConcurrency int
+	netVersion             string
+	invalidParams          *invalidParamsPolicy
+	coalescer              *requestCoalescer
 }
 
 func (bg *BackendGroup) GetRoutingStrategy() RoutingStrategy {
@@ -773,13 +1216,57 @@ func (bg *BackendGroup) Primaries() []*Backend {
 	return primaries
 }
 
-// NOTE: BackendGroup Forward contains the log for balancing with consensus aware
+// Forward sends rpcReqs to the group. When the group has an SLO timeout and
+// serving them takes longer, the backends are abandoned and ErrGatewayTimeout
+// is returned.
 func (bg *BackendGroup) Forward(ctx context.Context, rpcReqs []*RPCReq, isBatch bool) ([]*RPCRes, string, error) {
+	if bg.sloTimeout == 0 {
+		return bg.forward(ctx, rpcReqs, isBatch)
+	}
+
+	sloCtx, cancel := context.WithTimeout(ctx, bg.sloTimeout)
+	defer cancel()
+	res, servedBy, err := bg.forward(sloCtx, rpcReqs, isBatch)
+	if sloCtx.Err() == context.DeadlineExceeded && ctx.Err() == nil {
+		log.Warn("backend group exceeded its slo timeout",
+			"backend_group", bg.Name,
+			"req_id", GetReqID(ctx),
+			"slo_timeout", bg.sloTimeout,
+		)
+		RecordSLOViolation(bg.Name)
+		return nil, servedBy, ErrGatewayTimeout
+	}
+	return res, servedBy, err
+}
+
+// NOTE: BackendGroup forward contains the log for balancing with consensus aware
+func (bg *BackendGroup) forward(ctx context.Context, rpcReqs []*RPCReq, isBatch bool) ([]*RPCRes, string, error) {
 	if len(rpcReqs) == 0 {
 		return nil, "", nil
 	}
 
-	backends := bg.orderedBackendsForRequest()
+	if bg.methodLimiter != nil {
+		release, err := bg.methodLimiter.acquire(ctx, rpcReqs, bg.queueTimeout)
+		if err != nil {
+			return nil, "", err
+		}
+		defer release()
+	}
+
+	if bg.queueTimeout > 0 {
+		ctx = withQueueTimeout(ctx, bg.Name, bg.queueTimeout)
+	}
+
+	backends := bg.orderedBackendsForRequest(ctx, selectionMethod(rpcReqs))
+	if bg.pinNonceReads && len(rpcReqs) == 1 {
+		if addr, ok := nonceReadAddress(rpcReqs[0]); ok {
+			backends = pinBackends(backends, addr)
+		}
+	}
+	if bg.affinity != nil && len(rpcReqs) == 1 {
+		backends = bg.affinity.order(rpcReqs[0], backends)
+	}
+	backends = withoutExcludedBackends(backends, rpcReqs)
 
 	overriddenResponses := make([]*indexedReqRes, 0)
 	rewrittenReqs := make([]*RPCReq, 0, len(rpcReqs))
@@ -800,6 +1287,15 @@ func (bg *BackendGroup) Forward(ctx context.Context, rpcReqs []*RPCReq, isBatch
 
 	rpcRequestsTotal.Inc()
 
+	canaried := bg.canary != nil && bg.canary.applies(rpcReqs)
+	if canaried && bg.canary.selected() {
+		if backendResp, ok := bg.canary.forward(ctx, rpcReqs, isBatch); ok {
+			return OverrideResponses(backendResp.RPCRes, overriddenResponses), backendResp.ServedBy, nil
+		}
+		// only calls that skipped the canary count towards the baseline
+		canaried = false
+	}
+
 	ch := make(chan BackendGroupRPCResponse)
 	go func() {
 		defer close(ch)
@@ -807,6 +1303,9 @@ func (bg *BackendGroup) Forward(ctx context.Context, rpcReqs []*RPCReq, isBatch
 		ch <- *backendResp
 	}()
 	backendResp := <-ch
+	if canaried {
+		bg.canary.recordBaseline(&backendResp)
+	}
 
 	if backendResp.error != nil {
 		log.Error("error serving requests",
@@ -1029,36 +1528,59 @@ func weightedShuffle(backends []*Backend) {
 	weightedshuffle.ShuffleInplace(backends, weight, nil)
 }
 
-func (bg *BackendGroup) orderedBackendsForRequest() []*Backend {
+// selectBackends orders the healthy backends of the group with its selector.
+func (bg *BackendGroup) selectBackends(ctx context.Context, method string, healthy []*Backend) []*Backend {
+	selector := bg.selector
+	if selector == nil {
+		selector = BackendSelectorFunc(selectOrdered)
+	}
+	return selector.Select(ctx, method, healthy)
+}
+
+// selectionMethod returns the method passed to the backend selector for
+// rpcReqs, which is empty unless they all call the same method.
+func selectionMethod(rpcReqs []*RPCReq) string {
+	for _, req := range rpcReqs[1:] {
+		if req.Method != rpcReqs[0].Method {
+			return ""
+		}
+	}
+	return rpcReqs[0].Method
+}
+
+func (bg *BackendGroup) orderedBackendsForRequest(ctx context.Context, method string) []*Backend {
 	if bg.Consensus != nil {
-		return bg.loadBalancedConsensusGroup()
+		return bg.loadBalancedConsensusGroup(ctx, method)
 	} else {
 		healthy := make([]*Backend, 0, len(bg.Backends))
 		unhealthy := make([]*Backend, 0, len(bg.Backends))
 		for _, be := range bg.Backends {
-			if be.IsHealthy() {
+			if be.IsHealthy() && !be.IsBackingOff() {
 				healthy = append(healthy, be)
 			} else {
 				unhealthy = append(unhealthy, be)
 			}
 		}
+		healthy = bg.selectBackends(ctx, method, healthy)
 		if bg.WeightedRouting {
-			weightedShuffle(healthy)
 			weightedShuffle(unhealthy)
 		}
+		if bg.warmConnTie != nil {
+			healthy = preferWarmBackends(healthy, bg.warmConnTie)
+		}
 		return append(healthy, unhealthy...)
 	}
 }
 
-func (bg *BackendGroup) loadBalancedConsensusGroup() []*Backend {
+func (bg *BackendGroup) loadBalancedConsensusGroup(ctx context.Context, method string) []*Backend {
 	cg := bg.Consensus.GetConsensusGroup()
 
 	backendsHealthy := make([]*Backend, 0, len(cg))
 	backendsDegraded := make([]*Backend, 0, len(cg))
 	// separate into healthy, degraded and unhealthy backends
 	for _, be := range cg {
-		// unhealthy are filtered out and not attempted
-		if !be.IsHealthy() {
+		// unhealthy and backing off are filtered out and not attempted
+		if !be.IsHealthy() || be.IsBackingOff() {
 			continue
 		}
 		if be.IsDegraded() {
@@ -1068,17 +1590,11 @@ func (bg *BackendGroup) loadBalancedConsensusGroup() []*Backend {
 		backendsHealthy = append(backendsHealthy, be)
 	}
 
-	// shuffle both slices
-	r := rand.New(rand.NewSource(time.Now().UnixNano()))
-	r.Shuffle(len(backendsHealthy), func(i, j int) {
-		backendsHealthy[i], backendsHealthy[j] = backendsHealthy[j], backendsHealthy[i]
-	})
-	r.Shuffle(len(backendsDegraded), func(i, j int) {
-		backendsDegraded[i], backendsDegraded[j] = backendsDegraded[j], backendsDegraded[i]
-	})
+	backendsHealthy = bg.selectBackends(ctx, method, backendsHealthy)
+	backendsDegraded = selectRandom(ctx, method, backendsDegraded)
 
-	if bg.WeightedRouting {
-		weightedShuffle(backendsHealthy)
+	if bg.warmConnTie != nil {
+		backendsHealthy = preferWarmBackends(backendsHealthy, bg.warmConnTie)
 	}
 
 	// healthy are put into a priority position
@@ -1088,10 +1604,94 @@ func (bg *BackendGroup) loadBalancedConsensusGroup() []*Backend {
 	return backendsHealthy
 }
 
+// nonceReadAddress returns the address of an eth_getTransactionCount request.
+func nonceReadAddress(req *RPCReq) (string, bool) {
+	if req.Method != "eth_getTransactionCount" {
+		return "", false
+	}
+	var params []json.RawMessage
+	if err := json.Unmarshal(req.Params, &params); err != nil || len(params) == 0 {
+		return "", false
+	}
+	var addr string
+	if err := json.Unmarshal(params[0], &addr); err != nil || !common.IsHexAddress(addr) {
+		return "", false
+	}
+	return strings.ToLower(addr), true
+}
+
+// pinBackends reorders backends so that the same key is served by the same
+// backend, using rendezvous hashing so that few keys move when backends come
+// and go. Healthy backends still come before degraded and unhealthy ones.
+func pinBackends(backends []*Backend, key string) []*Backend {
+	scores := make(map[*Backend]uint64, len(backends))
+	for _, be := range backends {
+		h := fnv.New64a()
+		_, _ = h.Write([]byte(key))
+		_, _ = h.Write([]byte(be.Name))
+		scores[be] = mixHash(h.Sum64())
+	}
+	rank := func(be *Backend) int {
+		switch {
+		case !be.IsHealthy():
+			return 2
+		case be.IsDegraded():
+			return 1
+		default:
+			return 0
+		}
+	}
+
+	pinned := make([]*Backend, len(backends))
+	copy(pinned, backends)
+	sort.SliceStable(pinned, func(i, j int) bool {
+		if ri, rj := rank(pinned[i]), rank(pinned[j]); ri != rj {
+			return ri < rj
+		}
+		return scores[pinned[i]] > scores[pinned[j]]
+	})
+	return pinned
+}
+
+// mixHash spreads the bits of h, whose high bits FNV barely changes between
+// inputs differing only in their last bytes, such as backend names.
+func mixHash(h uint64) uint64 {
+	h ^= h >> 33
+	h *= 0xff51afd7ed558ccd
+	h ^= h >> 33
+	h *= 0xc4ceb9fe1a85ec53
+	h ^= h >> 33
+	return h
+}
+
+// withoutExcludedBackends removes the backends excluded from serving any of
+// the methods of reqs.
+func withoutExcludedBackends(backends []*Backend, reqs []*RPCReq) []*Backend {
+	filtered := make([]*Backend, 0, len(backends))
+	for _, be := range backends {
+		if !be.isExcludedFrom(reqs) {
+			filtered = append(filtered, be)
+		}
+	}
+	return filtered
+}
+
+func (b *Backend) isExcludedFrom(reqs []*RPCReq) bool {
+	for _, req := range reqs {
+		if b.excludedMethods[req.Method] {
+			return true
+		}
+	}
+	return false
+}
+
 func (bg *BackendGroup) Shutdown() {
 	if bg.Consensus != nil {
 		bg.Consensus.Shutdown()
 	}
+	if bg.DriftSampler != nil {
+		bg.DriftSampler.Shutdown()
+	}
 }
 
 func calcBackoff(i int) time.Duration {
@@ -1109,6 +1709,9 @@ type WSProxier struct {
 	methodWhitelist *StringSet
 	readTimeout     time.Duration
 	writeTimeout    time.Duration
+	// queue decouples writing backend messages to the client from reading
+	// them, when backpressure is configured.
+	queue *wsClientQueue
 }
 
 func NewWSProxier(backend *Backend, clientConn, backendConn *websocket.Conn, methodWhitelist *StringSet) *WSProxier {
@@ -1123,9 +1726,12 @@ func NewWSProxier(backend *Backend, clientConn, backendConn *websocket.Conn, met
 }
 
 func (w *WSProxier) Proxy(ctx context.Context) error {
-	errC := make(chan error, 2)
+	errC := make(chan error, 3)
 	go w.clientPump(ctx, errC)
 	go w.backendPump(ctx, errC)
+	if w.queue != nil {
+		go w.clientWriter(errC)
+	}
 	err := <-errC
 	w.close()
 	return err
@@ -1266,7 +1872,7 @@ func (w *WSProxier) backendPump(ctx context.Context, errC chan error) {
 			}
 		}
 
-		err = w.writeClientConn(msgType, msg)
+		err = w.sendClient(ctx, msgType, msg, res)
 		if err != nil {
 			errC <- err
 			return
@@ -1275,12 +1881,21 @@ func (w *WSProxier) backendPump(ctx context.Context, errC chan error) {
 }
 
 func (w *WSProxier) close() {
+	if w.queue != nil {
+		w.queue.close()
+	}
 	w.clientConn.Close()
 	w.backendConn.Close()
 	activeBackendWsConnsGauge.WithLabelValues(w.backend.Name).Dec()
 }
 
 func (w *WSProxier) prepareClientMsg(msg []byte) (*RPCReq, error) {
+	if IsBatch(msg) {
+		if reqs, err := ParseBatchRPCReq(msg); err == nil && len(reqs) == 0 {
+			return nil, ErrInvalidRequest("must specify at least one batch call")
+		}
+	}
+
 	req, err := ParseRPCReq(msg)
 	if err != nil {
 		return nil, err
@@ -1352,6 +1967,7 @@ func sleepContext(ctx context.Context, duration time.Duration) {
 type LimitedHTTPClient struct {
 	http.Client
 	sem         *semaphore.Weighted
+	prioritySem *PrioritySemaphore
 	backendName string
 }
 
@@ -1364,16 +1980,53 @@ func (c *LimitedHTTPClient) DoWithSemaphore(req *http.Request, sem *semaphore.We
 	if usedSem == nil {
 		usedSem = c.sem
 	}
-	if usedSem != nil {
-		if err := usedSem.Acquire(req.Context(), 1); err != nil {
-			tooManyRequestErrorsTotal.WithLabelValues(c.backendName).Inc()
-			return nil, wrapErr(err, "too many requests")
+
+	// Only requests waiting for the rpc semaphore are subject to the queue
+	// timeout of their group.
+	acquireCtx := req.Context()
+	qt, hasQueueTimeout := req.Context().Value(ContextKeyQueueTimeout).(*queueTimeout)
+	if hasQueueTimeout && usedSem == c.sem {
+		var cancel context.CancelFunc
+		acquireCtx, cancel = context.WithTimeout(acquireCtx, qt.timeout)
+		defer cancel()
+	}
+	acquireErr := func(err error) error {
+		tooManyRequestErrorsTotal.WithLabelValues(c.backendName).Inc()
+		if hasQueueTimeout && acquireCtx.Err() == context.DeadlineExceeded && req.Context().Err() == nil {
+			RecordQueueTimeout(qt.backendGroup)
+			return ErrQueueTimeout
+		}
+		return wrapErr(err, "too many requests")
+	}
+
+	if usedSem == c.sem && c.prioritySem != nil {
+		if err := c.prioritySem.Acquire(acquireCtx, GetPriority(req.Context())); err != nil {
+			return nil, acquireErr(err)
+		}
+		defer c.prioritySem.Release()
+	} else if usedSem != nil {
+		if err := usedSem.Acquire(acquireCtx, 1); err != nil {
+			return nil, acquireErr(err)
 		}
 		defer usedSem.Release(1)
 	}
 	return c.Do(req)
 }
 
+// queueTimeout bounds how long requests to a backend group wait for an rpc
+// slot.
+type queueTimeout struct {
+	backendGroup string
+	timeout      time.Duration
+}
+
+func withQueueTimeout(ctx context.Context, backendGroup string, timeout time.Duration) context.Context {
+	return context.WithValue(ctx, ContextKeyQueueTimeout, &queueTimeout{ // nolint:staticcheck
+		backendGroup: backendGroup,
+		timeout:      timeout,
+	})
+}
+
 func RecordBatchRPCError(ctx context.Context, backendName string, reqs []*RPCReq, err error) {
 	for _, req := range reqs {
 		RecordRPCError(ctx, backendName, req.Method, err)
@@ -1446,6 +2099,7 @@ func (bg *BackendGroup) ForwardRequestToBackendGroup(
 		if len(rpcReqs) > 0 {
 
 			res, err = back.Forward(ctx, rpcReqs, isBatch)
+			recordBackendAttempt(ctx, back.Name, err)
 
 			if errors.Is(err, ErrConsensusGetReceiptsCantBeBatched) ||
 				errors.Is(err, ErrConsensusGetReceiptsInvalidTarget) ||
@@ -1456,7 +2110,8 @@ func (bg *BackendGroup) ForwardRequestToBackendGroup(
 					error:    err,
 				}
 			}
-			if errors.Is(err, ErrBackendResponseTooLarge) {
+			if errors.Is(err, ErrBackendResponseTooLarge) || errors.Is(err, ErrQueueTimeout) ||
+				errors.Is(err, ErrMaxHopsExceeded) {
 				return &BackendGroupRPCResponse{
 					RPCRes:   nil,
 					ServedBy: "",
@@ -1534,6 +2189,11 @@ func (bg *BackendGroup) OverwriteConsensusResponses(rpcReqs []*RPCReq, overridde
 		result, err := RewriteTags(rctx, req, &res)
 		switch result {
 		case RewriteOverrideError:
+			if !errors.Is(err, ErrRewriteBlockOutOfRange) && !errors.Is(err, ErrRewriteRangeTooLarge) &&
+				bg.invalidParams.forward(req.Method, false) {
+				rewrittenReqs = append(rewrittenReqs, req)
+				continue
+			}
 			overriddenResponses = append(overriddenResponses, &indexedReqRes{
 				index: i,
 				req:   req,
@@ -0,0 +1,93 @@
+package proxyd
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// tokenBucket caps the rate of outbound requests to a backend. It holds up
+// to burst tokens, refilled at rate tokens per second.
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate float64, burst int) *tokenBucket {
+	return &tokenBucket{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+// reserve takes a token, and returns how long to wait before using it. It
+// takes none and returns false if the wait would be longer than maxWait.
+func (tb *tokenBucket) reserve(maxWait time.Duration) (time.Duration, bool) {
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
+
+	now := time.Now()
+	tb.tokens += now.Sub(tb.last).Seconds() * tb.rate
+	if tb.tokens > tb.burst {
+		tb.tokens = tb.burst
+	}
+	tb.last = now
+
+	var wait time.Duration
+	if tb.tokens < 1 {
+		wait = time.Duration((1 - tb.tokens) / tb.rate * float64(time.Second))
+	}
+	if wait > maxWait {
+		return 0, false
+	}
+	tb.tokens--
+	return wait, true
+}
+
+// cancel gives back a token reserved but not used.
+func (tb *tokenBucket) cancel() {
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
+	tb.tokens++
+}
+
+// waitForRPSToken holds back a request to the backend until it is within
+// max_rps. Without max_rps_wait, or when ctx would expire first, it returns
+// ErrBackendOverCapacity so that the request skips the backend instead.
+func (b *Backend) waitForRPSToken(ctx context.Context) error {
+	if b.rpsLimiter == nil {
+		return nil
+	}
+	var maxWait time.Duration
+	if b.maxRPSWait {
+		maxWait = time.Duration(math.MaxInt64)
+		if deadline, ok := ctx.Deadline(); ok {
+			maxWait = time.Until(deadline)
+		}
+	}
+	wait, ok := b.rpsLimiter.reserve(maxWait)
+	if !ok {
+		RecordBackendThrottled(b.Name, "skipped")
+		return ErrBackendOverCapacity
+	}
+	if wait == 0 {
+		return nil
+	}
+
+	RecordBackendThrottled(b.Name, "queued")
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		b.rpsLimiter.cancel()
+		return ErrBackendOverCapacity
+	}
+}
@@ -0,0 +1,104 @@
+package proxyd
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// BackendSelector orders the healthy backends of a group for a call to
+// method. Backends are tried in the returned order until one serves the call,
+// so a selector choosing a single backend returns it first. method is empty
+// for batches of different methods. Selectors are shared by every request of
+// every group using them, so they must be safe for concurrent use, and must
+// not modify candidates in place.
+type BackendSelector interface {
+	Select(ctx context.Context, method string, candidates []*Backend) []*Backend
+}
+
+// BackendSelectorFunc adapts a function to a BackendSelector.
+type BackendSelectorFunc func(ctx context.Context, method string, candidates []*Backend) []*Backend
+
+func (f BackendSelectorFunc) Select(ctx context.Context, method string, candidates []*Backend) []*Backend {
+	return f(ctx, method, candidates)
+}
+
+const (
+	// OrderedBackendSelector tries backends in the order of the group's
+	// config. It is the default for groups without consensus.
+	OrderedBackendSelector = "ordered"
+	// RandomBackendSelector tries backends in a random order. It is the
+	// default for consensus aware groups.
+	RandomBackendSelector = "random"
+	// WeightedBackendSelector tries backends in a random order weighted by
+	// their weight. It is the default with weighted_routing.
+	WeightedBackendSelector = "weighted"
+)
+
+var (
+	backendSelectorsMtx sync.RWMutex
+	backendSelectors    = map[string]BackendSelector{
+		OrderedBackendSelector:  BackendSelectorFunc(selectOrdered),
+		RandomBackendSelector:   BackendSelectorFunc(selectRandom),
+		WeightedBackendSelector: BackendSelectorFunc(selectWeighted),
+	}
+)
+
+// RegisterBackendSelector makes selector available to backend groups under
+// name, through their backend_selector config. It must be called before
+// Start, typically from an init function of the program embedding proxyd.
+func RegisterBackendSelector(name string, selector BackendSelector) error {
+	if name == "" || selector == nil {
+		return fmt.Errorf("backend selector must have a name and an implementation")
+	}
+	backendSelectorsMtx.Lock()
+	defer backendSelectorsMtx.Unlock()
+	if _, ok := backendSelectors[name]; ok {
+		return fmt.Errorf("backend selector %s is already registered", name)
+	}
+	backendSelectors[name] = selector
+	return nil
+}
+
+func lookupBackendSelector(name string) (BackendSelector, bool) {
+	backendSelectorsMtx.RLock()
+	defer backendSelectorsMtx.RUnlock()
+	selector, ok := backendSelectors[name]
+	return selector, ok
+}
+
+// defaultBackendSelector returns the name of the selector matching the
+// routing of a group without backend_selector.
+func defaultBackendSelector(bg *BackendGroupConfig) string {
+	switch {
+	case bg.WeightedRouting:
+		return WeightedBackendSelector
+	case bg.RoutingStrategy == ConsensusAwareRoutingStrategy || bg.ConsensusAware:
+		return RandomBackendSelector
+	default:
+		return OrderedBackendSelector
+	}
+}
+
+func selectOrdered(_ context.Context, _ string, candidates []*Backend) []*Backend {
+	return candidates
+}
+
+func selectRandom(_ context.Context, _ string, candidates []*Backend) []*Backend {
+	selected := make([]*Backend, len(candidates))
+	copy(selected, candidates)
+	r := rand.New(rand.NewSource(time.Now().UnixNano()))
+	r.Shuffle(len(selected), func(i, j int) {
+		selected[i], selected[j] = selected[j], selected[i]
+	})
+	return selected
+}
+
+func selectWeighted(_ context.Context, _ string, candidates []*Backend) []*Backend {
+	selected := make([]*Backend, len(candidates))
+	copy(selected, candidates)
+	weightedShuffle(selected)
+	return selected
+}
@@ -4,6 +4,7 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestStripXFF(t *testing.T) {
@@ -20,3 +21,43 @@ func TestStripXFF(t *testing.T) {
 		assert.Equal(t, test.out, actual)
 	}
 }
+
+func TestRewriteRequestIDsRoundTrip(t *testing.T) {
+	reqs := []*RPCReq{
+		{JSONRPC: JSONRPCVersion, Method: "eth_chainId", ID: []byte("1")},
+		{JSONRPC: JSONRPCVersion, Method: "eth_chainId", ID: []byte(`"abc"`)},
+		{JSONRPC: JSONRPCVersion, Method: "eth_subscription"},
+		{JSONRPC: JSONRPCVersion, Method: "eth_chainId", ID: []byte("1")},
+	}
+
+	rewritten, originalIDs := rewriteRequestIDs(reqs)
+	require.Len(t, rewritten, len(reqs))
+	require.Len(t, originalIDs, 3)
+
+	// originals are not mutated, and the notification is passed through as-is
+	require.Equal(t, "1", string(reqs[0].ID))
+	require.Same(t, reqs[2], rewritten[2])
+
+	seen := make(map[string]bool)
+	for i, req := range rewritten {
+		if i == 2 {
+			continue
+		}
+		require.False(t, seen[string(req.ID)], "rewritten IDs must be unique")
+		seen[string(req.ID)] = true
+		require.Equal(t, reqs[i].Method, req.Method)
+	}
+
+	res := []*RPCRes{
+		{JSONRPC: JSONRPCVersion, Result: "a", ID: rewritten[0].ID},
+		{JSONRPC: JSONRPCVersion, Result: "b", ID: rewritten[1].ID},
+		{JSONRPC: JSONRPCVersion, Result: "c", ID: rewritten[3].ID},
+	}
+	require.NoError(t, restoreResponseIDs(res, originalIDs))
+	require.Equal(t, "1", string(res[0].ID))
+	require.Equal(t, `"abc"`, string(res[1].ID))
+	require.Equal(t, "1", string(res[2].ID))
+
+	unknown := []*RPCRes{{JSONRPC: JSONRPCVersion, Result: "a", ID: []byte("424242424242")}}
+	require.ErrorIs(t, restoreResponseIDs(unknown, originalIDs), ErrBackendUnexpectedJSONRPC)
+}
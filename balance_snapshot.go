@@ -0,0 +1,109 @@
+package proxyd
+
+import (
+	"encoding/json"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+const defaultBalanceSnapshotMaxEntries = 10000
+
+// balanceSnapshot serves eth_getBalance at the finalized block from memory.
+// Balances are remembered per address on first read, and all of them are
+// dropped once the finalized block advances, so that a balance is only served
+// for the block it was read at.
+type balanceSnapshot struct {
+	maxEntries int
+
+	mu        sync.Mutex
+	finalized hexutil.Uint64
+	balances  map[common.Address]interface{}
+}
+
+func newBalanceSnapshot(maxEntries int) *balanceSnapshot {
+	return &balanceSnapshot{
+		maxEntries: maxEntries,
+		balances:   make(map[common.Address]interface{}),
+	}
+}
+
+// address returns the address whose balance req asks for at the finalized
+// block, either by the "finalized" tag or by its number.
+func (s *balanceSnapshot) address(req *RPCReq, finalized hexutil.Uint64) (common.Address, bool) {
+	if req.Method != "eth_getBalance" || finalized == 0 {
+		return common.Address{}, false
+	}
+	var params []json.RawMessage
+	if err := json.Unmarshal(req.Params, &params); err != nil || len(params) != 2 {
+		return common.Address{}, false
+	}
+	var addr common.Address
+	if err := json.Unmarshal(params[0], &addr); err != nil {
+		return common.Address{}, false
+	}
+	var bnh rpc.BlockNumberOrHash
+	if err := json.Unmarshal(params[1], &bnh); err != nil {
+		return common.Address{}, false
+	}
+	number, ok := bnh.Number()
+	if !ok {
+		return common.Address{}, false
+	}
+	if number != rpc.FinalizedBlockNumber && (number < 0 || uint64(number) != uint64(finalized)) {
+		return common.Address{}, false
+	}
+	return addr, true
+}
+
+// advance drops the balances read at an earlier finalized block. It must be
+// called with mu held.
+func (s *balanceSnapshot) advance(finalized hexutil.Uint64) {
+	if finalized != s.finalized {
+		s.finalized = finalized
+		s.balances = make(map[common.Address]interface{})
+	}
+}
+
+// Get returns the remembered balance asked for by req, if any.
+func (s *balanceSnapshot) Get(req *RPCReq, finalized hexutil.Uint64) *RPCRes {
+	addr, ok := s.address(req, finalized)
+	if !ok {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.advance(finalized)
+	balance, ok := s.balances[addr]
+	if !ok {
+		return nil
+	}
+	return &RPCRes{
+		JSONRPC: JSONRPCVersion,
+		Result:  balance,
+		ID:      req.ID,
+	}
+}
+
+// Observe remembers the balance in a successful response to req, unless the
+// snapshot is full. req is taken as forwarded, with the "finalized" tag
+// rewritten to the block it was read at, so that a balance read just before
+// the finalized block advanced isn't remembered for the new one.
+func (s *balanceSnapshot) Observe(req *RPCReq, finalized hexutil.Uint64, res *RPCRes) {
+	if res.IsError() || res.Result == nil {
+		return
+	}
+	addr, ok := s.address(req, finalized)
+	if !ok {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.advance(finalized)
+	if _, ok := s.balances[addr]; !ok && len(s.balances) >= s.maxEntries {
+		return
+	}
+	s.balances[addr] = res.Result
+}
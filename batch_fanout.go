@@ -0,0 +1,65 @@
+package proxyd
+
+import "sync"
+
+// batchFanout forwards the minibatches a batch is split into for a backend
+// group, up to concurrency at a time. With a concurrency of 1 or less, they
+// are forwarded one after the other on the calling goroutine. Minibatches
+// aren't started once one of them failed the request.
+type batchFanout struct {
+	sem chan struct{}
+	wg  sync.WaitGroup
+	mu  sync.Mutex
+	err error
+}
+
+func newBatchFanout(concurrency int) *batchFanout {
+	if concurrency <= 1 {
+		return &batchFanout{}
+	}
+	return &batchFanout{sem: make(chan struct{}, concurrency)}
+}
+
+func (f *batchFanout) run(fn func() error) {
+	if f.failed() {
+		return
+	}
+	if f.sem == nil {
+		f.setErr(fn())
+		return
+	}
+
+	f.sem <- struct{}{}
+	f.wg.Add(1)
+	go func() {
+		defer f.wg.Done()
+		defer func() { <-f.sem }()
+		f.setErr(fn())
+	}()
+}
+
+// wait waits for the minibatches being forwarded, and returns the error that
+// failed the request, if any.
+func (f *batchFanout) wait() error {
+	f.wg.Wait()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.err
+}
+
+func (f *batchFanout) failed() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.err != nil
+}
+
+func (f *batchFanout) setErr(err error) {
+	if err == nil {
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.err == nil {
+		f.err = err
+	}
+}
@@ -0,0 +1,61 @@
+package proxyd
+
+import (
+	"bytes"
+	"io"
+	"sync"
+)
+
+const (
+	defaultBodyBufferSize    = 4 * 1024
+	defaultBodyBufferMaxSize = 1024 * 1024
+)
+
+// bufferPool recycles the buffers request and response bodies are read into,
+// to spare allocating one per request. Buffers grown over maxSize are dropped
+// instead of being returned to the pool, so that a few large bodies don't
+// keep their memory retained. A nil pool allocates a buffer per read.
+type bufferPool struct {
+	pool    sync.Pool
+	maxSize int
+}
+
+func newBufferPool(size, maxSize int) *bufferPool {
+	if size <= 0 {
+		size = defaultBodyBufferSize
+	}
+	if maxSize <= 0 {
+		maxSize = defaultBodyBufferMaxSize
+	}
+	return &bufferPool{
+		pool: sync.Pool{
+			New: func() any {
+				return bytes.NewBuffer(make([]byte, 0, size))
+			},
+		},
+		maxSize: maxSize,
+	}
+}
+
+// readAll reads r into a buffer of the pool. The buffer must be released
+// with put once its bytes, and any slice of them, are no longer used.
+func (p *bufferPool) readAll(r io.Reader) (*bytes.Buffer, error) {
+	var buf *bytes.Buffer
+	if p == nil {
+		buf = new(bytes.Buffer)
+	} else {
+		buf = p.pool.Get().(*bytes.Buffer)
+	}
+	_, err := buf.ReadFrom(r)
+	return buf, err
+}
+
+// put returns buf to the pool, emptied so that its contents don't bleed into
+// the next read.
+func (p *bufferPool) put(buf *bytes.Buffer) {
+	if p == nil || buf == nil || buf.Cap() > p.maxSize {
+		return
+	}
+	buf.Reset()
+	p.pool.Put(buf)
+}
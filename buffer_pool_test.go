@@ -0,0 +1,79 @@
+package proxyd
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBufferPoolResetsBuffers(t *testing.T) {
+	pool := newBufferPool(16, 1024)
+
+	buf, err := pool.readAll(strings.NewReader(`{"method":"eth_sendRawTransaction","params":["0xsecret"]}`))
+	require.NoError(t, err)
+	pool.put(buf)
+
+	// a shorter body read into a recycled buffer must not carry the tail of
+	// the previous one
+	for i := 0; i < 10; i++ {
+		buf, err = pool.readAll(strings.NewReader(`{"method":"eth_chainId"}`))
+		require.NoError(t, err)
+		require.Equal(t, `{"method":"eth_chainId"}`, buf.String())
+		pool.put(buf)
+	}
+
+	buf, err = pool.readAll(strings.NewReader(""))
+	require.NoError(t, err)
+	require.Zero(t, buf.Len())
+	pool.put(buf)
+}
+
+func TestBufferPoolDropsLargeBuffers(t *testing.T) {
+	pool := newBufferPool(16, 1024)
+
+	large, err := pool.readAll(bytes.NewReader(make([]byte, 4096)))
+	require.NoError(t, err)
+	pool.put(large)
+
+	for i := 0; i < 10; i++ {
+		buf, err := pool.readAll(strings.NewReader("{}"))
+		require.NoError(t, err)
+		require.NotSame(t, large, buf)
+		require.LessOrEqual(t, buf.Cap(), 1024)
+		pool.put(buf)
+	}
+}
+
+func TestBufferPoolReadErrors(t *testing.T) {
+	var pool *bufferPool
+	buf, err := pool.readAll(LimitReader(strings.NewReader("0123456789"), 4))
+	require.ErrorIs(t, err, ErrLimitReaderOverLimit)
+	pool.put(buf)
+}
+
+var benchmarkBody = []byte(`{"jsonrpc":"2.0","id":1,"method":"eth_call","params":[{"to":"0x0000000000000000000000000000000000000001","data":"0x` + strings.Repeat("ab", 2048) + `"},"latest"]}`)
+
+func BenchmarkReadBodyReadAll(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		body, err := io.ReadAll(bytes.NewReader(benchmarkBody))
+		if err != nil || len(body) != len(benchmarkBody) {
+			b.Fatal("bad read")
+		}
+	}
+}
+
+func BenchmarkReadBodyBufferPool(b *testing.B) {
+	pool := newBufferPool(defaultBodyBufferSize, defaultBodyBufferMaxSize)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		buf, err := pool.readAll(bytes.NewReader(benchmarkBody))
+		if err != nil || buf.Len() != len(benchmarkBody) {
+			b.Fatal("bad read")
+		}
+		pool.put(buf)
+	}
+}
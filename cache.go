@@ -1,16 +1,23 @@
 package proxyd
 
 import (
+	"bytes"
+	"compress/gzip"
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"strings"
+	"sync/atomic"
 	"time"
 
+	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/rpc"
 	"github.com/redis/go-redis/v9"
 
 	"github.com/golang/snappy"
-	lru "github.com/hashicorp/golang-lru"
+	"github.com/klauspost/compress/zstd"
 )
 
 type Cache interface {
@@ -18,30 +25,31 @@ type Cache interface {
 	Put(ctx context.Context, key string, value string) error
 }
 
-const (
-	// assuming an average RPCRes size of 3 KB
-	memoryCacheLimit = 4096
-)
-
-type cache struct {
-	lru *lru.Cache
+// FlushableCache is implemented by caches that can remove entries by key
+// prefix. Flush returns the number of entries removed. Remote caches such as
+// redis are only flushed when includeRemote is set.
+type FlushableCache interface {
+	Flush(ctx context.Context, prefix string, includeRemote bool) (int, error)
 }
 
-func newMemoryCache() *cache {
-	rep, _ := lru.New(memoryCacheLimit)
-	return &cache{rep}
+func flushCache(ctx context.Context, c Cache, prefix string, includeRemote bool) (int, error) {
+	if fc, ok := c.(FlushableCache); ok {
+		return fc.Flush(ctx, prefix, includeRemote)
+	}
+	return 0, nil
 }
 
-func (c *cache) Get(ctx context.Context, key string) (string, error) {
-	if val, ok := c.lru.Get(key); ok {
-		return val.(string), nil
-	}
-	return "", nil
+// TTLCache is implemented by caches that can expire entries individually,
+// instead of after the cache wide TTL.
+type TTLCache interface {
+	PutWithTTL(ctx context.Context, key string, value string, ttl time.Duration) error
 }
 
-func (c *cache) Put(ctx context.Context, key string, value string) error {
-	c.lru.Add(key, value)
-	return nil
+func putCacheWithTTL(ctx context.Context, c Cache, key string, value string, ttl time.Duration) error {
+	if tc, ok := c.(TTLCache); ok {
+		return tc.PutWithTTL(ctx, key, value, ttl)
+	}
+	return c.Put(ctx, key, value)
 }
 
 type fallbackCache struct {
@@ -69,6 +77,23 @@ func (c *fallbackCache) Put(ctx context.Context, key string, value string) error
 	return nil
 }
 
+func (c *fallbackCache) PutWithTTL(ctx context.Context, key string, value string, ttl time.Duration) error {
+	err := putCacheWithTTL(ctx, c.primaryCache, key, value, ttl)
+	if err != nil {
+		return putCacheWithTTL(ctx, c.secondaryCache, key, value, ttl)
+	}
+	return nil
+}
+
+func (c *fallbackCache) Flush(ctx context.Context, prefix string, includeRemote bool) (int, error) {
+	primary, err := flushCache(ctx, c.primaryCache, prefix, includeRemote)
+	if err != nil {
+		return primary, err
+	}
+	secondary, err := flushCache(ctx, c.secondaryCache, prefix, includeRemote)
+	return primary + secondary, err
+}
+
 type redisCache struct {
 	redisClient     redis.UniversalClient
 	redisReadClient redis.UniversalClient
@@ -102,8 +127,12 @@ func (c *redisCache) Get(ctx context.Context, key string) (string, error) {
 }
 
 func (c *redisCache) Put(ctx context.Context, key string, value string) error {
+	return c.PutWithTTL(ctx, key, value, c.ttl)
+}
+
+func (c *redisCache) PutWithTTL(ctx context.Context, key string, value string, ttl time.Duration) error {
 	start := time.Now()
-	err := c.redisClient.SetEx(ctx, c.namespaced(key), value, c.ttl).Err()
+	err := c.redisClient.SetEx(ctx, c.namespaced(key), value, ttl).Err()
 	redisCacheDurationSumm.WithLabelValues("SETEX").Observe(float64(time.Since(start).Milliseconds()))
 
 	if err != nil {
@@ -112,12 +141,118 @@ func (c *redisCache) Put(ctx context.Context, key string, value string) error {
 	return err
 }
 
+// redisFlushBatchSize is the number of keys scanned and deleted per round trip
+// when flushing the redis cache.
+const redisFlushBatchSize = 1000
+
+var redisGlobEscaper = strings.NewReplacer(`\`, `\\`, "*", `\*`, "?", `\?`, "[", `\[`, "]", `\]`)
+
+func (c *redisCache) Flush(ctx context.Context, prefix string, includeRemote bool) (int, error) {
+	if !includeRemote {
+		return 0, nil
+	}
+
+	var removed atomic.Int64
+	flush := func(ctx context.Context, client redis.UniversalClient) error {
+		iter := client.Scan(ctx, 0, redisGlobEscaper.Replace(c.namespaced(prefix))+"*", redisFlushBatchSize).Iterator()
+		keys := make([]string, 0, redisFlushBatchSize)
+		del := func() error {
+			if len(keys) == 0 {
+				return nil
+			}
+			n, err := client.Del(ctx, keys...).Result()
+			removed.Add(n)
+			keys = keys[:0]
+			return err
+		}
+		for iter.Next(ctx) {
+			keys = append(keys, iter.Val())
+			if len(keys) == redisFlushBatchSize {
+				if err := del(); err != nil {
+					return err
+				}
+			}
+		}
+		if err := iter.Err(); err != nil {
+			return err
+		}
+		return del()
+	}
+
+	var err error
+	if cluster, ok := c.redisClient.(*redis.ClusterClient); ok {
+		err = cluster.ForEachMaster(ctx, func(ctx context.Context, client *redis.Client) error {
+			return flush(ctx, client)
+		})
+	} else {
+		err = flush(ctx, c.redisClient)
+	}
+	if err != nil {
+		RecordRedisError("CacheFlush")
+	}
+	return int(removed.Load()), err
+}
+
+// Compressed values start with a header byte naming their encoding, so that
+// the algorithm can change without invalidating cached values. Values cached
+// before the header was introduced are snappy encoded without one, and start
+// with the varint length of the decoded value. Those of 0 to 3 bytes, such as
+// "[]", start like a header, and are told apart by being at most
+// maxShortLegacyCacheValueLen long and not decoding with that header.
+const (
+	cacheEncodingNone byte = iota
+	cacheEncodingSnappy
+	cacheEncodingGzip
+	cacheEncodingZstd
+
+	// maxShortLegacyCacheValueLen is the length of the snappy encoding of a
+	// 3 byte value: its length, a literal tag and the value.
+	maxShortLegacyCacheValueLen = 5
+)
+
+var cacheEncodings = map[string]byte{
+	"none":   cacheEncodingNone,
+	"snappy": cacheEncodingSnappy,
+	"gzip":   cacheEncodingGzip,
+	"zstd":   cacheEncodingZstd,
+}
+
 type cacheWithCompression struct {
-	cache Cache
+	cache     Cache
+	encoding  byte
+	threshold int
+
+	zstdEncoder *zstd.Encoder
+	zstdDecoder *zstd.Decoder
 }
 
-func newCacheWithCompression(cache Cache) *cacheWithCompression {
-	return &cacheWithCompression{cache}
+func newCacheWithCompression(cache Cache, config CacheCompressionConfig) (*cacheWithCompression, error) {
+	algorithm := config.Algorithm
+	if algorithm == "" {
+		algorithm = "snappy"
+	}
+	encoding, ok := cacheEncodings[algorithm]
+	if !ok {
+		return nil, fmt.Errorf("unsupported cache compression algorithm %q", config.Algorithm)
+	}
+	if config.ThresholdBytes < 0 {
+		return nil, errors.New("cache compression threshold_bytes must be >= 0")
+	}
+	zstdEncoder, err := zstd.NewWriter(nil)
+	if err != nil {
+		return nil, err
+	}
+	zstdDecoder, err := zstd.NewReader(nil)
+	if err != nil {
+		return nil, err
+	}
+	return &cacheWithCompression{
+		cache:       cache,
+		encoding:    encoding,
+		threshold:   config.ThresholdBytes,
+		zstdEncoder: zstdEncoder,
+		zstdDecoder: zstdDecoder,
+	}, nil
 }
 
 func (c *cacheWithCompression) Get(ctx context.Context, key string) (string, error) {
@@ -128,7 +263,7 @@ func (c *cacheWithCompression) Get(ctx context.Context, key string) (string, err
 	if encodedVal == "" {
 		return "", nil
 	}
-	val, err := snappy.Decode(nil, []byte(encodedVal))
+	val, err := c.decode([]byte(encodedVal))
 	if err != nil {
 		return "", err
 	}
@@ -136,23 +271,120 @@ func (c *cacheWithCompression) Get(ctx context.Context, key string) (string, err
 }
 
 func (c *cacheWithCompression) Put(ctx context.Context, key string, value string) error {
-	encodedVal := snappy.Encode(nil, []byte(value))
+	encodedVal, err := c.encode([]byte(value))
+	if err != nil {
+		return err
+	}
 	return c.cache.Put(ctx, key, string(encodedVal))
 }
 
+func (c *cacheWithCompression) PutWithTTL(ctx context.Context, key string, value string, ttl time.Duration) error {
+	encodedVal, err := c.encode([]byte(value))
+	if err != nil {
+		return err
+	}
+	return putCacheWithTTL(ctx, c.cache, key, string(encodedVal), ttl)
+}
+
+func (c *cacheWithCompression) Flush(ctx context.Context, prefix string, includeRemote bool) (int, error) {
+	return flushCache(ctx, c.cache, prefix, includeRemote)
+}
+
+// encode compresses values of at least threshold bytes, and prefixes them with
+// the header byte of their encoding.
+func (c *cacheWithCompression) encode(value []byte) ([]byte, error) {
+	encoding := c.encoding
+	if len(value) < c.threshold {
+		encoding = cacheEncodingNone
+	}
+	out := []byte{encoding}
+	switch encoding {
+	case cacheEncodingSnappy:
+		return append(out, snappy.Encode(nil, value)...), nil
+	case cacheEncodingGzip:
+		buf := bytes.NewBuffer(out)
+		w := gzip.NewWriter(buf)
+		if _, err := w.Write(value); err != nil {
+			return nil, err
+		}
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	case cacheEncodingZstd:
+		return c.zstdEncoder.EncodeAll(value, out), nil
+	default:
+		return append(out, value...), nil
+	}
+}
+
+func (c *cacheWithCompression) decode(encodedVal []byte) ([]byte, error) {
+	val, err := c.decodeWithHeader(encodedVal)
+	if err != nil && len(encodedVal) <= maxShortLegacyCacheValueLen {
+		if legacyVal, legacyErr := snappy.Decode(nil, encodedVal); legacyErr == nil {
+			return legacyVal, nil
+		}
+	}
+	return val, err
+}
+
+func (c *cacheWithCompression) decodeWithHeader(encodedVal []byte) ([]byte, error) {
+	body := encodedVal[1:]
+	switch encodedVal[0] {
+	case cacheEncodingNone:
+		return body, nil
+	case cacheEncodingSnappy:
+		return snappy.Decode(nil, body)
+	case cacheEncodingGzip:
+		r, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		return io.ReadAll(r)
+	case cacheEncodingZstd:
+		return c.zstdDecoder.DecodeAll(body, nil)
+	default:
+		return snappy.Decode(nil, encodedVal)
+	}
+}
+
+// defaultReorgBypassMethods are the cached methods whose responses depend on
+// unfinalized blocks, and are bypassed during a reorg cooldown.
+var defaultReorgBypassMethods = []string{
+	"eth_getBlockTransactionCountByHash",
+	"eth_getUncleCountByBlockHash",
+	"eth_getBlockByHash",
+	"eth_getTransactionByBlockHashAndIndex",
+	"eth_getUncleByBlockHashAndIndex",
+	"debug_getRawReceipts",
+}
+
 type RPCCache interface {
 	GetRPC(ctx context.Context, req *RPCReq) (*RPCRes, error)
 	PutRPC(ctx context.Context, req *RPCReq, res *RPCRes) error
+	// Flush removes cached responses for the given methods, or for every
+	// method if none are given, and returns the number of entries removed.
+	Flush(ctx context.Context, methods []string, includeRemote bool) (int, error)
+	// IsCacheable reports whether responses to method may be cached.
+	IsCacheable(method string) bool
+	// SetHead moves the consensus block, invalidating block scoped responses
+	// cached before it and setting the depth of blocks for depth TTLs.
+	SetHead(blockNumber hexutil.Uint64)
 }
 
 type rpcCache struct {
-	cache    Cache
-	handlers map[string]RPCMethodHandler
+	cache       Cache
+	handlers    map[string]RPCMethodHandler
+	blockScoped *BlockScopedMethodHandler
+	depthTTL    *DepthTTLMethodHandler
+
+	codeHashCalls *CodeHashCallHandler
 }
 
-func newRPCCache(cache Cache) RPCCache {
-	staticHandler := &StaticMethodHandler{cache: cache}
-	debugGetRawReceiptsHandler := &StaticMethodHandler{cache: cache,
+func newRPCCache(cache Cache, config CacheConfig, opts ...rpcCacheOpt) RPCCache {
+	normalizeKeys := config.NormalizeKeys
+	staticHandler := &StaticMethodHandler{cache: cache, normalizeKeys: normalizeKeys}
+	debugGetRawReceiptsHandler := &StaticMethodHandler{cache: cache, normalizeKeys: normalizeKeys,
 		filterGet: func(req *RPCReq) bool {
 			// cache only if the request is for a block hash
 
@@ -185,10 +417,36 @@ func newRPCCache(cache Cache) RPCCache {
 		"eth_getUncleByBlockHashAndIndex":       staticHandler,
 		"debug_getRawReceipts":                  debugGetRawReceiptsHandler,
 	}
-	return &rpcCache{
-		cache:    cache,
-		handlers: handlers,
+	depthTTL := newDepthTTLMethodHandler(cache, normalizeKeys, config.DepthTTL)
+	for _, method := range config.DepthTTL.Methods {
+		handlers[method] = depthTTL
+	}
+	cacheControl := &BackendCacheControlMethodHandler{cache: cache, normalizeKeys: normalizeKeys}
+	for _, method := range config.BackendCacheControlMethods {
+		handlers[method] = cacheControl
+	}
+	blockScoped := &BlockScopedMethodHandler{cache: cache, normalizeKeys: normalizeKeys}
+	for _, method := range config.BlockScopedMethods {
+		handlers[method] = blockScoped
+	}
+	c := &rpcCache{
+		cache:       cache,
+		handlers:    handlers,
+		blockScoped: blockScoped,
+		depthTTL:    depthTTL,
+	}
+	if config.CodeHashCalls.Enabled {
+		minDepth := uint64(defaultCodeHashCallsMinDepth)
+		if config.CodeHashCalls.MinDepth != 0 {
+			minDepth = config.CodeHashCalls.MinDepth
+		}
+		c.codeHashCalls = newCodeHashCallHandler(cache, minDepth)
+		handlers["eth_call"] = c.codeHashCalls
+	}
+	for _, opt := range opts {
+		opt(c)
 	}
+	return c
 }
 
 func (c *rpcCache) GetRPC(ctx context.Context, req *RPCReq) (*RPCRes, error) {
@@ -216,3 +474,32 @@ func (c *rpcCache) PutRPC(ctx context.Context, req *RPCReq, res *RPCRes) error {
 	}
 	return handler.PutRPCMethod(ctx, req, res)
 }
+
+func (c *rpcCache) IsCacheable(method string) bool {
+	_, ok := c.handlers[method]
+	return ok
+}
+
+func (c *rpcCache) SetHead(blockNumber hexutil.Uint64) {
+	c.blockScoped.SetHead(blockNumber)
+	c.depthTTL.SetHead(blockNumber)
+	if c.codeHashCalls != nil {
+		c.codeHashCalls.SetHead(blockNumber)
+	}
+}
+
+func (c *rpcCache) Flush(ctx context.Context, methods []string, includeRemote bool) (int, error) {
+	if len(methods) == 0 {
+		return flushCache(ctx, c.cache, cacheKeyPrefix+":", includeRemote)
+	}
+
+	removed := 0
+	for _, method := range methods {
+		n, err := flushCache(ctx, c.cache, strings.Join([]string{cacheKeyPrefix, method, ""}, ":"), includeRemote)
+		removed += n
+		if err != nil {
+			return removed, err
+		}
+	}
+	return removed, nil
+}
@@ -0,0 +1,81 @@
+package proxyd
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// backendCacheTTL returns how long a shared cache may keep a response per the
+// Cache-Control header of the backend that sent it: its s-maxage, or else its
+// max-age. It returns false if the header forbids storing the response, as
+// with no-store or private, or doesn't set a positive TTL.
+func backendCacheTTL(header string) (time.Duration, bool) {
+	var maxAge, sMaxAge time.Duration
+	var hasMaxAge, hasSMaxAge bool
+	for _, directive := range strings.Split(header, ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(directive), "=")
+		switch strings.ToLower(name) {
+		case "no-store", "private":
+			return 0, false
+		case "max-age":
+			maxAge, hasMaxAge = parseCacheControlSeconds(value)
+		case "s-maxage":
+			sMaxAge, hasSMaxAge = parseCacheControlSeconds(value)
+		}
+	}
+	if hasSMaxAge {
+		return sMaxAge, sMaxAge > 0
+	}
+	return maxAge, hasMaxAge && maxAge > 0
+}
+
+func parseCacheControlSeconds(value string) (time.Duration, bool) {
+	secs, err := strconv.ParseInt(strings.Trim(value, `"`), 10, 64)
+	if err != nil || secs < 0 {
+		return 0, false
+	}
+	// cap the seconds so that the duration doesn't overflow
+	if secs > int64(maxCacheControlTTL/time.Second) {
+		secs = int64(maxCacheControlTTL / time.Second)
+	}
+	return time.Duration(secs) * time.Second, true
+}
+
+// maxCacheControlTTL bounds the TTLs taken from backend Cache-Control headers.
+const maxCacheControlTTL = 365 * 24 * time.Hour
+
+// BackendCacheControlMethodHandler caches responses for as long as the
+// Cache-Control header of the backend that sent them allows. Responses
+// without a TTL in their header aren't cached.
+type BackendCacheControlMethodHandler struct {
+	cache         Cache
+	normalizeKeys bool
+}
+
+func (e *BackendCacheControlMethodHandler) key(req *RPCReq) string {
+	return strings.Join([]string{cacheKeyPrefix, req.Method, paramsSignature(req, e.normalizeKeys)}, ":")
+}
+
+func (e *BackendCacheControlMethodHandler) GetRPCMethod(ctx context.Context, req *RPCReq) (*RPCRes, error) {
+	if e.cache == nil {
+		return nil, nil
+	}
+	return getCachedRPCRes(ctx, e.cache, e.key(req), req)
+}
+
+func (e *BackendCacheControlMethodHandler) PutRPCMethod(ctx context.Context, req *RPCReq, res *RPCRes) error {
+	if e.cache == nil || res.cacheTTL <= 0 {
+		return nil
+	}
+
+	key := e.key(req)
+	if err := putCacheWithTTL(ctx, e.cache, key, string(mustMarshalJSON(res.Result)), res.cacheTTL); err != nil {
+		log.Error("error putting into cache", "key", key, "method", req.Method, "err", err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package proxyd
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+)
+
+// cacheKeyQuantityParams lists, per cached method, the positions of params
+// holding a hex quantity other than a block number, such as an index. Block
+// numbers are at the positions of pendingTagParamPositions.
+var cacheKeyQuantityParams = map[string][]int{
+	"eth_getTransactionByBlockHashAndIndex":   {1},
+	"eth_getTransactionByBlockNumberAndIndex": {1},
+	"eth_getUncleByBlockHashAndIndex":         {1},
+}
+
+// normalizeCacheKeyParams rewrites params so that semantically equal requests
+// produce the same cache key. Hex strings are lowercased and quantities lose
+// their leading zeros, which the spec forbids but clients send anyway. Block
+// numbers also have their tags mapped to the number they stand for. Params
+// that can't be parsed are returned as is.
+func normalizeCacheKeyParams(method string, params json.RawMessage) json.RawMessage {
+	var elems []json.RawMessage
+	if err := json.Unmarshal(params, &elems); err != nil {
+		return params
+	}
+
+	quantities := make(map[int]bool)
+	for _, i := range cacheKeyQuantityParams[method] {
+		quantities[i] = true
+	}
+	blockNumberPos, hasBlockNumber := pendingTagParamPositions[method]
+
+	for i, elem := range elems {
+		var s string
+		if err := json.Unmarshal(elem, &s); err != nil {
+			continue
+		}
+		if hasBlockNumber && i == blockNumberPos {
+			s = normalizeBlockNumber(s)
+		} else if quantities[i] {
+			s = normalizeQuantity(s)
+		} else if isHexString(s) {
+			s = strings.ToLower(s)
+		}
+		elems[i] = mustMarshalJSON(s)
+	}
+
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, mustMarshalJSON(elems)); err != nil {
+		return params
+	}
+	return buf.Bytes()
+}
+
+// normalizeBlockNumber normalizes a block number like a quantity, mapping the
+// earliest tag to block 0. Other tags stand for a block that moves, and are
+// kept as is.
+func normalizeBlockNumber(s string) string {
+	if s == "earliest" {
+		return "0x0"
+	}
+	return normalizeQuantity(s)
+}
+
+func normalizeQuantity(s string) string {
+	if !isHexString(s) || len(s) == 2 {
+		return s
+	}
+	digits := strings.TrimLeft(strings.ToLower(s[2:]), "0")
+	if digits == "" {
+		digits = "0"
+	}
+	return "0x" + digits
+}
+
+func isHexString(s string) bool {
+	if len(s) < 2 || s[0] != '0' || (s[1] != 'x' && s[1] != 'X') {
+		return false
+	}
+	for _, c := range s[2:] {
+		if !('0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,54 @@
+package proxyd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// namespacedCache isolates the cache entries of domains whose backends may
+// answer differently. Keys of requests from a domain with a namespace are
+// stored as cache:<method>:ns:<namespace>:..., so that flushing a method
+// still flushes every namespace. Domains without one share the plain keys.
+type namespacedCache struct {
+	Cache
+	namespaces map[string]string
+}
+
+func newNamespacedCache(cache Cache, namespaces map[string]string) (*namespacedCache, error) {
+	for domain, namespace := range namespaces {
+		if namespace == "" || strings.Contains(namespace, ":") {
+			return nil, fmt.Errorf("invalid cache namespace %q for domain %s", namespace, domain)
+		}
+	}
+	return &namespacedCache{Cache: cache, namespaces: namespaces}, nil
+}
+
+func (c *namespacedCache) key(ctx context.Context, key string) string {
+	namespace, ok := c.namespaces[GetOriginCtx(ctx)]
+	if !ok {
+		return key
+	}
+	parts := strings.SplitN(key, ":", 3)
+	if len(parts) != 3 || parts[0] != cacheKeyPrefix {
+		return key
+	}
+	return strings.Join([]string{parts[0], parts[1], "ns", namespace, parts[2]}, ":")
+}
+
+func (c *namespacedCache) Get(ctx context.Context, key string) (string, error) {
+	return c.Cache.Get(ctx, c.key(ctx, key))
+}
+
+func (c *namespacedCache) Put(ctx context.Context, key string, value string) error {
+	return c.Cache.Put(ctx, c.key(ctx, key), value)
+}
+
+func (c *namespacedCache) PutWithTTL(ctx context.Context, key string, value string, ttl time.Duration) error {
+	return putCacheWithTTL(ctx, c.Cache, c.key(ctx, key), value, ttl)
+}
+
+func (c *namespacedCache) Flush(ctx context.Context, prefix string, includeRemote bool) (int, error) {
+	return flushCache(ctx, c.Cache, prefix, includeRemote)
+}
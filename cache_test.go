@@ -2,18 +2,25 @@ package proxyd
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"math"
 	"strconv"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/alicebob/miniredis"
+	"github.com/golang/snappy"
+	"github.com/redis/go-redis/v9"
 	"github.com/stretchr/testify/require"
 )
 
 func TestRPCCacheImmutableRPCs(t *testing.T) {
 	ctx := context.Background()
 
-	cache := newRPCCache(newMemoryCache())
+	cache := newRPCCache(newMemoryCache(), CacheConfig{})
 	ID := []byte(strconv.Itoa(1))
 
 	rpcs := []struct {
@@ -134,7 +141,7 @@ func TestRPCCacheImmutableRPCs(t *testing.T) {
 func TestRPCCacheUnsupportedMethod(t *testing.T) {
 	ctx := context.Background()
 
-	cache := newRPCCache(newMemoryCache())
+	cache := newRPCCache(newMemoryCache(), CacheConfig{})
 	ID := []byte(strconv.Itoa(1))
 
 	rpcs := []struct {
@@ -214,6 +221,327 @@ func TestRPCCacheUnsupportedMethod(t *testing.T) {
 
 }
 
+func TestRPCCacheNormalizedKeys(t *testing.T) {
+	ctx := context.Background()
+	ID := []byte(strconv.Itoa(1))
+	blockHash := "0x0b903239f8543d04b5dc1ba6579132b143087c68db1b2168786408fcbce56823"
+
+	newReq := func(params string) *RPCReq {
+		return &RPCReq{
+			JSONRPC: "2.0",
+			Method:  "eth_getTransactionByBlockHashAndIndex",
+			Params:  json.RawMessage(params),
+			ID:      ID,
+		}
+	}
+	original := newReq(fmt.Sprintf(`["%s","0x10"]`, blockHash))
+	res := &RPCRes{JSONRPC: "2.0", Result: "tx", ID: ID}
+
+	equivalent := []string{
+		fmt.Sprintf(`["%s","0x010"]`, blockHash),
+		fmt.Sprintf(`["%s","0X10"]`, blockHash),
+		fmt.Sprintf(`[ "%s", "0x0010" ]`, blockHash),
+		fmt.Sprintf(`["0x%s","0x10"]`, strings.ToUpper(blockHash[2:])),
+	}
+	different := []string{
+		fmt.Sprintf(`["%s","0x11"]`, blockHash),
+		fmt.Sprintf(`["0x%s","0x10"]`, blockHash[3:]),
+	}
+
+	t.Run("enabled", func(t *testing.T) {
+		cache := newRPCCache(newMemoryCache(), CacheConfig{NormalizeKeys: true})
+		require.NoError(t, cache.PutRPC(ctx, original, res))
+
+		for _, params := range equivalent {
+			cachedRes, err := cache.GetRPC(ctx, newReq(params))
+			require.NoError(t, err)
+			require.Equal(t, res, cachedRes, params)
+		}
+
+		for _, params := range different {
+			cachedRes, err := cache.GetRPC(ctx, newReq(params))
+			require.NoError(t, err)
+			require.Nil(t, cachedRes, params)
+		}
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		cache := newRPCCache(newMemoryCache(), CacheConfig{})
+		require.NoError(t, cache.PutRPC(ctx, original, res))
+
+		for _, params := range equivalent {
+			cachedRes, err := cache.GetRPC(ctx, newReq(params))
+			require.NoError(t, err)
+			require.Nil(t, cachedRes, params)
+		}
+	})
+}
+
+func TestNormalizeQuantity(t *testing.T) {
+	tests := map[string]string{
+		"0x0":      "0x0",
+		"0x00":     "0x0",
+		"0x010":    "0x10",
+		"0XAB":     "0xab",
+		"earliest": "earliest",
+		"latest":   "latest",
+		"0x":       "0x",
+		"0xzz":     "0xzz",
+		"10":       "10",
+	}
+	for in, out := range tests {
+		require.Equal(t, out, normalizeQuantity(in), in)
+	}
+}
+
+func TestNormalizeCacheKeyParams(t *testing.T) {
+	blockHash := "0x0b903239f8543d04b5dc1ba6579132b143087c68db1b2168786408fcbce56823"
+	tests := []struct {
+		name       string
+		method     string
+		equivalent []string
+		different  []string
+	}{
+		{
+			name:       "block number",
+			method:     "eth_getBlockByNumber",
+			equivalent: []string{`["0x0",false]`, `["0x00",false]`, `["earliest",false]`, `[ "0X0", false ]`},
+			different:  []string{`["0x0",true]`, `["latest",false]`, `["0x1",false]`},
+		},
+		{
+			name:       "block number and index",
+			method:     "eth_getTransactionByBlockNumberAndIndex",
+			equivalent: []string{`["0x10","0x0"]`, `["0x010","0x00"]`},
+			different:  []string{`["0x10","earliest"]`, `["0x10","0x1"]`},
+		},
+		{
+			name:       "index",
+			method:     "eth_getUncleByBlockHashAndIndex",
+			equivalent: []string{fmt.Sprintf(`["%s","0x0"]`, blockHash), fmt.Sprintf(`["%s","0x00"]`, blockHash)},
+			// earliest isn't an index, and the backend rejects it
+			different: []string{fmt.Sprintf(`["%s","earliest"]`, blockHash)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			normalized := string(normalizeCacheKeyParams(tt.method, json.RawMessage(tt.equivalent[0])))
+			for _, params := range tt.equivalent[1:] {
+				require.Equal(t, normalized, string(normalizeCacheKeyParams(tt.method, json.RawMessage(params))), params)
+			}
+			for _, params := range tt.different {
+				require.NotEqual(t, normalized, string(normalizeCacheKeyParams(tt.method, json.RawMessage(params))), params)
+			}
+		})
+	}
+}
+
+func TestRPCCacheFlush(t *testing.T) {
+	ctx := context.Background()
+	cache := newRPCCache(newMemoryCache(), CacheConfig{})
+	ID := []byte(strconv.Itoa(1))
+
+	for _, method := range []string{"eth_chainId", "net_version"} {
+		req := &RPCReq{JSONRPC: "2.0", Method: method, ID: ID}
+		require.NoError(t, cache.PutRPC(ctx, req, &RPCRes{JSONRPC: "2.0", Result: "0x1", ID: ID}))
+	}
+
+	removed, err := cache.Flush(ctx, []string{"eth_chainId"}, false)
+	require.NoError(t, err)
+	require.Equal(t, 1, removed)
+
+	res, err := cache.GetRPC(ctx, &RPCReq{JSONRPC: "2.0", Method: "eth_chainId", ID: ID})
+	require.NoError(t, err)
+	require.Nil(t, res)
+	res, err = cache.GetRPC(ctx, &RPCReq{JSONRPC: "2.0", Method: "net_version", ID: ID})
+	require.NoError(t, err)
+	require.NotNil(t, res)
+
+	removed, err = cache.Flush(ctx, nil, false)
+	require.NoError(t, err)
+	require.Equal(t, 1, removed)
+}
+
+func TestRPCCacheBlockScoped(t *testing.T) {
+	ctx := context.Background()
+	cache := newRPCCache(newMemoryCache(), CacheConfig{BlockScopedMethods: []string{"eth_blockNumber"}})
+	ID := []byte(strconv.Itoa(1))
+	req := &RPCReq{JSONRPC: "2.0", Method: "eth_blockNumber", ID: ID}
+	res := &RPCRes{JSONRPC: "2.0", Result: "0x100", ID: ID}
+	require.True(t, cache.IsCacheable("eth_blockNumber"))
+
+	// nothing is cached before the head is known
+	require.NoError(t, cache.PutRPC(ctx, req, res))
+	cachedRes, err := cache.GetRPC(ctx, req)
+	require.NoError(t, err)
+	require.Nil(t, cachedRes)
+
+	cache.SetHead(0x100)
+	require.NoError(t, cache.PutRPC(ctx, req, res))
+	cachedRes, err = cache.GetRPC(ctx, req)
+	require.NoError(t, err)
+	require.Equal(t, res, cachedRes)
+
+	cache.SetHead(0x101)
+	cachedRes, err = cache.GetRPC(ctx, req)
+	require.NoError(t, err)
+	require.Nil(t, cachedRes)
+}
+
+func TestDepthTTL(t *testing.T) {
+	handler := newDepthTTLMethodHandler(nil, false, DepthTTLConfig{
+		MinDepth:    5,
+		BaseTTL:     TOMLDuration(time.Second),
+		TTLPerBlock: TOMLDuration(100 * time.Millisecond),
+		MaxTTL:      TOMLDuration(time.Minute),
+	})
+
+	tests := []struct {
+		depth uint64
+		ttl   time.Duration
+		ok    bool
+	}{
+		{0, 0, false},
+		{4, 0, false},
+		{5, time.Second, true},
+		{6, 1100 * time.Millisecond, true},
+		{15, 2 * time.Second, true},
+		{595, time.Minute, true},
+		{596, time.Minute, true},
+		{math.MaxUint64, time.Minute, true},
+	}
+	for _, tt := range tests {
+		t.Run(strconv.FormatUint(tt.depth, 10), func(t *testing.T) {
+			ttl, ok := handler.TTL(tt.depth)
+			require.Equal(t, tt.ok, ok)
+			require.Equal(t, tt.ttl, ttl)
+		})
+	}
+
+	t.Run("constant without ttl_per_block", func(t *testing.T) {
+		handler := newDepthTTLMethodHandler(nil, false, DepthTTLConfig{BaseTTL: TOMLDuration(time.Second)})
+		for _, depth := range []uint64{0, 1, 1000} {
+			ttl, ok := handler.TTL(depth)
+			require.True(t, ok)
+			require.Equal(t, time.Second, ttl)
+		}
+	})
+}
+
+func TestRPCCacheDepthTTL(t *testing.T) {
+	ctx := context.Background()
+	cache := newRPCCache(newMemoryCache(), CacheConfig{DepthTTL: DepthTTLConfig{
+		Methods:     []string{"eth_getBlockByNumber", "eth_getBalance"},
+		MinDepth:    10,
+		BaseTTL:     TOMLDuration(50 * time.Millisecond),
+		TTLPerBlock: TOMLDuration(time.Second),
+		MaxTTL:      TOMLDuration(time.Minute),
+	}})
+	ID := []byte(strconv.Itoa(1))
+	res := &RPCRes{JSONRPC: "2.0", Result: "0x1", ID: ID}
+	cached := func(method string, params string) bool {
+		req := &RPCReq{JSONRPC: "2.0", Method: method, Params: json.RawMessage(params), ID: ID}
+		require.NoError(t, cache.PutRPC(ctx, req, res))
+		cachedRes, err := cache.GetRPC(ctx, req)
+		require.NoError(t, err)
+		return cachedRes != nil
+	}
+
+	// nothing is cached before the head is known
+	require.False(t, cached("eth_getBlockByNumber", `["0x1", false]`))
+
+	cache.SetHead(0x100)
+	require.True(t, cached("eth_getBlockByNumber", `["0xf6", false]`))
+	require.True(t, cached("eth_getBalance", `["0x1234", "0x10"]`))
+	require.True(t, cached("eth_getBalance", `["0x1234", {"blockNumber": "0x11"}]`))
+
+	// near the head, in the future, or not by number
+	require.False(t, cached("eth_getBlockByNumber", `["0xf7", false]`))
+	require.False(t, cached("eth_getBlockByNumber", `["0x101", false]`))
+	require.False(t, cached("eth_getBlockByNumber", `["latest", false]`))
+	require.False(t, cached("eth_getBalance", `["0x1234"]`))
+	require.False(t, cached("eth_getBalance", `["0x1234", {"blockHash": "0xc6ef2fc5426d6ad6fd9e2a26abeab0aa2411b7ab17f30a99d3cb96aed1d1055b"}]`))
+
+	// entries expire after their TTL, which is only the base TTL at min_depth
+	req := &RPCReq{JSONRPC: "2.0", Method: "eth_getBlockByNumber", Params: json.RawMessage(`["0xf6", false]`), ID: ID}
+	time.Sleep(100 * time.Millisecond)
+	cachedRes, err := cache.GetRPC(ctx, req)
+	require.NoError(t, err)
+	require.Nil(t, cachedRes)
+	req.Params = json.RawMessage(`["0xf5", false]`)
+	require.NoError(t, cache.PutRPC(ctx, req, res))
+	time.Sleep(100 * time.Millisecond)
+	cachedRes, err = cache.GetRPC(ctx, req)
+	require.NoError(t, err)
+	require.NotNil(t, cachedRes)
+}
+
+func TestRPCCacheCodeHashCalls(t *testing.T) {
+	ctx := context.Background()
+	codes := map[string]string{
+		"0x0000000000000000000000000000000000000001": "0x6001",
+		"0x0000000000000000000000000000000000000002": "0x6001",
+		"0x0000000000000000000000000000000000000003": "0x6002",
+	}
+	var codeFetches []string
+	fetch := func(ctx context.Context, req *RPCReq) (*RPCRes, error) {
+		var params []string
+		require.NoError(t, json.Unmarshal(req.Params, &params))
+		codeFetches = append(codeFetches, params[0]+"@"+params[1])
+		return &RPCRes{JSONRPC: "2.0", Result: codes[params[0]], ID: req.ID}, nil
+	}
+	cache := newRPCCache(newMemoryCache(), CacheConfig{CodeHashCalls: CodeHashCallsConfig{Enabled: true, MinDepth: 10}}, withCodeFetcher(fetch))
+	cache.SetHead(0x100)
+
+	ID := []byte(strconv.Itoa(1))
+	call := func(to string, block string) *RPCReq {
+		params := fmt.Sprintf(`[{"to": "%s", "data": "0x70a08231"}, "%s"]`, to, block)
+		return &RPCReq{JSONRPC: "2.0", Method: "eth_call", Params: json.RawMessage(params), ID: ID}
+	}
+	res := &RPCRes{JSONRPC: "2.0", Result: "0x2a", ID: ID}
+
+	require.NoError(t, cache.PutRPC(ctx, call("0x0000000000000000000000000000000000000001", "0xf0"), res))
+	require.Equal(t, []string{"0x0000000000000000000000000000000000000001@0xf0"}, codeFetches)
+
+	// another address with the same code shares the result
+	cachedRes, err := cache.GetRPC(ctx, call("0x0000000000000000000000000000000000000002", "0xf0"))
+	require.NoError(t, err)
+	require.Equal(t, res, cachedRes)
+
+	// and its code hash is cached too
+	cachedRes, err = cache.GetRPC(ctx, call("0x0000000000000000000000000000000000000002", "0xf0"))
+	require.NoError(t, err)
+	require.Equal(t, res, cachedRes)
+	require.Len(t, codeFetches, 2)
+
+	// different code, block or calldata miss
+	cachedRes, err = cache.GetRPC(ctx, call("0x0000000000000000000000000000000000000003", "0xf0"))
+	require.NoError(t, err)
+	require.Nil(t, cachedRes)
+	cachedRes, err = cache.GetRPC(ctx, call("0x0000000000000000000000000000000000000001", "0xef"))
+	require.NoError(t, err)
+	require.Nil(t, cachedRes)
+	req := call("0x0000000000000000000000000000000000000001", "0xf0")
+	req.Params = json.RawMessage(`[{"to": "0x0000000000000000000000000000000000000001", "data": "0x18160ddd"}, "0xf0"]`)
+	cachedRes, err = cache.GetRPC(ctx, req)
+	require.NoError(t, err)
+	require.Nil(t, cachedRes)
+
+	// calls near the head, by tag or with state overrides aren't cached, and
+	// don't look up code
+	codeFetches = nil
+	for _, req := range []*RPCReq{
+		call("0x0000000000000000000000000000000000000001", "0xf7"),
+		call("0x0000000000000000000000000000000000000001", "latest"),
+		{JSONRPC: "2.0", Method: "eth_call", Params: json.RawMessage(`[{"to": "0x0000000000000000000000000000000000000001"}, "0xf0", {}]`), ID: ID},
+	} {
+		require.NoError(t, cache.PutRPC(ctx, req, res))
+		cachedRes, err = cache.GetRPC(ctx, req)
+		require.NoError(t, err)
+		require.Nil(t, cachedRes)
+	}
+	require.Empty(t, codeFetches)
+}
+
 type errorCache struct{}
 
 func (c *errorCache) Get(ctx context.Context, key string) (string, error) {
@@ -261,3 +589,102 @@ func TestFallbackCache(t *testing.T) {
 		})
 	}
 }
+
+func TestCacheCompression(t *testing.T) {
+	ctx := context.Background()
+	large := `{"jsonrpc":"2.0","id":1,"result":"0x` + strings.Repeat("00", 4096) + `"}`
+	small := `{"jsonrpc":"2.0","id":1,"result":"0x1"}`
+
+	for _, algorithm := range []string{"", "none", "snappy", "gzip", "zstd"} {
+		name := algorithm
+		if name == "" {
+			name = "default"
+		}
+		t.Run(name, func(t *testing.T) {
+			memoryCache := newMemoryCache()
+			cache, err := newCacheWithCompression(memoryCache, CacheCompressionConfig{
+				Algorithm:      algorithm,
+				ThresholdBytes: 1024,
+			})
+			require.NoError(t, err)
+
+			require.NoError(t, cache.Put(ctx, "large", large))
+			stored, err := memoryCache.Get(ctx, "large")
+			require.NoError(t, err)
+			if algorithm == "none" {
+				require.Equal(t, string(cacheEncodingNone)+large, stored)
+			} else {
+				require.Less(t, len(stored), len(large)/10)
+			}
+			val, err := cache.Get(ctx, "large")
+			require.NoError(t, err)
+			require.Equal(t, large, val)
+
+			require.NoError(t, cache.Put(ctx, "small", small))
+			stored, err = memoryCache.Get(ctx, "small")
+			require.NoError(t, err)
+			require.Equal(t, string(cacheEncodingNone)+small, stored)
+			val, err = cache.Get(ctx, "small")
+			require.NoError(t, err)
+			require.Equal(t, small, val)
+		})
+	}
+
+	t.Run("redis", func(t *testing.T) {
+		redisServer, err := miniredis.Run()
+		require.NoError(t, err)
+		defer redisServer.Close()
+		redisClient := redis.NewClient(&redis.Options{
+			Addr: fmt.Sprintf("127.0.0.1:%s", redisServer.Port()),
+		})
+		redisCache := newRedisCache(redisClient, redisClient, "proxyd", time.Hour)
+		cache, err := newCacheWithCompression(redisCache, CacheCompressionConfig{Algorithm: "zstd", ThresholdBytes: 1024})
+		require.NoError(t, err)
+
+		require.NoError(t, cache.Put(ctx, "large", large))
+		stored, err := redisServer.Get("proxyd:large")
+		require.NoError(t, err)
+		require.Equal(t, cacheEncodingZstd, stored[0])
+		require.Less(t, len(stored), len(large)/10)
+		val, err := cache.Get(ctx, "large")
+		require.NoError(t, err)
+		require.Equal(t, large, val)
+	})
+
+	t.Run("reads values of another algorithm", func(t *testing.T) {
+		memoryCache := newMemoryCache()
+		gzipCache, err := newCacheWithCompression(memoryCache, CacheCompressionConfig{Algorithm: "gzip"})
+		require.NoError(t, err)
+		zstdCache, err := newCacheWithCompression(memoryCache, CacheCompressionConfig{Algorithm: "zstd"})
+		require.NoError(t, err)
+
+		require.NoError(t, gzipCache.Put(ctx, "foo", large))
+		val, err := zstdCache.Get(ctx, "foo")
+		require.NoError(t, err)
+		require.Equal(t, large, val)
+	})
+
+	t.Run("reads values without a header", func(t *testing.T) {
+		memoryCache := newMemoryCache()
+		cache, err := newCacheWithCompression(memoryCache, CacheCompressionConfig{Algorithm: "zstd"})
+		require.NoError(t, err)
+
+		require.NoError(t, memoryCache.Put(ctx, "foo", string(snappy.Encode(nil, []byte(small)))))
+		val, err := cache.Get(ctx, "foo")
+		require.NoError(t, err)
+		require.Equal(t, small, val)
+
+		// short values start with a length that reads as a header
+		for _, short := range []string{"", "1", "[]", "0x1"} {
+			require.NoError(t, memoryCache.Put(ctx, "short", string(snappy.Encode(nil, []byte(short)))))
+			val, err := cache.Get(ctx, "short")
+			require.NoError(t, err)
+			require.Equal(t, short, val)
+		}
+	})
+
+	t.Run("rejects unknown algorithms", func(t *testing.T) {
+		_, err := newCacheWithCompression(newMemoryCache(), CacheCompressionConfig{Algorithm: "lz4"})
+		require.Error(t, err)
+	})
+}
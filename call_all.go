@@ -0,0 +1,104 @@
+package proxyd
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// CallAllMethod sends a call to every healthy backend of the group its method
+// is mapped to, and answers with the response of each backend by name. It
+// takes the method and, optionally, its params: ["eth_blockNumber", []].
+const CallAllMethod = "proxyd_callAll"
+
+// callAll answers a proxyd_callAll request, which must come from an admin.
+func (s *Server) callAll(ctx context.Context, req *RPCReq, rpcMethodMappings map[string]string) *RPCRes {
+	if admin, _ := ctx.Value(ContextKeyAdmin).(bool); !admin {
+		RecordRPCError(ctx, BackendProxyd, CallAllMethod, ErrAdminUnauthorized)
+		return NewRPCErrorRes(req.ID, ErrAdminUnauthorized)
+	}
+
+	call, err := parseCallAllParams(req)
+	if err != nil {
+		RecordRPCError(ctx, BackendProxyd, CallAllMethod, err)
+		return NewRPCErrorRes(req.ID, err)
+	}
+	group := rpcMethodMappings[call.Method]
+	if routed := s.paramRouter.Route(call); routed != "" {
+		group = routed
+	}
+	bg := s.BackendGroups[group]
+	if bg == nil {
+		RecordRPCError(ctx, BackendProxyd, CallAllMethod, ErrMethodNotWhitelisted)
+		return NewRPCErrorRes(req.ID, ErrMethodNotWhitelisted)
+	}
+
+	log.Info("calling all backends",
+		"req_id", GetReqID(ctx),
+		"auth", GetAuthCtx(ctx),
+		"method", call.Method,
+		"backend_group", group,
+	)
+	return NewRPCRes(req.ID, bg.CallAll(ctx, call))
+}
+
+// parseCallAllParams returns the call wrapped by a proxyd_callAll request.
+func parseCallAllParams(req *RPCReq) (*RPCReq, error) {
+	var params []json.RawMessage
+	if err := json.Unmarshal(req.Params, &params); err != nil || len(params) < 1 || len(params) > 2 {
+		return nil, ErrInvalidParams("expected a method and optional params")
+	}
+	call := &RPCReq{
+		JSONRPC: JSONRPCVersion,
+		Params:  json.RawMessage("[]"),
+		ID:      req.ID,
+	}
+	if err := json.Unmarshal(params[0], &call.Method); err != nil || call.Method == "" {
+		return nil, ErrInvalidParams("method must be a non-empty string")
+	}
+	if call.Method == CallAllMethod {
+		return nil, ErrInvalidParams("method cannot be " + CallAllMethod)
+	}
+	if len(params) == 2 && string(params[1]) != "null" {
+		call.Params = params[1]
+	}
+	return call, nil
+}
+
+// CallAll forwards req to every healthy backend, or to every backend if none
+// is healthy, and returns their responses by backend name. Errors forwarding
+// to a backend are returned as its response.
+func (bg *BackendGroup) CallAll(ctx context.Context, req *RPCReq) map[string]*RPCRes {
+	targets := make([]*Backend, 0, len(bg.Backends))
+	for _, backend := range bg.Backends {
+		if backend.IsHealthy() {
+			targets = append(targets, backend)
+		}
+	}
+	if len(targets) == 0 {
+		targets = bg.Backends
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	responses := make(map[string]*RPCRes, len(targets))
+	for _, backend := range targets {
+		wg.Add(1)
+		go func(backend *Backend) {
+			defer wg.Done()
+			var res *RPCRes
+			if out, err := backend.Forward(ctx, []*RPCReq{req}, false); err != nil {
+				res = NewRPCErrorRes(req.ID, err)
+			} else {
+				res = out[0]
+			}
+			mu.Lock()
+			responses[backend.Name] = res
+			mu.Unlock()
+		}(backend)
+	}
+	wg.Wait()
+	return responses
+}
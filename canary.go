@@ -0,0 +1,88 @@
+package proxyd
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"time"
+
+	sw "github.com/ethereum-optimism/infra/proxyd/pkg/avg-sliding-window"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+const (
+	canaryTargetCanary   = "canary"
+	canaryTargetBaseline = "baseline"
+)
+
+// canary sends a share of the calls to one method of a backend group to a
+// canary backend, and compares the share of them failing with that of the
+// calls served by the group over a window. Calls failing on the canary are
+// served by the group instead, so that clients don't see its errors.
+type canary struct {
+	backendGroup   string
+	method         string
+	backend        *Backend
+	percent        float64
+	canaryErrors   *sw.AvgSlidingWindow
+	baselineErrors *sw.AvgSlidingWindow
+}
+
+func newCanary(backendGroup string, method string, backend *Backend, percent float64, window time.Duration) *canary {
+	return &canary{
+		backendGroup:   backendGroup,
+		method:         method,
+		backend:        backend,
+		percent:        percent,
+		canaryErrors:   sw.NewSlidingWindow(sw.WithWindowLength(window)),
+		baselineErrors: sw.NewSlidingWindow(sw.WithWindowLength(window)),
+	}
+}
+
+// applies returns whether rpcReqs is a single call to the canaried method.
+func (c *canary) applies(rpcReqs []*RPCReq) bool {
+	return len(rpcReqs) == 1 && rpcReqs[0].Method == c.method
+}
+
+func (c *canary) selected() bool {
+	return rand.Float64()*100 < c.percent
+}
+
+// forward sends rpcReqs to the canary backend. It returns false if the canary
+// failed to serve them, in which case they should be sent to the group.
+func (c *canary) forward(ctx context.Context, rpcReqs []*RPCReq, isBatch bool) (*BackendGroupRPCResponse, bool) {
+	res, err := c.backend.Forward(ctx, rpcReqs, isBatch)
+	recordBackendAttempt(ctx, c.backend.Name, err)
+	failed := err != nil || len(res) != 1 || res[0].IsError()
+	c.record(canaryTargetCanary, failed)
+	if failed {
+		log.Warn("canary failed to serve request, falling back to baseline",
+			"backend_group", c.backendGroup,
+			"canary", c.backend.Name,
+			"method", c.method,
+			"req_id", GetReqID(ctx),
+			"err", err,
+		)
+		return nil, false
+	}
+	return &BackendGroupRPCResponse{
+		RPCRes:   res,
+		ServedBy: fmt.Sprintf("%s/%s", c.backendGroup, c.backend.Name),
+	}, true
+}
+
+// recordBaseline records the outcome of a call to the canaried method served
+// by the group without going through the canary.
+func (c *canary) recordBaseline(backendResp *BackendGroupRPCResponse) {
+	failed := backendResp.error != nil || len(backendResp.RPCRes) != 1 || backendResp.RPCRes[0].IsError()
+	c.record(canaryTargetBaseline, failed)
+}
+
+func (c *canary) record(target string, failed bool) {
+	errors := c.baselineErrors
+	if target == canaryTargetCanary {
+		errors = c.canaryErrors
+	}
+	errors.Add(boolToFloat64(failed))
+	RecordCanaryRequest(c.backendGroup, c.method, target, failed, errors.Avg())
+}
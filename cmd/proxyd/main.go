@@ -1,7 +1,6 @@
 package main
 
 import (
-	"fmt"
 	"log/slog"
 	"net"
 	"net/http"
@@ -9,7 +8,6 @@ import (
 	"os"
 	"os/signal"
 	"strconv"
-	"strings"
 	"syscall"
 
 	"github.com/BurntSushi/toml"
@@ -29,6 +27,7 @@ func main() {
 	// Set up logger with a default INFO level in case we fail to parse flags.
 	// Otherwise the final critical log won't show what the parsing error was.
 	proxyd.SetLogLevel(slog.LevelInfo)
+	proxyd.Version = GitVersion
 
 	log.Info("starting proxyd", "version", GitVersion, "commit", GitCommit, "date", GitDate)
 
@@ -42,7 +41,7 @@ func main() {
 	}
 
 	// update log level from config
-	logLevel, err := LevelFromString(config.Server.LogLevel)
+	logLevel, err := proxyd.LevelFromString(config.Server.LogLevel)
 	if err != nil {
 		logLevel = log.LevelInfo
 		if config.Server.LogLevel != "" {
@@ -62,40 +61,38 @@ func main() {
 		}()
 	}
 
-	_, shutdown, err := proxyd.Start(config)
+	srv, shutdown, err := proxyd.Start(config)
 	if err != nil {
 		log.Crit("error starting proxyd", "err", err)
 	}
 
-	sig := make(chan os.Signal, 1)
-	signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
-	recvSig := <-sig
-	log.Info("caught signal, shutting down", "signal", recvSig)
-	shutdown()
-}
+	srv.SetConfigSource(func() (*proxyd.Config, error) {
+		config := new(proxyd.Config)
+		if _, err := toml.DecodeFile(os.Args[1], config); err != nil {
+			return nil, err
+		}
+		return config, nil
+	})
 
-// LevelFromString returns the appropriate Level from a string name.
-// Useful for parsing command line args and configuration files.
-// It also converts strings to lowercase.
-// Note: copied from op-service/log to avoid monorepo dependency
-func LevelFromString(lvlString string) (slog.Level, error) {
-	lvlString = strings.ToLower(lvlString) // ignore case
-	switch lvlString {
-	case "trace", "trce":
-		return log.LevelTrace, nil
-	case "debug", "dbug":
-		return log.LevelDebug, nil
-	case "info":
-		return log.LevelInfo, nil
-	case "warn":
-		return log.LevelWarn, nil
-	case "error", "eror":
-		return log.LevelError, nil
-	case "crit":
-		return log.LevelCrit, nil
-	default:
-		return log.LevelDebug, fmt.Errorf("unknown level: %v", lvlString)
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+	for recvSig := range sig {
+		if recvSig == syscall.SIGHUP {
+			// reloads are serialized by the server, so that a slow one
+			// doesn't hold back the signals that follow
+			go func() {
+				if err := srv.Reload(); err != nil {
+					log.Error("error reloading config", "err", err)
+				} else {
+					log.Info("reloaded TLS certificates and rpc method mappings")
+				}
+			}()
+			continue
+		}
+		log.Info("caught signal, shutting down", "signal", recvSig)
+		break
 	}
+	shutdown()
 }
 
 func StartPProf(hostname string, port int) *http.Server {
@@ -0,0 +1,156 @@
+package proxyd
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync/atomic"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+const defaultCodeHashCallsMinDepth = 15
+
+// codeFetcher serves an eth_getCode request.
+type codeFetcher func(ctx context.Context, req *RPCReq) (*RPCRes, error)
+
+type rpcCacheOpt func(*rpcCache)
+
+// withCodeFetcher sets how eth_getCode is served for caching eth_call by
+// code hash.
+func withCodeFetcher(fetch codeFetcher) rpcCacheOpt {
+	return func(c *rpcCache) {
+		if c.codeHashCalls != nil {
+			c.codeHashCalls.fetchCode = fetch
+		}
+	}
+}
+
+// CodeHashCallHandler caches eth_call results by the code hash of the called
+// contract rather than its address, so that contracts deployed with the same
+// code share results for the same call at the same block. Only calls at a
+// block at least minDepth below the consensus head are cached, and the code
+// hash of each address at each block is cached too, to fetch it only once.
+type CodeHashCallHandler struct {
+	cache     Cache
+	minDepth  uint64
+	fetchCode codeFetcher
+	head      atomic.Uint64
+}
+
+func newCodeHashCallHandler(cache Cache, minDepth uint64) *CodeHashCallHandler {
+	return &CodeHashCallHandler{cache: cache, minDepth: minDepth}
+}
+
+// SetHead moves the block that depths are measured from.
+func (e *CodeHashCallHandler) SetHead(blockNumber hexutil.Uint64) {
+	e.head.Store(uint64(blockNumber))
+}
+
+// key returns the cache key of req, made of the code hash of the called
+// contract, the block and the other fields of the call. It returns false for
+// calls that can't be cached, such as calls with state overrides or to
+// addresses without code.
+func (e *CodeHashCallHandler) key(ctx context.Context, req *RPCReq) (string, bool) {
+	head := e.head.Load()
+	depth, ok := blockDepth(req, head)
+	if !ok || depth < e.minDepth {
+		return "", false
+	}
+	var params []json.RawMessage
+	if err := json.Unmarshal(req.Params, &params); err != nil || len(params) != 2 {
+		return "", false
+	}
+	var call map[string]json.RawMessage
+	if err := json.Unmarshal(params[0], &call); err != nil {
+		return "", false
+	}
+	var to string
+	if err := json.Unmarshal(call["to"], &to); err != nil || !common.IsHexAddress(to) {
+		return "", false
+	}
+	block := head - depth
+	codeHash, ok := e.codeHash(ctx, strings.ToLower(to), block)
+	if !ok {
+		return "", false
+	}
+
+	delete(call, "to")
+	h := sha256.New()
+	h.Write(mustMarshalJSON(call))
+	signature := fmt.Sprintf("%x", h.Sum(nil))
+	return strings.Join([]string{cacheKeyPrefix, req.Method, "code", codeHash, hexutil.EncodeUint64(block), signature}, ":"), true
+}
+
+// codeHash returns the hash of the code of addr at block, from the cache or
+// else from eth_getCode.
+func (e *CodeHashCallHandler) codeHash(ctx context.Context, addr string, block uint64) (string, bool) {
+	key := strings.Join([]string{cacheKeyPrefix, "eth_getCode", "hash", addr, hexutil.EncodeUint64(block)}, ":")
+	if val, err := e.cache.Get(ctx, key); err == nil && val != "" {
+		RecordCodeHashLookup("cached")
+		return val, true
+	}
+	if e.fetchCode == nil {
+		return "", false
+	}
+
+	res, err := e.fetchCode(ctx, &RPCReq{
+		JSONRPC: JSONRPCVersion,
+		Method:  "eth_getCode",
+		Params:  mustMarshalJSON([]interface{}{addr, hexutil.EncodeUint64(block)}),
+		ID:      json.RawMessage("1"),
+	})
+	if err != nil || res == nil || res.IsError() {
+		RecordCodeHashLookup("failed")
+		log.Debug("error fetching code for eth_call cache key", "address", addr, "block", block, "err", err)
+		return "", false
+	}
+	encoded, ok := res.Result.(string)
+	if !ok {
+		RecordCodeHashLookup("failed")
+		return "", false
+	}
+	code, err := hexutil.Decode(encoded)
+	if err != nil || len(code) == 0 {
+		RecordCodeHashLookup("failed")
+		return "", false
+	}
+	RecordCodeHashLookup("fetched")
+
+	codeHash := crypto.Keccak256Hash(code).Hex()
+	if err := e.cache.Put(ctx, key, codeHash); err != nil {
+		log.Error("error putting into cache", "key", key, "method", "eth_getCode", "err", err)
+	}
+	return codeHash, true
+}
+
+func (e *CodeHashCallHandler) GetRPCMethod(ctx context.Context, req *RPCReq) (*RPCRes, error) {
+	if e.cache == nil {
+		return nil, nil
+	}
+	key, ok := e.key(ctx, req)
+	if !ok {
+		return nil, nil
+	}
+	return getCachedRPCRes(ctx, e.cache, key, req)
+}
+
+func (e *CodeHashCallHandler) PutRPCMethod(ctx context.Context, req *RPCReq, res *RPCRes) error {
+	if e.cache == nil {
+		return nil
+	}
+	key, ok := e.key(ctx, req)
+	if !ok {
+		return nil
+	}
+	if err := e.cache.Put(ctx, key, string(mustMarshalJSON(res.Result))); err != nil {
+		log.Error("error putting into cache", "key", key, "method", req.Method, "err", err)
+		return err
+	}
+	return nil
+}
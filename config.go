@@ -11,15 +11,39 @@ import (
 )
 
 type ServerConfig struct {
-	RPCHost                    string `toml:"rpc_host"`
-	RPCPort                    int    `toml:"rpc_port"`
-	WSHost                     string `toml:"ws_host"`
-	WSPort                     int    `toml:"ws_port"`
+	RPCHost string `toml:"rpc_host"`
+	RPCPort int    `toml:"rpc_port"`
+	WSHost  string `toml:"ws_host"`
+	WSPort  int    `toml:"ws_port"`
+	// WSBackendGroup is the backend group serving the WS listener. It takes
+	// the place of the top-level ws_backend_group, which is still honoured.
+	WSBackendGroup string `toml:"ws_backend_group"`
+	// ListenDualStack makes listeners bound to an IPv6 host also accept IPv4
+	// connections. Without it, IPv6 hosts only accept IPv6 connections.
+	ListenDualStack            bool   `toml:"listen_dual_stack"`
 	MaxBodySizeBytes           int64  `toml:"max_body_size_bytes"`
 	MaxConcurrentRPCs          int64  `toml:"max_concurrent_rpcs"`
 	MaxConcurrentConsensusRPCs int64  `toml:"max_concurrent_consensus_rpcs"`
+	MaxWSConnections           int64  `toml:"max_ws_connections"`
 	LogLevel                   string `toml:"log_level"`
 
+	// MaxConcurrentRPCsPerIP caps the HTTP requests each client IP, as
+	// resolved for rate limiting, has in flight. Requests over it get a 429.
+	// Zero, the default, disables it.
+	MaxConcurrentRPCsPerIP int `toml:"max_concurrent_rpcs_per_ip"`
+
+	// MaxHops rejects HTTP requests already forwarded by more than this many
+	// proxyd instances, per the X-Proxyd-Hop header each instance increments,
+	// which breaks forwarding loops. Zero, the default, disables it.
+	MaxHops int `toml:"max_hops"`
+
+	// WSClientBufferSize queues up to this many backend messages for each WS
+	// client, so that a slow client doesn't hold up reading from its backend.
+	// WSBackpressurePolicy sets what happens to a client whose queue is full.
+	// Zero, the default, writes messages to clients as they are read.
+	WSClientBufferSize   int                  `toml:"ws_client_buffer_size"`
+	WSBackpressurePolicy WSBackpressurePolicy `toml:"ws_backpressure_policy"`
+
 	// TimeoutSeconds specifies the maximum time spent serving an HTTP request. Note that isn't used for websocket connections
 	TimeoutSeconds int `toml:"timeout_seconds"`
 
@@ -30,11 +54,193 @@ type ServerConfig struct {
 	EnablePprof           bool `toml:"enable_pprof"`
 	EnableXServedByHeader bool `toml:"enable_served_by_header"`
 	AllowAllOrigins       bool `toml:"allow_all_origins"`
+
+	// StrictRequestFields rejects requests with top-level fields other than
+	// jsonrpc, id, method and params. It can be overridden per domain with
+	// domain_strict_request_fields.
+	StrictRequestFields bool `toml:"strict_request_fields"`
+
+	// StrictContentType rejects HTTP requests whose Content-Type isn't
+	// application/json with a 415. Websocket upgrades aren't affected.
+	StrictContentType bool `toml:"strict_content_type"`
+
+	// EnableGetRPC accepts single calls encoded in the query of GET requests,
+	// as method, params and id, for probes and cacheable reads.
+	EnableGetRPC bool `toml:"enable_get_rpc"`
+
+	// EnableVerboseHealthz answers GET /healthz?verbose=true with the health
+	// of every backend of every group as JSON.
+	EnableVerboseHealthz bool `toml:"enable_verbose_healthz"`
+
+	// LogRouting logs, per call, the domain and method mapping used, the
+	// backend group selected and the rule that selected it, or that proxyd
+	// answered the call itself.
+	LogRouting bool `toml:"log_routing"`
+
+	// MaxHeaderCount and MaxHeaderBytes bound the number and total size of
+	// request headers, default 100 and 64KiB. Requests over them get a 431.
+	MaxHeaderCount int `toml:"max_header_count"`
+	MaxHeaderBytes int `toml:"max_header_bytes"`
+	// MaxURLLength bounds the length of the request URL, query included,
+	// default 8KiB. Longer requests get a 414.
+	MaxURLLength int `toml:"max_url_length"`
+
+	// BodyBufferSize is the initial size of the pooled buffers request and
+	// backend response bodies are read into, default 4KiB. Buffers grown over
+	// BodyBufferMaxSize, default 1MiB, aren't kept in the pool.
+	BodyBufferSize    int `toml:"body_buffer_size"`
+	BodyBufferMaxSize int `toml:"body_buffer_max_size"`
+
+	// PendingToLatestMethods lists methods whose "pending" block param is
+	// rewritten to "latest". It can be overridden per domain with
+	// domain_pending_to_latest_methods.
+	PendingToLatestMethods []string `toml:"pending_to_latest_methods"`
+
+	// ProxyProtocol makes listeners require a PROXY protocol v1 or v2 header
+	// on every connection and take the client IP from it, ignoring the rate
+	// limit IP header. Only enable it behind a load balancer sending one.
+	ProxyProtocol bool `toml:"proxy_protocol"`
+
+	// EnableBackendNameHeader adds an X-Backend-Name response header naming
+	// the backends that served the request. When BackendNameHeaderTrustedIPs
+	// is set, the header is only returned to clients within those IPs or CIDRs.
+	EnableBackendNameHeader     bool     `toml:"enable_backend_name_header"`
+	BackendNameHeaderTrustedIPs []string `toml:"backend_name_header_trusted_ips"`
+
+	// TrustedProxies are the IPs or CIDRs of proxies in front of proxyd whose
+	// X-Forwarded-For is believed by the trusted IPs checks. The client IP of
+	// a request from one of them is the last X-Forwarded-For entry, appended
+	// by that proxy. Other requests are checked by their peer address.
+	TrustedProxies []string `toml:"trusted_proxies"`
+
+	// EnableErrorContext sets the data of errors returned after failing to
+	// forward a call to the backend group, backends tried and last backend
+	// error. When ErrorContextTrustedIPs is set, it is only returned to
+	// clients within those IPs or CIDRs.
+	EnableErrorContext     bool     `toml:"enable_error_context"`
+	ErrorContextTrustedIPs []string `toml:"error_context_trusted_ips"`
+
+	// StreamBackendResponses copies responses to single calls from backends to
+	// clients as they are received, instead of buffering them, when they are
+	// large and need no caching or transformation. Streamed responses can't
+	// fail over to another backend once started.
+	StreamBackendResponses bool `toml:"stream_backend_responses"`
+
+	// TLS terminates TLS on the RPC and WS listeners when certificates are
+	// configured.
+	TLS ServerTLSConfig `toml:"tls"`
+}
+
+// ServerTLSConfig selects the certificate of each TLS connection by its SNI.
+type ServerTLSConfig struct {
+	// Certificates maps server names, which may be wildcards such as
+	// *.example.com, to their certificate.
+	Certificates map[string]TLSCertificateConfig `toml:"certificates"`
+	// DefaultCertificate names the certificate served to clients whose SNI
+	// matches none. Without it their handshakes fail.
+	DefaultCertificate string `toml:"default_certificate"`
+	// MinTLSVersion is the lowest TLS version accepted, one of 1.0, 1.1, 1.2
+	// or 1.3. Defaults to 1.2.
+	MinTLSVersion string `toml:"min_tls_version"`
+	// CipherSuites restricts the TLS 1.2 and lower cipher suites accepted, by
+	// their crypto/tls names. Defaults to the Go defaults.
+	CipherSuites []string `toml:"cipher_suites"`
+}
+
+type TLSCertificateConfig struct {
+	CertFile string `toml:"cert_file"`
+	KeyFile  string `toml:"key_file"`
 }
 
 type CacheConfig struct {
 	Enabled bool         `toml:"enabled"`
 	TTL     TOMLDuration `toml:"ttl"`
+	// NormalizeKeys makes equivalent hex encodings of params, and the earliest
+	// block tag and block 0, share a cache entry.
+	NormalizeKeys bool `toml:"normalize_keys"`
+	// EnableETag sets an ETag on single responses to cacheable methods, and
+	// answers requests with a matching If-None-Match with a 304.
+	EnableETag bool `toml:"enable_etag"`
+	// ReorgCooldown bypasses the cache for ReorgBypassMethods for this long
+	// after a consensus poller detects a reorg. Zero disables it.
+	ReorgCooldown      TOMLDuration `toml:"reorg_cooldown"`
+	ReorgBypassMethods []string     `toml:"reorg_bypass_methods"`
+	// EnableNoCacheHeader lets requests with an X-Proxyd-No-Cache: true
+	// header skip the cache read and write. When NoCacheHeaderTrustedIPs is
+	// set, the header is only honored from clients within those IPs or CIDRs.
+	EnableNoCacheHeader     bool     `toml:"enable_no_cache_header"`
+	NoCacheHeaderTrustedIPs []string `toml:"no_cache_header_trusted_ips"`
+	// BlockScopedMethods are cached until a consensus aware backend group
+	// observes a new head, instead of being left uncached.
+	BlockScopedMethods []string `toml:"block_scoped_methods"`
+	// BackendCacheControlMethods are cached for as long as the Cache-Control
+	// header of the backend response allows, per its s-maxage or max-age.
+	BackendCacheControlMethods []string `toml:"backend_cache_control_methods"`
+	// DepthTTL caches reads of a block by number for longer the deeper the
+	// block is below the consensus head.
+	DepthTTL DepthTTLConfig `toml:"depth_ttl"`
+	// Compression sets how cached values are compressed.
+	Compression CacheCompressionConfig `toml:"compression"`
+	// CodeHashCalls caches eth_call results by the code hash of the called
+	// contract instead of its address.
+	CodeHashCalls CodeHashCallsConfig `toml:"code_hash_calls"`
+	// Memory sets how the in-memory cache, used without redis or as its
+	// fallback, evicts entries.
+	Memory MemoryCacheConfig `toml:"memory"`
+}
+
+// MemoryCacheConfig caps the in-memory cache at MaxEntries, default 4096, and
+// MaxBytes of keys and values, default unbounded. Once over a cap, entries
+// are evicted by EvictionPolicy.
+type MemoryCacheConfig struct {
+	EvictionPolicy MemoryCacheEvictionPolicy `toml:"eviction_policy"`
+	MaxEntries     int                       `toml:"max_entries"`
+	MaxBytes       int64                     `toml:"max_bytes"`
+}
+
+type MemoryCacheEvictionPolicy string
+
+const (
+	// MemoryCacheEvictionLRU evicts the least recently used entries first.
+	// This is the default.
+	MemoryCacheEvictionLRU MemoryCacheEvictionPolicy = "lru"
+	// MemoryCacheEvictionLFU evicts the least frequently used entries first,
+	// the least recently used among them.
+	MemoryCacheEvictionLFU MemoryCacheEvictionPolicy = "lfu"
+	// MemoryCacheEvictionTTL expires every entry after the cache TTL, and
+	// evicts the entries closest to expiring first.
+	MemoryCacheEvictionTTL MemoryCacheEvictionPolicy = "ttl"
+)
+
+// CodeHashCallsConfig caches eth_call results at blocks at least MinDepth,
+// default 15, below the consensus head by the code hash of the called
+// contract, fetched with eth_getCode, so that contracts with the same code
+// share them. Results must then not depend on the storage of the called
+// contract, as contracts sharing code but not storage would get each other's
+// results.
+type CodeHashCallsConfig struct {
+	Enabled  bool   `toml:"enabled"`
+	MinDepth uint64 `toml:"min_depth"`
+}
+
+// CacheCompressionConfig compresses cached values of at least ThresholdBytes
+// with Algorithm, which is none, snappy, gzip or zstd. It defaults to snappy
+// for every value.
+type CacheCompressionConfig struct {
+	Algorithm      string `toml:"algorithm"`
+	ThresholdBytes int    `toml:"threshold_bytes"`
+}
+
+// DepthTTLConfig sets the TTL of responses to Methods referencing a block by
+// number to BaseTTL at MinDepth blocks below the consensus head, plus
+// TTLPerBlock for every block deeper, up to MaxTTL, which is required along
+// with TTLPerBlock. Blocks closer to the head aren't cached.
+type DepthTTLConfig struct {
+	Methods     []string     `toml:"methods"`
+	MinDepth    uint64       `toml:"min_depth"`
+	BaseTTL     TOMLDuration `toml:"base_ttl"`
+	TTLPerBlock TOMLDuration `toml:"ttl_per_block"`
+	MaxTTL      TOMLDuration `toml:"max_ttl"`
 }
 
 type RedisConfig struct {
@@ -43,11 +249,159 @@ type RedisConfig struct {
 	// OR "rediss://<user>:<password>@<host>:<port>?addr=<host2>:<port2>&addr=<host3>:<port3>"
 	//
 	// Otherwise, it is also possible to specify single url for Redis cluster with proxy support.
-	URL              string `toml:"url"`
+	URL string `toml:"url"`
+	// Deprecated: use redis_key_prefix, which also applies to rate limit keys.
 	Namespace        string `toml:"namespace"`
 	ReadURL          string `toml:"read_url"`
 	FallbackToMemory bool   `toml:"fallback_to_memory"`
 	RedisCluster     bool   `toml:"redis_cluster"`
+	// KeyPrefix is prepended to every cache and rate limit key so that
+	// deployments sharing a redis don't collide.
+	KeyPrefix string `toml:"redis_key_prefix"`
+}
+
+// ResolvedKeyPrefix returns the prefix to apply to redis keys, falling back to
+// the deprecated namespace. Without either, keys aren't prefixed, as they
+// weren't before redis_key_prefix.
+func (r RedisConfig) ResolvedKeyPrefix() string {
+	if r.KeyPrefix != "" {
+		return r.KeyPrefix
+	}
+	return r.Namespace
+}
+
+type AdminConfig struct {
+	// Token enables the admin endpoints. Requests must send it as a bearer token.
+	Token string `toml:"token"`
+	// EnableCallAll serves proxyd_callAll to requests bearing the token.
+	EnableCallAll bool `toml:"enable_call_all"`
+	// SigningSecret enables the admin endpoints too, and requires requests
+	// to them to be signed with HMAC-SHA256 using it. Signed requests whose
+	// timestamp is further than SigningMaxSkew, default 5m, from the local
+	// time are rejected.
+	SigningSecret  string       `toml:"signing_secret"`
+	SigningMaxSkew TOMLDuration `toml:"signing_max_skew"`
+}
+
+// GroupOverrideConfig lets requests choose their backend group with the
+// X-Proxyd-Group header. Only requests authenticated with one of Keys, the
+// aliases of [authentication], or bearing the admin token may do so.
+type GroupOverrideConfig struct {
+	Enabled bool     `toml:"enabled"`
+	Keys    []string `toml:"keys"`
+}
+
+// DeadLetterConfig enables a log of requests that failed on every backend.
+type DeadLetterConfig struct {
+	Enabled bool `toml:"enabled"`
+	// Destination is stdout, stderr or a file path to append to.
+	Destination string `toml:"destination"`
+	// SampleRate is the share of failed requests logged, default 1.
+	SampleRate float64 `toml:"sample_rate"`
+	// MaxPerSecond caps the entries written each second. Zero is unlimited.
+	MaxPerSecond int `toml:"max_per_second"`
+}
+
+// WASMHookConfig runs a WebAssembly module on each HTTP request, letting it
+// pass, reject or rewrite the request. It is disabled without a module path.
+type WASMHookConfig struct {
+	ModulePath string `toml:"module_path"`
+	// Timeout bounds each run of the module, default 10ms.
+	Timeout TOMLDuration `toml:"timeout"`
+	// FailOpen forwards requests the module fails to handle instead of
+	// rejecting them.
+	FailOpen bool `toml:"fail_open"`
+}
+
+// WarmupConfig sends requests to every backend at startup, so that the first
+// client requests don't pay for establishing connections.
+type WarmupConfig struct {
+	// Methods are sent without params to each backend, in order.
+	Methods []string `toml:"methods"`
+	// Timeout bounds how long startup waits for the warmup, default 5s.
+	Timeout TOMLDuration `toml:"timeout"`
+	// ConsensusTimeout delays listening until every consensus aware group
+	// has a consensus head, for up to this long. Disabled when zero.
+	ConsensusTimeout TOMLDuration `toml:"consensus_timeout"`
+}
+
+// RejectionsConfig overrides the JSON-RPC errors answering requests rejected
+// for each reason. Unset fields keep their default.
+type RejectionsConfig struct {
+	RateLimit         RejectionConfig `toml:"rate_limit"`
+	Concurrency       RejectionConfig `toml:"concurrency"`
+	QueueTimeout      RejectionConfig `toml:"queue_timeout"`
+	NoHealthyBackends RejectionConfig `toml:"no_healthy_backends"`
+}
+
+type RejectionConfig struct {
+	Code       int    `toml:"code"`
+	Message    string `toml:"message"`
+	HTTPStatus int    `toml:"http_status"`
+}
+
+// SelfPingConfig makes proxyd time a request to its own loopback handler
+// every Interval, as a baseline for its internal latency. Zero disables it.
+type SelfPingConfig struct {
+	Interval TOMLDuration `toml:"interval"`
+}
+
+// GeoIPConfig labels requests with the country and autonomous system of the
+// client IP, looked up in MaxMind databases. Countries are added to the
+// client_requests_by_country_total metric, and both to the request log.
+// Without any database, it is disabled.
+type GeoIPConfig struct {
+	CountryDatabasePath string `toml:"country_database_path"`
+	ASNDatabasePath     string `toml:"asn_database_path"`
+}
+
+// OTelConfig traces requests with OpenTelemetry spans, continuing the W3C
+// traceparent of clients and propagating it to backends. Spans are exported
+// over OTLP/HTTP to Endpoint, or to the global tracer provider without one.
+type OTelConfig struct {
+	Enabled     bool              `toml:"enabled"`
+	Endpoint    string            `toml:"endpoint"`
+	Insecure    bool              `toml:"insecure"`
+	Headers     map[string]string `toml:"headers"`
+	ServiceName string            `toml:"service_name"`
+	// SampleRatio is the share of traces not started by clients that are
+	// sampled. Defaults to 1.
+	SampleRatio float64 `toml:"sample_ratio"`
+}
+
+type InvalidParamsPolicy string
+
+const (
+	// InvalidParamsPolicyForward forwards the call for the backend to reject.
+	InvalidParamsPolicyForward InvalidParamsPolicy = "forward"
+	// InvalidParamsPolicyReject answers the call with an invalid params error.
+	InvalidParamsPolicyReject InvalidParamsPolicy = "reject"
+)
+
+// InvalidParamsConfig decides what happens to calls whose params proxyd fails
+// to parse while validating them: the min gas price and sender rate limit
+// checks of eth_sendRawTransaction, and the block tag rewriting of consensus
+// aware backend groups. Unset, the min gas price check forwards them and the
+// others reject them.
+type InvalidParamsConfig struct {
+	Policy InvalidParamsPolicy `toml:"policy"`
+	// Methods overrides Policy for some methods.
+	Methods map[string]InvalidParamsPolicy `toml:"methods"`
+}
+
+// PriorityConfig assigns priority levels to requests. When max_concurrent_rpcs
+// is saturated, queued requests with higher levels are forwarded first.
+type PriorityConfig struct {
+	// Default is the level of requests not matching any method or key.
+	Default int `toml:"default"`
+	// Methods maps RPC methods to levels.
+	Methods map[string]int `toml:"methods"`
+	// Keys maps authentication aliases, the values of [authentication], to levels.
+	Keys map[string]int `toml:"keys"`
+}
+
+func (p PriorityConfig) Enabled() bool {
+	return len(p.Methods) > 0 || len(p.Keys) > 0
 }
 
 type MetricsConfig struct {
@@ -93,6 +447,10 @@ func (t *TOMLDuration) UnmarshalText(b []byte) error {
 	return nil
 }
 
+func (t TOMLDuration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(t).String()), nil
+}
+
 type BackendOptions struct {
 	ResponseTimeoutSeconds      int          `toml:"response_timeout_seconds"`
 	MaxResponseSizeBytes        int64        `toml:"max_response_size_bytes"`
@@ -101,6 +459,26 @@ type BackendOptions struct {
 	MaxDegradedLatencyThreshold TOMLDuration `toml:"max_degraded_latency_threshold"`
 	MaxLatencyThreshold         TOMLDuration `toml:"max_latency_threshold"`
 	MaxErrorRateThreshold       float64      `toml:"max_error_rate_threshold"`
+	RewriteRequestIDs           bool         `toml:"rewrite_request_ids"`
+	// ConsensusPollerInterval is the poll interval of consensus aware backend
+	// groups that don't set their own.
+	ConsensusPollerInterval TOMLDuration `toml:"consensus_poller_interval"`
+	// MaxRetryAfter caps how long a backend is avoided after answering with
+	// a 429 and a Retry-After header, default 1m.
+	MaxRetryAfter TOMLDuration `toml:"max_retry_after"`
+	// Proxy applies to every backend that doesn't configure its own.
+	Proxy BackendProxyConfig `toml:"proxy"`
+	// UserAgent is sent to every backend that doesn't configure its own,
+	// default proxyd/<version>.
+	UserAgent string `toml:"user_agent"`
+}
+
+// BackendProxyConfig routes backend traffic through an egress proxy. URL,
+// Username and Password are read from the environment if prefixed with $.
+type BackendProxyConfig struct {
+	URL      string `toml:"url"`
+	Username string `toml:"username"`
+	Password string `toml:"password"`
 }
 
 type BackendConfig struct {
@@ -116,9 +494,37 @@ type BackendConfig struct {
 	ClientKeyFile    string            `toml:"client_key_file"`
 	StripTrailingXFF bool              `toml:"strip_trailing_xff"`
 	Headers          map[string]string `toml:"headers"`
+	// TLSServerName and HostHeader override the SNI and Host header sent to
+	// the backend when they differ from the host in rpc_url.
+	TLSServerName string `toml:"tls_server_name"`
+	HostHeader    string `toml:"host_header"`
+	// UserAgent overrides backend.user_agent for this backend.
+	UserAgent string `toml:"user_agent"`
+
+	Proxy BackendProxyConfig `toml:"proxy"`
+
+	// JSONRPCMode controls whether the jsonrpc version field is sent to this
+	// backend. Responses omitting the field are normalized to "2.0" unless
+	// RejectMissingJSONRPC is set, in which case they are treated as invalid.
+	JSONRPCMode          JSONRPCMode `toml:"jsonrpc_mode"`
+	RejectMissingJSONRPC bool        `toml:"reject_missing_jsonrpc"`
+
+	// EnforceMaxRPS caps the requests sent to the backend at MaxRPS, which
+	// is otherwise not enforced. MaxRPSBurst is the number of requests that
+	// may be sent at once within MaxRPS, default MaxRPS. Requests over MaxRPS
+	// skip the backend, unless MaxRPSWait holds them until they are within it
+	// or time out.
+	EnforceMaxRPS bool `toml:"enforce_max_rps"`
+	MaxRPSBurst   int  `toml:"max_rps_burst"`
+	MaxRPSWait    bool `toml:"max_rps_wait"`
 
 	Weight int `toml:"weight"`
 
+	// ExcludedMethods are RPC methods this backend is never selected to
+	// serve, such as methods it is known to answer wrongly. It still serves
+	// other methods and is polled for consensus.
+	ExcludedMethods []string `toml:"excluded_methods"`
+
 	ConsensusSkipPeerCountCheck bool   `toml:"consensus_skip_peer_count"`
 	ConsensusForcedCandidate    bool   `toml:"consensus_forced_candidate"`
 	ConsensusReceiptsTarget     string `toml:"consensus_receipts_target"`
@@ -126,6 +532,15 @@ type BackendConfig struct {
 
 type BackendsConfig map[string]*BackendConfig
 
+type JSONRPCMode string
+
+const (
+	// JSONRPCModeStrict always sends jsonrpc "2.0".
+	JSONRPCModeStrict JSONRPCMode = "strict"
+	// JSONRPCModeOmit drops the jsonrpc field from requests.
+	JSONRPCModeOmit JSONRPCMode = "omit"
+)
+
 type RoutingStrategy string
 
 func (b *BackendGroupConfig) ValidateRoutingStrategy(bgName string) bool {
@@ -168,6 +583,10 @@ type BackendGroupConfig struct {
 
 	WeightedRouting bool `toml:"weighted_routing"`
 
+	// BackendSelector names the BackendSelector ordering the healthy backends
+	// of the group, either built in or registered with RegisterBackendSelector.
+	BackendSelector string `toml:"backend_selector"`
+
 	RoutingStrategy RoutingStrategy `toml:"routing_strategy"`
 
 	MulticallRPCErrorCheck bool `toml:"multicall_rpc_error_check"`
@@ -185,12 +604,155 @@ type BackendGroupConfig struct {
 	ConsensusMaxBlockRange      uint64       `toml:"consensus_max_block_range"`
 	ConsensusMinPeerCount       int          `toml:"consensus_min_peer_count"`
 
+	// ConsensusBanBackoffMultiplier grows the ban period of a backend that is
+	// banned again, up to ConsensusMaxBanPeriod. The ban count is reset once a
+	// backend stays unbanned for ConsensusMaxBanPeriod.
+	ConsensusBanBackoffMultiplier float64      `toml:"consensus_ban_backoff_multiplier"`
+	ConsensusMaxBanPeriod         TOMLDuration `toml:"consensus_max_ban_period"`
+	// ConsensusRecoveryPolls is the number of consecutive successful polls,
+	// within consensus_max_block_lag of the consensus head, a backend needs
+	// after a ban before it rejoins the consensus group.
+	ConsensusRecoveryPolls int `toml:"consensus_recovery_polls"`
+
+	// ConsensusHeadMethod replaces eth_getBlockByNumber("latest") as the call used
+	// to find a backend's latest block. The number, and optionally the hash, are
+	// read from the result at the given dot-separated paths; an empty number path
+	// reads the result itself. Safe and finalized blocks are still fetched with
+	// eth_getBlockByNumber.
+	ConsensusHeadMethod     string        `toml:"consensus_head_method"`
+	ConsensusHeadParams     []interface{} `toml:"consensus_head_params"`
+	ConsensusHeadNumberPath string        `toml:"consensus_head_number_path"`
+	ConsensusHeadHashPath   string        `toml:"consensus_head_hash_path"`
+
+	// ConsensusMaxHeadSkew bans backends whose latest block timestamp is
+	// further than this behind or ahead of local time. Zero disables it.
+	ConsensusMaxHeadSkew TOMLDuration `toml:"consensus_max_head_skew"`
+
+	// ConsensusStandbyHeadURL is the RPC URL of a trusted node outside of the
+	// group that the consensus head is taken from while no backend of the
+	// group is a consensus candidate.
+	ConsensusStandbyHeadURL string `toml:"consensus_standby_head_url"`
+
+	// ConsensusMinBackends and ConsensusMinFraction are the minimum number
+	// and fraction of the group's backends that must be in the consensus
+	// group for the group to serve requests. Whichever is stricter applies.
+	ConsensusMinBackends int     `toml:"consensus_min_backends"`
+	ConsensusMinFraction float64 `toml:"consensus_min_fraction"`
+
 	ConsensusHA                  bool         `toml:"consensus_ha"`
 	ConsensusHAHeartbeatInterval TOMLDuration `toml:"consensus_ha_heartbeat_interval"`
 	ConsensusHALockPeriod        TOMLDuration `toml:"consensus_ha_lock_period"`
 	ConsensusHARedis             RedisConfig  `toml:"consensus_ha_redis"`
 
 	Fallbacks []string `toml:"fallbacks"`
+
+	// SpilloverGroup receives SpilloverPercent of this group's requests even
+	// while this group is healthy.
+	SpilloverGroup   string `toml:"spillover_group"`
+	SpilloverPercent int    `toml:"spillover_percent"`
+
+	// ReplicaGroup receives calls to ReplicaMethods for blocks at least
+	// ReplicaMinDepth blocks below the consensus head of this group.
+	ReplicaGroup    string   `toml:"replica_group"`
+	ReplicaMethods  []string `toml:"replica_methods"`
+	ReplicaMinDepth uint64   `toml:"replica_min_depth"`
+	// ReplicaAlwaysMethods are sent to ReplicaGroup whatever their params,
+	// for methods such as traces that need full state on any call.
+	ReplicaAlwaysMethods []string `toml:"replica_always_methods"`
+
+	// SendRawTxDedupWindow answers repeated eth_sendRawTransaction calls with
+	// the same raw transaction within the window without broadcasting again.
+	SendRawTxDedupWindow TOMLDuration `toml:"send_raw_tx_dedup_window"`
+	// SendRawTxDedupRedis shares the dedup window through Redis, so that a
+	// retry reaching another proxyd instance isn't broadcast again either.
+	SendRawTxDedupRedis bool `toml:"send_raw_tx_dedup_redis"`
+
+	// BalanceSnapshot serves eth_getBalance at the finalized block from
+	// memory, for up to BalanceSnapshotMaxEntries addresses, until the
+	// finalized block advances.
+	BalanceSnapshot           bool `toml:"balance_snapshot"`
+	BalanceSnapshotMaxEntries int  `toml:"balance_snapshot_max_entries"`
+
+	// DriftSampleMethod is sent with DriftSampleParams to every backend of the
+	// group each DriftSampleInterval, default 1m, to report backends whose
+	// results diverge from the others. It should be a deterministic read, such
+	// as eth_getBalance at a finalized block.
+	DriftSampleMethod   string        `toml:"drift_sample_method"`
+	DriftSampleParams   []interface{} `toml:"drift_sample_params"`
+	DriftSampleInterval TOMLDuration  `toml:"drift_sample_interval"`
+
+	// PinNonceReads serves eth_getTransactionCount for an address from the
+	// same backend while it is healthy, so that nonce reads don't go back
+	// when backends lag behind each other.
+	PinNonceReads bool `toml:"pin_nonce_reads"`
+
+	// QueueTimeout bounds how long requests to the group wait for a slot
+	// when max_concurrent_rpcs is reached, after which they fail with a 503.
+	// Zero waits until the request times out.
+	QueueTimeout TOMLDuration `toml:"queue_timeout"`
+
+	// SLOTimeout bounds how long the group may take to serve a request,
+	// independently of the backend response timeout. Requests over it get a
+	// 504 while the backends are abandoned. Zero disables it.
+	SLOTimeout TOMLDuration `toml:"slo_timeout"`
+
+	// MethodConcurrencyLimits caps how many calls to each method the group
+	// forwards concurrently. Calls over the cap wait for a slot as bounded by
+	// QueueTimeout, or are rejected with a 429 if MethodConcurrencyReject.
+	MethodConcurrencyLimits map[string]int `toml:"method_concurrency_limits"`
+	MethodConcurrencyReject bool           `toml:"method_concurrency_reject"`
+
+	// PreferWarmConnections tries healthy backends holding an idle keep-alive
+	// connection before the other healthy backends the selector ranks
+	// equally: of the same degraded state, and of the same weight with the
+	// weighted selector. It requires the random or weighted selector.
+	PreferWarmConnections bool `toml:"prefer_warm_connections"`
+
+	// Canary sends a share of the calls to one method to a canary backend.
+	Canary *CanaryConfig `toml:"canary"`
+
+	// CacheOnlyOnOutage serves cacheable reads from their last response, up
+	// to CacheOnlyMaxStale old, default 1h, while no backend of the group is
+	// healthy, and fails other calls with a 503. It requires the cache.
+	CacheOnlyOnOutage bool         `toml:"cache_only_on_outage"`
+	CacheOnlyMaxStale TOMLDuration `toml:"cache_only_max_stale"`
+
+	// CacheOnBackoff serves cacheable reads from their last response, up to
+	// CacheOnlyMaxStale old, while the preferred backend of the group, its
+	// first, backs off after a 429 with a Retry-After header, rather than
+	// failing them over to the other backends. It requires the cache.
+	CacheOnBackoff bool `toml:"cache_on_backoff"`
+
+	// AffinityMethods sends single calls to each of these methods with the
+	// same first param, such as an address, to the same backend for the TTL
+	// of the method. Backends are chosen by rendezvous hashing of the param,
+	// like pinned nonce reads, so that a backend leaving or joining only
+	// moves its share of the params.
+	AffinityMethods map[string]TOMLDuration `toml:"affinity_methods"`
+
+	// BatchFanoutConcurrency bounds how many of the sub-batches a batch is
+	// split into, per max_upstream_batch_size, are forwarded to the group in
+	// parallel. Default 1, which forwards them one after the other.
+	BatchFanoutConcurrency int `toml:"batch_fanout_concurrency"`
+
+	// NetVersion answers net_version calls routed to the group with this
+	// decimal chain id, such as "56", without contacting a backend.
+	NetVersion string `toml:"net_version"`
+
+	// CoalesceMethods shares one backend call between concurrent single
+	// calls to each of these methods with the same params.
+	CoalesceMethods []string `toml:"coalesce_methods"`
+}
+
+// CanaryConfig sends Percent of the single calls to Method to Backend, which
+// needn't be part of the group, and compares its error rate with that of the
+// group over Window, default 5m. Calls failing on the canary are sent to the
+// group.
+type CanaryConfig struct {
+	Method  string       `toml:"method"`
+	Backend string       `toml:"backend"`
+	Percent float64      `toml:"percent"`
+	Window  TOMLDuration `toml:"window"`
 }
 
 type BackendGroupsConfig map[string]*BackendGroupConfig
@@ -198,10 +760,41 @@ type BackendGroupsConfig map[string]*BackendGroupConfig
 type MethodMappingsConfig map[string]string
 
 type BatchConfig struct {
-	MaxSize      int    `toml:"max_size"`
-	ErrorMessage string `toml:"error_message"`
+	MaxSize int `toml:"max_size"`
+	// ErrorMessage may reference the configured limit and the size of the
+	// rejected batch as {limit} and {size}.
+	ErrorMessage string          `toml:"error_message"`
+	ErrorCode    int             `toml:"error_code"`
+	ErrorStyle   BatchErrorStyle `toml:"error_style"`
+
+	// MethodLimits caps how many times a method may appear in a single batch.
+	MethodLimits      map[string]int         `toml:"method_limits"`
+	MethodLimitAction BatchMethodLimitAction `toml:"method_limit_action"`
+
+	// IncrementalEncoding writes batch responses to the client one call at a
+	// time instead of encoding the whole array in memory first. The array is
+	// only written once every call has resolved.
+	IncrementalEncoding bool `toml:"incremental_encoding"`
 }
 
+type BatchMethodLimitAction string
+
+const (
+	// BatchMethodLimitActionEntries rejects only the calls over the limit.
+	BatchMethodLimitActionEntries BatchMethodLimitAction = "entries"
+	// BatchMethodLimitActionBatch rejects the whole batch.
+	BatchMethodLimitActionBatch BatchMethodLimitAction = "batch"
+)
+
+type BatchErrorStyle string
+
+const (
+	// BatchErrorStyleSingle answers an oversized batch with one error object.
+	BatchErrorStyleSingle BatchErrorStyle = "single"
+	// BatchErrorStyleArray answers an oversized batch with one error per call.
+	BatchErrorStyleArray BatchErrorStyle = "array"
+)
+
 // SenderRateLimitConfig configures the sender-based rate limiter
 // for eth_sendRawTransaction requests.
 // To enable pre-eip155 transactions, add '0' to allowed_chain_ids.
@@ -212,6 +805,16 @@ type SenderRateLimitConfig struct {
 	AllowedChainIds []*big.Int `toml:"allowed_chain_ids"`
 }
 
+// SpendingLimitConfig limits what each API key, by its authentication alias,
+// spends per window. Calls cost their method's entry in MethodCosts, or else
+// DefaultCost. Keys without a budget aren't limited.
+type SpendingLimitConfig struct {
+	Window      TOMLDuration     `toml:"window"`
+	DefaultCost int64            `toml:"default_cost"`
+	MethodCosts map[string]int64 `toml:"method_costs"`
+	Budgets     map[string]int64 `toml:"budgets"`
+}
+
 type EthCallRule struct {
 	Address string `toml:"address"`
 	Value   string `toml:"value"`
@@ -222,24 +825,127 @@ type EthCallOverrideConfig struct {
 	Rules []EthCallRule `toml:"rules"`
 }
 
+// ErrorMappingConfig rewrites backend errors before they reach clients, so
+// that provider-specific details aren't leaked.
+type ErrorMappingConfig struct {
+	// Code matches errors with this code. Zero matches any code.
+	Code int `toml:"code"`
+	// Message is a regular expression matched against the error message.
+	Message string `toml:"message"`
+	// ReplaceCode replaces the code of matching errors. Zero keeps it.
+	ReplaceCode int `toml:"replace_code"`
+	// ReplaceMessage replaces the whole message of matching errors. It may
+	// refer to groups of Message as $1. Empty keeps the message.
+	ReplaceMessage string `toml:"replace_message"`
+}
+
+// ErrorStatusConfig sets the HTTP status of responses to single calls that
+// fail with a JSON-RPC error. Batches are always answered with a 200.
+type ErrorStatusConfig struct {
+	Mode ErrorStatusMode `toml:"mode"`
+	// Categories maps categories of error codes to the HTTP status of errors
+	// without one of their own, such as errors returned by backends. The
+	// categories are parse_error, invalid_request, method_not_found,
+	// invalid_params, internal_error, server_error (-32099 to -32000) and
+	// application_error (any other code). Unmapped errors get a 200.
+	Categories map[string]int `toml:"categories"`
+}
+
+type ErrorStatusMode string
+
+const (
+	// ErrorStatusModeMapped answers errors with their own HTTP status or that
+	// of their category. This is the default.
+	ErrorStatusModeMapped ErrorStatusMode = "mapped"
+	// ErrorStatusModeAlways200 answers every error with a 200.
+	ErrorStatusModeAlways200 ErrorStatusMode = "always_200"
+)
+
+type WSBackpressurePolicy string
+
+const (
+	// WSBackpressureDropOldest drops the oldest queued subscription
+	// notification. This is the default.
+	WSBackpressureDropOldest WSBackpressurePolicy = "drop_oldest"
+	// WSBackpressureDisconnect closes the client connection.
+	WSBackpressureDisconnect WSBackpressurePolicy = "disconnect"
+)
+
+// ParamRouteConfig routes calls of a method to another backend group when its
+// params meet every condition set on the route.
+type ParamRouteConfig struct {
+	BackendGroup string `toml:"backend_group"`
+	// Param is the index of the positional param to inspect.
+	Param int `toml:"param"`
+	// Field selects a field of an object param, such as "to" of eth_call.
+	Field string `toml:"field"`
+	// Equals matches string values equal to any of these, ignoring case.
+	Equals []string `toml:"equals"`
+	// BlockTag matches block params by class, historical or recent.
+	BlockTag ParamRouteBlockTag `toml:"block_tag"`
+}
+
+type ParamRouteBlockTag string
+
+const (
+	// ParamRouteBlockTagHistorical matches block numbers, block hashes and
+	// "earliest".
+	ParamRouteBlockTagHistorical ParamRouteBlockTag = "historical"
+	// ParamRouteBlockTagRecent matches "latest", "pending", "safe",
+	// "finalized" and omitted block params.
+	ParamRouteBlockTagRecent ParamRouteBlockTag = "recent"
+)
+
 type Config struct {
-	WSBackendGroup          string                       `toml:"ws_backend_group"`
-	Server                  ServerConfig                 `toml:"server"`
-	Cache                   CacheConfig                  `toml:"cache"`
-	Redis                   RedisConfig                  `toml:"redis"`
-	Metrics                 MetricsConfig                `toml:"metrics"`
-	RateLimit               RateLimitConfig              `toml:"rate_limit"`
-	BackendOptions          BackendOptions               `toml:"backend"`
-	Backends                BackendsConfig               `toml:"backends"`
-	BatchConfig             BatchConfig                  `toml:"batch"`
-	Authentication          map[string]string            `toml:"authentication"`
-	BackendGroups           BackendGroupsConfig          `toml:"backend_groups"`
-	RPCMethodMappings       map[string]string            `toml:"rpc_method_mappings"`
-	DomainRPCMethodMappings map[string]map[string]string `toml:"domain_rpc_method_mappings"`
-	WSMethodWhitelist       []string                     `toml:"ws_method_whitelist"`
-	WhitelistErrorMessage   string                       `toml:"whitelist_error_message"`
-	SenderRateLimit         SenderRateLimitConfig        `toml:"sender_rate_limit"`
-	EthCallOverride         EthCallOverrideConfig        `toml:"eth_call_override"`
+	WSBackendGroup            string                        `toml:"ws_backend_group"`
+	Server                    ServerConfig                  `toml:"server"`
+	Cache                     CacheConfig                   `toml:"cache"`
+	Redis                     RedisConfig                   `toml:"redis"`
+	Metrics                   MetricsConfig                 `toml:"metrics"`
+	Admin                     AdminConfig                   `toml:"admin"`
+	GroupOverride             GroupOverrideConfig           `toml:"group_override"`
+	Priority                  PriorityConfig                `toml:"priority"`
+	InvalidParams             InvalidParamsConfig           `toml:"invalid_params"`
+	DeadLetter                DeadLetterConfig              `toml:"dead_letter"`
+	WASMHook                  WASMHookConfig                `toml:"wasm_hook"`
+	Warmup                    WarmupConfig                  `toml:"warmup"`
+	SelfPing                  SelfPingConfig                `toml:"self_ping"`
+	GeoIP                     GeoIPConfig                   `toml:"geoip"`
+	OTel                      OTelConfig                    `toml:"otel"`
+	Rejections                RejectionsConfig              `toml:"rejections"`
+	RateLimit                 RateLimitConfig               `toml:"rate_limit"`
+	BackendOptions            BackendOptions                `toml:"backend"`
+	Backends                  BackendsConfig                `toml:"backends"`
+	BatchConfig               BatchConfig                   `toml:"batch"`
+	Authentication            map[string]string             `toml:"authentication"`
+	BackendGroups             BackendGroupsConfig           `toml:"backend_groups"`
+	RPCMethodMappings         map[string]string             `toml:"rpc_method_mappings"`
+	DomainRPCMethodMappings   map[string]map[string]string  `toml:"domain_rpc_method_mappings"`
+	DomainStrictRequestFields map[string]bool               `toml:"domain_strict_request_fields"`
+	DomainPendingToLatest     map[string][]string           `toml:"domain_pending_to_latest_methods"`
+	DomainCacheNamespaces     map[string]string             `toml:"domain_cache_namespaces"`
+	WSMethodWhitelist         []string                      `toml:"ws_method_whitelist"`
+	WhitelistErrorMessage     string                        `toml:"whitelist_error_message"`
+	SenderRateLimit           SenderRateLimitConfig         `toml:"sender_rate_limit"`
+	EthCallOverride           EthCallOverrideConfig         `toml:"eth_call_override"`
+	ParamRoutes               map[string][]ParamRouteConfig `toml:"param_routes"`
+	ErrorMappings             []ErrorMappingConfig          `toml:"error_mappings"`
+	ErrorStatus               ErrorStatusConfig             `toml:"error_status"`
+	// MinGasPrice, in wei, rejects eth_sendRawTransaction calls whose effective
+	// gas price is lower. Transactions that fail to decode are forwarded as is.
+	MinGasPrice *big.Int `toml:"min_gas_price"`
+
+	// ResponseFieldFilters strips the fields at the listed paths from the
+	// results of successful calls to each method. DomainResponseFieldFilters
+	// replaces them for calls with a given X-Forwarded-Host.
+	ResponseFieldFilters       map[string][]string            `toml:"response_field_filters"`
+	DomainResponseFieldFilters map[string]map[string][]string `toml:"domain_response_field_filters"`
+
+	SpendingLimit SpendingLimitConfig `toml:"spending_limit"`
+
+	// MaxParamsLength caps the length of the params array of calls to each
+	// method.
+	MaxParamsLength map[string]int `toml:"max_params_length"`
 }
 
 func ReadFromEnvOrConfig(value string) (string, error) {
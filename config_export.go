@@ -0,0 +1,232 @@
+package proxyd
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+const redactedValue = "[redacted]"
+
+// HandleConfig answers with the effective config as JSON, keyed like the
+// TOML config: env vars substituted, defaults applied and secrets redacted.
+// Settings changed at runtime, such as log levels, are listed under runtime,
+// and rpc method mappings are the ones in use since the last reload.
+func (s *Server) HandleConfig(w http.ResponseWriter, r *http.Request) {
+	if !s.authorizeAdmin(w, r) {
+		return
+	}
+
+	exported, err := exportConfig(s.effectiveConfig())
+	if err != nil {
+		log.Error("error exporting config", "err", err)
+		http.Error(w, "error exporting config", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("content-type", "application/json")
+	_ = json.NewEncoder(w).Encode(exported)
+}
+
+// effectiveConfig returns the config the server was started with, with the
+// rpc method mappings of the routing config in use.
+func (s *Server) effectiveConfig() *Config {
+	cfg := new(Config)
+	if s.config != nil {
+		*cfg = *s.config
+	}
+	if routing := s.routing.Load(); routing != nil {
+		cfg.RPCMethodMappings = routing.rpcMethodMappings
+		cfg.DomainRPCMethodMappings = routing.domainRPCMethodMappings
+	}
+	return cfg
+}
+
+// exportConfig returns cfg as a map keyed like the TOML config, with env vars
+// substituted, defaults applied and secrets redacted. cfg isn't modified.
+func exportConfig(cfg *Config) (map[string]interface{}, error) {
+	if cfg == nil {
+		cfg = new(Config)
+	}
+	resolved := *cfg
+	applyConfigDefaults(&resolved)
+
+	var buf bytes.Buffer
+	if err := toml.NewEncoder(&buf).Encode(&resolved); err != nil {
+		return nil, err
+	}
+	exported := make(map[string]interface{})
+	if _, err := toml.Decode(buf.String(), &exported); err != nil {
+		return nil, err
+	}
+
+	resolveExportedSecrets(exported)
+
+	defaultLevel, moduleLevels := logLevels.snapshot()
+	exported["runtime"] = map[string]interface{}{
+		"log_levels": map[string]interface{}{
+			"default": defaultLevel,
+			"modules": moduleLevels,
+		},
+	}
+	return exported, nil
+}
+
+// applyConfigDefaults fills the unset top-level settings of cfg that proxyd
+// defaults. Only sections held by value are changed, so that the backends and
+// backend groups of the original config are left alone.
+func applyConfigDefaults(cfg *Config) {
+	setDefault := func(v *int, def int) {
+		if *v == 0 {
+			*v = def
+		}
+	}
+	setDefaultDuration := func(v *TOMLDuration, def time.Duration) {
+		if *v == 0 {
+			*v = TOMLDuration(def)
+		}
+	}
+
+	setDefault(&cfg.Server.TimeoutSeconds, int(defaultRPCTimeout/time.Second))
+	if cfg.Server.MaxBodySizeBytes == 0 {
+		cfg.Server.MaxBodySizeBytes = defaultBodySizeLimit
+	}
+	setDefault(&cfg.Server.MaxUpstreamBatchSize, defaultMaxUpstreamBatchSize)
+	setDefault(&cfg.Server.MaxHeaderCount, defaultMaxHeaderCount)
+	setDefault(&cfg.Server.MaxHeaderBytes, defaultMaxHeaderBytes)
+	setDefault(&cfg.Server.MaxURLLength, defaultMaxURLLength)
+	setDefault(&cfg.Server.BodyBufferSize, defaultBodyBufferSize)
+	setDefault(&cfg.Server.BodyBufferMaxSize, defaultBodyBufferMaxSize)
+	if cfg.Server.WSBackpressurePolicy == "" {
+		cfg.Server.WSBackpressurePolicy = WSBackpressureDropOldest
+	}
+	setDefault(&cfg.BatchConfig.MaxSize, DefaultMaxBatchRPCCallsLimit)
+
+	setDefaultDuration(&cfg.Cache.TTL, defaultCacheTtl)
+	if cfg.Cache.Memory.EvictionPolicy == "" {
+		cfg.Cache.Memory.EvictionPolicy = MemoryCacheEvictionLRU
+	}
+	setDefault(&cfg.Cache.Memory.MaxEntries, memoryCacheLimit)
+
+	setDefault(&cfg.BackendOptions.ResponseTimeoutSeconds, 5)
+	setDefaultDuration(&cfg.BackendOptions.MaxLatencyThreshold, 10*time.Second)
+	setDefaultDuration(&cfg.BackendOptions.MaxDegradedLatencyThreshold, 5*time.Second)
+	if cfg.BackendOptions.MaxErrorRateThreshold == 0 {
+		cfg.BackendOptions.MaxErrorRateThreshold = 0.5
+	}
+	setDefaultDuration(&cfg.BackendOptions.MaxRetryAfter, defaultMaxRetryAfter)
+	if cfg.BackendOptions.UserAgent == "" {
+		cfg.BackendOptions.UserAgent = DefaultUserAgent()
+	}
+
+	if cfg.Admin.SigningSecret != "" {
+		setDefaultDuration(&cfg.Admin.SigningMaxSkew, defaultAdminSigningMaxSkew)
+	}
+	if cfg.ErrorStatus.Mode == "" {
+		cfg.ErrorStatus.Mode = ErrorStatusModeMapped
+	}
+}
+
+// resolveExportedSecrets substitutes the env vars of the settings proxyd
+// reads from the environment, then redacts the secrets among them. URLs keep
+// their scheme and host only, as their path or credentials often hold API
+// keys. Authentication keys are replaced by the list of their aliases.
+func resolveExportedSecrets(exported map[string]interface{}) {
+	table := func(m map[string]interface{}, key string) map[string]interface{} {
+		t, _ := m[key].(map[string]interface{})
+		return t
+	}
+	resolve := func(m map[string]interface{}, key string) {
+		if value, ok := m[key].(string); ok && value != "" {
+			if resolved, err := ReadFromEnvOrConfig(value); err == nil {
+				m[key] = resolved
+			}
+		}
+	}
+	redact := func(m map[string]interface{}, key string) {
+		if value, ok := m[key].(string); ok && value != "" {
+			m[key] = redactedValue
+		}
+	}
+	redactURL := func(m map[string]interface{}, key string) {
+		resolve(m, key)
+		if value, ok := m[key].(string); ok && value != "" {
+			m[key] = redactConfigURL(value)
+		}
+	}
+	redactHeaders := func(m map[string]interface{}) {
+		for name := range table(m, "headers") {
+			table(m, "headers")[name] = redactedValue
+		}
+	}
+	redactProxy := func(m map[string]interface{}) {
+		if proxy := table(m, "proxy"); proxy != nil {
+			redactURL(proxy, "url")
+			resolve(proxy, "username")
+			redact(proxy, "password")
+		}
+	}
+
+	if redis := table(exported, "redis"); redis != nil {
+		redactURL(redis, "url")
+		redactURL(redis, "read_url")
+	}
+	if backend := table(exported, "backend"); backend != nil {
+		redactProxy(backend)
+	}
+	for name := range table(exported, "backends") {
+		backend := table(table(exported, "backends"), name)
+		if backend == nil {
+			continue
+		}
+		redactURL(backend, "rpc_url")
+		redactURL(backend, "ws_url")
+		redact(backend, "password")
+		redactHeaders(backend)
+		redactProxy(backend)
+		resolve(backend, "consensus_receipts_target")
+	}
+	for name := range table(exported, "backend_groups") {
+		if group := table(table(exported, "backend_groups"), name); group != nil {
+			redactURL(group, "consensus_standby_head_url")
+		}
+	}
+	if admin := table(exported, "admin"); admin != nil {
+		redact(admin, "token")
+		redact(admin, "signing_secret")
+	}
+	if otel := table(exported, "otel"); otel != nil {
+		redactHeaders(otel)
+	}
+	if auth := table(exported, "authentication"); auth != nil {
+		aliases := make([]string, 0, len(auth))
+		for _, alias := range auth {
+			if s, ok := alias.(string); ok {
+				aliases = append(aliases, s)
+			}
+		}
+		sort.Strings(aliases)
+		exported["authentication"] = aliases
+	}
+}
+
+// redactConfigURL keeps the scheme and host of a URL, replacing anything
+// else, such as credentials, a path or a query, with a redacted marker.
+func redactConfigURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return redactedValue
+	}
+	redacted := u.Scheme + "://" + u.Host
+	if u.User != nil || strings.Trim(u.Path, "/") != "" || u.RawQuery != "" || u.Fragment != "" {
+		redacted += "/" + redactedValue
+	}
+	return redacted
+}
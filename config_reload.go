@@ -0,0 +1,141 @@
+package proxyd
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// routingConfig is the part of the config that is reloaded without a
+// restart. It is never modified once in use, but replaced as a whole, so that
+// a request routed with it sees the mappings of a single reload.
+type routingConfig struct {
+	rpcMethodMappings       map[string]string
+	domainRPCMethodMappings map[string]map[string]string
+}
+
+// newRoutingConfig returns the routing config of config, checking that it only
+// maps methods to existing backend groups.
+func newRoutingConfig(config *Config, backendGroups map[string]*BackendGroup) (*routingConfig, error) {
+	if len(config.RPCMethodMappings) == 0 {
+		return nil, fmt.Errorf("must define at least one RPC method mapping")
+	}
+	for _, bg := range config.RPCMethodMappings {
+		if backendGroups[bg] == nil {
+			return nil, fmt.Errorf("undefined backend group %s", bg)
+		}
+	}
+	for domain, mappings := range config.DomainRPCMethodMappings {
+		for _, bg := range mappings {
+			if backendGroups[bg] == nil {
+				return nil, fmt.Errorf("undefined backend group %s for domain %s", bg, domain)
+			}
+		}
+	}
+	return &routingConfig{
+		rpcMethodMappings:       config.RPCMethodMappings,
+		domainRPCMethodMappings: config.DomainRPCMethodMappings,
+	}, nil
+}
+
+// reloadCoordinator runs reloads one at a time. Reloads requested while one
+// runs are coalesced into a single run after it, which they all wait for, so
+// that every caller sees the state of the files as of its request at least.
+type reloadCoordinator struct {
+	reload func() error
+
+	mtx     sync.Mutex
+	running bool
+	pending *reloadRun
+}
+
+// reloadRun is a reload shared by the callers that requested it. err is set
+// before done is closed.
+type reloadRun struct {
+	done chan struct{}
+	err  error
+}
+
+func newReloadCoordinator(reload func() error) *reloadCoordinator {
+	return &reloadCoordinator{reload: reload}
+}
+
+// Reload requests a reload and waits for it.
+func (c *reloadCoordinator) Reload() error {
+	c.mtx.Lock()
+	if c.pending == nil {
+		c.pending = &reloadRun{done: make(chan struct{})}
+	} else {
+		RecordConfigReloadCoalesced()
+	}
+	run := c.pending
+	start := !c.running
+	c.running = true
+	c.mtx.Unlock()
+
+	// the caller finding no reload running runs the pending ones, including
+	// those requested meanwhile
+	if start {
+		c.runPending()
+	}
+	<-run.done
+	return run.err
+}
+
+func (c *reloadCoordinator) runPending() {
+	for {
+		c.mtx.Lock()
+		run := c.pending
+		c.pending = nil
+		if run == nil {
+			c.running = false
+			c.mtx.Unlock()
+			return
+		}
+		c.mtx.Unlock()
+
+		run.err = c.reload()
+		RecordConfigReload(run.err == nil)
+		close(run.done)
+	}
+}
+
+// SetConfigSource sets where Reload reads the config from. Without a source,
+// Reload only reloads the TLS certificates.
+func (s *Server) SetConfigSource(source func() (*Config, error)) {
+	s.configSourceMtx.Lock()
+	defer s.configSourceMtx.Unlock()
+	s.configSource = source
+}
+
+// Reload reads the TLS certificates and the config source again, and swaps
+// in the rpc method mappings of the config. Concurrent calls are serialized
+// and coalesced. If the config is invalid, the mappings in use are kept.
+func (s *Server) Reload() error {
+	return s.reloader.Reload()
+}
+
+func (s *Server) reload() error {
+	if err := s.ReloadTLSCertificates(); err != nil {
+		return err
+	}
+
+	s.configSourceMtx.Lock()
+	source := s.configSource
+	s.configSourceMtx.Unlock()
+	if source == nil {
+		return nil
+	}
+	config, err := source()
+	if err != nil {
+		return fmt.Errorf("error reading config: %w", err)
+	}
+	routing, err := newRoutingConfig(config, s.BackendGroups)
+	if err != nil {
+		return fmt.Errorf("invalid config: %w", err)
+	}
+	s.routing.Store(routing)
+	log.Info("reloaded rpc method mappings", "methods", len(routing.rpcMethodMappings), "domains", len(routing.domainRPCMethodMappings))
+	return nil
+}
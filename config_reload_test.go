@@ -0,0 +1,148 @@
+package proxyd
+
+import (
+	"errors"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReloadCoordinator(t *testing.T) {
+	var running, maxRunning, started, completed atomic.Int64
+	c := newReloadCoordinator(func() error {
+		n := running.Add(1)
+		defer running.Add(-1)
+		for {
+			max := maxRunning.Load()
+			if n <= max || maxRunning.CompareAndSwap(max, n) {
+				break
+			}
+		}
+		run := started.Add(1)
+		time.Sleep(time.Millisecond)
+		completed.Store(run)
+		return nil
+	})
+
+	const callers = 100
+	var wg sync.WaitGroup
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			requestedAfter := started.Load()
+			require.NoError(t, c.Reload())
+			// the caller waited for a reload started after its request
+			require.Greater(t, completed.Load(), requestedAfter)
+		}()
+	}
+	wg.Wait()
+
+	require.Equal(t, int64(1), maxRunning.Load())
+	require.Less(t, started.Load(), int64(callers))
+
+	// the coordinator is idle again
+	require.NoError(t, c.Reload())
+	require.Equal(t, completed.Load(), started.Load())
+}
+
+func TestServerReload(t *testing.T) {
+	s := &Server{BackendGroups: map[string]*BackendGroup{"a": {}, "b": {}}}
+	s.routing.Store(&routingConfig{rpcMethodMappings: map[string]string{"eth_chainId": "a"}})
+	s.reloader = newReloadCoordinator(s.reload)
+
+	// each config maps every method of every domain to the same group
+	var version atomic.Int64
+	s.SetConfigSource(func() (*Config, error) {
+		group := "a"
+		if version.Add(1)%2 == 0 {
+			group = "b"
+		}
+		domains := make(map[string]map[string]string)
+		mappings := make(map[string]string)
+		for i := 0; i < 10; i++ {
+			mappings["method"+strconv.Itoa(i)] = group
+			domains["domain"+strconv.Itoa(i)+".example.com"] = map[string]string{"eth_chainId": group}
+		}
+		mappings["eth_chainId"] = group
+		return &Config{RPCMethodMappings: mappings, DomainRPCMethodMappings: domains}, nil
+	})
+
+	stop := make(chan struct{})
+	var readers sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		readers.Add(1)
+		go func() {
+			defer readers.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				routing := s.routing.Load()
+				group := routing.rpcMethodMappings["eth_chainId"]
+				for method, mapped := range routing.rpcMethodMappings {
+					require.Equal(t, group, mapped, method)
+				}
+				for domain, mappings := range routing.domainRPCMethodMappings {
+					require.Equal(t, group, mappings["eth_chainId"], domain)
+				}
+			}
+		}()
+	}
+
+	var reloads sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		reloads.Add(1)
+		go func() {
+			defer reloads.Done()
+			require.NoError(t, s.Reload())
+		}()
+	}
+	reloads.Wait()
+	close(stop)
+	readers.Wait()
+	require.Len(t, s.getRPCMethodMappings(""), 11)
+	require.Equal(t, s.getRPCMethodMappings("")["eth_chainId"], s.getRPCMethodMappings("domain1.example.com")["eth_chainId"])
+
+	t.Run("invalid configs are rejected", func(t *testing.T) {
+		before := s.routing.Load()
+		s.SetConfigSource(func() (*Config, error) {
+			return &Config{RPCMethodMappings: map[string]string{"eth_chainId": "missing"}}, nil
+		})
+		require.ErrorContains(t, s.Reload(), "undefined backend group missing")
+		s.SetConfigSource(func() (*Config, error) {
+			return &Config{
+				RPCMethodMappings:       map[string]string{"eth_chainId": "a"},
+				DomainRPCMethodMappings: map[string]map[string]string{"x.example.com": {"eth_chainId": "missing"}},
+			}, nil
+		})
+		require.ErrorContains(t, s.Reload(), "undefined backend group missing for domain x.example.com")
+		s.SetConfigSource(func() (*Config, error) {
+			return nil, errors.New("bad toml")
+		})
+		require.ErrorContains(t, s.Reload(), "bad toml")
+		require.Same(t, before, s.routing.Load())
+	})
+	t.Run("the exported config has the reloaded mappings", func(t *testing.T) {
+		s.config = &Config{RPCMethodMappings: map[string]string{"eth_chainId": "a"}}
+		s.SetConfigSource(func() (*Config, error) {
+			return &Config{
+				RPCMethodMappings:       map[string]string{"eth_chainId": "b"},
+				DomainRPCMethodMappings: map[string]map[string]string{"x.example.com": {"eth_chainId": "a"}},
+			}, nil
+		})
+		require.NoError(t, s.Reload())
+		exported, err := exportConfig(s.effectiveConfig())
+		require.NoError(t, err)
+		require.Equal(t, map[string]interface{}{"eth_chainId": "b"}, exported["rpc_method_mappings"])
+		require.Equal(t, map[string]interface{}{"x.example.com": map[string]interface{}{"eth_chainId": "a"}}, exported["domain_rpc_method_mappings"])
+		// the config the server was started with is left as is
+		require.Equal(t, "a", s.config.RPCMethodMappings["eth_chainId"])
+	})
+}
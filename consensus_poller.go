@@ -2,7 +2,9 @@ package proxyd
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"math"
 	"strconv"
 	"strings"
 	"sync"
@@ -14,10 +16,15 @@ import (
 
 const (
 	DefaultPollerInterval = 1 * time.Second
+
+	defaultMaxBanPeriod = 1 * time.Hour
 )
 
 type OnConsensusBroken func()
 
+// OnNewHead is called with the new consensus block whenever it changes.
+type OnNewHead func(blockNumber hexutil.Uint64)
+
 // ConsensusPoller checks the consensus state for each member of a BackendGroup
 // resolves the highest common block for multiple nodes, and reconciles the consensus
 // in case of block hash divergence to minimize re-orgs
@@ -25,6 +32,8 @@ type ConsensusPoller struct {
 	ctx        context.Context
 	cancelFunc context.CancelFunc
 	listeners  []OnConsensusBroken
+	// headListeners are notified when the consensus block changes
+	headListeners []OnNewHead
 
 	backendGroup      *BackendGroup
 	backendState      map[*Backend]*backendState
@@ -36,10 +45,24 @@ type ConsensusPoller struct {
 
 	minPeerCount       uint64
 	banPeriod          time.Duration
+	banBackoff         float64
+	maxBanPeriod       time.Duration
+	recoveryPolls      int
 	maxUpdateThreshold time.Duration
 	maxBlockLag        uint64
 	maxBlockRange      uint64
 	interval           time.Duration
+	headProbe          *headProbe
+	// maxHeadSkew bans backends whose head timestamp is further than this
+	// from local time, when set
+	maxHeadSkew time.Duration
+	// standbyHead is a trusted node outside of the group that the consensus
+	// head is taken from while the group has no consensus candidates
+	standbyHead *Backend
+	// quorumBackends and quorumFraction are the minimum number and fraction
+	// of the backends that must agree for the group to serve requests
+	quorumBackends int
+	quorumFraction float64
 }
 
 type backendState struct {
@@ -56,6 +79,10 @@ type backendState struct {
 	lastUpdate time.Time
 
 	bannedUntil time.Time
+	banCount    int
+	// recovering is set by a ban until the backend completes its recovery polls
+	recovering    bool
+	recoveredPoll int
 }
 
 func (bs *backendState) IsBanned() bool {
@@ -230,12 +257,39 @@ func (cp *ConsensusPoller) ClearListeners() {
 	cp.listeners = []OnConsensusBroken{}
 }
 
+func WithHeadListener(listener OnNewHead) ConsensusOpt {
+	return func(cp *ConsensusPoller) {
+		cp.AddHeadListener(listener)
+	}
+}
+
+func (cp *ConsensusPoller) AddHeadListener(listener OnNewHead) {
+	cp.headListeners = append(cp.headListeners, listener)
+}
+
 func WithBanPeriod(banPeriod time.Duration) ConsensusOpt {
 	return func(cp *ConsensusPoller) {
 		cp.banPeriod = banPeriod
 	}
 }
 
+// WithBanBackoff multiplies the ban period by multiplier for every repeated
+// ban, up to maxBanPeriod.
+func WithBanBackoff(multiplier float64, maxBanPeriod time.Duration) ConsensusOpt {
+	return func(cp *ConsensusPoller) {
+		cp.banBackoff = multiplier
+		cp.maxBanPeriod = maxBanPeriod
+	}
+}
+
+// WithRecoveryPolls requires a banned backend to complete polls consecutive
+// successful polls before it rejoins the consensus group.
+func WithRecoveryPolls(polls int) ConsensusOpt {
+	return func(cp *ConsensusPoller) {
+		cp.recoveryPolls = polls
+	}
+}
+
 func WithMaxUpdateThreshold(maxUpdateThreshold time.Duration) ConsensusOpt {
 	return func(cp *ConsensusPoller) {
 		cp.maxUpdateThreshold = maxUpdateThreshold
@@ -260,12 +314,61 @@ func WithMinPeerCount(minPeerCount uint64) ConsensusOpt {
 	}
 }
 
+// WithQuorum sets the minimum number and fraction of the backends that must
+// be in the consensus group for the group to serve requests. Below it, the
+// consensus group is empty.
+func WithQuorum(minBackends int, minFraction float64) ConsensusOpt {
+	return func(cp *ConsensusPoller) {
+		cp.quorumBackends = minBackends
+		cp.quorumFraction = minFraction
+	}
+}
+
 func WithPollerInterval(interval time.Duration) ConsensusOpt {
 	return func(cp *ConsensusPoller) {
 		cp.interval = interval
 	}
 }
 
+// headProbe describes a custom call used to find the latest block of a
+// backend. It doesn't apply to the safe and finalized blocks, which are
+// always fetched with eth_getBlockByNumber.
+type headProbe struct {
+	method     string
+	params     []interface{}
+	numberPath string
+	hashPath   string
+}
+
+func WithHeadProbe(method string, params []interface{}, numberPath string, hashPath string) ConsensusOpt {
+	return func(cp *ConsensusPoller) {
+		cp.headProbe = &headProbe{
+			method:     method,
+			params:     params,
+			numberPath: numberPath,
+			hashPath:   hashPath,
+		}
+	}
+}
+
+// WithMaxHeadSkew bans backends whose latest block timestamp is more than
+// maxHeadSkew behind or ahead of local time, such as stalled backends still
+// answering with a plausible head number, or backends with a skewed clock.
+func WithMaxHeadSkew(maxHeadSkew time.Duration) ConsensusOpt {
+	return func(cp *ConsensusPoller) {
+		cp.maxHeadSkew = maxHeadSkew
+	}
+}
+
+// WithStandbyHead makes the poller take the head of be while no backend of the
+// group is a consensus candidate, instead of dropping the head to 0. The
+// consensus group stays empty meanwhile.
+func WithStandbyHead(be *Backend) ConsensusOpt {
+	return func(cp *ConsensusPoller) {
+		cp.standbyHead = be
+	}
+}
+
 func NewConsensusPoller(bg *BackendGroup, opts ...ConsensusOpt) *ConsensusPoller {
 	ctx, cancelFunc := context.WithCancel(context.Background())
 
@@ -278,6 +381,7 @@ func NewConsensusPoller(bg *BackendGroup, opts ...ConsensusOpt) *ConsensusPoller
 		backendState: state,
 
 		banPeriod:          5 * time.Minute,
+		banBackoff:         1,
 		maxUpdateThreshold: 30 * time.Second,
 		maxBlockLag:        8, // 8*12 seconds = 96 seconds ~ 1.6 minutes
 		minPeerCount:       3,
@@ -292,6 +396,13 @@ func NewConsensusPoller(bg *BackendGroup, opts ...ConsensusOpt) *ConsensusPoller
 		cp.tracker = NewInMemoryConsensusTracker()
 	}
 
+	if cp.maxBanPeriod == 0 {
+		cp.maxBanPeriod = cp.banPeriod
+		if cp.banBackoff > 1 {
+			cp.maxBanPeriod = defaultMaxBanPeriod
+		}
+	}
+
 	if cp.asyncHandler == nil {
 		cp.asyncHandler = NewPollerAsyncHandler(ctx, cp)
 	}
@@ -319,6 +430,14 @@ func (cp *ConsensusPoller) UpdateBackend(ctx context.Context, be *Backend) {
 		return
 	}
 
+	// a recovering backend needs consecutive successful polls to rejoin
+	recovered := false
+	if bs.recovering {
+		defer func() {
+			cp.recordRecoveryPoll(be, recovered)
+		}()
+	}
+
 	inSync, err := cp.isInSync(ctx, be)
 	RecordConsensusBackendInSync(be, err == nil && inSync)
 	if err != nil {
@@ -341,7 +460,7 @@ func (cp *ConsensusPoller) UpdateBackend(ctx context.Context, be *Backend) {
 		RecordConsensusBackendPeerCount(be, peerCount)
 	}
 
-	latestBlockNumber, latestBlockHash, err := cp.fetchBlock(ctx, be, "latest")
+	latestBlockNumber, latestBlockHash, err := cp.fetchHead(ctx, be)
 	if err != nil {
 		log.Warn("error updating backend - latest block will not be updated", "name", be.Name, "err", err)
 		return
@@ -352,6 +471,26 @@ func (cp *ConsensusPoller) UpdateBackend(ctx context.Context, be *Backend) {
 		return
 	}
 
+	if cp.maxHeadSkew > 0 {
+		timestamp, err := cp.fetchBlockTimestamp(ctx, be, latestBlockNumber)
+		if err != nil {
+			log.Warn("error updating backend - head timestamp unavailable", "name", be.Name, "err", err)
+			return
+		}
+		skew := time.Since(timestamp)
+		RecordBackendHeadSkew(be, skew)
+		if (skew > cp.maxHeadSkew || -skew > cp.maxHeadSkew) && !be.forcedCandidate {
+			log.Warn("backend banned - head timestamp skewed",
+				"backend", be.Name,
+				"latestBlockNumber", latestBlockNumber,
+				"headTimestamp", timestamp,
+				"skew", skew,
+			)
+			cp.Ban(be)
+			return
+		}
+	}
+
 	safeBlockNumber, _, err := cp.fetchBlock(ctx, be, "safe")
 	if err != nil {
 		log.Warn("error updating backend - safe block will not be updated", "name", be.Name, "err", err)
@@ -416,6 +555,29 @@ func (cp *ConsensusPoller) UpdateBackend(ctx context.Context, be *Backend) {
 			"latestBlockNumber", latestBlockNumber,
 		)
 		cp.Ban(be)
+		return
+	}
+
+	recovered = inSync && uint64(latestBlockNumber)+cp.maxBlockLag >= uint64(cp.GetLatestBlockNumber())
+}
+
+// recordRecoveryPoll counts consecutive successful polls of a recovering
+// backend, and readmits it once it reaches the required number.
+func (cp *ConsensusPoller) recordRecoveryPoll(be *Backend, success bool) {
+	bs := cp.backendState[be]
+	defer bs.backendStateMux.Unlock()
+	bs.backendStateMux.Lock()
+	if !bs.recovering {
+		return
+	}
+	if !success {
+		bs.recoveredPoll = 0
+		return
+	}
+	bs.recoveredPoll++
+	if bs.recoveredPoll >= cp.recoveryPolls {
+		bs.recovering = false
+		bs.recoveredPoll = 0
 	}
 }
 
@@ -461,6 +623,21 @@ func (cp *ConsensusPoller) UpdateBackendGroupConsensus(ctx context.Context) {
 		}
 	}
 
+	// without candidates, fall back to the standby head source if any
+	degraded := false
+	if len(candidates) == 0 && cp.standbyHead != nil {
+		latest, safe, finalized, err := cp.fetchStandbyHead(ctx)
+		if err != nil {
+			log.Warn("error fetching standby head", "backend_group", cp.backendGroup.Name, "err", err)
+		} else {
+			log.Warn("no consensus candidates, using standby head",
+				"backend_group", cp.backendGroup.Name,
+				"latestBlock", latest)
+			lowestLatestBlock, lowestSafeBlock, lowestFinalizedBlock = latest, safe, finalized
+			degraded = true
+		}
+	}
+
 	// find the proposed block among the candidates
 	// the proposed block needs have the same hash in the entire consensus group
 	proposedBlock := lowestLatestBlock
@@ -527,6 +704,12 @@ func (cp *ConsensusPoller) UpdateBackendGroupConsensus(ctx context.Context) {
 	cp.tracker.SetSafeBlockNumber(lowestSafeBlock)
 	cp.tracker.SetFinalizedBlockNumber(lowestFinalizedBlock)
 
+	if proposedBlock != currentConsensusBlockNumber {
+		for _, l := range cp.headListeners {
+			l(proposedBlock)
+		}
+	}
+
 	// update consensus group
 	group := make([]*Backend, 0, len(candidates))
 	consensusBackendsNames := make([]string, 0, len(candidates))
@@ -541,6 +724,17 @@ func (cp *ConsensusPoller) UpdateBackendGroupConsensus(ctx context.Context) {
 		}
 	}
 
+	// below quorum the backends in agreement aren't trusted to serve
+	quorum := cp.quorum()
+	hasQuorum := len(group) >= quorum
+	if !hasQuorum {
+		log.Warn("consensus group below quorum",
+			"backend_group", cp.backendGroup.Name,
+			"consensusBackends", strings.Join(consensusBackendsNames, ", "),
+			"quorum", quorum)
+		group = group[:0]
+	}
+
 	cp.consensusGroupMux.Lock()
 	cp.consensusGroup = group
 	cp.consensusGroupMux.Unlock()
@@ -548,6 +742,8 @@ func (cp *ConsensusPoller) UpdateBackendGroupConsensus(ctx context.Context) {
 	RecordGroupConsensusLatestBlock(cp.backendGroup, proposedBlock)
 	RecordGroupConsensusSafeBlock(cp.backendGroup, lowestSafeBlock)
 	RecordGroupConsensusFinalizedBlock(cp.backendGroup, lowestFinalizedBlock)
+	RecordGroupConsensusStandbyHead(cp.backendGroup, degraded)
+	RecordGroupConsensusQuorum(cp.backendGroup, hasQuorum)
 
 	RecordGroupConsensusCount(cp.backendGroup, len(group))
 	RecordGroupConsensusFilteredCount(cp.backendGroup, len(filteredBackendsNames))
@@ -559,6 +755,15 @@ func (cp *ConsensusPoller) UpdateBackendGroupConsensus(ctx context.Context) {
 		"filteredBackends", strings.Join(filteredBackendsNames, ", "))
 }
 
+// quorum returns how many backends the consensus group needs to serve.
+func (cp *ConsensusPoller) quorum() int {
+	quorum := cp.quorumBackends
+	if n := int(math.Ceil(cp.quorumFraction * float64(len(cp.backendGroup.Backends)))); n > quorum {
+		quorum = n
+	}
+	return quorum
+}
+
 // IsBanned checks if a specific backend is banned
 func (cp *ConsensusPoller) IsBanned(be *Backend) bool {
 	bs := cp.backendState[be]
@@ -584,7 +789,18 @@ func (cp *ConsensusPoller) Ban(be *Backend) {
 	bs := cp.backendState[be]
 	defer bs.backendStateMux.Unlock()
 	bs.backendStateMux.Lock()
-	bs.bannedUntil = time.Now().Add(cp.banPeriod)
+
+	now := time.Now()
+	// a backend that stayed unbanned long enough starts over
+	if bs.banCount > 0 && now.After(bs.bannedUntil.Add(cp.maxBanPeriod)) {
+		bs.banCount = 0
+	}
+	period := cp.nextBanPeriod(bs.banCount)
+	bs.banCount++
+	bs.bannedUntil = now.Add(period)
+	bs.recovering = cp.recoveryPolls > 0
+	bs.recoveredPoll = 0
+	RecordConsensusBackendBanBackoff(be, bs.banCount, period)
 
 	// when we ban a node, we give it the chance to start from any block when it is back
 	bs.latestBlockNumber = 0
@@ -592,12 +808,34 @@ func (cp *ConsensusPoller) Ban(be *Backend) {
 	bs.finalizedBlockNumber = 0
 }
 
+// nextBanPeriod is the ban period after banCount previous bans
+func (cp *ConsensusPoller) nextBanPeriod(banCount int) time.Duration {
+	period := float64(cp.banPeriod)
+	for i := 0; i < banCount && period < float64(cp.maxBanPeriod); i++ {
+		period *= cp.banBackoff
+	}
+	if cp.banBackoff > 1 && period > float64(cp.maxBanPeriod) {
+		period = float64(cp.maxBanPeriod)
+	}
+	return time.Duration(period)
+}
+
+// BanCount returns the number of bans counting towards the backoff of a backend
+func (cp *ConsensusPoller) BanCount(be *Backend) int {
+	bs := cp.backendState[be]
+	defer bs.backendStateMux.Unlock()
+	bs.backendStateMux.Lock()
+	return bs.banCount
+}
+
 // Unban removes any bans from the backends
 func (cp *ConsensusPoller) Unban(be *Backend) {
 	bs := cp.backendState[be]
 	defer bs.backendStateMux.Unlock()
 	bs.backendStateMux.Lock()
 	bs.bannedUntil = time.Now().Add(-10 * time.Hour)
+	bs.recovering = false
+	bs.recoveredPoll = 0
 }
 
 // Reset reset all backend states
@@ -625,6 +863,107 @@ func (cp *ConsensusPoller) fetchBlock(ctx context.Context, be *Backend, block st
 	return
 }
 
+// fetchBlockTimestamp retrieves the timestamp of a block from the backend.
+func (cp *ConsensusPoller) fetchBlockTimestamp(ctx context.Context, be *Backend, blockNumber hexutil.Uint64) (time.Time, error) {
+	var rpcRes RPCRes
+	err := be.ForwardRPC(ctx, &rpcRes, "67", "eth_getBlockByNumber", blockNumber.String(), false)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	jsonMap, ok := rpcRes.Result.(map[string]interface{})
+	if !ok {
+		return time.Time{}, fmt.Errorf("unexpected response to eth_getBlockByNumber on backend %s", be.Name)
+	}
+	encoded, _ := jsonMap["timestamp"].(string)
+	timestamp, err := hexutil.DecodeUint64(encoded)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid block timestamp on backend %s: %w", be.Name, err)
+	}
+	return time.Unix(int64(timestamp), 0), nil
+}
+
+// fetchHead retrieves the latest block of the backend, using the configured
+// head probe if there is one. Only the latest block is probed this way.
+func (cp *ConsensusPoller) fetchHead(ctx context.Context, be *Backend) (blockNumber hexutil.Uint64, blockHash string, err error) {
+	if cp.headProbe == nil {
+		return cp.fetchBlock(ctx, be, "latest")
+	}
+
+	var rpcRes RPCRes
+	err = be.ForwardRPC(ctx, &rpcRes, "67", cp.headProbe.method, cp.headProbe.params...)
+	if err != nil {
+		return 0, "", err
+	}
+
+	number, ok := lookupJSONPath(rpcRes.Result, cp.headProbe.numberPath)
+	if !ok {
+		return 0, "", fmt.Errorf("no head number at %q in response to %s on backend %s", cp.headProbe.numberPath, cp.headProbe.method, be.Name)
+	}
+	switch n := number.(type) {
+	case string:
+		decoded, err := hexutil.DecodeUint64(n)
+		if err != nil {
+			return 0, "", fmt.Errorf("invalid head number in response to %s on backend %s: %w", cp.headProbe.method, be.Name, err)
+		}
+		blockNumber = hexutil.Uint64(decoded)
+	case json.Number:
+		decoded, err := strconv.ParseUint(n.String(), 10, 64)
+		if err != nil {
+			return 0, "", fmt.Errorf("invalid head number in response to %s on backend %s: %w", cp.headProbe.method, be.Name, err)
+		}
+		blockNumber = hexutil.Uint64(decoded)
+	default:
+		return 0, "", fmt.Errorf("unexpected head number type in response to %s on backend %s", cp.headProbe.method, be.Name)
+	}
+
+	if cp.headProbe.hashPath != "" {
+		hash, _ := lookupJSONPath(rpcRes.Result, cp.headProbe.hashPath)
+		blockHash, ok = hash.(string)
+		if !ok {
+			return 0, "", fmt.Errorf("no head hash at %q in response to %s on backend %s", cp.headProbe.hashPath, cp.headProbe.method, be.Name)
+		}
+	}
+
+	return
+}
+
+// fetchStandbyHead retrieves the latest, safe and finalized blocks of the
+// standby head source.
+func (cp *ConsensusPoller) fetchStandbyHead(ctx context.Context) (latest, safe, finalized hexutil.Uint64, err error) {
+	latest, _, err = cp.fetchBlock(ctx, cp.standbyHead, "latest")
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	safe, _, err = cp.fetchBlock(ctx, cp.standbyHead, "safe")
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	finalized, _, err = cp.fetchBlock(ctx, cp.standbyHead, "finalized")
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	return
+}
+
+// lookupJSONPath walks a decoded JSON value along a dot-separated path of
+// object keys. An empty path returns the value itself.
+func lookupJSONPath(v interface{}, path string) (interface{}, bool) {
+	if path == "" {
+		return v, v != nil
+	}
+	for _, key := range strings.Split(path, ".") {
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if v, ok = obj[key]; !ok {
+			return nil, false
+		}
+	}
+	return v, v != nil
+}
+
 // getPeerCount is a convenient wrapper to retrieve the current peer count from the backend
 func (cp *ConsensusPoller) getPeerCount(ctx context.Context, be *Backend) (count uint64, err error) {
 	var rpcRes RPCRes
@@ -685,6 +1024,9 @@ func (cp *ConsensusPoller) GetBackendState(be *Backend) *backendState {
 		inSync:               bs.inSync,
 		lastUpdate:           bs.lastUpdate,
 		bannedUntil:          bs.bannedUntil,
+		banCount:             bs.banCount,
+		recovering:           bs.recovering,
+		recoveredPoll:        bs.recoveredPoll,
 	}
 }
 
@@ -751,6 +1093,9 @@ func (cp *ConsensusPoller) FilterCandidates(backends []*Backend) map[*Backend]*b
 		if bs.IsBanned() {
 			continue
 		}
+		if bs.recovering {
+			continue
+		}
 		if !be.IsHealthy() {
 			continue
 		}
@@ -0,0 +1,156 @@
+package proxyd
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+)
+
+const (
+	DeadLetterDestinationStdout = "stdout"
+	DeadLetterDestinationStderr = "stderr"
+)
+
+// DeadLetterLog writes requests that failed after every backend was tried as
+// JSON lines. Params are only recorded as a hash so that raw transactions and
+// other sensitive payloads never reach the log.
+type DeadLetterLog struct {
+	mu           sync.Mutex
+	w            io.Writer
+	closer       io.Closer
+	sampleRate   float64
+	maxPerSecond int
+	window       time.Time
+	written      int
+}
+
+type DeadLetterEntry struct {
+	Time       time.Time           `json:"time"`
+	ReqID      string              `json:"req_id"`
+	Method     string              `json:"method"`
+	ParamsHash string              `json:"params_hash"`
+	Error      *RPCErr             `json:"error"`
+	Attempts   []DeadLetterAttempt `json:"attempts"`
+}
+
+type DeadLetterAttempt struct {
+	Backend string `json:"backend"`
+	Error   string `json:"error,omitempty"`
+}
+
+func NewDeadLetterLog(cfg DeadLetterConfig) (*DeadLetterLog, error) {
+	dl := &DeadLetterLog{
+		sampleRate:   1,
+		maxPerSecond: cfg.MaxPerSecond,
+	}
+	if cfg.SampleRate != 0 {
+		if cfg.SampleRate < 0 || cfg.SampleRate > 1 {
+			return nil, fmt.Errorf("dead_letter sample_rate must be between 0 and 1")
+		}
+		dl.sampleRate = cfg.SampleRate
+	}
+
+	switch cfg.Destination {
+	case "", DeadLetterDestinationStdout:
+		dl.w = os.Stdout
+	case DeadLetterDestinationStderr:
+		dl.w = os.Stderr
+	default:
+		f, err := os.OpenFile(cfg.Destination, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+		if err != nil {
+			return nil, fmt.Errorf("error opening dead letter log: %w", err)
+		}
+		dl.w = f
+		dl.closer = f
+	}
+	return dl, nil
+}
+
+// Record writes an entry for a failed request unless it is sampled out or
+// over the rate limit.
+func (dl *DeadLetterLog) Record(ctx context.Context, req *RPCReq, res *RPCRes, attempts []DeadLetterAttempt) {
+	if dl.sampleRate < 1 && rand.Float64() >= dl.sampleRate {
+		return
+	}
+
+	sum := sha256.Sum256(req.Params)
+	entry := DeadLetterEntry{
+		Time:       time.Now(),
+		ReqID:      GetReqID(ctx),
+		Method:     req.Method,
+		ParamsHash: fmt.Sprintf("%x", sum),
+		Error: &RPCErr{
+			Code:    res.Error.Code,
+			Message: truncate(res.Error.Message, maxRequestBodyLogLen),
+		},
+		Attempts: attempts,
+	}
+	line, err := json.Marshal(entry)
+	if err != nil {
+		log.Error("error marshalling dead letter entry", "req_id", GetReqID(ctx), "err", err)
+		return
+	}
+
+	dl.mu.Lock()
+	defer dl.mu.Unlock()
+	if dl.maxPerSecond > 0 {
+		now := time.Now()
+		if now.Sub(dl.window) >= time.Second {
+			dl.window = now
+			dl.written = 0
+		}
+		if dl.written >= dl.maxPerSecond {
+			return
+		}
+		dl.written++
+	}
+	if _, err := dl.w.Write(append(line, '\n')); err != nil {
+		log.Error("error writing dead letter entry", "req_id", GetReqID(ctx), "err", err)
+	}
+}
+
+func (dl *DeadLetterLog) Close() error {
+	if dl.closer == nil {
+		return nil
+	}
+	return dl.closer.Close()
+}
+
+// backendAttempts collects the backends tried while forwarding a request.
+type backendAttempts struct {
+	mu       sync.Mutex
+	attempts []DeadLetterAttempt
+}
+
+func withBackendAttempts(ctx context.Context) (context.Context, *backendAttempts) {
+	ba := &backendAttempts{}
+	return context.WithValue(ctx, ContextKeyBackendAttempts, ba), ba // nolint:staticcheck
+}
+
+func recordBackendAttempt(ctx context.Context, backend string, err error) {
+	ba, ok := ctx.Value(ContextKeyBackendAttempts).(*backendAttempts)
+	if !ok {
+		return
+	}
+	attempt := DeadLetterAttempt{Backend: backend}
+	if err != nil {
+		attempt.Error = err.Error()
+	}
+	ba.mu.Lock()
+	ba.attempts = append(ba.attempts, attempt)
+	ba.mu.Unlock()
+}
+
+func (ba *backendAttempts) list() []DeadLetterAttempt {
+	ba.mu.Lock()
+	defer ba.mu.Unlock()
+	return append([]DeadLetterAttempt(nil), ba.attempts...)
+}
@@ -0,0 +1,142 @@
+package proxyd
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+)
+
+const defaultDriftSampleInterval = time.Minute
+
+// DriftSampler periodically sends the same read to every backend of a group
+// and reports the backends whose results diverge from the others. It catches
+// backends silently serving wrong data, independently of consensus.
+type DriftSampler struct {
+	bg       *BackendGroup
+	method   string
+	params   []interface{}
+	interval time.Duration
+
+	mu        sync.Mutex
+	divergent []string
+
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+func NewDriftSampler(bg *BackendGroup, method string, params []interface{}, interval time.Duration) *DriftSampler {
+	if interval == 0 {
+		interval = defaultDriftSampleInterval
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &DriftSampler{
+		bg:       bg,
+		method:   method,
+		params:   params,
+		interval: interval,
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+}
+
+func (s *DriftSampler) Start() {
+	go func() {
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				s.Sample(s.ctx)
+			case <-s.ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+func (s *DriftSampler) Shutdown() {
+	s.cancel()
+}
+
+// Sample sends the read to every backend and records which of them diverge.
+// Backends that fail to answer are left out of the comparison. A backend
+// diverges if its result differs from the result shared by a strict majority
+// of the answering backends, or if there is no such majority while results
+// differ.
+func (s *DriftSampler) Sample(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, s.interval)
+	defer cancel()
+
+	results := make(map[string]string, len(s.bg.Backends))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, be := range s.bg.Backends {
+		wg.Add(1)
+		go func(be *Backend) {
+			defer wg.Done()
+			var res RPCRes
+			if err := be.ForwardRPC(ctx, &res, "1", s.method, s.params...); err != nil {
+				log.Warn("error sampling backend for drift",
+					"backend_group", s.bg.Name,
+					"backend_name", be.Name,
+					"method", s.method,
+					"err", err,
+				)
+				return
+			}
+			result, err := json.Marshal(res.Result)
+			if err != nil {
+				return
+			}
+			mu.Lock()
+			results[be.Name] = string(result)
+			mu.Unlock()
+		}(be)
+	}
+	wg.Wait()
+
+	counts := make(map[string]int)
+	for _, result := range results {
+		counts[result]++
+	}
+	var majority string
+	for result, count := range counts {
+		if count*2 > len(results) {
+			majority = result
+		}
+	}
+
+	var divergent []string
+	for _, be := range s.bg.Backends {
+		result, ok := results[be.Name]
+		diverged := ok && len(counts) > 1 && result != majority
+		if diverged {
+			divergent = append(divergent, be.Name)
+		}
+		RecordBackendDrift(s.bg, be, diverged)
+	}
+	RecordDriftSample(s.bg, len(divergent) > 0)
+	if len(divergent) > 0 {
+		log.Warn("backend responses diverged",
+			"backend_group", s.bg.Name,
+			"method", s.method,
+			"divergent_backends", divergent,
+			"results", results,
+		)
+	}
+
+	s.mu.Lock()
+	s.divergent = divergent
+	s.mu.Unlock()
+}
+
+// DivergentBackends returns the names of the backends that diverged in the
+// last sample.
+func (s *DriftSampler) DivergentBackends() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.divergent
+}
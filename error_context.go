@@ -0,0 +1,34 @@
+package proxyd
+
+// ErrorContext is the data of errors returned to trusted clients after proxyd
+// failed to forward a call, to help them debug integrations.
+type ErrorContext struct {
+	BackendGroup string              `json:"backend_group"`
+	Attempts     []DeadLetterAttempt `json:"attempts"`
+	LastError    string              `json:"last_error,omitempty"`
+}
+
+// withErrorContext returns res with an ErrorContext as the data of its error,
+// unless it has data already.
+func withErrorContext(res *RPCRes, backendGroup string, attempts []DeadLetterAttempt) *RPCRes {
+	if res.Error == nil || res.Error.Data != nil {
+		return res
+	}
+	ec := &ErrorContext{
+		BackendGroup: backendGroup,
+		Attempts:     attempts,
+	}
+	for i := len(attempts) - 1; i >= 0; i-- {
+		if attempts[i].Error != "" {
+			ec.LastError = attempts[i].Error
+			break
+		}
+	}
+
+	// errors are shared, so copy them before setting their data
+	rpcErr := *res.Error
+	rpcErr.Data = ec
+	withContext := *res
+	withContext.Error = &rpcErr
+	return &withContext
+}
@@ -0,0 +1,72 @@
+package proxyd
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// errorMapper rewrites the errors of backend responses with the first
+// matching mapping. The data of rewritten errors is dropped.
+type errorMapper []errorMapping
+
+type errorMapping struct {
+	code           int
+	message        *regexp.Regexp
+	replaceCode    int
+	replaceMessage string
+}
+
+func newErrorMapper(config []ErrorMappingConfig) (errorMapper, error) {
+	mapper := make(errorMapper, 0, len(config))
+	for i, m := range config {
+		if m.ReplaceCode == 0 && m.ReplaceMessage == "" {
+			return nil, fmt.Errorf("error mapping %d must set replace_code or replace_message", i)
+		}
+		message, err := regexp.Compile(m.Message)
+		if err != nil {
+			return nil, fmt.Errorf("invalid message in error mapping %d: %w", i, err)
+		}
+		mapper = append(mapper, errorMapping{
+			code:           m.Code,
+			message:        message,
+			replaceCode:    m.ReplaceCode,
+			replaceMessage: m.ReplaceMessage,
+		})
+	}
+	return mapper, nil
+}
+
+// Map returns res with its error rewritten, or res itself if no mapping
+// matches. res is never modified, as errors may be shared.
+func (m errorMapper) Map(res *RPCRes) *RPCRes {
+	if res == nil || res.Error == nil {
+		return res
+	}
+	for _, mapping := range m {
+		if mapping.code != 0 && mapping.code != res.Error.Code {
+			continue
+		}
+		match := mapping.message.FindStringSubmatchIndex(res.Error.Message)
+		if match == nil {
+			continue
+		}
+
+		rpcErr := &RPCErr{
+			Code:          res.Error.Code,
+			Message:       res.Error.Message,
+			HTTPErrorCode: res.Error.HTTPErrorCode,
+		}
+		if mapping.replaceCode != 0 {
+			rpcErr.Code = mapping.replaceCode
+		}
+		if mapping.replaceMessage != "" {
+			rpcErr.Message = string(mapping.message.ExpandString(nil, mapping.replaceMessage, res.Error.Message, match))
+		}
+		RecordErrorMapped(res.Error.Code)
+
+		mapped := *res
+		mapped.Error = rpcErr
+		return &mapped
+	}
+	return res
+}
@@ -0,0 +1,88 @@
+package proxyd
+
+import (
+	"fmt"
+)
+
+// errorCategories are the categories of JSON-RPC error codes that HTTP
+// statuses can be mapped from.
+var errorCategories = map[string]bool{
+	"parse_error":       true,
+	"invalid_request":   true,
+	"method_not_found":  true,
+	"invalid_params":    true,
+	"internal_error":    true,
+	"server_error":      true,
+	"application_error": true,
+}
+
+// errorCategory returns the category of a JSON-RPC error code: one of the
+// errors reserved by the spec, a server error in -32099..-32000, or an
+// application error for any other code, such as reverted executions.
+func errorCategory(code int) string {
+	switch {
+	case code == -32700:
+		return "parse_error"
+	case code == -32600:
+		return "invalid_request"
+	case code == -32601:
+		return "method_not_found"
+	case code == -32602:
+		return "invalid_params"
+	case code == -32603:
+		return "internal_error"
+	case code >= -32099 && code <= -32000:
+		return "server_error"
+	default:
+		return "application_error"
+	}
+}
+
+// errorStatus chooses the HTTP status of responses to single calls that
+// fail with a JSON-RPC error.
+type errorStatus struct {
+	always200  bool
+	categories map[string]int
+}
+
+func newErrorStatus(config ErrorStatusConfig) (*errorStatus, error) {
+	switch config.Mode {
+	case "", ErrorStatusModeMapped:
+	case ErrorStatusModeAlways200:
+		if len(config.Categories) > 0 {
+			return nil, fmt.Errorf("error_status categories can't be set with mode %s", ErrorStatusModeAlways200)
+		}
+	default:
+		return nil, fmt.Errorf("invalid error_status mode: %s", config.Mode)
+	}
+	for category, status := range config.Categories {
+		if !errorCategories[category] {
+			return nil, fmt.Errorf("unknown error_status category: %s", category)
+		}
+		if status < 400 || status > 599 {
+			return nil, fmt.Errorf("http status of error_status category %s must be between 400 and 599", category)
+		}
+	}
+	return &errorStatus{
+		always200:  config.Mode == ErrorStatusModeAlways200,
+		categories: config.Categories,
+	}, nil
+}
+
+// status returns the HTTP status of a response failing with rpcErr. Errors
+// proxyd answers with carry their own status, which wins over the status of
+// their category.
+func (e *errorStatus) status(rpcErr *RPCErr) int {
+	if e != nil && e.always200 {
+		return 200
+	}
+	if rpcErr.HTTPErrorCode != 0 {
+		return rpcErr.HTTPErrorCode
+	}
+	if e != nil {
+		if status, ok := e.categories[errorCategory(rpcErr.Code)]; ok {
+			return status
+		}
+	}
+	return 200
+}
@@ -6,6 +6,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/ethereum/go-ethereum/log"
 	"github.com/redis/go-redis/v9"
 )
 
@@ -36,16 +37,47 @@ func newLimitedKeys(t int64) *limitedKeys {
 	}
 }
 
-func (l *limitedKeys) Take(key string, max int) bool {
+// Take increments the usage of key and returns it.
+func (l *limitedKeys) Take(key string) int {
 	l.mtx.Lock()
 	defer l.mtx.Unlock()
-	val, ok := l.keys[key]
-	if !ok {
-		l.keys[key] = 0
-		val = 0
+	l.keys[key]++
+	return l.keys[key]
+}
+
+// limiterUsage exports how close a rate limiter is to its limit. Only the
+// busiest key of each window is tracked, so that the metrics don't grow with
+// the number of keys, such as client IPs.
+type limiterUsage struct {
+	name    string
+	max     int
+	truncTS int64
+	busiest int64
+	mtx     sync.Mutex
+}
+
+func (u *limiterUsage) observe(truncTS int64, used int64) {
+	allowed := used <= int64(u.max)
+	RecordFrontendRateLimitTake(u.name, allowed)
+	if !allowed {
+		log.Debug("frontend rate limit exceeded", "limiter", u.name, "used", used, "max", u.max)
 	}
-	l.keys[key] = val + 1
-	return val < max
+
+	u.mtx.Lock()
+	defer u.mtx.Unlock()
+	if u.truncTS != truncTS {
+		u.truncTS = truncTS
+		u.busiest = 0
+	}
+	if used <= u.busiest {
+		return
+	}
+	u.busiest = used
+	available := int64(u.max) - used
+	if available < 0 {
+		available = 0
+	}
+	RecordFrontendRateLimitAvailableTokens(u.name, available)
 }
 
 // MemoryFrontendRateLimiter is a rate limiter that stores
@@ -60,13 +92,15 @@ type MemoryFrontendRateLimiter struct {
 	currGeneration *limitedKeys
 	dur            time.Duration
 	max            int
+	usage          *limiterUsage
 	mtx            sync.Mutex
 }
 
-func NewMemoryFrontendRateLimit(dur time.Duration, max int) FrontendRateLimiter {
+func NewMemoryFrontendRateLimit(dur time.Duration, max int, prefix string) FrontendRateLimiter {
 	return &MemoryFrontendRateLimiter{
-		dur: dur,
-		max: max,
+		dur:   dur,
+		max:   max,
+		usage: &limiterUsage{name: prefix, max: max},
 	}
 }
 
@@ -86,25 +120,31 @@ func (m *MemoryFrontendRateLimiter) Take(ctx context.Context, key string) (bool,
 
 	m.mtx.Unlock()
 
-	return limiter.Take(key, m.max), nil
+	used := limiter.Take(key)
+	m.usage.observe(truncTS, int64(used))
+	return used <= m.max, nil
 }
 
 // RedisFrontendRateLimiter is a rate limiter that stores data in Redis.
 // It uses the basic rate limiter pattern described on the Redis best
 // practices website: https://redis.com/redis-best-practices/basic-rate-limiting/.
 type RedisFrontendRateLimiter struct {
-	r      redis.UniversalClient
-	dur    time.Duration
-	max    int
-	prefix string
+	r         redis.UniversalClient
+	keyPrefix string
+	dur       time.Duration
+	max       int
+	prefix    string
+	usage     *limiterUsage
 }
 
-func NewRedisFrontendRateLimiter(r redis.UniversalClient, dur time.Duration, max int, prefix string) FrontendRateLimiter {
+func NewRedisFrontendRateLimiter(r redis.UniversalClient, keyPrefix string, dur time.Duration, max int, prefix string) FrontendRateLimiter {
 	return &RedisFrontendRateLimiter{
-		r:      r,
-		dur:    dur,
-		max:    max,
-		prefix: prefix,
+		r:         r,
+		keyPrefix: keyPrefix,
+		dur:       dur,
+		max:       max,
+		prefix:    prefix,
+		usage:     &limiterUsage{name: prefix, max: max},
 	}
 }
 
@@ -112,17 +152,21 @@ func (r *RedisFrontendRateLimiter) Take(ctx context.Context, key string) (bool,
 	var incr *redis.IntCmd
 	truncTS := truncateNow(r.dur)
 	fullKey := fmt.Sprintf("rate_limit:%s:%s:%d", r.prefix, key, truncTS)
+	if r.keyPrefix != "" {
+		fullKey = r.keyPrefix + ":" + fullKey
+	}
 	_, err := r.r.Pipelined(ctx, func(pipe redis.Pipeliner) error {
 		incr = pipe.Incr(ctx, fullKey)
 		pipe.PExpire(ctx, fullKey, r.dur-time.Millisecond)
 		return nil
 	})
 	if err != nil {
-		frontendRateLimitTakeErrors.Inc()
+		RecordFrontendRateLimitTakeError(r.prefix)
 		return false, err
 	}
 
-	return incr.Val()-1 < int64(r.max), nil
+	r.usage.observe(truncTS, incr.Val())
+	return incr.Val() <= int64(r.max), nil
 }
 
 type noopFrontendRateLimiter struct{}
@@ -157,6 +201,7 @@ func NewFallbackRateLimiter(primary FrontendRateLimiter, secondary FrontendRateL
 
 func (r *FallbackRateLimiter) Take(ctx context.Context, key string) (bool, error) {
 	if ok, err := r.primary.Take(ctx, key); err != nil {
+		log.Debug("falling back to the secondary rate limiter", "err", err)
 		return r.secondary.Take(ctx, key)
 	} else {
 		return ok, err
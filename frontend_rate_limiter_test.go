@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/alicebob/miniredis"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/redis/go-redis/v9"
 	"github.com/stretchr/testify/require"
 )
@@ -25,9 +26,9 @@ func TestFrontendRateLimiter(t *testing.T) {
 		name string
 		frl  FrontendRateLimiter
 	}{
-		{"memory", NewMemoryFrontendRateLimit(2*time.Second, max)},
-		{"redis", NewRedisFrontendRateLimiter(redisClient, 2*time.Second, max, "")},
-		{"fallback", NewFallbackRateLimiter(NewMemoryFrontendRateLimit(2*time.Second, max), NewRedisFrontendRateLimiter(redisClient, 2*time.Second, max, ""))},
+		{"memory", NewMemoryFrontendRateLimit(2*time.Second, max, "")},
+		{"redis", NewRedisFrontendRateLimiter(redisClient, "", 2*time.Second, max, "")},
+		{"fallback", NewFallbackRateLimiter(NewMemoryFrontendRateLimit(2*time.Second, max, ""), NewRedisFrontendRateLimiter(redisClient, "", 2*time.Second, max, ""))},
 	}
 
 	for _, cfg := range lims {
@@ -53,6 +54,59 @@ func TestFrontendRateLimiter(t *testing.T) {
 	}
 }
 
+func TestFrontendRateLimiterMetrics(t *testing.T) {
+	redisServer, err := miniredis.Run()
+	require.NoError(t, err)
+	defer redisServer.Close()
+
+	redisClient := redis.NewClient(&redis.Options{
+		Addr: fmt.Sprintf("127.0.0.1:%s", redisServer.Port()),
+	})
+
+	max := 3
+	lims := []struct {
+		name string
+		frl  FrontendRateLimiter
+	}{
+		{"metrics_memory", NewMemoryFrontendRateLimit(time.Hour, max, "metrics_memory")},
+		{"metrics_redis", NewRedisFrontendRateLimiter(redisClient, "", time.Hour, max, "metrics_redis")},
+	}
+
+	ctx := context.Background()
+	for _, cfg := range lims {
+		t.Run(cfg.name, func(t *testing.T) {
+			allowed := frontendRateLimitTakesTotal.WithLabelValues(cfg.name, "true")
+			rejected := frontendRateLimitTakesTotal.WithLabelValues(cfg.name, "false")
+			available := frontendRateLimitAvailableTokens.WithLabelValues(cfg.name)
+
+			_, err := cfg.frl.Take(ctx, "foo")
+			require.NoError(t, err)
+			require.Equal(t, float64(1), testutil.ToFloat64(allowed))
+			require.Equal(t, float64(0), testutil.ToFloat64(rejected))
+			require.Equal(t, float64(2), testutil.ToFloat64(available))
+
+			// tokens are tracked for the busiest key
+			for i := 0; i < 4; i++ {
+				_, err := cfg.frl.Take(ctx, "bar")
+				require.NoError(t, err)
+			}
+			_, err = cfg.frl.Take(ctx, "foo")
+			require.NoError(t, err)
+			require.Equal(t, float64(5), testutil.ToFloat64(allowed))
+			require.Equal(t, float64(1), testutil.ToFloat64(rejected))
+			require.Equal(t, float64(0), testutil.ToFloat64(available))
+		})
+	}
+
+	t.Run("redis errors", func(t *testing.T) {
+		frl := NewRedisFrontendRateLimiter(redisClient, "", time.Hour, max, "metrics_redis_errors")
+		redisServer.Close()
+		_, err := frl.Take(ctx, "foo")
+		require.Error(t, err)
+		require.Equal(t, float64(1), testutil.ToFloat64(frontendRateLimitTakeErrors.WithLabelValues("metrics_redis_errors")))
+	})
+}
+
 type errorFrontend struct{}
 
 func (e *errorFrontend) Take(ctx context.Context, key string) (bool, error) {
@@ -0,0 +1,111 @@
+package proxyd
+
+import (
+	"context"
+	"fmt"
+	"net"
+
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/oschwald/maxminddb-golang"
+)
+
+// GeoIPCountryUnknown labels clients whose country isn't in the database.
+const GeoIPCountryUnknown = "unknown"
+
+// GeoIP looks up the country and autonomous system of client IPs in MaxMind
+// databases, such as GeoLite2-Country and GeoLite2-ASN.
+type GeoIP struct {
+	country *maxminddb.Reader
+	asn     *maxminddb.Reader
+}
+
+// GeoInfo is what is known of the location of a client IP. Country is an ISO
+// 3166-1 code, or GeoIPCountryUnknown.
+type GeoInfo struct {
+	Country string
+	ASN     uint
+	ASNOrg  string
+}
+
+type geoIPRecord struct {
+	Country struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"country"`
+	AutonomousSystemNumber       uint   `maxminddb:"autonomous_system_number"`
+	AutonomousSystemOrganization string `maxminddb:"autonomous_system_organization"`
+}
+
+// NewGeoIP opens the configured databases. It returns nil if none is
+// configured.
+func NewGeoIP(config GeoIPConfig) (*GeoIP, error) {
+	if config.CountryDatabasePath == "" && config.ASNDatabasePath == "" {
+		return nil, nil
+	}
+	g := new(GeoIP)
+	var err error
+	if config.CountryDatabasePath != "" {
+		if g.country, err = maxminddb.Open(config.CountryDatabasePath); err != nil {
+			return nil, fmt.Errorf("error opening geoip country database: %w", err)
+		}
+	}
+	if config.ASNDatabasePath != "" {
+		if g.asn, err = maxminddb.Open(config.ASNDatabasePath); err != nil {
+			_ = g.Close()
+			return nil, fmt.Errorf("error opening geoip asn database: %w", err)
+		}
+	}
+	return g, nil
+}
+
+// Lookup returns the location of ip. Errors are logged, and leave the
+// location unknown.
+func (g *GeoIP) Lookup(ip string) GeoInfo {
+	info := GeoInfo{Country: GeoIPCountryUnknown}
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return info
+	}
+	var record geoIPRecord
+	for _, db := range []*maxminddb.Reader{g.country, g.asn} {
+		if db == nil {
+			continue
+		}
+		if err := db.Lookup(parsed, &record); err != nil {
+			log.Debug("error looking up geoip record", "ip", ip, "err", err)
+		}
+	}
+	if record.Country.ISOCode != "" {
+		info.Country = record.Country.ISOCode
+	}
+	info.ASN = record.AutonomousSystemNumber
+	info.ASNOrg = record.AutonomousSystemOrganization
+	return info
+}
+
+func (g *GeoIP) Close() error {
+	var err error
+	for _, db := range []*maxminddb.Reader{g.country, g.asn} {
+		if db == nil {
+			continue
+		}
+		if closeErr := db.Close(); closeErr != nil {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+// GetGeoInfo returns the location of the client of ctx, if geoip is enabled.
+func GetGeoInfo(ctx context.Context) (GeoInfo, bool) {
+	info, ok := ctx.Value(ContextKeyGeoInfo).(GeoInfo)
+	return info, ok
+}
+
+// geoLogFields returns the location of the client of ctx as log key values.
+func geoLogFields(ctx context.Context) []interface{} {
+	info, ok := GetGeoInfo(ctx)
+	if !ok {
+		return nil
+	}
+	return []interface{}{"country", info.Country, "asn", info.ASN, "asn_org", info.ASNOrg}
+}
@@ -0,0 +1,42 @@
+package proxyd
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+)
+
+// HandleGetRPC serves a single call encoded in the query of a GET request,
+// such as /?method=eth_getBalance&params=["0x...","latest"]&id=1, by handing
+// it to HandleRPC as the equivalent POST body. params is a JSON array,
+// default [], and id a JSON value, default 1.
+func (s *Server) HandleGetRPC(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	req := &RPCReq{
+		JSONRPC: JSONRPCVersion,
+		Method:  query.Get("method"),
+		Params:  json.RawMessage("[]"),
+		ID:      json.RawMessage("1"),
+	}
+	if query.Has("params") {
+		req.Params = json.RawMessage(query.Get("params"))
+	}
+	if query.Has("id") {
+		req.ID = json.RawMessage(query.Get("id"))
+	}
+	if req.Method == "" {
+		s.writeRPCError(r.Context(), w, nil, ErrInvalidRequest("missing method query param"))
+		return
+	}
+	body, err := json.Marshal(req)
+	if err != nil {
+		s.writeRPCError(r.Context(), w, nil, ErrParseErr)
+		return
+	}
+
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	r.ContentLength = int64(len(body))
+	r.Header.Set("Content-Type", "application/json")
+	s.HandleRPC(w, r)
+}
@@ -0,0 +1,46 @@
+package proxyd
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// groupOverrideKeySet validates that keys are authentication aliases.
+func groupOverrideKeySet(keys []string, authentication map[string]string) (map[string]bool, error) {
+	aliases := make(map[string]bool, len(authentication))
+	for _, alias := range authentication {
+		aliases[alias] = true
+	}
+	set := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		if !aliases[key] {
+			return nil, fmt.Errorf("group_override key %s is not an authentication alias", key)
+		}
+		set[key] = true
+	}
+	return set, nil
+}
+
+// withGroupOverride sets the backend group requested with the X-Proxyd-Group
+// header on ctx. The group replaces the one calls would be routed to, but
+// calls must still be to methods mapped to some group.
+func (s *Server) withGroupOverride(ctx context.Context, r *http.Request) (context.Context, error) {
+	group := r.Header.Get(groupOverrideHdr)
+	if group == "" {
+		return ctx, nil
+	}
+	if !s.enableGroupOverride || !(s.groupOverrideKeys[GetAuthCtx(ctx)] || s.isAdminRequest(r)) {
+		return ctx, ErrGroupOverrideNotAllowed
+	}
+	if s.BackendGroups[group] == nil {
+		return ctx, ErrInvalidGroupOverride
+	}
+	RecordGroupOverride(group)
+	return context.WithValue(ctx, ContextKeyGroupOverride, group), nil // nolint:staticcheck
+}
+
+func GetGroupOverride(ctx context.Context) string {
+	group, _ := ctx.Value(ContextKeyGroupOverride).(string)
+	return group
+}
@@ -0,0 +1,101 @@
+package proxyd
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+type verboseHealthz struct {
+	BackendGroups map[string]*backendGroupHealth `json:"backend_groups"`
+}
+
+type backendGroupHealth struct {
+	// ConsensusBlock is the consensus head of consensus aware groups.
+	ConsensusBlock *hexutil.Uint64  `json:"consensus_block,omitempty"`
+	Backends       []*backendHealth `json:"backends"`
+}
+
+type backendHealth struct {
+	Name       string  `json:"name"`
+	URL        string  `json:"url"`
+	Healthy    bool    `json:"healthy"`
+	Degraded   bool    `json:"degraded"`
+	ErrorRate  float64 `json:"error_rate"`
+	AvgLatency string  `json:"avg_latency"`
+	InFlight   int64   `json:"in_flight"`
+	BackingOff bool    `json:"backing_off"`
+	// Consensus is set for the backends of consensus aware groups.
+	Consensus *backendConsensusHealth `json:"consensus,omitempty"`
+}
+
+type backendConsensusHealth struct {
+	InConsensusGroup bool           `json:"in_consensus_group"`
+	InSync           bool           `json:"in_sync"`
+	LatestBlock      hexutil.Uint64 `json:"latest_block"`
+	// Lag is how many blocks the backend is behind the consensus head.
+	Lag         uint64     `json:"lag"`
+	LastPoll    *time.Time `json:"last_poll,omitempty"`
+	Banned      bool       `json:"banned"`
+	BannedUntil *time.Time `json:"banned_until,omitempty"`
+}
+
+// handleVerboseHealthz answers with the health of every backend of every
+// group. Backend URLs are redacted like in the exported config.
+func (s *Server) handleVerboseHealthz(w http.ResponseWriter) {
+	res := verboseHealthz{BackendGroups: make(map[string]*backendGroupHealth, len(s.BackendGroups))}
+	for name, bg := range s.BackendGroups {
+		res.BackendGroups[name] = backendGroupHealthOf(bg)
+	}
+	w.Header().Set("content-type", "application/json")
+	_ = json.NewEncoder(w).Encode(res)
+}
+
+func backendGroupHealthOf(bg *BackendGroup) *backendGroupHealth {
+	group := &backendGroupHealth{Backends: make([]*backendHealth, 0, len(bg.Backends))}
+	var consensusGroup map[*Backend]bool
+	if bg.Consensus != nil {
+		head := bg.Consensus.GetLatestBlockNumber()
+		group.ConsensusBlock = &head
+		consensusGroup = make(map[*Backend]bool)
+		for _, be := range bg.Consensus.GetConsensusGroup() {
+			consensusGroup[be] = true
+		}
+	}
+
+	for _, be := range bg.Backends {
+		health := &backendHealth{
+			Name:       be.Name,
+			URL:        redactConfigURL(be.rpcURL),
+			Healthy:    be.IsHealthy(),
+			Degraded:   be.IsDegraded(),
+			ErrorRate:  be.ErrorRate(),
+			AvgLatency: time.Duration(be.latencySlidingWindow.Avg()).String(),
+			InFlight:   be.inFlight.Load(),
+			BackingOff: be.IsBackingOff(),
+		}
+		if bg.Consensus != nil {
+			bs := bg.Consensus.GetBackendState(be)
+			consensus := &backendConsensusHealth{
+				InConsensusGroup: consensusGroup[be],
+				InSync:           bs.inSync,
+				LatestBlock:      bs.latestBlockNumber,
+				Banned:           bs.IsBanned(),
+			}
+			if head := *group.ConsensusBlock; bs.latestBlockNumber < head {
+				consensus.Lag = uint64(head - bs.latestBlockNumber)
+			}
+			if !bs.lastUpdate.IsZero() {
+				consensus.LastPoll = &bs.lastUpdate
+			}
+			if consensus.Banned {
+				consensus.BannedUntil = &bs.bannedUntil
+			}
+			health.Consensus = consensus
+		}
+		group.Backends = append(group.Backends, health)
+	}
+	return group
+}
@@ -0,0 +1,132 @@
+package proxyd
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// idleConnTracker keeps track of the connections to a backend that its
+// transport holds idle for reuse. A connection becomes idle when the
+// transport puts it back in its pool after a request, and stops being idle
+// when a request takes it or it is closed.
+type idleConnTracker struct {
+	mu   sync.Mutex
+	idle map[net.Conn]bool
+}
+
+func newIdleConnTracker() *idleConnTracker {
+	return &idleConnTracker{idle: make(map[net.Conn]bool)}
+}
+
+// Idle returns the number of idle connections.
+func (t *idleConnTracker) Idle() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.idle)
+}
+
+func (t *idleConnTracker) setIdle(conn net.Conn, idle bool) {
+	// TLS connections are tracked by the connection they wrap, which is the
+	// one closed by the transport.
+	if tlsConn, ok := conn.(interface{ NetConn() net.Conn }); ok {
+		conn = tlsConn.NetConn()
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if idle {
+		t.idle[conn] = true
+	} else {
+		delete(t.idle, conn)
+	}
+}
+
+// withTrace returns a context tracking the connection used by a request.
+func (t *idleConnTracker) withTrace(ctx context.Context) context.Context {
+	var conn net.Conn
+	return httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			conn = info.Conn
+			t.setIdle(conn, false)
+		},
+		PutIdleConn: func(err error) {
+			if err == nil && conn != nil {
+				t.setIdle(conn, true)
+			}
+		},
+	})
+}
+
+// install wraps the dialer of transport so that closed connections stop
+// being tracked.
+func (t *idleConnTracker) install(transport *http.Transport) {
+	dial := transport.DialContext
+	if dial == nil {
+		dial = (&net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}).DialContext
+	}
+	transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := dial(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		return &trackedConn{Conn: conn, tracker: t}, nil
+	}
+}
+
+type trackedConn struct {
+	net.Conn
+	tracker *idleConnTracker
+}
+
+func (c *trackedConn) Close() error {
+	c.tracker.setIdle(c, false)
+	return c.Conn.Close()
+}
+
+// preferWarmBackends breaks the ties of the order of backends in favor of the
+// backends holding an idle connection. Within each run of consecutive
+// backends tied with the first of the run, warm backends are moved ahead of
+// cold ones, keeping the order within each, so that a warm backend never
+// passes one ranked before it.
+func preferWarmBackends(backends []*Backend, tied func(a, b *Backend) bool) []*Backend {
+	ordered := make([]*Backend, 0, len(backends))
+	for start := 0; start < len(backends); {
+		end := start + 1
+		for end < len(backends) && tied(backends[start], backends[end]) {
+			end++
+		}
+		var cold []*Backend
+		for _, be := range backends[start:end] {
+			if be.IdleConns() > 0 {
+				ordered = append(ordered, be)
+			} else {
+				cold = append(cold, be)
+			}
+		}
+		ordered = append(ordered, cold...)
+		start = end
+	}
+	return ordered
+}
+
+// warmConnTie returns which backends the selector named selectorName ranks
+// equally, for preferWarmBackends to break their ties, or false if it ranks
+// every position of its order. The random selector ranks backends only by
+// their health, and the weighted one also by their weight.
+func warmConnTie(selectorName string) (func(a, b *Backend) bool, bool) {
+	switch selectorName {
+	case RandomBackendSelector:
+		return func(a, b *Backend) bool {
+			return a.IsDegraded() == b.IsDegraded()
+		}, true
+	case WeightedBackendSelector:
+		return func(a, b *Backend) bool {
+			return a.IsDegraded() == b.IsDegraded() && a.weight == b.weight
+		}, true
+	default:
+		return nil, false
+	}
+}
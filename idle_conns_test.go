@@ -0,0 +1,39 @@
+package proxyd
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPreferWarmBackends(t *testing.T) {
+	newBackend := func(name string, weight int, warm bool) *Backend {
+		be := NewBackend(name, "http://"+name, "", nil, nil, WithWeight(weight))
+		be.idleConns = newIdleConnTracker()
+		if warm {
+			conn, peer := net.Pipe()
+			t.Cleanup(func() {
+				_ = conn.Close()
+				_ = peer.Close()
+			})
+			be.idleConns.setIdle(conn, true)
+		}
+		return be
+	}
+	heavyCold := newBackend("heavy-cold", 2, false)
+	heavyWarm := newBackend("heavy-warm", 2, true)
+	lightWarm := newBackend("light-warm", 1, true)
+	lightCold := newBackend("light-cold", 1, false)
+	tie, ok := warmConnTie(WeightedBackendSelector)
+	require.True(t, ok)
+
+	// warm backends only pass the cold ones ranked the same
+	ordered := preferWarmBackends([]*Backend{heavyCold, heavyWarm, lightCold, lightWarm}, tie)
+	require.Equal(t, []*Backend{heavyWarm, heavyCold, lightWarm, lightCold}, ordered)
+	ordered = preferWarmBackends([]*Backend{lightCold, heavyCold, lightWarm}, tie)
+	require.Equal(t, []*Backend{lightCold, heavyCold, lightWarm}, ordered)
+
+	_, ok = warmConnTie(OrderedBackendSelector)
+	require.False(t, ok)
+}
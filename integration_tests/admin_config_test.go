@@ -0,0 +1,94 @@
+package integration_tests
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/ethereum-optimism/infra/proxyd"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdminConfig(t *testing.T) {
+	goodBackend := NewMockBackend(BatchedResponseHandler(200, goodResponse))
+	defer goodBackend.Close()
+	require.NoError(t, os.Setenv("GOOD_BACKEND_RPC_URL", goodBackend.URL()))
+
+	_, shutdown, err := proxyd.Start(ReadConfig("admin_config"))
+	require.NoError(t, err)
+	defer shutdown()
+	defer func() {
+		require.NoError(t, proxyd.ResetModuleLogLevel("cache"))
+	}()
+
+	admin := func(method, path, token, body string) (int, []byte) {
+		req, err := http.NewRequest(method, "http://127.0.0.1:8545"+path, strings.NewReader(body))
+		require.NoError(t, err)
+		req.Header.Set("Authorization", "Bearer "+token)
+		res, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer res.Body.Close()
+		resBody, err := io.ReadAll(res.Body)
+		require.NoError(t, err)
+		return res.StatusCode, resBody
+	}
+	getConfig := func() (string, map[string]interface{}) {
+		code, body := admin("GET", "/admin/config", "admin-secret", "")
+		require.Equal(t, http.StatusOK, code)
+		var config map[string]interface{}
+		require.NoError(t, json.Unmarshal(body, &config))
+		return string(body), config
+	}
+	field := func(config map[string]interface{}, path ...string) interface{} {
+		var v interface{} = config
+		for _, key := range path {
+			m, ok := v.(map[string]interface{})
+			require.True(t, ok, "%v", path)
+			v = m[key]
+		}
+		return v
+	}
+
+	code, _ := admin("GET", "/admin/config", "wrong", "")
+	require.Equal(t, http.StatusUnauthorized, code)
+
+	raw, config := getConfig()
+
+	t.Run("secrets are redacted", func(t *testing.T) {
+		for _, secret := range []string{"admin-secret", "auth-secret", "backend-password", "header-api-key", "secret-api-key"} {
+			require.NotContains(t, raw, secret)
+		}
+		require.Equal(t, "[redacted]", field(config, "admin", "token"))
+		require.Equal(t, "https://rpc.example.com/[redacted]", field(config, "backends", "keyed", "rpc_url"))
+		require.Equal(t, "[redacted]", field(config, "backends", "keyed", "password"))
+		require.Equal(t, "user", field(config, "backends", "keyed", "username"))
+		require.Equal(t, "[redacted]", field(config, "backends", "keyed", "headers", "X-Api-Key"))
+		require.Equal(t, []interface{}{"alias"}, config["authentication"])
+	})
+
+	t.Run("env vars are substituted", func(t *testing.T) {
+		require.Equal(t, goodBackend.URL(), field(config, "backends", "good", "rpc_url"))
+	})
+
+	t.Run("defaults are shown", func(t *testing.T) {
+		require.Equal(t, float64(10), field(config, "server", "timeout_seconds"))
+		require.Equal(t, float64(262144), field(config, "server", "max_body_size_bytes"))
+		require.Equal(t, float64(100), field(config, "batch", "max_size"))
+		require.Equal(t, "1h0m0s", field(config, "cache", "ttl"))
+		require.Equal(t, "lru", field(config, "cache", "memory", "eviction_policy"))
+		require.Equal(t, "1m0s", field(config, "backend", "max_retry_after"))
+		// set values are kept
+		require.Equal(t, float64(1), field(config, "backend", "response_timeout_seconds"))
+	})
+
+	t.Run("runtime changes are reflected", func(t *testing.T) {
+		require.Equal(t, map[string]interface{}{}, field(config, "runtime", "log_levels", "modules"))
+		code, _ := admin("POST", "/admin/loglevel", "admin-secret", `{"module":"cache","level":"debug"}`)
+		require.Equal(t, http.StatusOK, code)
+		_, config := getConfig()
+		require.Equal(t, "debug", field(config, "runtime", "log_levels", "modules", "cache"))
+	})
+}
@@ -0,0 +1,89 @@
+package integration_tests
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum-optimism/infra/proxyd"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdminSigning(t *testing.T) {
+	goodBackend := NewMockBackend(BatchedResponseHandler(200, goodResponse))
+	defer goodBackend.Close()
+	require.NoError(t, os.Setenv("GOOD_BACKEND_RPC_URL", goodBackend.URL()))
+
+	_, shutdown, err := proxyd.Start(ReadConfig("admin_signing"))
+	require.NoError(t, err)
+	defer shutdown()
+	defer func() {
+		require.NoError(t, proxyd.ResetModuleLogLevel("consensus"))
+	}()
+
+	sign := func(secret, method, path, timestamp, body string) string {
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write([]byte(method + "\n" + path + "\n" + timestamp + "\n" + body))
+		return hex.EncodeToString(mac.Sum(nil))
+	}
+	send := func(timestamp, signature, body string) int {
+		req, err := http.NewRequest("POST", "http://127.0.0.1:8545/admin/loglevel", strings.NewReader(body))
+		require.NoError(t, err)
+		if timestamp != "" {
+			req.Header.Set("X-Proxyd-Timestamp", timestamp)
+		}
+		if signature != "" {
+			req.Header.Set("X-Proxyd-Signature", signature)
+		}
+		res, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer res.Body.Close()
+		return res.StatusCode
+	}
+	now := func() string {
+		return strconv.FormatInt(time.Now().Unix(), 10)
+	}
+	const body = `{"module":"consensus","level":"debug"}`
+
+	t.Run("valid", func(t *testing.T) {
+		ts := now()
+		require.Equal(t, http.StatusOK, send(ts, sign("signing-secret", "POST", "/admin/loglevel", ts, body), body))
+	})
+
+	t.Run("unsigned", func(t *testing.T) {
+		require.Equal(t, http.StatusUnauthorized, send("", "", body))
+		require.Equal(t, http.StatusUnauthorized, send(now(), "", body))
+	})
+
+	t.Run("tampered", func(t *testing.T) {
+		ts := now()
+		tampered := `{"module":"consensus","level":"trace"}`
+		require.Equal(t, http.StatusUnauthorized, send(ts, sign("signing-secret", "POST", "/admin/loglevel", ts, body), tampered))
+		require.Equal(t, http.StatusUnauthorized, send(ts, sign("signing-secret", "POST", "/admin/cache/flush", ts, body), body))
+		require.Equal(t, http.StatusUnauthorized, send(ts, sign("wrong-secret", "POST", "/admin/loglevel", ts, body), body))
+		// the timestamp is signed too
+		later := strconv.FormatInt(time.Now().Unix()+1, 10)
+		require.Equal(t, http.StatusUnauthorized, send(later, sign("signing-secret", "POST", "/admin/loglevel", ts, body), body))
+	})
+
+	t.Run("replayed", func(t *testing.T) {
+		ts := now()
+		resetBody := `{"module":"consensus"}`
+		signature := sign("signing-secret", "POST", "/admin/loglevel", ts, resetBody)
+		require.Equal(t, http.StatusOK, send(ts, signature, resetBody))
+		require.Equal(t, http.StatusUnauthorized, send(ts, signature, resetBody))
+	})
+
+	t.Run("stale", func(t *testing.T) {
+		for _, offset := range []time.Duration{-time.Minute, time.Minute} {
+			ts := strconv.FormatInt(time.Now().Add(offset).Unix(), 10)
+			require.Equal(t, http.StatusUnauthorized, send(ts, sign("signing-secret", "POST", "/admin/loglevel", ts, body), body))
+		}
+	})
+}
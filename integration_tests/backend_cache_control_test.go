@@ -0,0 +1,76 @@
+package integration_tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/ethereum-optimism/infra/proxyd"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBackendCacheControl(t *testing.T) {
+	// the backend answers with the Cache-Control header given as first param
+	goodBackend := NewMockBackend(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req proxyd.RPCReq
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		var params []string
+		require.NoError(t, json.Unmarshal(req.Params, &params))
+		if params[0] != "" {
+			w.Header().Set("Cache-Control", params[0])
+		}
+		SingleResponseHandler(200, goodResponse)(w, r)
+	}))
+	defer goodBackend.Close()
+	require.NoError(t, os.Setenv("GOOD_BACKEND_RPC_URL", goodBackend.URL()))
+
+	_, shutdown, err := proxyd.Start(ReadConfig("backend_cache_control"))
+	require.NoError(t, err)
+	defer shutdown()
+
+	client := NewProxydClient("http://127.0.0.1:8545")
+	// backendCalls sends method twice with the header and returns how many
+	// times the backend was called
+	backendCalls := func(t *testing.T, method string, header string) int {
+		goodBackend.Reset()
+		for i := 0; i < 2; i++ {
+			res, code, err := client.SendRPC(method, []interface{}{header, "latest"})
+			require.NoError(t, err)
+			require.Equal(t, http.StatusOK, code)
+			RequireEqualJSON(t, []byte(goodResponse), res)
+		}
+		return len(goodBackend.Requests())
+	}
+
+	t.Run("max-age", func(t *testing.T) {
+		require.Equal(t, 1, backendCalls(t, "eth_getCode", "public, max-age=60"))
+	})
+
+	t.Run("s-maxage takes precedence", func(t *testing.T) {
+		require.Equal(t, 1, backendCalls(t, "eth_getCode", "max-age=0, s-maxage=60"))
+		require.Equal(t, 2, backendCalls(t, "eth_getCode", "max-age=60, s-maxage=0"))
+	})
+
+	t.Run("no-store", func(t *testing.T) {
+		require.Equal(t, 2, backendCalls(t, "eth_getCode", "no-store, max-age=60"))
+		require.Equal(t, 2, backendCalls(t, "eth_getCode", "private, max-age=60"))
+	})
+
+	t.Run("no ttl", func(t *testing.T) {
+		require.Equal(t, 2, backendCalls(t, "eth_getCode", ""))
+		require.Equal(t, 2, backendCalls(t, "eth_getCode", "no-cache"))
+		require.Equal(t, 2, backendCalls(t, "eth_getCode", "max-age=invalid"))
+	})
+
+	t.Run("methods not listed", func(t *testing.T) {
+		require.Equal(t, 2, backendCalls(t, "eth_getBalance", "max-age=60"))
+	})
+
+	t.Run("expiry", func(t *testing.T) {
+		require.Equal(t, 1, backendCalls(t, "eth_getCode", "max-age=1"))
+		time.Sleep(1100 * time.Millisecond)
+		require.Equal(t, 1, backendCalls(t, "eth_getCode", "max-age=1"))
+	})
+}
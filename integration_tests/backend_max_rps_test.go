@@ -0,0 +1,121 @@
+package integration_tests
+
+import (
+	"net/http"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum-optimism/infra/proxyd"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBackendMaxRPS(t *testing.T) {
+	var mu sync.Mutex
+	var times []time.Time
+	limited := NewMockBackend(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		times = append(times, time.Now())
+		mu.Unlock()
+		BatchedResponseHandler(200, goodResponse)(w, r)
+	}))
+	defer limited.Close()
+	skipped := NewMockBackend(BatchedResponseHandler(200, goodResponse))
+	defer skipped.Close()
+	other := NewMockBackend(BatchedResponseHandler(200, goodResponse))
+	defer other.Close()
+
+	require.NoError(t, os.Setenv("LIMITED_BACKEND_RPC_URL", limited.URL()))
+	require.NoError(t, os.Setenv("SKIPPED_BACKEND_RPC_URL", skipped.URL()))
+	require.NoError(t, os.Setenv("OTHER_BACKEND_RPC_URL", other.URL()))
+
+	config := ReadConfig("backend_max_rps")
+	_, shutdown, err := proxyd.Start(config)
+	require.NoError(t, err)
+	defer shutdown()
+
+	client := NewProxydClient("http://127.0.0.1:8545")
+
+	t.Run("requests over max_rps wait for the backend", func(t *testing.T) {
+		const n = 10
+		var wg sync.WaitGroup
+		for i := 0; i < n; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, code, err := client.SendRPC("eth_chainId", nil)
+				require.NoError(t, err)
+				require.Equal(t, http.StatusOK, code)
+			}()
+		}
+		wg.Wait()
+
+		// at 20 rps without bursts, the last request is sent about 450ms
+		// after the first
+		require.Len(t, times, n)
+		first, last := times[0], times[0]
+		for _, at := range times {
+			if at.Before(first) {
+				first = at
+			}
+			if at.After(last) {
+				last = at
+			}
+		}
+		require.GreaterOrEqual(t, last.Sub(first), 400*time.Millisecond)
+	})
+
+	t.Run("requests over max_rps skip the backend", func(t *testing.T) {
+		for i := 0; i < 3; i++ {
+			_, code, err := client.SendRPC("net_version", nil)
+			require.NoError(t, err)
+			require.Equal(t, http.StatusOK, code)
+		}
+		require.Equal(t, 1, len(skipped.Requests()))
+		require.Equal(t, 2, len(other.Requests()))
+	})
+}
+
+func TestBackendMaxRPSNotEnforced(t *testing.T) {
+	skipped := NewMockBackend(BatchedResponseHandler(200, goodResponse))
+	defer skipped.Close()
+	other := NewMockBackend(BatchedResponseHandler(200, goodResponse))
+	defer other.Close()
+
+	require.NoError(t, os.Setenv("LIMITED_BACKEND_RPC_URL", other.URL()))
+	require.NoError(t, os.Setenv("SKIPPED_BACKEND_RPC_URL", skipped.URL()))
+	require.NoError(t, os.Setenv("OTHER_BACKEND_RPC_URL", other.URL()))
+
+	// max_rps alone keeps the backend uncapped
+	config := ReadConfig("backend_max_rps")
+	config.Backends["skipped"].EnforceMaxRPS = false
+	_, shutdown, err := proxyd.Start(config)
+	require.NoError(t, err)
+	defer shutdown()
+
+	client := NewProxydClient("http://127.0.0.1:8545")
+	for i := 0; i < 3; i++ {
+		_, code, err := client.SendRPC("net_version", nil)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, code)
+	}
+	require.Equal(t, 3, len(skipped.Requests()))
+	require.Equal(t, 0, len(other.Requests()))
+}
+
+func TestBackendMaxRPSInvalid(t *testing.T) {
+	require.NoError(t, os.Setenv("LIMITED_BACKEND_RPC_URL", "http://127.0.0.1:0"))
+	require.NoError(t, os.Setenv("SKIPPED_BACKEND_RPC_URL", "http://127.0.0.1:0"))
+	require.NoError(t, os.Setenv("OTHER_BACKEND_RPC_URL", "http://127.0.0.1:0"))
+
+	config := ReadConfig("backend_max_rps")
+	config.Backends["limited"].EnforceMaxRPS = false
+	_, _, err := proxyd.Start(config)
+	require.ErrorContains(t, err, "require enforce_max_rps")
+
+	config = ReadConfig("backend_max_rps")
+	config.Backends["skipped"].MaxRPS = 0
+	_, _, err = proxyd.Start(config)
+	require.ErrorContains(t, err, "requires max_rps")
+}
@@ -0,0 +1,121 @@
+package integration_tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/ethereum-optimism/infra/proxyd"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBackendNameHeader(t *testing.T) {
+	node1 := NewMockBackend(BatchedResponseHandler(200, goodResponse))
+	defer node1.Close()
+	node2 := NewMockBackend(BatchedResponseHandler(200, goodResponse))
+	defer node2.Close()
+
+	require.NoError(t, os.Setenv("NODE1_URL", node1.URL()))
+	require.NoError(t, os.Setenv("NODE2_URL", node2.URL()))
+
+	sendWithXFF := func(t *testing.T, xff string, body interface{}) *http.Response {
+		b, err := json.Marshal(body)
+		require.NoError(t, err)
+		req, err := http.NewRequest("POST", "http://127.0.0.1:8545", bytes.NewReader(b))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+		if xff != "" {
+			req.Header.Set("X-Forwarded-For", xff)
+		}
+		res, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		require.NoError(t, res.Body.Close())
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		return res
+	}
+
+	t.Run("disabled by default", func(t *testing.T) {
+		config := ReadConfig("backend_name_header")
+		config.Server.EnableBackendNameHeader = false
+		_, shutdown, err := proxyd.Start(config)
+		require.NoError(t, err)
+		defer shutdown()
+
+		res := sendWithXFF(t, "", NewRPCReq("1", "eth_chainId", nil))
+		require.Empty(t, res.Header.Values("X-Backend-Name"))
+	})
+
+	t.Run("single and batch", func(t *testing.T) {
+		_, shutdown, err := proxyd.Start(ReadConfig("backend_name_header"))
+		require.NoError(t, err)
+		defer shutdown()
+
+		res := sendWithXFF(t, "", NewRPCReq("1", "eth_chainId", nil))
+		require.Equal(t, "node1", res.Header.Get("X-Backend-Name"))
+
+		res = sendWithXFF(t, "", []*proxyd.RPCReq{
+			NewRPCReq("1", "eth_chainId", nil),
+			NewRPCReq("2", "net_version", nil),
+		})
+		require.Equal(t, "node1,node2", res.Header.Get("X-Backend-Name"))
+	})
+
+	t.Run("trusted ips", func(t *testing.T) {
+		config := ReadConfig("backend_name_header")
+		config.Server.BackendNameHeaderTrustedIPs = []string{"10.0.0.0/8", "127.0.0.1"}
+		_, shutdown, err := proxyd.Start(config)
+		require.NoError(t, err)
+		defer shutdown()
+
+		// the peer address is trusted
+		res := sendWithXFF(t, "", NewRPCReq("1", "eth_chainId", nil))
+		require.Equal(t, "node1", res.Header.Get("X-Backend-Name"))
+	})
+
+	t.Run("spoofed x-forwarded-for", func(t *testing.T) {
+		config := ReadConfig("backend_name_header")
+		config.Server.BackendNameHeaderTrustedIPs = []string{"10.0.0.0/8"}
+		_, shutdown, err := proxyd.Start(config)
+		require.NoError(t, err)
+		defer shutdown()
+
+		res := sendWithXFF(t, "10.1.2.3", NewRPCReq("1", "eth_chainId", nil))
+		require.Empty(t, res.Header.Values("X-Backend-Name"))
+	})
+
+	t.Run("trusted proxies", func(t *testing.T) {
+		config := ReadConfig("backend_name_header")
+		config.Server.BackendNameHeaderTrustedIPs = []string{"10.0.0.0/8", "192.168.1.1"}
+		config.Server.TrustedProxies = []string{"127.0.0.1"}
+		_, shutdown, err := proxyd.Start(config)
+		require.NoError(t, err)
+		defer shutdown()
+
+		res := sendWithXFF(t, "10.1.2.3", NewRPCReq("1", "eth_chainId", nil))
+		require.Equal(t, "node1", res.Header.Get("X-Backend-Name"))
+		res = sendWithXFF(t, "192.168.1.1", NewRPCReq("1", "eth_chainId", nil))
+		require.Equal(t, "node1", res.Header.Get("X-Backend-Name"))
+		res = sendWithXFF(t, "192.168.1.2", NewRPCReq("1", "eth_chainId", nil))
+		require.Empty(t, res.Header.Values("X-Backend-Name"))
+		// only the entry appended by the trusted proxy is believed
+		res = sendWithXFF(t, "10.1.2.3, 192.168.1.2", NewRPCReq("1", "eth_chainId", nil))
+		require.Empty(t, res.Header.Values("X-Backend-Name"))
+		// the proxy itself isn't within the trusted IPs
+		res = sendWithXFF(t, "", NewRPCReq("1", "eth_chainId", nil))
+		require.Empty(t, res.Header.Values("X-Backend-Name"))
+	})
+
+	t.Run("invalid trusted ips", func(t *testing.T) {
+		config := ReadConfig("backend_name_header")
+		config.Server.BackendNameHeaderTrustedIPs = []string{"not-an-ip"}
+		_, _, err := proxyd.Start(config)
+		require.Error(t, err)
+
+		config = ReadConfig("backend_name_header")
+		config.Server.TrustedProxies = []string{"not-an-ip"}
+		_, _, err = proxyd.Start(config)
+		require.Error(t, err)
+	})
+}
@@ -0,0 +1,103 @@
+package integration_tests
+
+import (
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/ethereum-optimism/infra/proxyd"
+	"github.com/stretchr/testify/require"
+)
+
+// stubProxy is a forward HTTP proxy recording the requests it relays.
+type stubProxy struct {
+	*httptest.Server
+	mtx  sync.Mutex
+	reqs []*http.Request
+}
+
+func newStubProxy() *stubProxy {
+	p := &stubProxy{}
+	p.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p.mtx.Lock()
+		p.reqs = append(p.reqs, r.Clone(r.Context()))
+		p.mtx.Unlock()
+
+		out := r.Clone(r.Context())
+		out.RequestURI = ""
+		out.Header.Del("Proxy-Authorization")
+		res, err := http.DefaultTransport.RoundTrip(out)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		defer res.Body.Close()
+		for k, v := range res.Header {
+			w.Header()[k] = v
+		}
+		w.WriteHeader(res.StatusCode)
+		_, _ = io.Copy(w, res.Body)
+	}))
+	return p
+}
+
+func (p *stubProxy) Requests() []*http.Request {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	return p.reqs
+}
+
+func TestBackendProxy(t *testing.T) {
+	goodBackend := NewMockBackend(BatchedResponseHandler(200, goodResponse))
+	defer goodBackend.Close()
+	globalProxy := newStubProxy()
+	defer globalProxy.Close()
+	backendProxy := newStubProxy()
+	defer backendProxy.Close()
+
+	require.NoError(t, os.Setenv("GOOD_BACKEND_RPC_URL", goodBackend.URL()))
+	require.NoError(t, os.Setenv("GLOBAL_PROXY_URL", globalProxy.URL))
+	require.NoError(t, os.Setenv("GLOBAL_PROXY_USERNAME", "user"))
+	require.NoError(t, os.Setenv("GLOBAL_PROXY_PASSWORD", "secret"))
+	require.NoError(t, os.Setenv("BACKEND_PROXY_URL", backendProxy.URL))
+
+	config := ReadConfig("backend_proxy")
+	client := NewProxydClient("http://127.0.0.1:8545")
+	_, shutdown, err := proxyd.Start(config)
+	require.NoError(t, err)
+	defer shutdown()
+
+	res, code, err := client.SendRPC("eth_chainId", nil)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, code)
+	RequireEqualJSON(t, []byte(goodResponse), res)
+	require.Len(t, globalProxy.Requests(), 1)
+	require.Empty(t, backendProxy.Requests())
+	req := globalProxy.Requests()[0]
+	require.Equal(t, goodBackend.URL(), "http://"+req.Host)
+	require.Equal(t, "Basic "+base64.StdEncoding.EncodeToString([]byte("user:secret")), req.Header.Get("Proxy-Authorization"))
+
+	res, code, err = client.SendRPC("net_version", nil)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, code)
+	RequireEqualJSON(t, []byte(goodResponse), res)
+	require.Len(t, globalProxy.Requests(), 1)
+	require.Len(t, backendProxy.Requests(), 1)
+	require.Empty(t, backendProxy.Requests()[0].Header.Get("Proxy-Authorization"))
+	require.Len(t, goodBackend.Requests(), 2)
+}
+
+func TestBackendProxyInvalidScheme(t *testing.T) {
+	require.NoError(t, os.Setenv("GOOD_BACKEND_RPC_URL", "http://127.0.0.1:1"))
+	require.NoError(t, os.Setenv("GLOBAL_PROXY_URL", "ftp://127.0.0.1:21"))
+	require.NoError(t, os.Setenv("GLOBAL_PROXY_USERNAME", "user"))
+	require.NoError(t, os.Setenv("GLOBAL_PROXY_PASSWORD", "secret"))
+	require.NoError(t, os.Setenv("BACKEND_PROXY_URL", "http://127.0.0.1:1"))
+
+	_, _, err := proxyd.Start(ReadConfig("backend_proxy"))
+	require.ErrorContains(t, err, "unsupported scheme")
+}
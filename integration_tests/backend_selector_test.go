@@ -0,0 +1,113 @@
+package integration_tests
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/ethereum-optimism/infra/proxyd"
+	"github.com/stretchr/testify/require"
+)
+
+// reversedSelector tries the backends in reverse order and remembers what it
+// was asked to select from.
+type reversedSelector struct {
+	mtx        sync.Mutex
+	methods    []string
+	candidates [][]string
+}
+
+func (s *reversedSelector) Select(_ context.Context, method string, candidates []*proxyd.Backend) []*proxyd.Backend {
+	names := make([]string, 0, len(candidates))
+	selected := make([]*proxyd.Backend, len(candidates))
+	for i, be := range candidates {
+		names = append(names, be.Name)
+		selected[len(candidates)-1-i] = be
+	}
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.methods = append(s.methods, method)
+	s.candidates = append(s.candidates, names)
+	return selected
+}
+
+func (s *reversedSelector) reset() {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.methods = nil
+	s.candidates = nil
+}
+
+var testReversedSelector = &reversedSelector{}
+
+func init() {
+	if err := proxyd.RegisterBackendSelector("test_reversed", testReversedSelector); err != nil {
+		panic(err)
+	}
+}
+
+func TestBackendSelector(t *testing.T) {
+	router := NewBatchRPCResponseRouter()
+	router.SetFallbackRoute("eth_chainId", "0x1")
+	router.SetFallbackRoute("eth_blockNumber", "0x2")
+	first := NewMockBackend(router)
+	defer first.Close()
+	second := NewMockBackend(router)
+	defer second.Close()
+
+	require.NoError(t, os.Setenv("FIRST_BACKEND_RPC_URL", first.URL()))
+	require.NoError(t, os.Setenv("SECOND_BACKEND_RPC_URL", second.URL()))
+
+	config := ReadConfig("backend_selector")
+	client := NewProxydClient("http://127.0.0.1:8545")
+	_, shutdown, err := proxyd.Start(config)
+	require.NoError(t, err)
+	defer shutdown()
+
+	t.Run("custom selector chooses the backend", func(t *testing.T) {
+		first.Reset()
+		second.Reset()
+		testReversedSelector.reset()
+
+		_, code, err := client.SendRPC("eth_chainId", nil)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, code)
+		require.Equal(t, 0, len(first.Requests()))
+		require.Equal(t, 1, len(second.Requests()))
+
+		testReversedSelector.mtx.Lock()
+		defer testReversedSelector.mtx.Unlock()
+		require.Equal(t, []string{"eth_chainId"}, testReversedSelector.methods)
+		require.Equal(t, [][]string{{"first", "second"}}, testReversedSelector.candidates)
+	})
+
+	t.Run("batches of different methods have no method", func(t *testing.T) {
+		testReversedSelector.reset()
+
+		_, code, err := client.SendBatchRPC(
+			NewRPCReq("1", "eth_chainId", nil),
+			NewRPCReq("2", "eth_blockNumber", nil),
+		)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, code)
+
+		testReversedSelector.mtx.Lock()
+		defer testReversedSelector.mtx.Unlock()
+		require.Equal(t, []string{""}, testReversedSelector.methods)
+	})
+}
+
+func TestBackendSelectorConfig(t *testing.T) {
+	require.NoError(t, os.Setenv("FIRST_BACKEND_RPC_URL", "http://127.0.0.1:1"))
+	require.NoError(t, os.Setenv("SECOND_BACKEND_RPC_URL", "http://127.0.0.1:1"))
+
+	config := ReadConfig("backend_selector")
+	config.BackendGroups["main"].BackendSelector = "missing"
+	_, _, err := proxyd.Start(config)
+	require.ErrorContains(t, err, "undefined backend selector missing for backend group main")
+
+	require.ErrorContains(t, proxyd.RegisterBackendSelector("test_reversed", testReversedSelector), "already registered")
+	require.ErrorContains(t, proxyd.RegisterBackendSelector(proxyd.RandomBackendSelector, testReversedSelector), "already registered")
+}
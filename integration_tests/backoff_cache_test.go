@@ -0,0 +1,130 @@
+package integration_tests
+
+import (
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/ethereum-optimism/infra/proxyd"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCacheOnBackoff(t *testing.T) {
+	const preferredBalance = `{"jsonrpc": "2.0", "result": "0x10", "id": 999}`
+	const pricierBalance = `{"jsonrpc": "2.0", "result": "0x11", "id": 999}`
+
+	preferred := NewMockBackend(SingleResponseHandler(200, preferredBalance))
+	defer preferred.Close()
+	pricierRouter := NewBatchRPCResponseRouter()
+	pricierRouter.SetFallbackRoute("eth_getBalance", "0x11")
+	pricierRouter.SetFallbackRoute("eth_blockNumber", "0x100")
+	pricier := NewMockBackend(pricierRouter)
+	defer pricier.Close()
+
+	require.NoError(t, os.Setenv("PREFERRED_BACKEND_RPC_URL", preferred.URL()))
+	require.NoError(t, os.Setenv("PRICIER_BACKEND_RPC_URL", pricier.URL()))
+
+	config := ReadConfig("backoff_cache")
+	svr, shutdown, err := proxyd.Start(config)
+	require.NoError(t, err)
+	defer shutdown()
+
+	client := NewProxydClient("http://127.0.0.1:8545")
+	balanceParams := []interface{}{"0x0000000000000000000000000000000000000001", "latest"}
+
+	// warm the cache from the preferred backend
+	res, code, err := client.SendRPC("eth_getBalance", balanceParams)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, code)
+	RequireEqualJSON(t, []byte(preferredBalance), res)
+	require.Equal(t, 1, len(preferred.Requests()))
+
+	// while the preferred backend is healthy, reads aren't served from the
+	// last response
+	res, _, err = client.SendRPC("eth_getBalance", balanceParams)
+	require.NoError(t, err)
+	RequireEqualJSON(t, []byte(preferredBalance), res)
+	require.Equal(t, 2, len(preferred.Requests()))
+
+	// the preferred backend gets throttled, and the call fails over
+	preferred.SetHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "60")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	res, code, err = client.SendRPC("eth_blockNumber", nil)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, 1, len(pricier.Requests()))
+	require.True(t, svr.BackendGroups["main"].Backends[0].IsBackingOff())
+	preferred.Reset()
+	pricier.Reset()
+
+	// cached reads are served from the cache instead of the pricier backend
+	res, code, err = client.SendRPC("eth_getBalance", balanceParams)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, code)
+	RequireEqualJSON(t, []byte(preferredBalance), res)
+	require.Equal(t, 0, len(pricier.Requests()))
+
+	// reads missing from the cache and other calls still fail over
+	res, code, err = client.SendRPC("eth_getBalance", []interface{}{"0x0000000000000000000000000000000000000002", "latest"})
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, code)
+	RequireEqualJSON(t, []byte(pricierBalance), res)
+	require.Equal(t, 1, len(pricier.Requests()))
+
+	_, code, err = client.SendRPC("eth_blockNumber", nil)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, 2, len(pricier.Requests()))
+
+	// batches are split between the cache and the pricier backend
+	res, code, err = client.SendBatchRPC(
+		NewRPCReq("1", "eth_getBalance", balanceParams),
+		NewRPCReq("2", "eth_blockNumber", nil),
+	)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, 3, len(pricier.Requests()))
+	require.Equal(t, 0, len(preferred.Requests()))
+	RequireEqualJSON(t, []byte(`[
+		{"jsonrpc": "2.0", "result": "0x10", "id": 1},
+		{"jsonrpc": "2.0", "result": "0x100", "id": 2}
+	]`), res)
+
+	// with every backend backing off, calls aren't answered like on an
+	// outage, which only cache_only_on_outage does
+	outageResponses := outageResponsesMetric(t, "main")
+	pricier.SetHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "60")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	_, _, err = client.SendRPC("eth_blockNumber", nil)
+	require.NoError(t, err)
+	require.True(t, svr.BackendGroups["main"].Backends[1].IsBackingOff())
+	_, _, err = client.SendRPC("eth_blockNumber", nil)
+	require.NoError(t, err)
+	require.Equal(t, outageResponses, outageResponsesMetric(t, "main"))
+}
+
+// outageResponsesMetric returns the cache_only_outage_responses_total of
+// group.
+func outageResponsesMetric(t *testing.T, group string) float64 {
+	families, err := prometheus.DefaultGatherer.Gather()
+	require.NoError(t, err)
+	var total float64
+	for _, family := range families {
+		if family.GetName() != "proxyd_cache_only_outage_responses_total" {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			for _, label := range metric.GetLabel() {
+				if label.GetName() == "backend_group" && label.GetValue() == group {
+					total += metric.GetCounter().GetValue()
+				}
+			}
+		}
+	}
+	return total
+}
@@ -0,0 +1,110 @@
+package integration_tests
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/ethereum-optimism/infra/proxyd"
+	ms "github.com/ethereum-optimism/infra/proxyd/tools/mockserver/handler"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBalanceSnapshot(t *testing.T) {
+	dir, err := os.Getwd()
+	require.NoError(t, err)
+
+	h := ms.MockedHandler{
+		Overrides:    []*ms.MethodTemplate{},
+		Autoload:     true,
+		AutoloadFile: path.Join(dir, "testdata/consensus_responses.yml"),
+	}
+	h.AddOverride(&ms.MethodTemplate{
+		Method:   "eth_getBalance",
+		Response: buildResponse("0x1234"),
+	})
+	node := NewMockBackend(http.HandlerFunc(h.Handler))
+	defer node.Close()
+	require.NoError(t, os.Setenv("NODE1_URL", node.URL()))
+
+	config := ReadConfig("balance_snapshot")
+	svr, shutdown, err := proxyd.Start(config)
+	require.NoError(t, err)
+	defer shutdown()
+	client := NewProxydClient("http://127.0.0.1:8545")
+
+	bg := svr.BackendGroups["node"]
+	ctx := context.Background()
+	update := func() {
+		bg.Consensus.UpdateBackend(ctx, bg.Backends[0])
+		bg.Consensus.UpdateBackendGroupConsensus(ctx)
+	}
+	update()
+	require.Equal(t, "0xc1", bg.Consensus.GetFinalizedBlockNumber().String())
+
+	balanceCalls := func() int {
+		var calls int
+		for _, req := range node.Requests() {
+			var rpcReq proxyd.RPCReq
+			if json.Unmarshal(req.Body, &rpcReq) == nil && rpcReq.Method == "eth_getBalance" {
+				calls++
+			}
+		}
+		return calls
+	}
+	getBalance := func(addr string, block string) {
+		res, code, err := client.SendRPC("eth_getBalance", []interface{}{addr, block})
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, code)
+		RequireEqualJSON(t, []byte(`{"jsonrpc":"2.0","result":"0x1234","id":999}`), res)
+	}
+	addr1 := "0x0000000000000000000000000000000000000001"
+	addr2 := "0x0000000000000000000000000000000000000002"
+	addr3 := "0x0000000000000000000000000000000000000003"
+
+	t.Run("second read at the finalized block is a hit", func(t *testing.T) {
+		getBalance(addr1, "finalized")
+		require.Equal(t, 1, balanceCalls())
+		getBalance(addr1, "finalized")
+		require.Equal(t, 1, balanceCalls())
+		// the finalized block by number is the same entry
+		getBalance(addr1, "0xc1")
+		require.Equal(t, 1, balanceCalls())
+	})
+
+	t.Run("other blocks are forwarded", func(t *testing.T) {
+		node.Reset()
+		getBalance(addr1, "latest")
+		getBalance(addr1, "latest")
+		getBalance(addr1, "0xc0")
+		require.Equal(t, 3, balanceCalls())
+	})
+
+	t.Run("entries are capped", func(t *testing.T) {
+		node.Reset()
+		getBalance(addr2, "finalized")
+		getBalance(addr3, "finalized")
+		getBalance(addr2, "finalized")
+		getBalance(addr3, "finalized")
+		require.Equal(t, 3, balanceCalls())
+	})
+
+	t.Run("a new finalized block invalidates the snapshot", func(t *testing.T) {
+		h.AddOverride(&ms.MethodTemplate{
+			Method:   "eth_getBlockByNumber",
+			Block:    "finalized",
+			Response: buildResponse(map[string]string{"number": "0xc2", "hash": "hash_0xc2"}),
+		})
+		update()
+		require.Equal(t, "0xc2", bg.Consensus.GetFinalizedBlockNumber().String())
+
+		node.Reset()
+		getBalance(addr1, "finalized")
+		getBalance(addr1, "finalized")
+		getBalance(addr1, "0xc1")
+		require.Equal(t, 2, balanceCalls())
+	})
+}
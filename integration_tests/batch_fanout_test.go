@@ -0,0 +1,82 @@
+package integration_tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ethereum-optimism/infra/proxyd"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBatchFanoutConcurrency(t *testing.T) {
+	router := NewBatchRPCResponseRouter()
+	router.SetFallbackRoute("eth_chainId", "0x38")
+
+	var inFlight, maxInFlight, calls atomic.Int64
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			max := maxInFlight.Load()
+			if n <= max || maxInFlight.CompareAndSwap(max, n) {
+				break
+			}
+		}
+		calls.Add(1)
+		time.Sleep(50 * time.Millisecond)
+		router.ServeHTTP(w, r)
+	}))
+	defer backend.Close()
+
+	require.NoError(t, os.Setenv("GOOD_BACKEND_RPC_URL", backend.URL))
+
+	sendBatch := func(t *testing.T) {
+		reqs := make([]*proxyd.RPCReq, 12)
+		for i := range reqs {
+			reqs[i] = NewRPCReq(strconv.Itoa(i+1), "eth_chainId", nil)
+		}
+		res, code, err := NewProxydClient("http://127.0.0.1:8545").SendBatchRPC(reqs...)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, code)
+
+		var rpcRes []proxyd.RPCRes
+		require.NoError(t, json.Unmarshal(res, &rpcRes))
+		require.Len(t, rpcRes, len(reqs))
+		for i, r := range rpcRes {
+			require.Equal(t, strconv.Itoa(i+1), string(r.ID), "responses keep the order of the batch")
+			require.Equal(t, "0x38", r.Result, fmt.Sprintf("call %d", i+1))
+		}
+		require.Equal(t, int64(6), calls.Load())
+	}
+
+	t.Run("bounded", func(t *testing.T) {
+		calls.Store(0)
+		maxInFlight.Store(0)
+		_, shutdown, err := proxyd.Start(ReadConfig("batch_fanout"))
+		require.NoError(t, err)
+		defer shutdown()
+
+		sendBatch(t)
+		require.Equal(t, int64(3), maxInFlight.Load())
+	})
+
+	t.Run("sequential by default", func(t *testing.T) {
+		calls.Store(0)
+		maxInFlight.Store(0)
+		config := ReadConfig("batch_fanout")
+		config.BackendGroups["main"].BatchFanoutConcurrency = 0
+		_, shutdown, err := proxyd.Start(config)
+		require.NoError(t, err)
+		defer shutdown()
+
+		sendBatch(t)
+		require.Equal(t, int64(1), maxInFlight.Load())
+	})
+}
@@ -0,0 +1,55 @@
+package integration_tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/ethereum-optimism/infra/proxyd"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBatchIncrementalEncoding(t *testing.T) {
+	const size = 1000
+	block := "0x" + strings.Repeat("ab", 1024)
+
+	router := NewBatchRPCResponseRouter()
+	router.SetFallbackRoute("eth_getBlockByNumber", block)
+	goodBackend := NewMockBackend(router)
+	defer goodBackend.Close()
+
+	require.NoError(t, os.Setenv("GOOD_BACKEND_RPC_URL", goodBackend.URL()))
+
+	config := ReadConfig("batch_incremental_encoding")
+	_, shutdown, err := proxyd.Start(config)
+	require.NoError(t, err)
+	defer shutdown()
+
+	reqs := make([]*proxyd.RPCReq, 0, size)
+	for i := 0; i < size; i++ {
+		reqs = append(reqs, NewRPCReq(fmt.Sprint(i), "eth_getBlockByNumber", []interface{}{fmt.Sprintf("0x%x", i), false}))
+	}
+	body, err := json.Marshal(reqs)
+	require.NoError(t, err)
+
+	res, err := http.Post("http://127.0.0.1:8545", "application/json", bytes.NewReader(body))
+	require.NoError(t, err)
+	defer res.Body.Close()
+	require.Equal(t, http.StatusOK, res.StatusCode)
+
+	resBody, err := io.ReadAll(res.Body)
+	require.NoError(t, err)
+	var batchRes []*proxyd.RPCRes
+	require.NoError(t, json.Unmarshal(resBody, &batchRes))
+	require.Len(t, batchRes, size)
+	for i, elem := range batchRes {
+		require.Nil(t, elem.Error)
+		require.Equal(t, json.RawMessage(fmt.Sprint(i)), elem.ID)
+		require.Equal(t, block, elem.Result)
+	}
+}
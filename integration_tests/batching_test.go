@@ -186,3 +186,125 @@ func TestBatching(t *testing.T) {
 		})
 	}
 }
+
+func TestOversizedBatchErrorStyle(t *testing.T) {
+	goodBackend := NewMockBackend(BatchedResponseHandler(200, goodResponse))
+	defer goodBackend.Close()
+	require.NoError(t, os.Setenv("GOOD_BACKEND_RPC_URL", goodBackend.URL()))
+
+	reqs := []*proxyd.RPCReq{
+		NewRPCReq("1", "net_version", nil),
+		NewRPCReq("2", "eth_chainId", nil),
+		NewRPCReq("3", "eth_chainId", nil),
+		NewRPCReq("4", "eth_call", nil),
+		NewRPCReq("5", "eth_call", nil),
+		NewRPCReq("6", "eth_call", nil),
+	}
+
+	tests := []struct {
+		name        string
+		style       proxyd.BatchErrorStyle
+		expectedRes string
+	}{
+		{
+			name:        "single",
+			style:       proxyd.BatchErrorStyleSingle,
+			expectedRes: `{"error":{"code":-32600,"message":"batch of 6 exceeds limit of 5"},"id":null,"jsonrpc":"2.0"}`,
+		},
+		{
+			name:  "array",
+			style: proxyd.BatchErrorStyleArray,
+			expectedRes: asArray(
+				`{"error":{"code":-32600,"message":"batch of 6 exceeds limit of 5"},"id":1,"jsonrpc":"2.0"}`,
+				`{"error":{"code":-32600,"message":"batch of 6 exceeds limit of 5"},"id":2,"jsonrpc":"2.0"}`,
+				`{"error":{"code":-32600,"message":"batch of 6 exceeds limit of 5"},"id":3,"jsonrpc":"2.0"}`,
+				`{"error":{"code":-32600,"message":"batch of 6 exceeds limit of 5"},"id":4,"jsonrpc":"2.0"}`,
+				`{"error":{"code":-32600,"message":"batch of 6 exceeds limit of 5"},"id":5,"jsonrpc":"2.0"}`,
+				`{"error":{"code":-32600,"message":"batch of 6 exceeds limit of 5"},"id":6,"jsonrpc":"2.0"}`,
+			),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := ReadConfig("batching")
+			config.BatchConfig.ErrorMessage = "batch of {size} exceeds limit of {limit}"
+			config.BatchConfig.ErrorCode = -32600
+			config.BatchConfig.ErrorStyle = tt.style
+
+			client := NewProxydClient("http://127.0.0.1:8545")
+			_, shutdown, err := proxyd.Start(config)
+			require.NoError(t, err)
+			defer shutdown()
+
+			res, statusCode, err := client.SendBatchRPC(reqs...)
+			require.NoError(t, err)
+			require.Equal(t, http.StatusOK, statusCode)
+			RequireEqualJSON(t, []byte(tt.expectedRes), res)
+			require.Empty(t, goodBackend.Requests())
+		})
+	}
+}
+
+func TestBatchMethodLimits(t *testing.T) {
+	chainIDResponse1 := `{"jsonrpc": "2.0", "result": "hello1", "id": 1}`
+	chainIDResponse2 := `{"jsonrpc": "2.0", "result": "hello2", "id": 2}`
+	netVersionResponse4 := `{"jsonrpc": "2.0", "result": "1.0", "id": 4}`
+	overLimitResponse3 := `{"error":{"code":-32023,"message":"too many calls to method in batch request"},"id":3,"jsonrpc":"2.0"}`
+
+	router := NewBatchRPCResponseRouter()
+	router.SetRoute("eth_chainId", "1", "hello1")
+	router.SetRoute("eth_chainId", "2", "hello2")
+	router.SetRoute("eth_chainId", "3", "hello3")
+	router.SetRoute("net_version", "4", "1.0")
+	goodBackend := NewMockBackend(router)
+	defer goodBackend.Close()
+	require.NoError(t, os.Setenv("GOOD_BACKEND_RPC_URL", goodBackend.URL()))
+
+	reqs := []*proxyd.RPCReq{
+		NewRPCReq("1", "eth_chainId", nil),
+		NewRPCReq("2", "eth_chainId", nil),
+		NewRPCReq("3", "eth_chainId", nil),
+		NewRPCReq("4", "net_version", nil),
+	}
+
+	tests := []struct {
+		name        string
+		action      proxyd.BatchMethodLimitAction
+		expectedRes string
+		forwarded   int
+	}{
+		{
+			name:        "entries",
+			action:      proxyd.BatchMethodLimitActionEntries,
+			expectedRes: asArray(chainIDResponse1, chainIDResponse2, overLimitResponse3, netVersionResponse4),
+			forwarded:   1,
+		},
+		{
+			name:        "batch",
+			action:      proxyd.BatchMethodLimitActionBatch,
+			expectedRes: `{"error":{"code":-32023,"message":"too many calls to method in batch request"},"id":null,"jsonrpc":"2.0"}`,
+			forwarded:   0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			goodBackend.Reset()
+			config := ReadConfig("batching")
+			config.BatchConfig.MethodLimits = map[string]int{"eth_chainId": 2}
+			config.BatchConfig.MethodLimitAction = tt.action
+
+			client := NewProxydClient("http://127.0.0.1:8545")
+			_, shutdown, err := proxyd.Start(config)
+			require.NoError(t, err)
+			defer shutdown()
+
+			res, statusCode, err := client.SendBatchRPC(reqs...)
+			require.NoError(t, err)
+			require.Equal(t, http.StatusOK, statusCode)
+			RequireEqualJSON(t, []byte(tt.expectedRes), res)
+			require.Len(t, goodBackend.Requests(), tt.forwarded)
+		})
+	}
+}
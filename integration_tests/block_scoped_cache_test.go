@@ -0,0 +1,96 @@
+package integration_tests
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/ethereum-optimism/infra/proxyd"
+	ms "github.com/ethereum-optimism/infra/proxyd/tools/mockserver/handler"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBlockScopedCache(t *testing.T) {
+	dir, err := os.Getwd()
+	require.NoError(t, err)
+	responses := path.Join(dir, "testdata/consensus_responses.yml")
+
+	gasPrice := &ms.MethodTemplate{
+		Method:   "eth_gasPrice",
+		Response: `{"jsonrpc": "2.0", "id": 67, "result": "0x3b9aca00"}`,
+	}
+	h1 := ms.MockedHandler{Overrides: []*ms.MethodTemplate{gasPrice}, Autoload: true, AutoloadFile: responses}
+	h2 := ms.MockedHandler{Overrides: []*ms.MethodTemplate{gasPrice}, Autoload: true, AutoloadFile: responses}
+
+	node1 := NewMockBackend(http.HandlerFunc(h1.Handler))
+	defer node1.Close()
+	node2 := NewMockBackend(http.HandlerFunc(h2.Handler))
+	defer node2.Close()
+
+	require.NoError(t, os.Setenv("NODE1_URL", node1.URL()))
+	require.NoError(t, os.Setenv("NODE2_URL", node2.URL()))
+
+	svr, shutdown, err := proxyd.Start(ReadConfig("block_scoped_cache"))
+	require.NoError(t, err)
+	defer shutdown()
+	client := NewProxydClient("http://127.0.0.1:8545")
+
+	bg := svr.BackendGroups["node"]
+	ctx := context.Background()
+	update := func() {
+		for _, be := range bg.Backends {
+			bg.Consensus.UpdateBackend(ctx, be)
+		}
+		bg.Consensus.UpdateBackendGroupConsensus(ctx)
+	}
+	advance := func(number string) {
+		for _, h := range []*ms.MockedHandler{&h1, &h2} {
+			h.AddOverride(&ms.MethodTemplate{
+				Method:   "eth_getBlockByNumber",
+				Block:    "latest",
+				Response: buildResponse(map[string]string{"number": number, "hash": "hash_" + number}),
+			})
+		}
+		update()
+		require.Equal(t, number, bg.Consensus.GetLatestBlockNumber().String())
+	}
+	forwarded := func() int {
+		n := 0
+		for _, node := range []*MockBackend{node1, node2} {
+			for _, req := range node.Requests() {
+				if bytes.Contains(req.Body, []byte("eth_gasPrice")) {
+					n++
+				}
+			}
+		}
+		return n
+	}
+	getGasPrice := func() {
+		res, code, err := client.SendRPC("eth_gasPrice", nil)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, code)
+		RequireEqualJSON(t, []byte(`{"jsonrpc":"2.0","result":"0x3b9aca00","id":999}`), res)
+	}
+
+	update()
+	require.Equal(t, "0x101", bg.Consensus.GetLatestBlockNumber().String())
+
+	// cached within a block
+	getGasPrice()
+	getGasPrice()
+	require.Equal(t, 1, forwarded())
+
+	// and invalidated by a new head
+	advance("0x102")
+	getGasPrice()
+	getGasPrice()
+	require.Equal(t, 2, forwarded())
+
+	// an unchanged head keeps the entries
+	update()
+	getGasPrice()
+	require.Equal(t, 2, forwarded())
+}
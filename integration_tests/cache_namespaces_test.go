@@ -0,0 +1,52 @@
+package integration_tests
+
+import (
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/ethereum-optimism/infra/proxyd"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDomainCacheNamespaces(t *testing.T) {
+	router := NewBatchRPCResponseRouter()
+	router.SetFallbackRoute("eth_chainId", "0x38")
+	goodBackend := NewMockBackend(router)
+	defer goodBackend.Close()
+
+	require.NoError(t, os.Setenv("GOOD_BACKEND_RPC_URL", goodBackend.URL()))
+
+	config := ReadConfig("cache_namespaces")
+	_, shutdown, err := proxyd.Start(config)
+	require.NoError(t, err)
+	defer shutdown()
+
+	client := NewProxydClient("http://127.0.0.1:8545")
+	send := func(host string) {
+		var headers map[string]string
+		if host != "" {
+			headers = map[string]string{"X-Forwarded-Host": host}
+		}
+		res, code, err := client.SendRequestWithHeaders(NewRPCReq("999", "eth_chainId", nil), headers)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, code)
+		RequireEqualJSON(t, []byte(`{"jsonrpc": "2.0", "result": "0x38", "id": 999}`), res)
+	}
+
+	send("a.example.com")
+	require.Len(t, goodBackend.Requests(), 1)
+	send("a.example.com")
+	require.Len(t, goodBackend.Requests(), 1)
+
+	// other namespaces and the default one are isolated
+	send("b.example.com")
+	require.Len(t, goodBackend.Requests(), 2)
+	send("")
+	require.Len(t, goodBackend.Requests(), 3)
+
+	// domains sharing a namespace share entries, as do domains without one
+	send("a-eu.example.com")
+	send("other.example.com")
+	require.Len(t, goodBackend.Requests(), 3)
+}
@@ -2,8 +2,12 @@ package integration_tests
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
+	"io"
+	"net/http"
 	"os"
+	"strings"
 	"testing"
 	"time"
 
@@ -264,6 +268,64 @@ func TestBatchCaching(t *testing.T) {
 	require.Equal(t, 1, countRequests(backend, "eth_call"))
 }
 
+func TestPartialBatchCaching(t *testing.T) {
+	redis, err := miniredis.Run()
+	require.NoError(t, err)
+	defer redis.Close()
+
+	hdlr := NewBatchRPCResponseRouter()
+	hdlr.SetRoute("eth_chainId", "1", "0x420")
+	hdlr.SetRoute("net_version", "2", "0x1234")
+	hdlr.SetRoute("eth_call", "3", "dummy_call")
+	hdlr.SetRoute("eth_chainId", "4", "0x420")
+	hdlr.SetRoute("eth_blockNumber", "5", "0x100")
+	hdlr.SetRoute("net_version", "6", "0x1234")
+
+	backend := NewMockBackend(hdlr)
+	defer backend.Close()
+
+	require.NoError(t, os.Setenv("GOOD_BACKEND_RPC_URL", backend.URL()))
+	require.NoError(t, os.Setenv("REDIS_URL", fmt.Sprintf("redis://127.0.0.1:%s", redis.Port())))
+
+	config := ReadConfig("caching")
+	client := NewProxydClient("http://127.0.0.1:8545")
+	_, shutdown, err := proxyd.Start(config)
+	require.NoError(t, err)
+	defer shutdown()
+
+	// warm the cache
+	_, _, err = client.SendBatchRPC(
+		NewRPCReq("1", "eth_chainId", nil),
+		NewRPCReq("2", "net_version", nil),
+	)
+	require.NoError(t, err)
+	backend.Reset()
+
+	// cache hits are interleaved with misses
+	res, code, err := client.SendBatchRPC(
+		NewRPCReq("3", "eth_call", []interface{}{`{"to":"0x1234"}`, "latest"}),
+		NewRPCReq("4", "eth_chainId", nil),
+		NewRPCReq("5", "eth_blockNumber", nil),
+		NewRPCReq("6", "net_version", nil),
+	)
+	require.NoError(t, err)
+	require.Equal(t, 200, code)
+	RequireEqualJSON(t, []byte(asArray(
+		`{"jsonrpc": "2.0", "result": "dummy_call", "id": 3}`,
+		`{"jsonrpc": "2.0", "result": "0x420", "id": 4}`,
+		`{"jsonrpc": "2.0", "result": "0x100", "id": 5}`,
+		`{"jsonrpc": "2.0", "result": "0x1234", "id": 6}`,
+	)), res)
+
+	// only the misses are forwarded, in a single upstream batch
+	require.Len(t, backend.Requests(), 1)
+	var forwarded []proxyd.RPCReq
+	require.NoError(t, json.Unmarshal(backend.Requests()[0].Body, &forwarded))
+	require.Len(t, forwarded, 2)
+	require.Equal(t, "eth_call", forwarded[0].Method)
+	require.Equal(t, "eth_blockNumber", forwarded[1].Method)
+}
+
 func TestCachingWithReadReplica(t *testing.T) {
 	primary, err := miniredis.Run()
 	require.NoError(t, err)
@@ -330,3 +392,328 @@ func countRequests(backend *MockBackend, name string) int {
 	}
 	return count
 }
+
+func TestCacheFlush(t *testing.T) {
+	redis, err := miniredis.Run()
+	require.NoError(t, err)
+	defer redis.Close()
+
+	hdlr := NewBatchRPCResponseRouter()
+	hdlr.SetRoute("eth_chainId", "999", "0x420")
+	hdlr.SetRoute("net_version", "999", "0x1234")
+
+	backend := NewMockBackend(hdlr)
+	defer backend.Close()
+
+	require.NoError(t, os.Setenv("GOOD_BACKEND_RPC_URL", backend.URL()))
+	require.NoError(t, os.Setenv("REDIS_URL", fmt.Sprintf("redis://127.0.0.1:%s", redis.Port())))
+	config := ReadConfig("caching")
+	config.Admin.Token = "admin-secret"
+	client := NewProxydClient("http://127.0.0.1:8545")
+	_, shutdown, err := proxyd.Start(config)
+	require.NoError(t, err)
+	defer shutdown()
+
+	require.NoError(t, redis.Set("unrelated:key", "value"))
+
+	flush := func(token string, body string) (int, []byte) {
+		req, err := http.NewRequest("POST", "http://127.0.0.1:8545/admin/cache/flush", strings.NewReader(body))
+		require.NoError(t, err)
+		req.Header.Set("Authorization", "Bearer "+token)
+		res, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer res.Body.Close()
+		resBody, err := io.ReadAll(res.Body)
+		require.NoError(t, err)
+		return res.StatusCode, resBody
+	}
+
+	for _, method := range []string{"eth_chainId", "net_version"} {
+		_, _, err := client.SendRPC(method, nil)
+		require.NoError(t, err)
+	}
+	require.Equal(t, 1, countRequests(backend, "eth_chainId"))
+
+	code, _ := flush("wrong", "")
+	require.Equal(t, http.StatusUnauthorized, code)
+
+	code, body := flush("admin-secret", "")
+	require.Equal(t, http.StatusOK, code)
+	RequireEqualJSON(t, []byte(`{"removed":0}`), body)
+
+	code, body = flush("admin-secret", `{"methods":["eth_chainId"],"redis":true}`)
+	require.Equal(t, http.StatusOK, code)
+	RequireEqualJSON(t, []byte(`{"removed":1}`), body)
+
+	_, _, err = client.SendRPC("eth_chainId", nil)
+	require.NoError(t, err)
+	require.Equal(t, 2, countRequests(backend, "eth_chainId"))
+	_, _, err = client.SendRPC("net_version", nil)
+	require.NoError(t, err)
+	require.Equal(t, 1, countRequests(backend, "net_version"))
+
+	code, body = flush("admin-secret", `{"redis":true}`)
+	require.Equal(t, http.StatusOK, code)
+	RequireEqualJSON(t, []byte(`{"removed":2}`), body)
+
+	val, err := redis.Get("unrelated:key")
+	require.NoError(t, err)
+	require.Equal(t, "value", val)
+}
+
+func TestRedisKeyPrefix(t *testing.T) {
+	redis, err := miniredis.Run()
+	require.NoError(t, err)
+	defer redis.Close()
+
+	hdlr := NewBatchRPCResponseRouter()
+	hdlr.SetRoute("eth_chainId", "999", "0x420")
+
+	backend := NewMockBackend(hdlr)
+	defer backend.Close()
+
+	require.NoError(t, os.Setenv("GOOD_BACKEND_RPC_URL", backend.URL()))
+	require.NoError(t, os.Setenv("REDIS_URL", fmt.Sprintf("redis://127.0.0.1:%s", redis.Port())))
+	config := ReadConfig("caching")
+	config.Redis.KeyPrefix = "deploy-a"
+	config.RateLimit.UseRedis = true
+	config.RateLimit.BaseRate = 100
+	config.RateLimit.BaseInterval = proxyd.TOMLDuration(time.Minute)
+	config.Admin.Token = "admin-secret"
+	client := NewProxydClient("http://127.0.0.1:8545")
+	_, shutdown, err := proxyd.Start(config)
+	require.NoError(t, err)
+	defer shutdown()
+
+	otherDeployment := "deploy-b:cache:eth_chainId:abc"
+	require.NoError(t, redis.Set(otherDeployment, "value"))
+
+	_, _, err = client.SendRPC("eth_chainId", nil)
+	require.NoError(t, err)
+
+	var cacheKeys, rateLimitKeys int
+	for _, key := range redis.Keys() {
+		if key == otherDeployment {
+			continue
+		}
+		require.True(t, strings.HasPrefix(key, "deploy-a:"), key)
+		if strings.HasPrefix(key, "deploy-a:cache:") {
+			cacheKeys++
+		}
+		if strings.HasPrefix(key, "deploy-a:rate_limit:") {
+			rateLimitKeys++
+		}
+	}
+	require.Equal(t, 1, cacheKeys)
+	require.Equal(t, 1, rateLimitKeys)
+
+	req, err := http.NewRequest("POST", "http://127.0.0.1:8545/admin/cache/flush", strings.NewReader(`{"redis":true}`))
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer admin-secret")
+	res, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer res.Body.Close()
+	body, err := io.ReadAll(res.Body)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	RequireEqualJSON(t, []byte(`{"removed":1}`), body)
+
+	require.True(t, redis.Exists(otherDeployment))
+}
+
+func TestCacheETag(t *testing.T) {
+	redis, err := miniredis.Run()
+	require.NoError(t, err)
+	defer redis.Close()
+
+	hdlr := NewBatchRPCResponseRouter()
+	for _, id := range []string{"1", "2", "3", "4"} {
+		hdlr.SetRoute("eth_chainId", id, "0x420")
+	}
+	hdlr.SetRoute("eth_blockNumber", "5", "0x64")
+
+	backend := NewMockBackend(hdlr)
+	defer backend.Close()
+
+	require.NoError(t, os.Setenv("GOOD_BACKEND_RPC_URL", backend.URL()))
+	require.NoError(t, os.Setenv("REDIS_URL", fmt.Sprintf("redis://127.0.0.1:%s", redis.Port())))
+	config := ReadConfig("caching")
+	config.Cache.EnableETag = true
+	_, shutdown, err := proxyd.Start(config)
+	require.NoError(t, err)
+	defer shutdown()
+
+	send := func(id string, method string, ifNoneMatch string) (*http.Response, []byte) {
+		body := fmt.Sprintf(`{"jsonrpc":"2.0","method":"%s","params":[],"id":%s}`, method, id)
+		req, err := http.NewRequest("POST", "http://127.0.0.1:8545", strings.NewReader(body))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		res, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer res.Body.Close()
+		resBody, err := io.ReadAll(res.Body)
+		require.NoError(t, err)
+		return res, resBody
+	}
+
+	res, body := send("1", "eth_chainId", "")
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	RequireEqualJSON(t, []byte(`{"jsonrpc":"2.0","result":"0x420","id":1}`), body)
+	etag := res.Header.Get("ETag")
+	require.NotEmpty(t, etag)
+
+	t.Run("matching etag", func(t *testing.T) {
+		// the etag doesn't depend on the request ID
+		res, body := send("2", "eth_chainId", etag)
+		require.Equal(t, http.StatusNotModified, res.StatusCode)
+		require.Equal(t, etag, res.Header.Get("ETag"))
+		require.Empty(t, body)
+
+		res, _ = send("3", "eth_chainId", `"other", W/`+etag)
+		require.Equal(t, http.StatusNotModified, res.StatusCode)
+	})
+
+	t.Run("non-matching etag", func(t *testing.T) {
+		res, body := send("4", "eth_chainId", `"other"`)
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		require.Equal(t, etag, res.Header.Get("ETag"))
+		RequireEqualJSON(t, []byte(`{"jsonrpc":"2.0","result":"0x420","id":4}`), body)
+	})
+
+	t.Run("not cacheable", func(t *testing.T) {
+		res, _ := send("5", "eth_blockNumber", "*")
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		require.Empty(t, res.Header.Get("ETag"))
+	})
+}
+
+func TestCacheNoCacheHeader(t *testing.T) {
+	redis, err := miniredis.Run()
+	require.NoError(t, err)
+	defer redis.Close()
+
+	hdlr := NewBatchRPCResponseRouter()
+	hdlr.SetRoute("eth_chainId", "999", "0x420")
+	hdlr.SetRoute("net_version", "999", "0x1234")
+
+	backend := NewMockBackend(hdlr)
+	defer backend.Close()
+
+	require.NoError(t, os.Setenv("GOOD_BACKEND_RPC_URL", backend.URL()))
+	require.NoError(t, os.Setenv("REDIS_URL", fmt.Sprintf("redis://127.0.0.1:%s", redis.Port())))
+	client := NewProxydClient("http://127.0.0.1:8545")
+	noCache := map[string]string{"X-Proxyd-No-Cache": "true"}
+	start := func(t *testing.T, trustedIPs []string) func() {
+		redis.FlushAll()
+		config := ReadConfig("caching")
+		config.Cache.EnableNoCacheHeader = true
+		config.Cache.NoCacheHeaderTrustedIPs = trustedIPs
+		_, shutdown, err := proxyd.Start(config)
+		require.NoError(t, err)
+		return shutdown
+	}
+	send := func(t *testing.T, method string, headers map[string]string) {
+		res, code, err := client.SendRequestWithHeaders(NewRPCReq("999", method, nil), headers)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, code)
+		require.NotContains(t, string(res), "error")
+	}
+
+	t.Run("bypasses warm cache", func(t *testing.T) {
+		defer start(t, nil)()
+		calls := hdlr.GetNumCalls("eth_chainId", "999")
+
+		send(t, "eth_chainId", nil)
+		send(t, "eth_chainId", nil)
+		require.Equal(t, calls+1, hdlr.GetNumCalls("eth_chainId", "999"))
+
+		send(t, "eth_chainId", noCache)
+		require.Equal(t, calls+2, hdlr.GetNumCalls("eth_chainId", "999"))
+	})
+
+	t.Run("skips cache write", func(t *testing.T) {
+		defer start(t, nil)()
+		calls := hdlr.GetNumCalls("net_version", "999")
+
+		send(t, "net_version", noCache)
+		send(t, "net_version", nil)
+		require.Equal(t, calls+2, hdlr.GetNumCalls("net_version", "999"))
+	})
+
+	t.Run("ignored from untrusted client", func(t *testing.T) {
+		defer start(t, []string{"10.0.0.0/8"})()
+		calls := hdlr.GetNumCalls("eth_chainId", "999")
+
+		send(t, "eth_chainId", nil)
+		send(t, "eth_chainId", noCache)
+		require.Equal(t, calls+1, hdlr.GetNumCalls("eth_chainId", "999"))
+
+		// a spoofed X-Forwarded-For isn't believed
+		send(t, "eth_chainId", map[string]string{"X-Proxyd-No-Cache": "true", "X-Forwarded-For": "10.1.2.3"})
+		require.Equal(t, calls+1, hdlr.GetNumCalls("eth_chainId", "999"))
+	})
+
+	t.Run("honored from trusted peer", func(t *testing.T) {
+		defer start(t, []string{"127.0.0.1"})()
+		calls := hdlr.GetNumCalls("eth_chainId", "999")
+
+		send(t, "eth_chainId", nil)
+		send(t, "eth_chainId", noCache)
+		require.Equal(t, calls+2, hdlr.GetNumCalls("eth_chainId", "999"))
+	})
+
+	t.Run("ignored when disabled", func(t *testing.T) {
+		redis.FlushAll()
+		_, shutdown, err := proxyd.Start(ReadConfig("caching"))
+		require.NoError(t, err)
+		defer shutdown()
+		calls := hdlr.GetNumCalls("eth_chainId", "999")
+
+		send(t, "eth_chainId", nil)
+		send(t, "eth_chainId", noCache)
+		require.Equal(t, calls+1, hdlr.GetNumCalls("eth_chainId", "999"))
+	})
+}
+
+func TestRedisKeyPrefixUnset(t *testing.T) {
+	redis, err := miniredis.Run()
+	require.NoError(t, err)
+	defer redis.Close()
+
+	hdlr := NewBatchRPCResponseRouter()
+	hdlr.SetRoute("eth_chainId", "999", "0x420")
+
+	backend := NewMockBackend(hdlr)
+	defer backend.Close()
+
+	require.NoError(t, os.Setenv("GOOD_BACKEND_RPC_URL", backend.URL()))
+	require.NoError(t, os.Setenv("REDIS_URL", fmt.Sprintf("redis://127.0.0.1:%s", redis.Port())))
+	config := ReadConfig("caching")
+	config.Redis.Namespace = ""
+	config.RateLimit.UseRedis = true
+	config.RateLimit.BaseRate = 100
+	config.RateLimit.BaseInterval = proxyd.TOMLDuration(time.Minute)
+	client := NewProxydClient("http://127.0.0.1:8545")
+	_, shutdown, err := proxyd.Start(config)
+	require.NoError(t, err)
+	defer shutdown()
+
+	_, _, err = client.SendRPC("eth_chainId", nil)
+	require.NoError(t, err)
+
+	// without a prefix, keys keep the names of earlier releases
+	var cacheKeys, rateLimitKeys int
+	for _, key := range redis.Keys() {
+		if strings.HasPrefix(key, "cache:") {
+			cacheKeys++
+		}
+		if strings.HasPrefix(key, "rate_limit:") {
+			rateLimitKeys++
+		}
+	}
+	require.Equal(t, 1, cacheKeys)
+	require.Equal(t, 1, rateLimitKeys)
+}
@@ -0,0 +1,96 @@
+package integration_tests
+
+import (
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/ethereum-optimism/infra/proxyd"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCallAll(t *testing.T) {
+	hdlr1 := NewBatchRPCResponseRouter()
+	hdlr1.SetRoute("eth_blockNumber", "999", "0x100")
+	node1 := NewMockBackend(hdlr1)
+	defer node1.Close()
+
+	hdlr2 := NewBatchRPCResponseRouter()
+	hdlr2.SetRoute("eth_blockNumber", "999", "0x101")
+	node2 := NewMockBackend(hdlr2)
+	defer node2.Close()
+
+	require.NoError(t, os.Setenv("NODE1_URL", node1.URL()))
+	require.NoError(t, os.Setenv("NODE2_URL", node2.URL()))
+
+	config := ReadConfig("call_all")
+	_, shutdown, err := proxyd.Start(config)
+	require.NoError(t, err)
+	defer func() { shutdown() }()
+
+	admin := NewProxydClientWithHeaders("http://127.0.0.1:8545", http.Header{
+		"Authorization": []string{"Bearer admin-secret"},
+	})
+
+	t.Run("returns the response of every backend", func(t *testing.T) {
+		res, code, err := admin.SendRPC(proxyd.CallAllMethod, []interface{}{"eth_blockNumber", []interface{}{}})
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, code)
+		RequireEqualJSON(t, []byte(`{"jsonrpc":"2.0","id":999,"result":{
+			"node1":{"jsonrpc":"2.0","id":999,"result":"0x100"},
+			"node2":{"jsonrpc":"2.0","id":999,"result":"0x101"}
+		}}`), res)
+		require.Equal(t, 1, countRequests(node1, "eth_blockNumber"))
+		require.Equal(t, 1, countRequests(node2, "eth_blockNumber"))
+	})
+
+	t.Run("returns backend errors", func(t *testing.T) {
+		node1.SetHandler(SingleResponseHandler(200, `{"jsonrpc":"2.0","id":999,"error":{"code":-32000,"message":"header not found"}}`))
+		defer node1.SetHandler(hdlr1)
+		hdlr2.SetRoute("eth_getBalance", "999", "0x1")
+		res, code, err := admin.SendRPC(proxyd.CallAllMethod, []interface{}{"eth_getBalance", []interface{}{"0x0", "latest"}})
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, code)
+		RequireEqualJSON(t, []byte(`{"jsonrpc":"2.0","id":999,"result":{
+			"node1":{"jsonrpc":"2.0","id":999,"error":{"code":-32000,"message":"header not found"}},
+			"node2":{"jsonrpc":"2.0","id":999,"result":"0x1"}
+		}}`), res)
+	})
+
+	t.Run("rejects unmapped methods", func(t *testing.T) {
+		res, _, err := admin.SendRPC(proxyd.CallAllMethod, []interface{}{"eth_chainId"})
+		require.NoError(t, err)
+		RequireEqualJSON(t, []byte(`{"jsonrpc":"2.0","id":999,"error":{"code":-32601,"message":"rpc method is not whitelisted"}}`), res)
+	})
+
+	t.Run("rejects invalid params", func(t *testing.T) {
+		res, _, err := admin.SendRPC(proxyd.CallAllMethod, []interface{}{})
+		require.NoError(t, err)
+		RequireEqualJSON(t, []byte(`{"jsonrpc":"2.0","id":999,"error":{"code":-32602,"message":"expected a method and optional params"}}`), res)
+	})
+
+	t.Run("requires the admin token", func(t *testing.T) {
+		for _, client := range []*ProxydHTTPClient{
+			NewProxydClient("http://127.0.0.1:8545"),
+			NewProxydClientWithHeaders("http://127.0.0.1:8545", http.Header{
+				"Authorization": []string{"Bearer wrong"},
+			}),
+		} {
+			res, code, err := client.SendRPC(proxyd.CallAllMethod, []interface{}{"eth_blockNumber"})
+			require.NoError(t, err)
+			require.Equal(t, http.StatusUnauthorized, code)
+			RequireEqualJSON(t, []byte(`{"jsonrpc":"2.0","id":999,"error":{"code":-32031,"message":"admin authorization required"}}`), res)
+		}
+	})
+
+	t.Run("is not whitelisted when disabled", func(t *testing.T) {
+		shutdown()
+		config.Admin.EnableCallAll = false
+		_, shutdown, err = proxyd.Start(config)
+		require.NoError(t, err)
+
+		res, _, err := admin.SendRPC(proxyd.CallAllMethod, []interface{}{"eth_blockNumber"})
+		require.NoError(t, err)
+		RequireEqualJSON(t, []byte(`{"jsonrpc":"2.0","id":999,"error":{"code":-32601,"message":"rpc method is not whitelisted"}}`), res)
+	})
+}
@@ -0,0 +1,115 @@
+package integration_tests
+
+import (
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/ethereum-optimism/infra/proxyd"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+)
+
+const canaryResponse = `{"jsonrpc": "2.0", "result": "canary", "id": 999}`
+
+func TestCanary(t *testing.T) {
+	baseline := NewMockBackend(BatchedResponseHandler(200, goodResponse))
+	defer baseline.Close()
+	canary := NewMockBackend(BatchedResponseHandler(200, canaryResponse))
+	defer canary.Close()
+
+	require.NoError(t, os.Setenv("BASELINE_BACKEND_RPC_URL", baseline.URL()))
+	require.NoError(t, os.Setenv("CANARY_BACKEND_RPC_URL", canary.URL()))
+
+	config := ReadConfig("canary")
+	client := NewProxydClient("http://127.0.0.1:8545")
+
+	t.Run("canary serves its share of the method", func(t *testing.T) {
+		_, shutdown, err := proxyd.Start(config)
+		require.NoError(t, err)
+		defer shutdown()
+		baseline.Reset()
+		canary.Reset()
+
+		res, code, err := client.SendRPC("eth_call", nil)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, code)
+		RequireEqualJSON(t, []byte(canaryResponse), res)
+		require.Equal(t, 0.0, canaryErrorRate(t, "canary"))
+
+		// other methods are never canaried
+		res, code, err = client.SendRPC("eth_chainId", nil)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, code)
+		RequireEqualJSON(t, []byte(goodResponse), res)
+
+		require.Equal(t, 1, len(canary.Requests()))
+		require.Equal(t, 1, len(baseline.Requests()))
+	})
+
+	t.Run("canary errors fall back to the baseline", func(t *testing.T) {
+		_, shutdown, err := proxyd.Start(config)
+		require.NoError(t, err)
+		defer shutdown()
+
+		_, code, err := client.SendRPC("eth_call", nil)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, code)
+
+		canary.SetHandler(SingleResponseHandler(200, `{"jsonrpc": "2.0", "error": {"code": -32000, "message": "boom"}, "id": 999}`))
+		defer canary.SetHandler(BatchedResponseHandler(200, canaryResponse))
+		baseline.Reset()
+
+		res, code, err := client.SendRPC("eth_call", nil)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, code)
+		RequireEqualJSON(t, []byte(goodResponse), res)
+		require.Equal(t, 1, len(baseline.Requests()))
+		require.Equal(t, 0.5, canaryErrorRate(t, "canary"))
+	})
+
+	t.Run("calls skipping the canary count towards the baseline", func(t *testing.T) {
+		config.BackendGroups["main"].Canary.Percent = 0
+		defer func() { config.BackendGroups["main"].Canary.Percent = 100 }()
+		_, shutdown, err := proxyd.Start(config)
+		require.NoError(t, err)
+		defer shutdown()
+		canary.Reset()
+
+		baseline.SetHandler(SingleResponseHandler(200, `{"jsonrpc": "2.0", "error": {"code": -32000, "message": "boom"}, "id": 999}`))
+		defer baseline.SetHandler(BatchedResponseHandler(200, goodResponse))
+
+		_, code, err := client.SendRPC("eth_call", nil)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, code)
+		require.Equal(t, 0, len(canary.Requests()))
+		require.Equal(t, 1.0, canaryErrorRate(t, "baseline"))
+	})
+
+	t.Run("undefined canary backend", func(t *testing.T) {
+		config.BackendGroups["main"].Canary.Backend = "missing"
+		defer func() { config.BackendGroups["main"].Canary.Backend = "canary" }()
+		_, _, err := proxyd.Start(config)
+		require.Error(t, err)
+	})
+}
+
+func canaryErrorRate(t *testing.T, target string) float64 {
+	families, err := prometheus.DefaultGatherer.Gather()
+	require.NoError(t, err)
+	for _, family := range families {
+		if family.GetName() != "proxyd_canary_error_rate" {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			labels := make(map[string]string)
+			for _, label := range metric.GetLabel() {
+				labels[label.GetName()] = label.GetValue()
+			}
+			if labels["backend_group"] == "main" && labels["method"] == "eth_call" && labels["target"] == target {
+				return metric.GetGauge().GetValue()
+			}
+		}
+	}
+	return -1
+}
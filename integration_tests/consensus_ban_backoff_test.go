@@ -0,0 +1,91 @@
+package integration_tests
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/ethereum-optimism/infra/proxyd"
+	ms "github.com/ethereum-optimism/infra/proxyd/tools/mockserver/handler"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConsensusBanBackoff(t *testing.T) {
+	dir, err := os.Getwd()
+	require.NoError(t, err)
+	responses := path.Join(dir, "testdata/consensus_responses.yml")
+
+	h1 := ms.MockedHandler{Overrides: []*ms.MethodTemplate{}, Autoload: true, AutoloadFile: responses}
+	h2 := ms.MockedHandler{Overrides: []*ms.MethodTemplate{}, Autoload: true, AutoloadFile: responses}
+	node1 := NewMockBackend(http.HandlerFunc(h1.Handler))
+	defer node1.Close()
+	node2 := NewMockBackend(http.HandlerFunc(h2.Handler))
+	defer node2.Close()
+
+	require.NoError(t, os.Setenv("NODE1_URL", node1.URL()))
+	require.NoError(t, os.Setenv("NODE2_URL", node2.URL()))
+
+	svr, shutdown, err := proxyd.Start(ReadConfig("consensus_ban_backoff"))
+	require.NoError(t, err)
+	defer shutdown()
+
+	bg := svr.BackendGroups["node"]
+	be := bg.Backends[0]
+	ctx := context.Background()
+	update := func() {
+		for _, be := range bg.Backends {
+			bg.Consensus.UpdateBackend(ctx, be)
+		}
+		bg.Consensus.UpdateBackendGroupConsensus(ctx)
+	}
+
+	t.Run("ban period grows up to the max", func(t *testing.T) {
+		bg.Consensus.Reset()
+		for i, expected := range []time.Duration{
+			100 * time.Millisecond,
+			200 * time.Millisecond,
+			400 * time.Millisecond,
+			400 * time.Millisecond,
+		} {
+			bg.Consensus.Ban(be)
+			require.Equal(t, i+1, bg.Consensus.BanCount(be))
+			require.InDelta(t, expected, time.Until(bg.Consensus.BannedUntil(be)), float64(50*time.Millisecond))
+		}
+	})
+
+	t.Run("ban count resets after staying unbanned", func(t *testing.T) {
+		bg.Consensus.Reset()
+		bg.Consensus.Ban(be)
+		bg.Consensus.Ban(be)
+		require.Equal(t, 2, bg.Consensus.BanCount(be))
+
+		// unbanned for longer than the max ban period
+		time.Sleep(700 * time.Millisecond)
+		bg.Consensus.Ban(be)
+		require.Equal(t, 1, bg.Consensus.BanCount(be))
+		require.InDelta(t, 100*time.Millisecond, time.Until(bg.Consensus.BannedUntil(be)), float64(50*time.Millisecond))
+	})
+
+	t.Run("rejoins after consecutive recovery polls", func(t *testing.T) {
+		bg.Consensus.Reset()
+		update()
+		require.Contains(t, bg.Consensus.GetConsensusGroup(), be)
+
+		bg.Consensus.Ban(be)
+		update()
+		require.NotContains(t, bg.Consensus.GetConsensusGroup(), be)
+
+		time.Sleep(150 * time.Millisecond)
+		require.False(t, bg.Consensus.IsBanned(be))
+
+		// the ban expired, but one poll isn't enough to rejoin
+		update()
+		require.NotContains(t, bg.Consensus.GetConsensusGroup(), be)
+
+		update()
+		require.Contains(t, bg.Consensus.GetConsensusGroup(), be)
+	})
+}
@@ -0,0 +1,52 @@
+package integration_tests
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/ethereum-optimism/infra/proxyd"
+	ms "github.com/ethereum-optimism/infra/proxyd/tools/mockserver/handler"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConsensusHeadProbe(t *testing.T) {
+	dir, err := os.Getwd()
+	require.NoError(t, err)
+
+	h := ms.MockedHandler{
+		Overrides:    []*ms.MethodTemplate{},
+		Autoload:     true,
+		AutoloadFile: path.Join(dir, "testdata/consensus_responses.yml"),
+	}
+	h.AddOverride(&ms.MethodTemplate{
+		Method: "bsc_getHead",
+		Response: buildResponse(map[string]interface{}{
+			"header": map[string]string{
+				"number": "0x1a0",
+				"hash":   "hash_0x1a0",
+			},
+		}),
+	})
+	node := NewMockBackend(http.HandlerFunc(h.Handler))
+	defer node.Close()
+	require.NoError(t, os.Setenv("NODE1_URL", node.URL()))
+
+	config := ReadConfig("consensus_head_probe")
+	svr, shutdown, err := proxyd.Start(config)
+	require.NoError(t, err)
+	defer shutdown()
+
+	bg := svr.BackendGroups["node"]
+	require.NotNil(t, bg.Consensus)
+	be := bg.Backends[0]
+
+	bg.Consensus.UpdateBackend(context.Background(), be)
+
+	number, hash := bg.Consensus.GetBackendState(be).GetLatestBlock()
+	require.Equal(t, "0x1a0", number.String())
+	require.Equal(t, "hash_0x1a0", hash)
+	require.Equal(t, "0xe1", bg.Consensus.GetBackendState(be).GetSafeBlockNumber().String())
+}
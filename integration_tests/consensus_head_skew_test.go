@@ -0,0 +1,75 @@
+package integration_tests
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+
+	"github.com/ethereum-optimism/infra/proxyd"
+	ms "github.com/ethereum-optimism/infra/proxyd/tools/mockserver/handler"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConsensusHeadSkew(t *testing.T) {
+	dir, err := os.Getwd()
+	require.NoError(t, err)
+
+	h := ms.MockedHandler{
+		Overrides:    []*ms.MethodTemplate{},
+		Autoload:     true,
+		AutoloadFile: path.Join(dir, "testdata/consensus_responses.yml"),
+	}
+	node := NewMockBackend(http.HandlerFunc(h.Handler))
+	defer node.Close()
+	require.NoError(t, os.Setenv("NODE1_URL", node.URL()))
+
+	config := ReadConfig("consensus_head_skew")
+	svr, shutdown, err := proxyd.Start(config)
+	require.NoError(t, err)
+	defer shutdown()
+
+	bg := svr.BackendGroups["node"]
+	require.NotNil(t, bg.Consensus)
+	be := bg.Backends[0]
+	ctx := context.Background()
+
+	headAt := func(ts time.Time) {
+		h.ResetOverrides()
+		h.AddOverride(&ms.MethodTemplate{
+			Method: "eth_getBlockByNumber",
+			Block:  "0x101",
+			Response: buildResponse(map[string]string{
+				"number":    "0x101",
+				"hash":      "hash_0x101",
+				"timestamp": hexutil.EncodeUint64(uint64(ts.Unix())),
+			}),
+		})
+	}
+
+	tests := []struct {
+		name   string
+		ts     time.Time
+		banned bool
+	}{
+		{"fresh head", time.Now().Add(-3 * time.Second), false},
+		{"stale head", time.Now().Add(-10 * time.Minute), true},
+		{"head in the future", time.Now().Add(10 * time.Minute), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bg.Consensus.Reset()
+			headAt(tt.ts)
+			bg.Consensus.UpdateBackend(ctx, be)
+			require.Equal(t, tt.banned, bg.Consensus.IsBanned(be))
+			if !tt.banned {
+				number, _ := bg.Consensus.GetBackendState(be).GetLatestBlock()
+				require.Equal(t, "0x101", number.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package integration_tests
+
+import (
+	"net/http"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/ethereum-optimism/infra/proxyd"
+	ms "github.com/ethereum-optimism/infra/proxyd/tools/mockserver/handler"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConsensusPollerInterval(t *testing.T) {
+	dir, err := os.Getwd()
+	require.NoError(t, err)
+
+	newNode := func() *MockBackend {
+		h := ms.MockedHandler{
+			Overrides:    []*ms.MethodTemplate{},
+			Autoload:     true,
+			AutoloadFile: path.Join(dir, "testdata/consensus_responses.yml"),
+		}
+		return NewMockBackend(http.HandlerFunc(h.Handler))
+	}
+	fast := newNode()
+	defer fast.Close()
+	slow := newNode()
+	defer slow.Close()
+	require.NoError(t, os.Setenv("NODE1_URL", fast.URL()))
+	require.NoError(t, os.Setenv("NODE2_URL", slow.URL()))
+
+	config := ReadConfig("consensus_poller_interval")
+	_, shutdown, err := proxyd.Start(config)
+	require.NoError(t, err)
+	defer shutdown()
+
+	// Each poll fetches the safe block once.
+	polls := func(node *MockBackend) int {
+		return countRequests(node, `"safe"`)
+	}
+
+	// The fast group polls at the global interval of 50ms, while the slow
+	// group overrides it with 1h and only polls once at startup.
+	require.Eventually(t, func() bool {
+		return polls(fast) >= 5
+	}, 2*time.Second, 10*time.Millisecond)
+	require.Equal(t, 1, polls(slow))
+}
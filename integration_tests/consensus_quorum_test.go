@@ -0,0 +1,154 @@
+package integration_tests
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/ethereum-optimism/infra/proxyd"
+	ms "github.com/ethereum-optimism/infra/proxyd/tools/mockserver/handler"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConsensusQuorum(t *testing.T) {
+	dir, err := os.Getwd()
+	require.NoError(t, err)
+	responses := path.Join(dir, "testdata/consensus_responses.yml")
+
+	for _, name := range []string{"NODE1_URL", "NODE2_URL", "NODE3_URL"} {
+		h := ms.MockedHandler{
+			Overrides:    []*ms.MethodTemplate{},
+			Autoload:     true,
+			AutoloadFile: responses,
+		}
+		node := NewMockBackend(http.HandlerFunc(h.Handler))
+		defer node.Close()
+		require.NoError(t, os.Setenv(name, node.URL()))
+	}
+
+	config := ReadConfig("consensus_quorum")
+	svr, shutdown, err := proxyd.Start(config)
+	require.NoError(t, err)
+	defer shutdown()
+	client := NewProxydClient("http://127.0.0.1:8545")
+
+	bg := svr.BackendGroups["node"]
+	require.NotNil(t, bg.Consensus)
+	ctx := context.Background()
+
+	update := func() {
+		for _, be := range bg.Backends {
+			bg.Consensus.UpdateBackend(ctx, be)
+		}
+		bg.Consensus.UpdateBackendGroupConsensus(ctx)
+	}
+
+	// all backends agree
+	update()
+	require.Equal(t, 3, len(bg.Consensus.GetConsensusGroup()))
+	require.Equal(t, float64(1), quorumMetric(t, "node"))
+
+	// one backend down still leaves the quorum of two
+	bg.Consensus.Ban(bg.Backends[0])
+	update()
+	require.Equal(t, 2, len(bg.Consensus.GetConsensusGroup()))
+	require.Equal(t, float64(1), quorumMetric(t, "node"))
+	_, code, err := client.SendRPC("eth_getBlockByNumber", []interface{}{"0x101", false})
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, code)
+
+	// below quorum the group stops serving, though a backend is in agreement
+	bg.Consensus.Ban(bg.Backends[1])
+	update()
+	require.Equal(t, 0, len(bg.Consensus.GetConsensusGroup()))
+	require.Equal(t, float64(0), quorumMetric(t, "node"))
+	_, code, err = client.SendRPC("eth_getBlockByNumber", []interface{}{"0x101", false})
+	require.NoError(t, err)
+	require.Equal(t, http.StatusServiceUnavailable, code)
+
+	// regaining quorum restores the group
+	bg.Consensus.Unban(bg.Backends[1])
+	update()
+	require.Equal(t, 2, len(bg.Consensus.GetConsensusGroup()))
+	require.Equal(t, float64(1), quorumMetric(t, "node"))
+}
+
+func TestConsensusQuorumFraction(t *testing.T) {
+	dir, err := os.Getwd()
+	require.NoError(t, err)
+	responses := path.Join(dir, "testdata/consensus_responses.yml")
+
+	for _, name := range []string{"NODE1_URL", "NODE2_URL", "NODE3_URL"} {
+		h := ms.MockedHandler{
+			Overrides:    []*ms.MethodTemplate{},
+			Autoload:     true,
+			AutoloadFile: responses,
+		}
+		node := NewMockBackend(http.HandlerFunc(h.Handler))
+		defer node.Close()
+		require.NoError(t, os.Setenv(name, node.URL()))
+	}
+
+	// every backend must agree
+	config := ReadConfig("consensus_quorum")
+	config.BackendGroups["node"].ConsensusMinBackends = 0
+	config.BackendGroups["node"].ConsensusMinFraction = 1
+	svr, shutdown, err := proxyd.Start(config)
+	require.NoError(t, err)
+	defer shutdown()
+
+	bg := svr.BackendGroups["node"]
+	ctx := context.Background()
+	update := func() {
+		for _, be := range bg.Backends {
+			bg.Consensus.UpdateBackend(ctx, be)
+		}
+		bg.Consensus.UpdateBackendGroupConsensus(ctx)
+	}
+
+	update()
+	require.Equal(t, 3, len(bg.Consensus.GetConsensusGroup()))
+
+	bg.Consensus.Ban(bg.Backends[2])
+	update()
+	require.Equal(t, 0, len(bg.Consensus.GetConsensusGroup()))
+	require.Equal(t, float64(0), quorumMetric(t, "node"))
+}
+
+func TestConsensusQuorumInvalid(t *testing.T) {
+	for _, name := range []string{"NODE1_URL", "NODE2_URL", "NODE3_URL"} {
+		require.NoError(t, os.Setenv(name, "http://127.0.0.1:1"))
+	}
+
+	config := ReadConfig("consensus_quorum")
+	config.BackendGroups["node"].ConsensusMinBackends = 4
+	_, _, err := proxyd.Start(config)
+	require.ErrorContains(t, err, "consensus_min_backends")
+
+	config = ReadConfig("consensus_quorum")
+	config.BackendGroups["node"].ConsensusMinFraction = 1.5
+	_, _, err = proxyd.Start(config)
+	require.ErrorContains(t, err, "consensus_min_fraction")
+}
+
+// quorumMetric returns the group_consensus_quorum gauge of group.
+func quorumMetric(t *testing.T, group string) float64 {
+	families, err := prometheus.DefaultGatherer.Gather()
+	require.NoError(t, err)
+	for _, family := range families {
+		if family.GetName() != "proxyd_group_consensus_quorum" {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			for _, label := range metric.GetLabel() {
+				if label.GetName() == "backend_group_name" && label.GetValue() == group {
+					return metric.GetGauge().GetValue()
+				}
+			}
+		}
+	}
+	return -1
+}
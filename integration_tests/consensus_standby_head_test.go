@@ -0,0 +1,83 @@
+package integration_tests
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/ethereum-optimism/infra/proxyd"
+	ms "github.com/ethereum-optimism/infra/proxyd/tools/mockserver/handler"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConsensusStandbyHead(t *testing.T) {
+	dir, err := os.Getwd()
+	require.NoError(t, err)
+	responses := path.Join(dir, "testdata/consensus_responses.yml")
+
+	h1 := ms.MockedHandler{
+		Overrides:    []*ms.MethodTemplate{},
+		Autoload:     true,
+		AutoloadFile: responses,
+	}
+	node1 := NewMockBackend(http.HandlerFunc(h1.Handler))
+	defer node1.Close()
+
+	hs := ms.MockedHandler{
+		Overrides:    []*ms.MethodTemplate{},
+		Autoload:     true,
+		AutoloadFile: responses,
+	}
+	for block, number := range map[string]string{"latest": "0x2a0", "safe": "0x280", "finalized": "0x260"} {
+		hs.AddOverride(&ms.MethodTemplate{
+			Method: "eth_getBlockByNumber",
+			Block:  block,
+			Response: buildResponse(map[string]string{
+				"number": number,
+				"hash":   "hash_" + number,
+			}),
+		})
+	}
+	standby := NewMockBackend(http.HandlerFunc(hs.Handler))
+	defer standby.Close()
+
+	require.NoError(t, os.Setenv("NODE1_URL", node1.URL()))
+	require.NoError(t, os.Setenv("STANDBY_URL", standby.URL()))
+
+	config := ReadConfig("consensus_standby_head")
+	svr, shutdown, err := proxyd.Start(config)
+	require.NoError(t, err)
+	defer shutdown()
+
+	bg := svr.BackendGroups["node"]
+	require.NotNil(t, bg.Consensus)
+	be := bg.Backends[0]
+	ctx := context.Background()
+
+	update := func() {
+		bg.Consensus.UpdateBackend(ctx, be)
+		bg.Consensus.UpdateBackendGroupConsensus(ctx)
+	}
+
+	// with quorum the head comes from the group
+	update()
+	require.Equal(t, 1, len(bg.Consensus.GetConsensusGroup()))
+	require.Equal(t, "0x101", bg.Consensus.GetLatestBlockNumber().String())
+	require.Equal(t, 0, len(standby.Requests()))
+
+	// losing quorum falls back to the standby head, without routing to it
+	bg.Consensus.Ban(be)
+	update()
+	require.Equal(t, 0, len(bg.Consensus.GetConsensusGroup()))
+	require.Equal(t, "0x2a0", bg.Consensus.GetLatestBlockNumber().String())
+	require.Equal(t, "0x280", bg.Consensus.GetSafeBlockNumber().String())
+	require.Equal(t, "0x260", bg.Consensus.GetFinalizedBlockNumber().String())
+
+	// regaining quorum goes back to the head of the group
+	bg.Consensus.Unban(be)
+	update()
+	require.Equal(t, 1, len(bg.Consensus.GetConsensusGroup()))
+	require.Equal(t, "0x101", bg.Consensus.GetLatestBlockNumber().String())
+}
@@ -0,0 +1,109 @@
+package integration_tests
+
+import (
+	"net"
+	"net/http"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/ethereum-optimism/infra/proxyd"
+	ms "github.com/ethereum-optimism/infra/proxyd/tools/mockserver/handler"
+	"github.com/stretchr/testify/require"
+)
+
+type startResult struct {
+	svr      *proxyd.Server
+	shutdown func()
+	err      error
+}
+
+func startAsync(config *proxyd.Config) <-chan startResult {
+	ch := make(chan startResult, 1)
+	go func() {
+		svr, shutdown, err := proxyd.Start(config)
+		ch <- startResult{svr, shutdown, err}
+	}()
+	return ch
+}
+
+func isListening(addr string) bool {
+	conn, err := net.DialTimeout("tcp", addr, 100*time.Millisecond)
+	if err != nil {
+		return false
+	}
+	_ = conn.Close()
+	return true
+}
+
+func TestConsensusWarmup(t *testing.T) {
+	dir, err := os.Getwd()
+	require.NoError(t, err)
+
+	responses := path.Join(dir, "testdata/consensus_responses.yml")
+
+	// the node is syncing at first, so that no consensus head is found
+	syncing := ms.MockedHandler{
+		Overrides:    []*ms.MethodTemplate{},
+		Autoload:     true,
+		AutoloadFile: responses,
+	}
+	syncing.AddOverride(&ms.MethodTemplate{
+		Method:   "eth_syncing",
+		Response: buildResponse(true),
+	})
+	node := NewMockBackend(http.HandlerFunc(syncing.Handler))
+	defer node.Close()
+	require.NoError(t, os.Setenv("NODE1_URL", node.URL()))
+
+	started := startAsync(ReadConfig("consensus_warmup"))
+	time.Sleep(500 * time.Millisecond)
+	select {
+	case <-started:
+		t.Fatal("proxyd started without consensus")
+	default:
+	}
+	require.False(t, isListening("127.0.0.1:8545"))
+
+	synced := ms.MockedHandler{
+		Overrides:    []*ms.MethodTemplate{},
+		Autoload:     true,
+		AutoloadFile: responses,
+	}
+	node.SetHandler(http.HandlerFunc(synced.Handler))
+
+	var res startResult
+	select {
+	case res = <-started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("proxyd didn't start after consensus")
+	}
+	require.NoError(t, res.err)
+	defer res.shutdown()
+
+	// block tags are rewritten from the first request
+	require.Equal(t, "0x101", res.svr.BackendGroups["node"].Consensus.GetLatestBlockNumber().String())
+	client := NewProxydClient("http://127.0.0.1:8545")
+	_, code, err := client.SendRPC("eth_getBlockByNumber", []interface{}{"latest", false})
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, code)
+}
+
+func TestConsensusWarmupTimeout(t *testing.T) {
+	node := NewMockBackend(SingleResponseHandler(http.StatusServiceUnavailable, ""))
+	defer node.Close()
+	require.NoError(t, os.Setenv("NODE1_URL", node.URL()))
+
+	config := ReadConfig("consensus_warmup")
+	config.Warmup.ConsensusTimeout = proxyd.TOMLDuration(300 * time.Millisecond)
+	start := time.Now()
+	svr, shutdown, err := proxyd.Start(config)
+	require.NoError(t, err)
+	defer shutdown()
+
+	// proxyd serves anyway once the timeout elapsed
+	require.GreaterOrEqual(t, time.Since(start), 300*time.Millisecond)
+	require.Zero(t, svr.BackendGroups["node"].Consensus.GetLatestBlockNumber())
+	require.True(t, isListening("127.0.0.1:8545"))
+}
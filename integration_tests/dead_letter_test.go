@@ -0,0 +1,57 @@
+package integration_tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum-optimism/infra/proxyd"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeadLetterLog(t *testing.T) {
+	goodBackend := NewMockBackend(BatchedResponseHandler(200, goodResponse))
+	defer goodBackend.Close()
+	badBackend := NewMockBackend(SingleResponseHandler(500, "oh no"))
+	defer badBackend.Close()
+
+	require.NoError(t, os.Setenv("GOOD_BACKEND_RPC_URL", goodBackend.URL()))
+	require.NoError(t, os.Setenv("BAD_BACKEND_RPC_URL", badBackend.URL()))
+
+	dest := filepath.Join(t.TempDir(), "dead_letter.log")
+	config := ReadConfig("dead_letter")
+	config.DeadLetter.Destination = dest
+	client := NewProxydClient("http://127.0.0.1:8545")
+	_, shutdown, err := proxyd.Start(config)
+	require.NoError(t, err)
+	defer shutdown()
+
+	_, code, err := client.SendRPC("eth_chainId", nil)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, code)
+
+	rawTx := "0x02f8b28201a406849502f931849502f931830147f9948f3ddd0fbf3e78ca1d6cd17379ed88e261249b5280b844a9059cbb"
+	_, code, err = client.SendRPC("eth_sendRawTransaction", []interface{}{rawTx})
+	require.NoError(t, err)
+	require.Equal(t, 503, code)
+
+	contents, err := os.ReadFile(dest)
+	require.NoError(t, err)
+	lines := bytes.Split(bytes.TrimSpace(contents), []byte("\n"))
+	require.Len(t, lines, 1)
+	require.NotContains(t, string(contents), rawTx)
+
+	var entry proxyd.DeadLetterEntry
+	require.NoError(t, json.Unmarshal(lines[0], &entry))
+	require.Equal(t, "eth_sendRawTransaction", entry.Method)
+	require.Len(t, entry.ParamsHash, 64)
+	require.Equal(t, proxyd.ErrNoBackends.Code, entry.Error.Code)
+	require.Equal(t, []proxyd.DeadLetterAttempt{{
+		Backend: "bad",
+		Error:   entry.Attempts[0].Error,
+	}}, entry.Attempts)
+	require.NotEmpty(t, entry.Attempts[0].Error)
+}
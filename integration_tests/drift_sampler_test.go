@@ -0,0 +1,75 @@
+package integration_tests
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/ethereum-optimism/infra/proxyd"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDriftSampler(t *testing.T) {
+	const balance = `{"jsonrpc": "2.0", "result": "0x10", "id": 1}`
+	const driftedBalance = `{"jsonrpc": "2.0", "result": "0x20", "id": 1}`
+
+	node1 := NewMockBackend(SingleResponseHandler(200, balance))
+	defer node1.Close()
+	node2 := NewMockBackend(SingleResponseHandler(200, balance))
+	defer node2.Close()
+	node3 := NewMockBackend(SingleResponseHandler(200, driftedBalance))
+	defer node3.Close()
+
+	require.NoError(t, os.Setenv("NODE1_URL", node1.URL()))
+	require.NoError(t, os.Setenv("NODE2_URL", node2.URL()))
+	require.NoError(t, os.Setenv("NODE3_URL", node3.URL()))
+
+	t.Run("rejected config doesn't sample", func(t *testing.T) {
+		config := ReadConfig("drift_sampler")
+		config.BackendGroups["node"].DriftSampleInterval = proxyd.TOMLDuration(10 * time.Millisecond)
+		config.WSBackendGroup = "missing"
+		_, _, err := proxyd.Start(config)
+		require.Error(t, err)
+		time.Sleep(100 * time.Millisecond)
+		require.Empty(t, node1.Requests())
+	})
+
+	svr, shutdown, err := proxyd.Start(ReadConfig("drift_sampler"))
+	require.NoError(t, err)
+	defer shutdown()
+
+	sampler := svr.BackendGroups["node"].DriftSampler
+	require.NotNil(t, sampler)
+	ctx := context.Background()
+
+	t.Run("divergent backend is reported", func(t *testing.T) {
+		sampler.Sample(ctx)
+		require.Equal(t, []string{"node3"}, sampler.DivergentBackends())
+
+		for _, node := range []*MockBackend{node1, node2, node3} {
+			require.Len(t, node.Requests(), 1)
+			var req proxyd.RPCReq
+			require.NoError(t, json.Unmarshal(node.Requests()[0].Body, &req))
+			require.Equal(t, "eth_getBalance", req.Method)
+			require.JSONEq(t, `["0x00000000000000000000000000000000000000aa","0x100"]`, string(req.Params))
+		}
+	})
+
+	t.Run("failing backend is left out", func(t *testing.T) {
+		node3.SetHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+		sampler.Sample(ctx)
+		require.Empty(t, sampler.DivergentBackends())
+	})
+
+	t.Run("no majority", func(t *testing.T) {
+		node2.SetHandler(SingleResponseHandler(200, driftedBalance))
+		node3.SetHandler(SingleResponseHandler(200, `{"jsonrpc": "2.0", "result": "0x30", "id": 1}`))
+		sampler.Sample(ctx)
+		require.Equal(t, []string{"node1", "node2", "node3"}, sampler.DivergentBackends())
+	})
+}
@@ -0,0 +1,108 @@
+package integration_tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/ethereum-optimism/infra/proxyd"
+	"github.com/stretchr/testify/require"
+)
+
+func TestErrorContext(t *testing.T) {
+	node1 := NewMockBackend(SingleResponseHandler(503, "unavailable"))
+	defer node1.Close()
+
+	require.NoError(t, os.Setenv("NODE1_URL", node1.URL()))
+
+	sendWithXFF := func(t *testing.T, xff string) *proxyd.RPCErr {
+		b, err := json.Marshal(NewRPCReq("1", "eth_chainId", nil))
+		require.NoError(t, err)
+		req, err := http.NewRequest("POST", "http://127.0.0.1:8545", bytes.NewReader(b))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+		if xff != "" {
+			req.Header.Set("X-Forwarded-For", xff)
+		}
+		res, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer res.Body.Close()
+		require.Equal(t, http.StatusServiceUnavailable, res.StatusCode)
+		body, err := io.ReadAll(res.Body)
+		require.NoError(t, err)
+
+		var rpcRes proxyd.RPCRes
+		require.NoError(t, json.Unmarshal(body, &rpcRes))
+		require.NotNil(t, rpcRes.Error)
+		require.Equal(t, proxyd.ErrNoBackends.Code, rpcRes.Error.Code)
+		return rpcRes.Error
+	}
+	requireErrorContext := func(t *testing.T, rpcErr *proxyd.RPCErr) {
+		data, err := json.Marshal(rpcErr.Data)
+		require.NoError(t, err)
+		var ec proxyd.ErrorContext
+		require.NoError(t, json.Unmarshal(data, &ec))
+		require.Equal(t, "main", ec.BackendGroup)
+		require.Len(t, ec.Attempts, 1)
+		require.Equal(t, "node1", ec.Attempts[0].Backend)
+		require.NotEmpty(t, ec.Attempts[0].Error)
+		require.Equal(t, ec.Attempts[0].Error, ec.LastError)
+	}
+
+	t.Run("disabled by default", func(t *testing.T) {
+		config := ReadConfig("error_context")
+		config.Server.EnableErrorContext = false
+		_, shutdown, err := proxyd.Start(config)
+		require.NoError(t, err)
+		defer shutdown()
+
+		require.Nil(t, sendWithXFF(t, "").Data)
+	})
+
+	t.Run("enabled", func(t *testing.T) {
+		_, shutdown, err := proxyd.Start(ReadConfig("error_context"))
+		require.NoError(t, err)
+		defer shutdown()
+
+		requireErrorContext(t, sendWithXFF(t, ""))
+		// shared errors aren't modified
+		require.Nil(t, proxyd.ErrNoBackends.Data)
+	})
+
+	t.Run("trusted ips", func(t *testing.T) {
+		config := ReadConfig("error_context")
+		config.Server.ErrorContextTrustedIPs = []string{"10.0.0.0/8"}
+		_, shutdown, err := proxyd.Start(config)
+		require.NoError(t, err)
+		defer shutdown()
+
+		// a spoofed X-Forwarded-For isn't believed
+		require.Nil(t, sendWithXFF(t, "10.1.2.3").Data)
+		require.Nil(t, sendWithXFF(t, "").Data)
+	})
+
+	t.Run("trusted peer", func(t *testing.T) {
+		config := ReadConfig("error_context")
+		config.Server.ErrorContextTrustedIPs = []string{"127.0.0.1"}
+		_, shutdown, err := proxyd.Start(config)
+		require.NoError(t, err)
+		defer shutdown()
+
+		requireErrorContext(t, sendWithXFF(t, ""))
+	})
+
+	t.Run("trusted proxies", func(t *testing.T) {
+		config := ReadConfig("error_context")
+		config.Server.ErrorContextTrustedIPs = []string{"10.0.0.0/8"}
+		config.Server.TrustedProxies = []string{"127.0.0.1"}
+		_, shutdown, err := proxyd.Start(config)
+		require.NoError(t, err)
+		defer shutdown()
+
+		requireErrorContext(t, sendWithXFF(t, "10.1.2.3"))
+		require.Nil(t, sendWithXFF(t, "192.168.1.2").Data)
+	})
+}
@@ -0,0 +1,54 @@
+package integration_tests
+
+import (
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/ethereum-optimism/infra/proxyd"
+	"github.com/stretchr/testify/require"
+)
+
+func TestErrorMapping(t *testing.T) {
+	goodBackend := NewMockBackend(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		if strings.Contains(string(body), "eth_chainId") {
+			SingleResponseHandler(200, `{"jsonrpc":"2.0","error":{"code":-32000,"message":"upstream node 10.0.0.7:8545 failed: header not found","data":"internal trace"},"id":999}`)(w, r)
+			return
+		}
+		SingleResponseHandler(200, `{"jsonrpc":"2.0","error":{"code":3,"message":"execution reverted","data":"0x08c379a0"},"id":999}`)(w, r)
+	}))
+	defer goodBackend.Close()
+
+	require.NoError(t, os.Setenv("GOOD_BACKEND_RPC_URL", goodBackend.URL()))
+
+	config := ReadConfig("error_mapping")
+	client := NewProxydClient("http://127.0.0.1:8545")
+	_, shutdown, err := proxyd.Start(config)
+	require.NoError(t, err)
+	defer shutdown()
+
+	t.Run("sanitized", func(t *testing.T) {
+		res, code, err := client.SendRPC("eth_chainId", nil)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, code)
+		RequireEqualJSON(t, []byte(`{"jsonrpc":"2.0","error":{"code":-32603,"message":"request failed: header not found"},"id":999}`), res)
+	})
+
+	t.Run("passed through", func(t *testing.T) {
+		res, code, err := client.SendRPC("eth_call", []interface{}{map[string]string{"to": "0x00000000000000000000000000000000000000aa"}, "latest"})
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, code)
+		RequireEqualJSON(t, []byte(`{"jsonrpc":"2.0","error":{"code":3,"message":"execution reverted","data":"0x08c379a0"},"id":999}`), res)
+	})
+
+	t.Run("invalid message pattern", func(t *testing.T) {
+		config := ReadConfig("error_mapping")
+		config.ErrorMappings[0].Message = "("
+		_, _, err := proxyd.Start(config)
+		require.ErrorContains(t, err, "invalid message in error mapping 0")
+	})
+}
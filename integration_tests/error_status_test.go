@@ -0,0 +1,100 @@
+package integration_tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/ethereum-optimism/infra/proxyd"
+	"github.com/stretchr/testify/require"
+)
+
+func TestErrorStatus(t *testing.T) {
+	errorsByMethod := map[string]*proxyd.RPCErr{
+		"eth_call":       {Code: 3, Message: "execution reverted"},
+		"eth_getBalance": {Code: -32602, Message: "invalid argument 0"},
+		"eth_gasPrice":   {Code: -32000, Message: "header not found"},
+	}
+	respond := func(req *proxyd.RPCReq) *proxyd.RPCRes {
+		if rpcErr, ok := errorsByMethod[req.Method]; ok {
+			return proxyd.NewRPCErrorRes(req.ID, rpcErr)
+		}
+		return &proxyd.RPCRes{JSONRPC: proxyd.JSONRPCVersion, ID: req.ID, Result: "0x1"}
+	}
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body := mustReadAll(t, r.Body)
+		if !proxyd.IsBatch(body) {
+			req, err := proxyd.ParseRPCReq(body)
+			require.NoError(t, err)
+			require.NoError(t, json.NewEncoder(w).Encode(respond(req)))
+			return
+		}
+		batch, err := proxyd.ParseBatchRPCReq(body)
+		require.NoError(t, err)
+		out := make([]*proxyd.RPCRes, len(batch))
+		for i, raw := range batch {
+			req, err := proxyd.ParseRPCReq(raw)
+			require.NoError(t, err)
+			out[i] = respond(req)
+		}
+		require.NoError(t, json.NewEncoder(w).Encode(out))
+	}))
+	defer backend.Close()
+	require.NoError(t, os.Setenv("ERRORS_BACKEND_RPC_URL", backend.URL))
+
+	client := NewProxydClient("http://127.0.0.1:8545")
+	config := ReadConfig("error_status")
+
+	t.Run("mapped", func(t *testing.T) {
+		_, shutdown, err := proxyd.Start(config)
+		require.NoError(t, err)
+		defer shutdown()
+
+		for method, code := range map[string]int{
+			"eth_chainId":    http.StatusOK,
+			"eth_call":       http.StatusOK, // unmapped application error
+			"eth_getBalance": http.StatusBadRequest,
+			"eth_gasPrice":   http.StatusBadGateway,
+			"eth_foo":        http.StatusForbidden, // proxyd's own status wins
+		} {
+			_, statusCode, err := client.SendRPC(method, nil)
+			require.NoError(t, err)
+			require.Equal(t, code, statusCode, method)
+		}
+
+		_, statusCode, err := client.SendBatchRPC(
+			NewRPCReq("1", "eth_chainId", nil),
+			NewRPCReq("2", "eth_getBalance", nil),
+		)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, statusCode)
+	})
+
+	t.Run("always 200", func(t *testing.T) {
+		config.ErrorStatus = proxyd.ErrorStatusConfig{Mode: proxyd.ErrorStatusModeAlways200}
+		_, shutdown, err := proxyd.Start(config)
+		require.NoError(t, err)
+		defer shutdown()
+
+		for _, method := range []string{"eth_chainId", "eth_call", "eth_getBalance", "eth_gasPrice", "eth_foo"} {
+			res, statusCode, err := client.SendRPC(method, nil)
+			require.NoError(t, err)
+			require.Equal(t, http.StatusOK, statusCode, method)
+			if method != "eth_chainId" {
+				require.Contains(t, string(res), `"error"`, method)
+			}
+		}
+	})
+
+	t.Run("invalid config", func(t *testing.T) {
+		config.ErrorStatus = proxyd.ErrorStatusConfig{Categories: map[string]int{"reverted": 400}}
+		_, _, err := proxyd.Start(config)
+		require.ErrorContains(t, err, "unknown error_status category")
+
+		config.ErrorStatus = proxyd.ErrorStatusConfig{Categories: map[string]int{"invalid_params": 200}}
+		_, _, err = proxyd.Start(config)
+		require.ErrorContains(t, err, "must be between 400 and 599")
+	})
+}
@@ -0,0 +1,52 @@
+package integration_tests
+
+import (
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/ethereum-optimism/infra/proxyd"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBackendExcludedMethods(t *testing.T) {
+	first := NewMockBackend(BatchedResponseHandler(200, goodResponse))
+	defer first.Close()
+	second := NewMockBackend(BatchedResponseHandler(200, goodResponse))
+	defer second.Close()
+
+	require.NoError(t, os.Setenv("FIRST_BACKEND_RPC_URL", first.URL()))
+	require.NoError(t, os.Setenv("SECOND_BACKEND_RPC_URL", second.URL()))
+
+	config := ReadConfig("excluded_methods")
+	_, shutdown, err := proxyd.Start(config)
+	require.NoError(t, err)
+	defer shutdown()
+
+	client := NewProxydClient("http://127.0.0.1:8545")
+
+	// the first backend keeps serving methods it isn't excluded from
+	_, code, err := client.SendRPC("eth_chainId", nil)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, code)
+	require.Len(t, first.Requests(), 1)
+	require.Len(t, second.Requests(), 0)
+
+	first.Reset()
+	_, code, err = client.SendRPC("eth_getLogs", nil)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, code)
+	require.Len(t, first.Requests(), 0)
+	require.Len(t, second.Requests(), 1)
+
+	// batches including the method skip the backend too
+	second.Reset()
+	_, code, err = client.SendBatchRPC(
+		NewRPCReq("1", "eth_chainId", nil),
+		NewRPCReq("2", "eth_getLogs", nil),
+	)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, code)
+	require.Len(t, first.Requests(), 0)
+	require.Len(t, second.Requests(), 1)
+}
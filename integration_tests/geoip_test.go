@@ -0,0 +1,225 @@
+package integration_tests
+
+import (
+	"encoding/binary"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/ethereum-optimism/infra/proxyd"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGeoIP(t *testing.T) {
+	goodBackend := NewMockBackend(BatchedResponseHandler(200, goodResponse))
+	defer goodBackend.Close()
+
+	require.NoError(t, os.Setenv("GOOD_BACKEND_RPC_URL", goodBackend.URL()))
+
+	dir := t.TempDir()
+	countryDB := filepath.Join(dir, "country.mmdb")
+	asnDB := filepath.Join(dir, "asn.mmdb")
+	require.NoError(t, os.WriteFile(countryDB, stubMMDB(map[string]map[string]interface{}{
+		"203.0.113.0/24": {"country": map[string]interface{}{"iso_code": "DE"}},
+	}), 0o644))
+	require.NoError(t, os.WriteFile(asnDB, stubMMDB(map[string]map[string]interface{}{
+		"203.0.113.0/25": {"autonomous_system_number": uint32(64500), "autonomous_system_organization": "Example Net"},
+	}), 0o644))
+
+	t.Run("lookup", func(t *testing.T) {
+		geoIP, err := proxyd.NewGeoIP(proxyd.GeoIPConfig{CountryDatabasePath: countryDB, ASNDatabasePath: asnDB})
+		require.NoError(t, err)
+		defer geoIP.Close()
+
+		require.Equal(t, proxyd.GeoInfo{Country: "DE", ASN: 64500, ASNOrg: "Example Net"}, geoIP.Lookup("203.0.113.7"))
+		require.Equal(t, proxyd.GeoInfo{Country: "DE"}, geoIP.Lookup("203.0.113.200"))
+		require.Equal(t, proxyd.GeoInfo{Country: proxyd.GeoIPCountryUnknown}, geoIP.Lookup("198.51.100.1"))
+		require.Equal(t, proxyd.GeoInfo{Country: proxyd.GeoIPCountryUnknown}, geoIP.Lookup("not an ip"))
+	})
+
+	t.Run("disabled without databases", func(t *testing.T) {
+		geoIP, err := proxyd.NewGeoIP(proxyd.GeoIPConfig{})
+		require.NoError(t, err)
+		require.Nil(t, geoIP)
+	})
+
+	t.Run("invalid database", func(t *testing.T) {
+		_, err := proxyd.NewGeoIP(proxyd.GeoIPConfig{CountryDatabasePath: filepath.Join(dir, "missing.mmdb")})
+		require.Error(t, err)
+	})
+
+	t.Run("requests are counted by country", func(t *testing.T) {
+		config := ReadConfig("geoip")
+		config.GeoIP.CountryDatabasePath = countryDB
+		config.GeoIP.ASNDatabasePath = asnDB
+		_, shutdown, err := proxyd.Start(config)
+		require.NoError(t, err)
+		defer shutdown()
+
+		deBefore := clientRequestsByCountry(t, "DE")
+		unknownBefore := clientRequestsByCountry(t, proxyd.GeoIPCountryUnknown)
+
+		client := NewProxydClientWithHeaders("http://127.0.0.1:8545", http.Header{
+			"X-Forwarded-For": []string{"203.0.113.7"},
+		})
+		_, code, err := client.SendRPC("eth_chainId", nil)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, code)
+		_, code, err = NewProxydClient("http://127.0.0.1:8545").SendRPC("eth_chainId", nil)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, code)
+
+		require.Equal(t, deBefore+1, clientRequestsByCountry(t, "DE"))
+		require.Equal(t, unknownBefore+1, clientRequestsByCountry(t, proxyd.GeoIPCountryUnknown))
+	})
+}
+
+func clientRequestsByCountry(t *testing.T, country string) float64 {
+	families, err := prometheus.DefaultGatherer.Gather()
+	require.NoError(t, err)
+	for _, family := range families {
+		if family.GetName() != "proxyd_client_requests_by_country_total" {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			for _, label := range metric.GetLabel() {
+				if label.GetName() == "country" && label.GetValue() == country {
+					return metric.GetCounter().GetValue()
+				}
+			}
+		}
+	}
+	return 0
+}
+
+// stubMMDB builds an IPv4 MaxMind DB with 32 bit records mapping networks to
+// records, to test lookups without shipping a real database. Record values
+// may be strings, unsigned integers, arrays or maps of them.
+func stubMMDB(networks map[string]map[string]interface{}) []byte {
+	type node struct {
+		records [2]int // node index, or -(data index + 2), or -1 for empty
+	}
+	nodes := []node{{records: [2]int{-1, -1}}}
+	var data [][]byte
+
+	cidrs := make([]string, 0, len(networks))
+	for cidr := range networks {
+		cidrs = append(cidrs, cidr)
+	}
+	sort.Strings(cidrs)
+	for _, cidr := range cidrs {
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		ones, _ := ipNet.Mask.Size()
+		ip := ipNet.IP.To4()
+		data = append(data, encodeMMDBValue(networks[cidr]))
+		cur := 0
+		for i := 0; i < ones; i++ {
+			bit := (ip[i/8] >> (7 - uint(i%8))) & 1
+			if i == ones-1 {
+				nodes[cur].records[bit] = -(len(data) - 1 + 2)
+				break
+			}
+			if nodes[cur].records[bit] < 0 {
+				nodes = append(nodes, node{records: [2]int{-1, -1}})
+				nodes[cur].records[bit] = len(nodes) - 1
+			}
+			cur = nodes[cur].records[bit]
+		}
+	}
+
+	nodeCount := len(nodes)
+	var dataSection []byte
+	offsets := make([]int, len(data))
+	for i, d := range data {
+		offsets[i] = len(dataSection)
+		dataSection = append(dataSection, d...)
+	}
+
+	var out []byte
+	for _, n := range nodes {
+		for _, r := range n.records {
+			value := nodeCount
+			if r >= 0 {
+				value = r
+			} else if r < -1 {
+				value = nodeCount + 16 + offsets[-r-2]
+			}
+			out = binary.BigEndian.AppendUint32(out, uint32(value))
+		}
+	}
+	out = append(out, make([]byte, 16)...)
+	out = append(out, dataSection...)
+	out = append(out, "\xab\xcd\xefMaxMind.com"...)
+	return append(out, encodeMMDBValue(map[string]interface{}{
+		"binary_format_major_version": uint16(2),
+		"binary_format_minor_version": uint16(0),
+		"build_epoch":                 uint64(0),
+		"database_type":               "Test",
+		"description":                 map[string]interface{}{},
+		"ip_version":                  uint16(4),
+		"languages":                   []interface{}{},
+		"node_count":                  uint32(nodeCount),
+		"record_size":                 uint16(32),
+	})...)
+}
+
+func encodeMMDBValue(v interface{}) []byte {
+	// control encodes the type and size of a value, for sizes up to 284.
+	control := func(typ int, size int) []byte {
+		var ext []byte
+		if size >= 29 {
+			ext = []byte{byte(size - 29)}
+			size = 29
+		}
+		if typ > 7 {
+			return append([]byte{byte(size), byte(typ - 7)}, ext...)
+		}
+		return append([]byte{byte(typ<<5 | size)}, ext...)
+	}
+	uint := func(typ int, n uint64, width int) []byte {
+		b := make([]byte, 8)
+		binary.BigEndian.PutUint64(b, n)
+		b = b[8-width:]
+		for len(b) > 0 && b[0] == 0 {
+			b = b[1:]
+		}
+		return append(control(typ, len(b)), b...)
+	}
+	switch v := v.(type) {
+	case string:
+		return append(control(2, len(v)), v...)
+	case uint16:
+		return uint(5, uint64(v), 2)
+	case uint32:
+		return uint(6, uint64(v), 4)
+	case uint64:
+		return uint(9, v, 8)
+	case []interface{}:
+		out := control(11, len(v))
+		for _, elem := range v {
+			out = append(out, encodeMMDBValue(elem)...)
+		}
+		return out
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		out := control(7, len(v))
+		for _, k := range keys {
+			out = append(out, encodeMMDBValue(k)...)
+			out = append(out, encodeMMDBValue(v[k])...)
+		}
+		return out
+	default:
+		panic("unsupported mmdb value")
+	}
+}
@@ -0,0 +1,87 @@
+package integration_tests
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"testing"
+
+	"github.com/alicebob/miniredis"
+	"github.com/ethereum-optimism/infra/proxyd"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetRPC(t *testing.T) {
+	redis, err := miniredis.Run()
+	require.NoError(t, err)
+	defer redis.Close()
+
+	hdlr := NewBatchRPCResponseRouter()
+	hdlr.SetFallbackRoute("eth_blockNumber", "0x101")
+	hdlr.SetFallbackRoute("eth_chainId", "0x38")
+	backend := NewMockBackend(hdlr)
+	defer backend.Close()
+
+	require.NoError(t, os.Setenv("GOOD_BACKEND_RPC_URL", backend.URL()))
+	require.NoError(t, os.Setenv("REDIS_URL", fmt.Sprintf("redis://127.0.0.1:%s", redis.Port())))
+
+	config := ReadConfig("get_rpc")
+	_, shutdown, err := proxyd.Start(config)
+	require.NoError(t, err)
+	defer func() { shutdown() }()
+
+	get := func(query url.Values, ifNoneMatch string) (*http.Response, []byte) {
+		req, err := http.NewRequest("GET", "http://127.0.0.1:8545/?"+query.Encode(), nil)
+		require.NoError(t, err)
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		res, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer res.Body.Close()
+		body, err := io.ReadAll(res.Body)
+		require.NoError(t, err)
+		return res, body
+	}
+
+	t.Run("eth_blockNumber", func(t *testing.T) {
+		res, body := get(url.Values{"method": {"eth_blockNumber"}}, "")
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		RequireEqualJSON(t, []byte(`{"jsonrpc":"2.0","result":"0x101","id":1}`), body)
+
+		res, body = get(url.Values{"method": {"eth_blockNumber"}, "params": {"[]"}, "id": {`"probe"`}}, "")
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		RequireEqualJSON(t, []byte(`{"jsonrpc":"2.0","result":"0x101","id":"probe"}`), body)
+	})
+
+	t.Run("cacheable reads get an etag", func(t *testing.T) {
+		res, _ := get(url.Values{"method": {"eth_chainId"}}, "")
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		etag := res.Header.Get("ETag")
+		require.NotEmpty(t, etag)
+
+		res, _ = get(url.Values{"method": {"eth_chainId"}}, etag)
+		require.Equal(t, http.StatusNotModified, res.StatusCode)
+	})
+
+	t.Run("invalid query", func(t *testing.T) {
+		res, _ := get(url.Values{}, "")
+		require.Equal(t, http.StatusBadRequest, res.StatusCode)
+
+		res, body := get(url.Values{"method": {"eth_blockNumber"}, "params": {"[oops"}}, "")
+		require.Equal(t, http.StatusBadRequest, res.StatusCode)
+		RequireEqualJSON(t, []byte(`{"jsonrpc":"2.0","error":{"code":-32700,"message":"parse error"},"id":null}`), body)
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		shutdown()
+		config.Server.EnableGetRPC = false
+		_, shutdown, err = proxyd.Start(config)
+		require.NoError(t, err)
+
+		res, _ := get(url.Values{"method": {"eth_blockNumber"}}, "")
+		require.Equal(t, http.StatusMethodNotAllowed, res.StatusCode)
+	})
+}
@@ -0,0 +1,89 @@
+package integration_tests
+
+import (
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/ethereum-optimism/infra/proxyd"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGroupOverride(t *testing.T) {
+	mainBackend := NewMockBackend(BatchedResponseHandler(200, goodResponse))
+	defer mainBackend.Close()
+	dedicatedBackend := NewMockBackend(BatchedResponseHandler(200, goodResponse))
+	defer dedicatedBackend.Close()
+
+	require.NoError(t, os.Setenv("MAIN_BACKEND_RPC_URL", mainBackend.URL()))
+	require.NoError(t, os.Setenv("DEDICATED_BACKEND_RPC_URL", dedicatedBackend.URL()))
+
+	config := ReadConfig("group_override")
+	_, shutdown, err := proxyd.Start(config)
+	require.NoError(t, err)
+	defer shutdown()
+
+	premium := NewProxydClient("http://127.0.0.1:8545/premium-secret")
+	basic := NewProxydClient("http://127.0.0.1:8545/basic-secret")
+	admin := NewProxydClientWithHeaders("http://127.0.0.1:8545/basic-secret", http.Header{
+		"Authorization": []string{"Bearer admin-secret"},
+	})
+	override := map[string]string{"X-Proxyd-Group": "dedicated"}
+
+	reset := func() {
+		mainBackend.Reset()
+		dedicatedBackend.Reset()
+	}
+
+	t.Run("without override", func(t *testing.T) {
+		reset()
+		_, code, err := premium.SendRPC("eth_chainId", nil)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, code)
+		require.Len(t, mainBackend.Requests(), 1)
+		require.Len(t, dedicatedBackend.Requests(), 0)
+	})
+
+	t.Run("authorized by key", func(t *testing.T) {
+		reset()
+		_, code, err := premium.SendRequestWithHeaders(NewRPCReq("1", "eth_chainId", nil), override)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, code)
+		require.Len(t, mainBackend.Requests(), 0)
+		require.Len(t, dedicatedBackend.Requests(), 1)
+	})
+
+	t.Run("authorized by admin token", func(t *testing.T) {
+		reset()
+		_, code, err := admin.SendRequestWithHeaders(NewRPCReq("1", "eth_chainId", nil), override)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, code)
+		require.Len(t, dedicatedBackend.Requests(), 1)
+	})
+
+	t.Run("unauthorized", func(t *testing.T) {
+		reset()
+		res, code, err := basic.SendRequestWithHeaders(NewRPCReq("1", "eth_chainId", nil), override)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusForbidden, code)
+		RequireEqualJSON(t, []byte(`{"jsonrpc":"2.0","error":{"code":-32033,"message":"backend group override not allowed"},"id":null}`), res)
+		require.Len(t, mainBackend.Requests(), 0)
+		require.Len(t, dedicatedBackend.Requests(), 0)
+	})
+
+	t.Run("invalid group", func(t *testing.T) {
+		reset()
+		res, code, err := premium.SendRequestWithHeaders(NewRPCReq("1", "eth_chainId", nil), map[string]string{"X-Proxyd-Group": "nope"})
+		require.NoError(t, err)
+		require.Equal(t, http.StatusBadRequest, code)
+		RequireEqualJSON(t, []byte(`{"jsonrpc":"2.0","error":{"code":-32034,"message":"invalid backend group override"},"id":null}`), res)
+	})
+
+	t.Run("unmapped methods stay blocked", func(t *testing.T) {
+		reset()
+		_, code, err := premium.SendRequestWithHeaders(NewRPCReq("1", "eth_foo", nil), override)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusForbidden, code)
+		require.Len(t, dedicatedBackend.Requests(), 0)
+	})
+}
@@ -0,0 +1,41 @@
+package integration_tests
+
+import (
+	"net/http"
+	"net/url"
+	"os"
+	"testing"
+
+	"github.com/ethereum-optimism/infra/proxyd"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHostHeaderOverride(t *testing.T) {
+	overridden := NewMockBackend(BatchedResponseHandler(200, goodResponse))
+	defer overridden.Close()
+	plain := NewMockBackend(BatchedResponseHandler(200, goodResponse))
+	defer plain.Close()
+
+	require.NoError(t, os.Setenv("OVERRIDDEN_BACKEND_RPC_URL", overridden.URL()))
+	require.NoError(t, os.Setenv("PLAIN_BACKEND_RPC_URL", plain.URL()))
+
+	config := ReadConfig("host_override")
+	client := NewProxydClient("http://127.0.0.1:8545")
+	_, shutdown, err := proxyd.Start(config)
+	require.NoError(t, err)
+	defer shutdown()
+
+	_, code, err := client.SendRPC("eth_chainId", nil)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, code)
+	require.Len(t, overridden.Requests(), 1)
+	require.Equal(t, "rpc.example.com", overridden.Requests()[0].Host)
+
+	_, code, err = client.SendRPC("net_version", nil)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, code)
+	require.Len(t, plain.Requests(), 1)
+	plainURL, err := url.Parse(plain.URL())
+	require.NoError(t, err)
+	require.Equal(t, plainURL.Host, plain.Requests()[0].Host)
+}
@@ -0,0 +1,102 @@
+package integration_tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/alicebob/miniredis"
+	"github.com/ethereum-optimism/infra/proxyd"
+	"github.com/stretchr/testify/require"
+)
+
+// reencodingIDHandler answers every request with its ID decoded and encoded
+// again, as backends written in other languages do, so that 1.50 comes back
+// as 1.5.
+func reencodingIDHandler(t *testing.T) http.HandlerFunc {
+	respond := func(raw json.RawMessage) map[string]interface{} {
+		var req struct {
+			ID interface{} `json:"id"`
+		}
+		require.NoError(t, json.Unmarshal(raw, &req))
+		return map[string]interface{}{"jsonrpc": "2.0", "result": "0x1", "id": req.ID}
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		var out interface{}
+		if proxyd.IsBatch(body) {
+			batch, err := proxyd.ParseBatchRPCReq(body)
+			require.NoError(t, err)
+			res := make([]interface{}, len(batch))
+			for i, raw := range batch {
+				res[i] = respond(raw)
+			}
+			out = res
+		} else {
+			out = respond(body)
+		}
+		require.NoError(t, json.NewEncoder(w).Encode(out))
+	}
+}
+
+func TestIDPreservation(t *testing.T) {
+	redis, err := miniredis.Run()
+	require.NoError(t, err)
+	defer redis.Close()
+
+	backend := NewMockBackend(reencodingIDHandler(t))
+	defer backend.Close()
+
+	require.NoError(t, os.Setenv("GOOD_BACKEND_RPC_URL", backend.URL()))
+	require.NoError(t, os.Setenv("REDIS_URL", fmt.Sprintf("redis://127.0.0.1:%s", redis.Port())))
+	config := ReadConfig("id_preservation")
+	client := NewProxydClient("http://127.0.0.1:8545")
+	_, shutdown, err := proxyd.Start(config)
+	require.NoError(t, err)
+	defer shutdown()
+
+	ids := []string{`1`, `1.50`, `"1"`, `"abc"`, `null`}
+
+	t.Run("single", func(t *testing.T) {
+		// eth_chainId is cached after the first request, eth_blockNumber isn't.
+		for _, method := range []string{"eth_chainId", "eth_blockNumber"} {
+			for _, id := range ids {
+				res, code, err := client.SendRequest([]byte(fmt.Sprintf(`{"jsonrpc":"2.0","method":"%s","params":[],"id":%s}`, method, id)))
+				require.NoError(t, err)
+				require.Equal(t, http.StatusOK, code)
+				var rpcRes struct {
+					ID json.RawMessage `json:"id"`
+				}
+				require.NoError(t, json.Unmarshal(res, &rpcRes))
+				require.Equal(t, id, string(rpcRes.ID), "method %s", method)
+			}
+		}
+	})
+
+	t.Run("batch", func(t *testing.T) {
+		var reqs []string
+		for _, method := range []string{"eth_chainId", "eth_blockNumber"} {
+			for _, id := range ids {
+				reqs = append(reqs, fmt.Sprintf(`{"jsonrpc":"2.0","method":"%s","params":[],"id":%s}`, method, id))
+			}
+		}
+		res, code, err := client.SendRequest([]byte("[" + strings.Join(reqs, ",") + "]"))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, code)
+		var rpcRes []struct {
+			ID     json.RawMessage `json:"id"`
+			Result string          `json:"result"`
+		}
+		require.NoError(t, json.Unmarshal(res, &rpcRes))
+		require.Len(t, rpcRes, len(reqs))
+		for i := range reqs {
+			require.Equal(t, ids[i%len(ids)], string(rpcRes[i].ID))
+			require.Equal(t, "0x1", rpcRes[i].Result)
+		}
+	})
+}
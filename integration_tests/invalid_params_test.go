@@ -0,0 +1,85 @@
+package integration_tests
+
+import (
+	"encoding/json"
+	"math/big"
+	"os"
+	"testing"
+
+	"github.com/ethereum-optimism/infra/proxyd"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInvalidParamsPolicy(t *testing.T) {
+	goodBackend := NewMockBackend(SingleResponseHandler(200, dummyRes))
+	defer goodBackend.Close()
+
+	require.NoError(t, os.Setenv("GOOD_BACKEND_RPC_URL", goodBackend.URL()))
+
+	// undecodable is a raw transaction that fails to decode
+	undecodable := makeSendRawTransaction("0xzz")
+
+	// send returns the error code of the response to undecodable, or 0 if it
+	// was forwarded
+	send := func(t *testing.T, config *proxyd.Config) int {
+		goodBackend.Reset()
+		_, shutdown, err := proxyd.Start(config)
+		require.NoError(t, err)
+		defer shutdown()
+
+		res, _, err := NewProxydClient("http://127.0.0.1:8545").SendRequest(undecodable)
+		require.NoError(t, err)
+		var rpcRes proxyd.RPCRes
+		require.NoError(t, json.Unmarshal(res, &rpcRes))
+		if rpcRes.Error == nil {
+			require.Len(t, goodBackend.Requests(), 1)
+			return 0
+		}
+		require.Len(t, goodBackend.Requests(), 0)
+		return rpcRes.Error.Code
+	}
+
+	t.Run("sender rate limit rejects by default", func(t *testing.T) {
+		config := ReadConfig("sender_rate_limit")
+		require.Equal(t, -32602, send(t, config))
+	})
+
+	t.Run("sender rate limit forwards", func(t *testing.T) {
+		config := ReadConfig("sender_rate_limit")
+		config.InvalidParams.Policy = proxyd.InvalidParamsPolicyForward
+		require.Equal(t, 0, send(t, config))
+	})
+
+	t.Run("method policy overrides the global one", func(t *testing.T) {
+		config := ReadConfig("sender_rate_limit")
+		config.InvalidParams.Policy = proxyd.InvalidParamsPolicyForward
+		config.InvalidParams.Methods = map[string]proxyd.InvalidParamsPolicy{
+			"eth_sendRawTransaction": proxyd.InvalidParamsPolicyReject,
+		}
+		require.Equal(t, -32602, send(t, config))
+	})
+
+	t.Run("min gas price forwards by default", func(t *testing.T) {
+		config := ReadConfig("sender_rate_limit")
+		config.SenderRateLimit.Enabled = false
+		config.MinGasPrice = big.NewInt(1)
+		require.Equal(t, 0, send(t, config))
+	})
+
+	t.Run("min gas price rejects", func(t *testing.T) {
+		config := ReadConfig("sender_rate_limit")
+		config.SenderRateLimit.Enabled = false
+		config.MinGasPrice = big.NewInt(1)
+		config.InvalidParams.Methods = map[string]proxyd.InvalidParamsPolicy{
+			"eth_sendRawTransaction": proxyd.InvalidParamsPolicyReject,
+		}
+		require.Equal(t, -32602, send(t, config))
+	})
+
+	t.Run("invalid policy", func(t *testing.T) {
+		config := ReadConfig("sender_rate_limit")
+		config.InvalidParams.Policy = "drop"
+		_, _, err := proxyd.Start(config)
+		require.ErrorContains(t, err, "invalid invalid_params policy")
+	})
+}
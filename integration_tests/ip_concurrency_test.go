@@ -0,0 +1,83 @@
+package integration_tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ethereum-optimism/infra/proxyd"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMaxConcurrentRPCsPerIP(t *testing.T) {
+	var inFlight atomic.Int64
+	release := make(chan struct{})
+	var releaseOnce sync.Once
+	releaseAll := func() { releaseOnce.Do(func() { close(release) }) }
+	// unlike MockBackend, httptest serves requests concurrently
+	goodBackend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		inFlight.Add(1)
+		defer inFlight.Add(-1)
+		<-release
+		BatchedResponseHandler(200, goodResponse)(w, r)
+	}))
+	defer goodBackend.Close()
+	defer releaseAll()
+	require.NoError(t, os.Setenv("GOOD_BACKEND_RPC_URL", goodBackend.URL))
+
+	_, shutdown, err := proxyd.Start(ReadConfig("ip_concurrency"))
+	require.NoError(t, err)
+	defer shutdown()
+
+	client := NewProxydClient("http://127.0.0.1:8545")
+	send := func(ip string) ([]byte, int) {
+		res, code, err := client.SendRequestWithHeaders(NewRPCReq("999", "eth_chainId", nil), map[string]string{"X-Forwarded-For": ip})
+		require.NoError(t, err)
+		return res, code
+	}
+
+	// saturate the concurrency of one IP
+	var wg sync.WaitGroup
+	codes := make(chan int, 3)
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, code := send("1.1.1.1")
+			codes <- code
+		}()
+	}
+	require.Eventually(t, func() bool {
+		return inFlight.Load() == 2
+	}, 5*time.Second, 10*time.Millisecond)
+
+	res, code := send("1.1.1.1")
+	require.Equal(t, http.StatusTooManyRequests, code)
+	RequireEqualJSON(t, []byte(`{"jsonrpc":"2.0","error":{"code":-32035,"message":"too many concurrent requests from client IP"},"id":null}`), res)
+
+	// other IPs aren't affected
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		_, code := send("2.2.2.2")
+		codes <- code
+	}()
+	require.Eventually(t, func() bool {
+		return inFlight.Load() == 3
+	}, 5*time.Second, 10*time.Millisecond)
+
+	releaseAll()
+	wg.Wait()
+	close(codes)
+	for code := range codes {
+		require.Equal(t, http.StatusOK, code)
+	}
+
+	// the slots are given back once the requests are served
+	_, code = send("1.1.1.1")
+	require.Equal(t, http.StatusOK, code)
+}
@@ -0,0 +1,61 @@
+package integration_tests
+
+import (
+	"net"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/ethereum-optimism/infra/proxyd"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIPv6Listener(t *testing.T) {
+	ln, err := net.Listen("tcp6", "[::1]:0")
+	if err != nil {
+		t.Skip("IPv6 loopback is not available", err)
+	}
+	ln.Close()
+
+	goodBackend := NewMockBackend(BatchedResponseHandler(200, goodResponse))
+	defer goodBackend.Close()
+
+	require.NoError(t, os.Setenv("GOOD_BACKEND_RPC_URL", goodBackend.URL()))
+
+	config := ReadConfig("ipv6")
+	_, shutdown, err := proxyd.Start(config)
+	require.NoError(t, err)
+	defer shutdown()
+
+	t.Run("rpc", func(t *testing.T) {
+		client := NewProxydClient("http://[::1]:8545")
+		res, code, err := client.SendRPC(ethChainID, nil)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, code)
+		RequireEqualJSON(t, []byte(goodResponse), res)
+	})
+
+	t.Run("healthz", func(t *testing.T) {
+		res, err := http.Get("http://[::1]:8545/healthz")
+		require.NoError(t, err)
+		defer res.Body.Close()
+		require.Equal(t, http.StatusOK, res.StatusCode)
+	})
+
+	t.Run("metrics", func(t *testing.T) {
+		require.Eventually(t, func() bool {
+			res, err := http.Get("http://[::1]:9761/metrics")
+			if err != nil {
+				return false
+			}
+			defer res.Body.Close()
+			return res.StatusCode == http.StatusOK
+		}, time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("ipv4 is refused without dual stack", func(t *testing.T) {
+		_, err := net.DialTimeout("tcp4", "127.0.0.1:8545", 100*time.Millisecond)
+		require.Error(t, err)
+	})
+}
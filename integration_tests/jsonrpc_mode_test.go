@@ -0,0 +1,66 @@
+package integration_tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/ethereum-optimism/infra/proxyd"
+	"github.com/stretchr/testify/require"
+)
+
+const versionlessResponse = `{"result": "hello", "id": 999}`
+
+func TestJSONRPCMode(t *testing.T) {
+	strictBackend := NewMockBackend(SingleResponseHandler(200, versionlessResponse))
+	defer strictBackend.Close()
+	lenientBackend := NewMockBackend(SingleResponseHandler(200, versionlessResponse))
+	defer lenientBackend.Close()
+	omitBackend := NewMockBackend(SingleResponseHandler(200, versionlessResponse))
+	defer omitBackend.Close()
+
+	require.NoError(t, os.Setenv("STRICT_BACKEND_RPC_URL", strictBackend.URL()))
+	require.NoError(t, os.Setenv("LENIENT_BACKEND_RPC_URL", lenientBackend.URL()))
+	require.NoError(t, os.Setenv("OMIT_BACKEND_RPC_URL", omitBackend.URL()))
+
+	config := ReadConfig("jsonrpc_mode")
+	client := NewProxydClient("http://127.0.0.1:8545")
+	_, shutdown, err := proxyd.Start(config)
+	require.NoError(t, err)
+	defer shutdown()
+
+	sentFields := func(t *testing.T, mb *MockBackend) map[string]interface{} {
+		require.Len(t, mb.Requests(), 1)
+		var fields map[string]interface{}
+		require.NoError(t, json.Unmarshal(mb.Requests()[0].Body, &fields))
+		return fields
+	}
+
+	t.Run("rejects a response without jsonrpc when configured", func(t *testing.T) {
+		res, code, err := client.SendRPC("eth_chainId", nil)
+		require.NoError(t, err)
+		require.Equal(t, 503, code)
+		RequireEqualJSON(t, []byte(noBackendsResponse), res)
+		require.Equal(t, proxyd.JSONRPCVersion, sentFields(t, strictBackend)["jsonrpc"])
+	})
+
+	t.Run("fills in a missing jsonrpc by default", func(t *testing.T) {
+		res, code, err := client.SendRPC("net_version", nil)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, code)
+		RequireEqualJSON(t, []byte(goodResponse), res)
+		require.Equal(t, proxyd.JSONRPCVersion, sentFields(t, lenientBackend)["jsonrpc"])
+	})
+
+	t.Run("omit drops jsonrpc from the request", func(t *testing.T) {
+		res, code, err := client.SendRPC("eth_blockNumber", nil)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, code)
+		RequireEqualJSON(t, []byte(goodResponse), res)
+
+		fields := sentFields(t, omitBackend)
+		require.NotContains(t, fields, "jsonrpc")
+		require.Equal(t, "eth_blockNumber", fields["method"])
+	})
+}
@@ -0,0 +1,62 @@
+package integration_tests
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/ethereum-optimism/infra/proxyd"
+	ms "github.com/ethereum-optimism/infra/proxyd/tools/mockserver/handler"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLargeNumbersRoundTrip(t *testing.T) {
+	// both are well beyond the 53 bits of precision of a float64
+	const (
+		largeParam  = "123456789012345678901234567890"
+		largeResult = "340282366920938463463374607431768211457"
+	)
+
+	dir, err := os.Getwd()
+	require.NoError(t, err)
+
+	h := ms.MockedHandler{
+		Overrides: []*ms.MethodTemplate{
+			{Method: "eth_call", Response: `{"jsonrpc": "2.0", "id": 67, "result": ` + largeResult + `}`},
+		},
+		Autoload:     true,
+		AutoloadFile: path.Join(dir, "testdata/consensus_responses.yml"),
+	}
+	node1 := NewMockBackend(http.HandlerFunc(h.Handler))
+	defer node1.Close()
+
+	require.NoError(t, os.Setenv("NODE1_URL", node1.URL()))
+
+	svr, shutdown, err := proxyd.Start(ReadConfig("large_numbers"))
+	require.NoError(t, err)
+	defer shutdown()
+
+	bg := svr.BackendGroups["node"]
+	bg.Consensus.UpdateBackend(context.Background(), bg.Backends[0])
+	bg.Consensus.UpdateBackendGroupConsensus(context.Background())
+	node1.Reset()
+
+	// the block tag is rewritten by the consensus group, which re-encodes the
+	// params
+	body := `{"jsonrpc":"2.0","id":1,"method":"eth_call","params":[{"to":"0x0000000000000000000000000000000000000048","gas":` + largeParam + `},"latest"]}`
+	res, code, err := NewProxydClient("http://127.0.0.1:8545").SendRequest([]byte(body))
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, code)
+	RequireEqualJSON(t, []byte(`{"jsonrpc":"2.0","id":1,"result":`+largeResult+`}`), res)
+	require.Contains(t, string(res), largeResult)
+
+	require.Len(t, node1.Requests(), 1)
+	var forwarded proxyd.RPCReq
+	require.NoError(t, json.Unmarshal(node1.Requests()[0].Body, &forwarded))
+	require.JSONEq(t, `[{"to":"0x0000000000000000000000000000000000000048","gas":`+largeParam+`},{"blockNumber":"0x101"}]`, string(forwarded.Params))
+	// JSONEq compares numbers as float64, so check the digits too
+	require.Contains(t, string(forwarded.Params), largeParam)
+}
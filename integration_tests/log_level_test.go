@@ -0,0 +1,56 @@
+package integration_tests
+
+import (
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/ethereum-optimism/infra/proxyd"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdminLogLevel(t *testing.T) {
+	goodBackend := NewMockBackend(BatchedResponseHandler(200, goodResponse))
+	defer goodBackend.Close()
+	require.NoError(t, os.Setenv("GOOD_BACKEND_RPC_URL", goodBackend.URL()))
+
+	_, shutdown, err := proxyd.Start(ReadConfig("log_level"))
+	require.NoError(t, err)
+	defer shutdown()
+	defer func() {
+		require.NoError(t, proxyd.ResetModuleLogLevel("consensus"))
+	}()
+
+	setLevel := func(token string, body string) (int, []byte) {
+		req, err := http.NewRequest("POST", "http://127.0.0.1:8545/admin/loglevel", strings.NewReader(body))
+		require.NoError(t, err)
+		req.Header.Set("Authorization", "Bearer "+token)
+		res, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer res.Body.Close()
+		resBody, err := io.ReadAll(res.Body)
+		require.NoError(t, err)
+		return res.StatusCode, resBody
+	}
+
+	code, _ := setLevel("wrong", `{"module":"consensus","level":"debug"}`)
+	require.Equal(t, http.StatusUnauthorized, code)
+
+	code, body := setLevel("admin-secret", `{"module":"consensus","level":"debug"}`)
+	require.Equal(t, http.StatusOK, code)
+	RequireEqualJSON(t, []byte(`{"default":"info","modules":{"consensus":"debug"}}`), body)
+
+	code, body = setLevel("admin-secret", `{"module":"consensus"}`)
+	require.Equal(t, http.StatusOK, code)
+	RequireEqualJSON(t, []byte(`{"default":"info","modules":{}}`), body)
+
+	code, body = setLevel("admin-secret", `{"module":"mempool","level":"debug"}`)
+	require.Equal(t, http.StatusBadRequest, code)
+	require.Contains(t, string(body), "unknown log module")
+
+	code, body = setLevel("admin-secret", `{"module":"cache","level":"loud"}`)
+	require.Equal(t, http.StatusBadRequest, code)
+	require.Contains(t, string(body), "unknown level")
+}
@@ -0,0 +1,36 @@
+package integration_tests
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/ethereum-optimism/infra/proxyd"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMaxHops(t *testing.T) {
+	// a and b are each other's backend, so requests loop between them
+	_, shutdownA, err := proxyd.Start(ReadConfig("max_hops_a"))
+	require.NoError(t, err)
+	defer shutdownA()
+	_, shutdownB, err := proxyd.Start(ReadConfig("max_hops_b"))
+	require.NoError(t, err)
+	defer shutdownB()
+
+	client := NewProxydClient("http://127.0.0.1:8545")
+	loopErr := `{"jsonrpc":"2.0","error":{"code":-32036,"message":"request forwarded through too many proxyd instances"},"id":999}`
+
+	t.Run("loop is broken", func(t *testing.T) {
+		res, code, err := client.SendRPC("eth_chainId", nil)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusLoopDetected, code)
+		RequireEqualJSON(t, []byte(loopErr), res)
+	})
+
+	t.Run("requests from upstream tiers count", func(t *testing.T) {
+		res, code, err := client.SendRequestWithHeaders(NewRPCReq("999", "eth_chainId", nil), map[string]string{"X-Proxyd-Hop": "4"})
+		require.NoError(t, err)
+		require.Equal(t, http.StatusLoopDetected, code)
+		RequireEqualJSON(t, []byte(`{"jsonrpc":"2.0","error":{"code":-32036,"message":"request forwarded through too many proxyd instances"},"id":null}`), res)
+	})
+}
@@ -0,0 +1,74 @@
+package integration_tests
+
+import (
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/ethereum-optimism/infra/proxyd"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMaxParamsLength(t *testing.T) {
+	hdlr := NewBatchRPCResponseRouter()
+	hdlr.SetFallbackRoute("eth_getLogs", "hello")
+	hdlr.SetFallbackRoute("eth_chainId", "0x1")
+	goodBackend := NewMockBackend(hdlr)
+	defer goodBackend.Close()
+
+	require.NoError(t, os.Setenv("GOOD_BACKEND_RPC_URL", goodBackend.URL()))
+
+	config := ReadConfig("max_params_length")
+	client := NewProxydClient("http://127.0.0.1:8545")
+	_, shutdown, err := proxyd.Start(config)
+	require.NoError(t, err)
+	defer shutdown()
+
+	filter := map[string]interface{}{"fromBlock": "0x1", "toBlock": "0x2"}
+	tooManyParams := `{"jsonrpc":"2.0","error":{"code":-32602,"message":"too many params for eth_getLogs, at most 1 allowed"},"id":999}`
+
+	t.Run("within the limit", func(t *testing.T) {
+		goodBackend.Reset()
+		res, code, err := client.SendRPC("eth_getLogs", []interface{}{filter})
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, code)
+		RequireEqualJSON(t, []byte(goodResponse), res)
+		require.Equal(t, 1, len(goodBackend.Requests()))
+	})
+
+	t.Run("over the limit", func(t *testing.T) {
+		goodBackend.Reset()
+		params := make([]interface{}, 50)
+		for i := range params {
+			params[i] = filter
+		}
+		res, code, err := client.SendRPC("eth_getLogs", params)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusBadRequest, code)
+		RequireEqualJSON(t, []byte(tooManyParams), res)
+		require.Equal(t, 0, len(goodBackend.Requests()))
+	})
+
+	t.Run("methods without a limit", func(t *testing.T) {
+		goodBackend.Reset()
+		_, code, err := client.SendRPC("eth_chainId", []interface{}{1, 2, 3})
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, code)
+		require.Equal(t, 1, len(goodBackend.Requests()))
+	})
+
+	t.Run("over the limit in a batch", func(t *testing.T) {
+		goodBackend.Reset()
+		res, code, err := client.SendBatchRPC(
+			NewRPCReq("1", "eth_getLogs", []interface{}{filter, filter}),
+			NewRPCReq("2", "eth_getLogs", []interface{}{filter}),
+		)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, code)
+		RequireEqualJSON(t, []byte(`[
+			{"jsonrpc":"2.0","error":{"code":-32602,"message":"too many params for eth_getLogs, at most 1 allowed"},"id":1},
+			{"jsonrpc":"2.0","result":"hello","id":2}
+		]`), res)
+		require.Equal(t, 1, len(goodBackend.Requests()))
+	})
+}
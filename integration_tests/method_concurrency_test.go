@@ -0,0 +1,148 @@
+package integration_tests
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ethereum-optimism/infra/proxyd"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMethodConcurrencyLimits(t *testing.T) {
+	// debug_traceBlockByNumber calls block until a value is sent on release.
+	var inFlight, maxInFlight atomic.Int32
+	release := make(chan struct{})
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		if bytes.Contains(body, []byte("debug_traceBlockByNumber")) {
+			n := inFlight.Add(1)
+			for {
+				max := maxInFlight.Load()
+				if n <= max || maxInFlight.CompareAndSwap(max, n) {
+					break
+				}
+			}
+			<-release
+			inFlight.Add(-1)
+		}
+		_, _ = w.Write([]byte(goodResponse))
+	}))
+	defer backend.Close()
+	defer close(release)
+
+	require.NoError(t, os.Setenv("GOOD_BACKEND_RPC_URL", backend.URL))
+
+	config := ReadConfig("method_concurrency")
+	client := NewProxydClient("http://127.0.0.1:8545")
+	trace := func() (string, int) {
+		res, code, err := client.SendRPC("debug_traceBlockByNumber", []interface{}{"0x1"})
+		require.NoError(t, err)
+		return string(res), code
+	}
+
+	// saturate sends limit calls and waits for them to reach the backend. It
+	// returns a wait group done once they are answered.
+	saturate := func() *sync.WaitGroup {
+		var wg sync.WaitGroup
+		for i := 0; i < 2; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, code := trace()
+				require.Equal(t, http.StatusOK, code)
+			}()
+		}
+		require.Eventually(t, func() bool {
+			return inFlight.Load() == 2
+		}, time.Second, 10*time.Millisecond)
+		return &wg
+	}
+
+	t.Run("queues calls over the limit", func(t *testing.T) {
+		_, shutdown, err := proxyd.Start(config)
+		require.NoError(t, err)
+		defer shutdown()
+
+		wg := saturate()
+		require.Equal(t, float64(2), methodInFlight(t, "main", "debug_traceBlockByNumber"))
+
+		// other methods aren't limited
+		_, code, err := client.SendRPC("eth_chainId", nil)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, code)
+
+		// a queued call is forwarded once a slot frees
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, code := trace()
+			require.Equal(t, http.StatusOK, code)
+		}()
+		time.Sleep(100 * time.Millisecond)
+		require.Equal(t, int32(2), inFlight.Load())
+		release <- struct{}{}
+		require.Eventually(t, func() bool {
+			return inFlight.Load() == 2
+		}, time.Second, 10*time.Millisecond)
+
+		// a call waiting longer than queue_timeout is rejected
+		res, code := trace()
+		require.Equal(t, http.StatusServiceUnavailable, code)
+		RequireEqualJSON(t, []byte(`{"jsonrpc":"2.0","id":999,"error":{"code":-32029,"message":"timed out waiting for an rpc slot"}}`), []byte(res))
+
+		release <- struct{}{}
+		release <- struct{}{}
+		wg.Wait()
+		require.Equal(t, int32(2), maxInFlight.Load())
+		require.Equal(t, float64(0), methodInFlight(t, "main", "debug_traceBlockByNumber"))
+	})
+
+	t.Run("rejects calls over the limit", func(t *testing.T) {
+		config.BackendGroups["main"].MethodConcurrencyReject = true
+		_, shutdown, err := proxyd.Start(config)
+		require.NoError(t, err)
+		defer shutdown()
+
+		wg := saturate()
+
+		start := time.Now()
+		res, code := trace()
+		require.Less(t, time.Since(start), 500*time.Millisecond)
+		require.Equal(t, http.StatusTooManyRequests, code)
+		RequireEqualJSON(t, []byte(`{"jsonrpc":"2.0","id":999,"error":{"code":-32032,"message":"too many concurrent calls to method"}}`), []byte(res))
+
+		release <- struct{}{}
+		release <- struct{}{}
+		wg.Wait()
+		require.Equal(t, int32(2), maxInFlight.Load())
+	})
+}
+
+func methodInFlight(t *testing.T, backendGroup, method string) float64 {
+	families, err := prometheus.DefaultGatherer.Gather()
+	require.NoError(t, err)
+	for _, family := range families {
+		if family.GetName() != "proxyd_method_in_flight_requests" {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			labels := make(map[string]string)
+			for _, label := range metric.GetLabel() {
+				labels[label.GetName()] = label.GetValue()
+			}
+			if labels["backend_group"] == backendGroup && labels["method"] == method {
+				return metric.GetGauge().GetValue()
+			}
+		}
+	}
+	return 0
+}
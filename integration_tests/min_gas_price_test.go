@@ -0,0 +1,89 @@
+package integration_tests
+
+import (
+	"math/big"
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/ethereum-optimism/infra/proxyd"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMinGasPrice(t *testing.T) {
+	goodBackend := NewMockBackend(BatchedResponseHandler(200, goodResponse))
+	defer goodBackend.Close()
+
+	require.NoError(t, os.Setenv("GOOD_BACKEND_RPC_URL", goodBackend.URL()))
+
+	config := ReadConfig("min_gas_price")
+	client := NewProxydClient("http://127.0.0.1:8545")
+	_, shutdown, err := proxyd.Start(config)
+	require.NoError(t, err)
+	defer shutdown()
+
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	signer := types.LatestSignerForChainID(big.NewInt(56))
+	rawTx := func(txData types.TxData) string {
+		tx, err := types.SignNewTx(key, signer, txData)
+		require.NoError(t, err)
+		b, err := tx.MarshalBinary()
+		require.NoError(t, err)
+		return hexutil.Encode(b)
+	}
+	gwei := func(n float64) *big.Int {
+		v, _ := new(big.Float).Mul(big.NewFloat(n), big.NewFloat(1e9)).Int(nil)
+		return v
+	}
+
+	tests := []struct {
+		name      string
+		rawTx     string
+		forwarded bool
+	}{
+		{
+			name:      "under-priced legacy tx",
+			rawTx:     rawTx(&types.LegacyTx{Gas: 21000, GasPrice: gwei(0.5)}),
+			forwarded: false,
+		},
+		{
+			name:      "adequately priced legacy tx",
+			rawTx:     rawTx(&types.LegacyTx{Gas: 21000, GasPrice: gwei(1)}),
+			forwarded: true,
+		},
+		{
+			name:      "under-priced dynamic fee tx",
+			rawTx:     rawTx(&types.DynamicFeeTx{ChainID: big.NewInt(56), Gas: 21000, GasFeeCap: gwei(3), GasTipCap: gwei(0.5)}),
+			forwarded: false,
+		},
+		{
+			name:      "adequately priced dynamic fee tx",
+			rawTx:     rawTx(&types.DynamicFeeTx{ChainID: big.NewInt(56), Gas: 21000, GasFeeCap: gwei(3), GasTipCap: gwei(2)}),
+			forwarded: true,
+		},
+		{
+			name:      "undecodable tx",
+			rawTx:     "0x1234",
+			forwarded: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			goodBackend.Reset()
+			res, code, err := client.SendRPC("eth_sendRawTransaction", []interface{}{tt.rawTx})
+			require.NoError(t, err)
+			if tt.forwarded {
+				require.Equal(t, http.StatusOK, code)
+				require.Len(t, goodBackend.Requests(), 1)
+				return
+			}
+			require.Equal(t, http.StatusBadRequest, code)
+			require.Contains(t, string(res), proxyd.ErrTxUnderpriced.Message)
+			require.Empty(t, goodBackend.Requests())
+		})
+	}
+}
@@ -16,6 +16,7 @@ import (
 
 type RecordedRequest struct {
 	Method  string
+	Host    string
 	Headers http.Header
 	Body    []byte
 }
@@ -250,6 +251,7 @@ func (m *MockBackend) wrappedHandler(w http.ResponseWriter, r *http.Request) {
 	clone.Body = io.NopCloser(bytes.NewReader(body))
 	m.requests = append(m.requests, &RecordedRequest{
 		Method:  r.Method,
+		Host:    r.Host,
 		Headers: r.Header.Clone(),
 		Body:    body,
 	})
@@ -0,0 +1,56 @@
+package integration_tests
+
+import (
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/ethereum-optimism/infra/proxyd"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNetVersionFromConfig(t *testing.T) {
+	router := NewBatchRPCResponseRouter()
+	router.SetFallbackRoute("net_version", "1")
+	router.SetFallbackRoute("eth_chainId", "0x38")
+	goodBackend := NewMockBackend(router)
+	defer goodBackend.Close()
+
+	require.NoError(t, os.Setenv("GOOD_BACKEND_RPC_URL", goodBackend.URL()))
+
+	config := ReadConfig("net_version")
+	_, shutdown, err := proxyd.Start(config)
+	require.NoError(t, err)
+	defer shutdown()
+
+	client := NewProxydClient("http://127.0.0.1:8545")
+	sendNetVersion := func(host string) []byte {
+		var headers map[string]string
+		if host != "" {
+			headers = map[string]string{"X-Forwarded-Host": host}
+		}
+		res, code, err := client.SendRequestWithHeaders(NewRPCReq("999", "net_version", nil), headers)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, code)
+		return res
+	}
+
+	RequireEqualJSON(t, []byte(`{"jsonrpc": "2.0", "result": "56", "id": 999}`), sendNetVersion(""))
+	RequireEqualJSON(t, []byte(`{"jsonrpc": "2.0", "result": "97", "id": 999}`), sendNetVersion("testnet.example.com"))
+	require.Empty(t, goodBackend.Requests())
+
+	// in batches too
+	res, code, err := client.SendBatchRPC(
+		NewRPCReq("1", "net_version", nil),
+		NewRPCReq("2", "eth_chainId", nil),
+	)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, code)
+	RequireEqualJSON(t, []byte(`[{"jsonrpc": "2.0", "result": "56", "id": 1}, {"jsonrpc": "2.0", "result": "0x38", "id": 2}]`), res)
+	require.Len(t, goodBackend.Requests(), 1)
+
+	// groups without net_version forward it
+	goodBackend.Reset()
+	RequireEqualJSON(t, []byte(`{"jsonrpc": "2.0", "result": "1", "id": 999}`), sendNetVersion("forwarded.example.com"))
+	require.Len(t, goodBackend.Requests(), 1)
+}
@@ -0,0 +1,119 @@
+package integration_tests
+
+import (
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/ethereum-optimism/infra/proxyd"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestOTelTracing(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+	defer otel.SetTracerProvider(prev)
+
+	router := NewBatchRPCResponseRouter()
+	router.SetFallbackRoute("eth_chainId", "0x38")
+	router.SetFallbackRoute("eth_blockNumber", "0x100")
+	goodBackend := NewMockBackend(router)
+	defer goodBackend.Close()
+
+	require.NoError(t, os.Setenv("GOOD_BACKEND_RPC_URL", goodBackend.URL()))
+
+	config := ReadConfig("otel")
+	_, shutdown, err := proxyd.Start(config)
+	require.NoError(t, err)
+	defer shutdown()
+
+	client := NewProxydClient("http://127.0.0.1:8545")
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	const parentID = "00f067aa0ba902b7"
+	_, code, err := client.SendRequestWithHeaders(NewRPCReq("999", "eth_chainId", nil), map[string]string{
+		"traceparent":      "00-" + traceID + "-" + parentID + "-01",
+		"X-Forwarded-Host": "bsc.example.com",
+	})
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, code)
+
+	spans := spansByName(exporter.GetSpans())
+	require.Len(t, spans["proxyd.rpc"], 1)
+	require.Len(t, spans["proxyd.cache"], 1)
+	require.Len(t, spans["proxyd.backend"], 1)
+
+	root := spans["proxyd.rpc"][0]
+	require.Equal(t, traceID, root.SpanContext.TraceID().String())
+	require.Equal(t, parentID, root.Parent.SpanID().String())
+	require.Equal(t, trace.SpanKindServer, root.SpanKind)
+	requireSpanAttr(t, root, "rpc.method", attribute.StringValue("eth_chainId"))
+	requireSpanAttr(t, root, "proxyd.domain", attribute.StringValue("bsc.example.com"))
+	requireSpanAttr(t, root, "proxyd.cache_hit", attribute.BoolValue(false))
+
+	cacheSpan := spans["proxyd.cache"][0]
+	require.Equal(t, root.SpanContext.SpanID(), cacheSpan.Parent.SpanID())
+	requireSpanAttr(t, cacheSpan, "proxyd.cache_hit", attribute.BoolValue(false))
+
+	backendSpan := spans["proxyd.backend"][0]
+	require.Equal(t, root.SpanContext.SpanID(), backendSpan.Parent.SpanID())
+	require.Equal(t, trace.SpanKindClient, backendSpan.SpanKind)
+	requireSpanAttr(t, backendSpan, "proxyd.backend", attribute.StringValue("good"))
+	requireSpanAttr(t, backendSpan, "rpc.method", attribute.StringValue("eth_chainId"))
+
+	// the backend continues the trace from the backend span
+	require.Len(t, goodBackend.Requests(), 1)
+	require.Equal(t,
+		"00-"+traceID+"-"+backendSpan.SpanContext.SpanID().String()+"-01",
+		goodBackend.Requests()[0].Headers.Get("traceparent"),
+	)
+
+	// cache hits are served without a backend span
+	exporter.Reset()
+	_, code, err = client.SendRPC("eth_chainId", nil)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, code)
+	spans = spansByName(exporter.GetSpans())
+	require.Len(t, spans["proxyd.rpc"], 1)
+	require.Len(t, spans["proxyd.backend"], 0)
+	root = spans["proxyd.rpc"][0]
+	require.False(t, root.Parent.IsValid())
+	requireSpanAttr(t, root, "proxyd.cache_hit", attribute.BoolValue(true))
+	requireSpanAttr(t, spans["proxyd.cache"][0], "proxyd.cache_hit", attribute.BoolValue(true))
+
+	// batches
+	exporter.Reset()
+	_, code, err = client.SendBatchRPC(
+		NewRPCReq("1", "eth_blockNumber", nil),
+		NewRPCReq("2", "eth_blockNumber", nil),
+	)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, code)
+	spans = spansByName(exporter.GetSpans())
+	require.Len(t, spans["proxyd.rpc"], 1)
+	requireSpanAttr(t, spans["proxyd.rpc"][0], "rpc.method", attribute.StringValue("<batch>"))
+	requireSpanAttr(t, spans["proxyd.rpc"][0], "proxyd.batch_size", attribute.IntValue(2))
+}
+
+func spansByName(spans tracetest.SpanStubs) map[string][]tracetest.SpanStub {
+	byName := make(map[string][]tracetest.SpanStub)
+	for _, span := range spans {
+		byName[span.Name] = append(byName[span.Name], span)
+	}
+	return byName
+}
+
+func requireSpanAttr(t *testing.T, span tracetest.SpanStub, key string, value attribute.Value) {
+	for _, attr := range span.Attributes {
+		if string(attr.Key) == key {
+			require.Equal(t, value, attr.Value, key)
+			return
+		}
+	}
+	t.Fatalf("span %s has no attribute %s", span.Name, key)
+}
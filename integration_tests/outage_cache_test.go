@@ -0,0 +1,77 @@
+package integration_tests
+
+import (
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/ethereum-optimism/infra/proxyd"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCacheOnlyOnOutage(t *testing.T) {
+	const balanceResponse = `{"jsonrpc": "2.0", "result": "0x10", "id": 999}`
+	const noBackendsResponse = `{"jsonrpc": "2.0", "error": {"code": -32011, "message": "no backend is currently healthy to serve traffic"}, "id": 999}`
+
+	goodBackend := NewMockBackend(SingleResponseHandler(200, balanceResponse))
+	defer goodBackend.Close()
+
+	require.NoError(t, os.Setenv("GOOD_BACKEND_RPC_URL", goodBackend.URL()))
+
+	config := ReadConfig("outage_cache")
+	svr, shutdown, err := proxyd.Start(config)
+	require.NoError(t, err)
+	defer shutdown()
+
+	client := NewProxydClient("http://127.0.0.1:8545")
+	balanceParams := []interface{}{"0x0000000000000000000000000000000000000001", "latest"}
+
+	// warm the cache while the backend is up
+	res, code, err := client.SendRPC("eth_getBalance", balanceParams)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, code)
+	RequireEqualJSON(t, []byte(balanceResponse), res)
+	require.Equal(t, 1, len(goodBackend.Requests()))
+
+	// the backend goes away for a minute
+	goodBackend.SetHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "60")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+
+	// the backend fails, and the read is served from the cache instead
+	res, code, err = client.SendRPC("eth_getBalance", balanceParams)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, code)
+	RequireEqualJSON(t, []byte(balanceResponse), res)
+	require.Equal(t, 2, len(goodBackend.Requests()))
+	require.True(t, svr.BackendGroups["main"].Backends[0].IsBackingOff())
+
+	// with no healthy backend, nothing is forwarded, and only cached reads
+	// are served
+	goodBackend.Reset()
+	res, code, err = client.SendRPC("eth_blockNumber", nil)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusServiceUnavailable, code)
+	RequireEqualJSON(t, []byte(noBackendsResponse), res)
+
+	res, code, err = client.SendRPC("eth_getBalance", balanceParams)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, code)
+	RequireEqualJSON(t, []byte(balanceResponse), res)
+
+	res, code, err = client.SendRPC("eth_getBalance", []interface{}{"0x0000000000000000000000000000000000000002", "latest"})
+	require.NoError(t, err)
+	require.Equal(t, http.StatusServiceUnavailable, code)
+	RequireEqualJSON(t, []byte(noBackendsResponse), res)
+	require.Equal(t, 0, len(goodBackend.Requests()))
+
+	// responses older than cache_only_max_stale aren't served
+	time.Sleep(1100 * time.Millisecond)
+	res, code, err = client.SendRPC("eth_getBalance", balanceParams)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusServiceUnavailable, code)
+	RequireEqualJSON(t, []byte(noBackendsResponse), res)
+	require.Equal(t, 0, len(goodBackend.Requests()))
+}
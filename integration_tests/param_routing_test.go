@@ -0,0 +1,104 @@
+package integration_tests
+
+import (
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/ethereum-optimism/infra/proxyd"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParamRouting(t *testing.T) {
+	mainBackend := NewMockBackend(BatchedResponseHandler(200, goodResponse))
+	defer mainBackend.Close()
+	archiveBackend := NewMockBackend(BatchedResponseHandler(200, goodResponse))
+	defer archiveBackend.Close()
+
+	require.NoError(t, os.Setenv("MAIN_BACKEND_RPC_URL", mainBackend.URL()))
+	require.NoError(t, os.Setenv("ARCHIVE_BACKEND_RPC_URL", archiveBackend.URL()))
+
+	config := ReadConfig("param_routing")
+	client := NewProxydClient("http://127.0.0.1:8545")
+	_, shutdown, err := proxyd.Start(config)
+	require.NoError(t, err)
+	defer shutdown()
+
+	const addr = "0x00000000000000000000000000000000000000AA"
+	const otherAddr = "0x00000000000000000000000000000000000000bb"
+
+	tests := []struct {
+		name     string
+		method   string
+		params   []interface{}
+		archived bool
+	}{
+		{
+			name:     "eth_call to routed address",
+			method:   "eth_call",
+			params:   []interface{}{map[string]string{"to": addr}, "latest"},
+			archived: true,
+		},
+		{
+			name:     "eth_call to other address",
+			method:   "eth_call",
+			params:   []interface{}{map[string]string{"to": otherAddr}, "latest"},
+			archived: false,
+		},
+		{
+			name:     "eth_getBalance at block number",
+			method:   "eth_getBalance",
+			params:   []interface{}{otherAddr, "0x10"},
+			archived: true,
+		},
+		{
+			name:     "eth_getBalance at block hash",
+			method:   "eth_getBalance",
+			params:   []interface{}{otherAddr, map[string]string{"blockHash": "0x01"}},
+			archived: true,
+		},
+		{
+			name:     "eth_getBalance at latest",
+			method:   "eth_getBalance",
+			params:   []interface{}{otherAddr, "latest"},
+			archived: false,
+		},
+		{
+			name:     "eth_getBalance without block",
+			method:   "eth_getBalance",
+			params:   []interface{}{otherAddr},
+			archived: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mainBackend.Reset()
+			archiveBackend.Reset()
+			res, code, err := client.SendRPC(tt.method, tt.params)
+			require.NoError(t, err)
+			require.Equal(t, http.StatusOK, code)
+			RequireEqualJSON(t, []byte(goodResponse), res)
+			if tt.archived {
+				require.Len(t, archiveBackend.Requests(), 1)
+				require.Empty(t, mainBackend.Requests())
+			} else {
+				require.Len(t, mainBackend.Requests(), 1)
+				require.Empty(t, archiveBackend.Requests())
+			}
+		})
+	}
+
+	t.Run("undefined backend group", func(t *testing.T) {
+		config := ReadConfig("param_routing")
+		config.ParamRoutes["eth_call"][0].BackendGroup = "missing"
+		_, _, err := proxyd.Start(config)
+		require.ErrorContains(t, err, "undefined backend group missing")
+	})
+
+	t.Run("invalid block tag", func(t *testing.T) {
+		config := ReadConfig("param_routing")
+		config.ParamRoutes["eth_getBalance"][0].BlockTag = "old"
+		_, _, err := proxyd.Start(config)
+		require.ErrorContains(t, err, "invalid block_tag old")
+	})
+}
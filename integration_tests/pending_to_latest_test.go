@@ -0,0 +1,87 @@
+package integration_tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/ethereum-optimism/infra/proxyd"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPendingToLatest(t *testing.T) {
+	goodBackend := NewMockBackend(BatchedResponseHandler(200, goodResponse))
+	defer goodBackend.Close()
+	require.NoError(t, os.Setenv("GOOD_BACKEND_RPC_URL", goodBackend.URL()))
+
+	config := ReadConfig("pending_to_latest")
+	_, shutdown, err := proxyd.Start(config)
+	require.NoError(t, err)
+	defer shutdown()
+
+	const addr = "0x00000000000000000000000000000000000000aa"
+	tests := []struct {
+		name     string
+		domain   string
+		method   string
+		params   []interface{}
+		expected string
+	}{
+		{
+			name:     "configured method",
+			method:   "eth_getBalance",
+			params:   []interface{}{addr, "pending"},
+			expected: `["` + addr + `","latest"]`,
+		},
+		{
+			name:     "configured method with EIP-1898 param",
+			method:   "eth_getBalance",
+			params:   []interface{}{addr, map[string]string{"blockNumber": "pending"}},
+			expected: `["` + addr + `",{"blockNumber":"latest"}]`,
+		},
+		{
+			name:     "unconfigured method",
+			method:   "eth_getTransactionCount",
+			params:   []interface{}{addr, "pending"},
+			expected: `["` + addr + `","pending"]`,
+		},
+		{
+			name:     "domain configured method",
+			domain:   "wallet.example.com",
+			method:   "eth_getStorageAt",
+			params:   []interface{}{addr, "0x0", "pending"},
+			expected: `["` + addr + `","0x0","latest"]`,
+		},
+		{
+			name:     "domain overrides the global methods",
+			domain:   "wallet.example.com",
+			method:   "eth_getBalance",
+			params:   []interface{}{addr, "pending"},
+			expected: `["` + addr + `","pending"]`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			goodBackend.Reset()
+			client := NewProxydClientWithHeaders("http://127.0.0.1:8545", http.Header{
+				"X-Forwarded-Host": []string{tt.domain},
+			})
+			_, code, err := client.SendRPC(tt.method, tt.params)
+			require.NoError(t, err)
+			require.Equal(t, http.StatusOK, code)
+			require.Len(t, goodBackend.Requests(), 1)
+
+			var req proxyd.RPCReq
+			require.NoError(t, json.Unmarshal(goodBackend.Requests()[0].Body, &req))
+			require.JSONEq(t, tt.expected, string(req.Params))
+		})
+	}
+
+	t.Run("method without block param", func(t *testing.T) {
+		config := ReadConfig("pending_to_latest")
+		config.Server.PendingToLatestMethods = []string{"eth_chainId"}
+		_, _, err := proxyd.Start(config)
+		require.ErrorContains(t, err, "eth_chainId has no block param")
+	})
+}
@@ -0,0 +1,71 @@
+package integration_tests
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/ethereum-optimism/infra/proxyd"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPinNonceReads(t *testing.T) {
+	nodes := make([]*MockBackend, 3)
+	for i := range nodes {
+		nodes[i] = NewMockBackend(BatchedResponseHandler(200, goodResponse))
+		defer nodes[i].Close()
+		require.NoError(t, os.Setenv(fmt.Sprintf("NODE%d_URL", i+1), nodes[i].URL()))
+	}
+	reset := func() {
+		for _, node := range nodes {
+			node.Reset()
+		}
+	}
+	servedBy := func() []int {
+		var served []int
+		for i, node := range nodes {
+			if len(node.Requests()) > 0 {
+				served = append(served, i)
+			}
+		}
+		return served
+	}
+
+	config := ReadConfig("pin_nonce_reads")
+	client := NewProxydClient("http://127.0.0.1:8545")
+	_, shutdown, err := proxyd.Start(config)
+	require.NoError(t, err)
+	defer shutdown()
+
+	t.Run("repeated reads for an address hit the same backend", func(t *testing.T) {
+		reset()
+		for i := 0; i < 20; i++ {
+			_, code, err := client.SendRPC("eth_getTransactionCount", []interface{}{"0x00000000000000000000000000000000000000aa", "latest"})
+			require.NoError(t, err)
+			require.Equal(t, http.StatusOK, code)
+		}
+		require.Len(t, servedBy(), 1)
+	})
+
+	t.Run("address case does not matter", func(t *testing.T) {
+		reset()
+		for _, addr := range []string{"0x00000000000000000000000000000000000000aa", "0x00000000000000000000000000000000000000AA"} {
+			_, code, err := client.SendRPC("eth_getTransactionCount", []interface{}{addr, "pending"})
+			require.NoError(t, err)
+			require.Equal(t, http.StatusOK, code)
+		}
+		require.Len(t, servedBy(), 1)
+	})
+
+	t.Run("addresses are spread across backends", func(t *testing.T) {
+		reset()
+		for i := 0; i < 30; i++ {
+			addr := fmt.Sprintf("0x%040x", i)
+			_, code, err := client.SendRPC("eth_getTransactionCount", []interface{}{addr, "latest"})
+			require.NoError(t, err)
+			require.Equal(t, http.StatusOK, code)
+		}
+		require.Greater(t, len(servedBy()), 1)
+	})
+}
@@ -0,0 +1,87 @@
+package integration_tests
+
+import (
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/ethereum-optimism/infra/proxyd"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPreferWarmConnections(t *testing.T) {
+	node1 := NewMockBackend(BatchedResponseHandler(200, goodResponse))
+	defer node1.Close()
+	node2 := NewMockBackend(BatchedResponseHandler(200, goodResponse))
+	defer node2.Close()
+
+	require.NoError(t, os.Setenv("NODE1_URL", node1.URL()))
+	require.NoError(t, os.Setenv("NODE2_URL", node2.URL()))
+
+	config := ReadConfig("prefer_warm_connections")
+	client := NewProxydClient("http://127.0.0.1:8545")
+	send := func(n int) {
+		for i := 0; i < n; i++ {
+			_, code, err := client.SendRPC("eth_chainId", nil)
+			require.NoError(t, err)
+			require.Equal(t, http.StatusOK, code)
+		}
+	}
+
+	t.Run("sticks to the warm backend", func(t *testing.T) {
+		svr, shutdown, err := proxyd.Start(config)
+		require.NoError(t, err)
+		defer shutdown()
+		node1.Reset()
+		node2.Reset()
+
+		// The first request warms up a connection to a random backend, which
+		// every sequential request after it reuses.
+		send(20)
+		counts := []int{len(node1.Requests()), len(node2.Requests())}
+		require.ElementsMatch(t, []int{0, 20}, counts)
+
+		warm, cold := svr.BackendGroups["main"].Backends[0], svr.BackendGroups["main"].Backends[1]
+		if counts[0] == 0 {
+			warm, cold = cold, warm
+		}
+		require.Equal(t, 1, warm.IdleConns())
+		require.Equal(t, 0, cold.IdleConns())
+	})
+
+	t.Run("stops tracking closed connections", func(t *testing.T) {
+		svr, shutdown, err := proxyd.Start(config)
+		require.NoError(t, err)
+		defer shutdown()
+
+		send(1)
+		bg := svr.BackendGroups["main"]
+		require.Equal(t, 1, bg.Backends[0].IdleConns()+bg.Backends[1].IdleConns())
+
+		node1.server.CloseClientConnections()
+		node2.server.CloseClientConnections()
+		require.Eventually(t, func() bool {
+			return bg.Backends[0].IdleConns()+bg.Backends[1].IdleConns() == 0
+		}, time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("spreads requests when disabled", func(t *testing.T) {
+		config.BackendGroups["main"].PreferWarmConnections = false
+		_, shutdown, err := proxyd.Start(config)
+		require.NoError(t, err)
+		defer shutdown()
+		node1.Reset()
+		node2.Reset()
+
+		send(20)
+		require.NotEmpty(t, node1.Requests())
+		require.NotEmpty(t, node2.Requests())
+	})
+	t.Run("requires a selector ranking backends equally", func(t *testing.T) {
+		config := ReadConfig("prefer_warm_connections")
+		config.BackendGroups["main"].BackendSelector = proxyd.OrderedBackendSelector
+		_, _, err := proxyd.Start(config)
+		require.ErrorContains(t, err, "prefer_warm_connections of backend group main requires the random or weighted backend selector")
+	})
+}
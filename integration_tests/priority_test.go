@@ -0,0 +1,81 @@
+package integration_tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum-optimism/infra/proxyd"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPriorityClasses(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		served  []string
+		release = make(chan struct{})
+		first   = make(chan struct{})
+		once    sync.Once
+	)
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		var req proxyd.RPCReq
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+
+		mu.Lock()
+		served = append(served, req.Method)
+		mu.Unlock()
+
+		// hold the only rpc slot until every request is queued
+		once.Do(func() {
+			close(first)
+			<-release
+		})
+		BatchedResponseHandler(200, goodResponse)(w, r)
+	}
+	// We don't use the MockBackend because it serializes requests to the handler
+	slowBackend := httptest.NewServer(http.HandlerFunc(handler))
+	defer slowBackend.Close()
+
+	require.NoError(t, os.Setenv("GOOD_BACKEND_RPC_URL", slowBackend.URL))
+
+	config := ReadConfig("priority")
+	client := NewProxydClient("http://127.0.0.1:8545")
+	_, shutdown, err := proxyd.Start(config)
+	require.NoError(t, err)
+	defer shutdown()
+
+	var wg sync.WaitGroup
+	send := func(method string) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, code, err := client.SendRPC(method, nil)
+			require.NoError(t, err)
+			require.Equal(t, 200, code)
+		}()
+	}
+
+	send("eth_chainId")
+	<-first
+	for i := 0; i < 3; i++ {
+		send("eth_chainId")
+	}
+	time.Sleep(100 * time.Millisecond)
+	send("eth_blockNumber")
+	time.Sleep(100 * time.Millisecond)
+
+	close(release)
+	wg.Wait()
+
+	require.Equal(t, []string{
+		"eth_chainId",
+		"eth_blockNumber",
+		"eth_chainId",
+		"eth_chainId",
+		"eth_chainId",
+	}, served)
+}
@@ -0,0 +1,109 @@
+package integration_tests
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/ethereum-optimism/infra/proxyd"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProxyProtocol(t *testing.T) {
+	goodBackend := NewMockBackend(BatchedResponseHandler(200, goodResponse))
+	defer goodBackend.Close()
+
+	require.NoError(t, os.Setenv("GOOD_BACKEND_RPC_URL", goodBackend.URL()))
+
+	config := ReadConfig("proxy_protocol")
+	_, shutdown, err := proxyd.Start(config)
+	require.NoError(t, err)
+	defer shutdown()
+
+	// send writes header and an RPC request spoofing its client IP with
+	// X-Forwarded-For on a new connection.
+	send := func(t *testing.T, header []byte) (*http.Response, error) {
+		conn, err := net.Dial("tcp", "127.0.0.1:8545")
+		require.NoError(t, err)
+		defer conn.Close()
+
+		body := `{"jsonrpc":"2.0","method":"eth_chainId","params":[],"id":999}`
+		req, err := http.NewRequest("POST", "http://127.0.0.1:8545", strings.NewReader(body))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Forwarded-For", "203.0.113.1")
+		_, err = conn.Write(header)
+		require.NoError(t, err)
+		require.NoError(t, req.Write(conn))
+
+		res, err := http.ReadResponse(bufio.NewReader(conn), req)
+		if err != nil {
+			return nil, err
+		}
+		_, err = io.ReadAll(res.Body)
+		require.NoError(t, err)
+		res.Body.Close()
+		return res, nil
+	}
+
+	t.Run("v2 client addresses are rate limited separately", func(t *testing.T) {
+		res, err := send(t, proxyProtocolV2Header(net.ParseIP("192.0.2.1"), 40000))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, res.StatusCode)
+
+		res, err = send(t, proxyProtocolV2Header(net.ParseIP("192.0.2.2"), 40000))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, res.StatusCode)
+
+		res, err = send(t, proxyProtocolV2Header(net.ParseIP("192.0.2.1"), 40001))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusTooManyRequests, res.StatusCode)
+	})
+
+	t.Run("v2 ipv6 client address", func(t *testing.T) {
+		res, err := send(t, proxyProtocolV2Header(net.ParseIP("2001:db8::1"), 40000))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, res.StatusCode)
+	})
+
+	t.Run("v1 client address", func(t *testing.T) {
+		res, err := send(t, []byte("PROXY TCP4 192.0.2.3 127.0.0.1 40000 8545\r\n"))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, res.StatusCode)
+
+		res, err = send(t, []byte("PROXY TCP4 192.0.2.3 127.0.0.1 40001 8545\r\n"))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusTooManyRequests, res.StatusCode)
+	})
+
+	t.Run("missing header", func(t *testing.T) {
+		_, err := send(t, nil)
+		require.Error(t, err)
+	})
+}
+
+// proxyProtocolV2Header builds a PROXY protocol v2 header for a TCP
+// connection from ip:port to 127.0.0.1:8545.
+func proxyProtocolV2Header(ip net.IP, port uint16) []byte {
+	header := []byte("\r\n\r\n\x00\r\nQUIT\n")
+	header = append(header, 0x21) // version 2, PROXY
+	var addrs []byte
+	if ip4 := ip.To4(); ip4 != nil {
+		header = append(header, 0x11) // AF_INET, STREAM
+		addrs = append(addrs, ip4...)
+		addrs = append(addrs, net.IPv4(127, 0, 0, 1).To4()...)
+	} else {
+		header = append(header, 0x21) // AF_INET6, STREAM
+		addrs = append(addrs, ip.To16()...)
+		addrs = append(addrs, net.IPv6loopback...)
+	}
+	addrs = binary.BigEndian.AppendUint16(addrs, port)
+	addrs = binary.BigEndian.AppendUint16(addrs, 8545)
+	header = binary.BigEndian.AppendUint16(header, uint16(len(addrs)))
+	return append(header, addrs...)
+}
@@ -0,0 +1,66 @@
+package integration_tests
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum-optimism/infra/proxyd"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueueTimeout(t *testing.T) {
+	started := make(chan struct{}, 1)
+	release := make(chan struct{})
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		<-release
+		BatchedResponseHandler(200, goodResponse)(w, r)
+	}
+	// We don't use the MockBackend because it serializes requests to the handler
+	slowBackend := httptest.NewServer(http.HandlerFunc(handler))
+	defer slowBackend.Close()
+
+	require.NoError(t, os.Setenv("GOOD_BACKEND_RPC_URL", slowBackend.URL))
+
+	config := ReadConfig("queue_timeout")
+	client := NewProxydClient("http://127.0.0.1:8545")
+	_, shutdown, err := proxyd.Start(config)
+	require.NoError(t, err)
+	defer shutdown()
+
+	type resWithCodeErr struct {
+		res  []byte
+		code int
+		err  error
+	}
+	firstCh := make(chan *resWithCodeErr, 1)
+	go func() {
+		res, code, err := client.SendRPC("eth_chainId", nil)
+		firstCh <- &resWithCodeErr{res: res, code: code, err: err}
+	}()
+	// The first request holds the only rpc slot until released.
+	<-started
+
+	start := time.Now()
+	res, err := http.Post("http://127.0.0.1:8545", "application/json",
+		strings.NewReader(`{"jsonrpc":"2.0","method":"eth_chainId","params":[],"id":999}`))
+	require.NoError(t, err)
+	defer res.Body.Close()
+	body, err := io.ReadAll(res.Body)
+	require.NoError(t, err)
+	require.Less(t, time.Since(start), time.Second)
+	require.Equal(t, http.StatusServiceUnavailable, res.StatusCode)
+	require.Equal(t, "1", res.Header.Get("Retry-After"))
+	RequireEqualJSON(t, []byte(`{"jsonrpc":"2.0","error":{"code":-32029,"message":"timed out waiting for an rpc slot"},"id":999}`), body)
+
+	close(release)
+	first := <-firstCh
+	require.NoError(t, first.err)
+	require.Equal(t, http.StatusOK, first.code)
+	RequireEqualJSON(t, []byte(goodResponse), first.res)
+}
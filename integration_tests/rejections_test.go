@@ -0,0 +1,111 @@
+package integration_tests
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/ethereum-optimism/infra/proxyd"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRejections(t *testing.T) {
+	// debug_trace calls are slow enough to hold their slot while rejections
+	// are checked.
+	goodBackend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		if bytes.Contains(body, []byte("debug_trace")) {
+			time.Sleep(500 * time.Millisecond)
+		}
+		_, _ = w.Write([]byte(goodResponse))
+	}))
+	defer goodBackend.Close()
+	badBackend := NewMockBackend(SingleResponseHandler(503, "unavailable"))
+	defer badBackend.Close()
+
+	require.NoError(t, os.Setenv("GOOD_BACKEND_RPC_URL", goodBackend.URL))
+	require.NoError(t, os.Setenv("BAD_BACKEND_RPC_URL", badBackend.URL()))
+
+	config := ReadConfig("rejections")
+	_, shutdown, err := proxyd.Start(config)
+	require.NoError(t, err)
+	defer func() { shutdown() }()
+	client := NewProxydClient("http://127.0.0.1:8545")
+
+	// block takes the only slot of method.
+	block := func(method string) {
+		go func() { _, _, _ = client.SendRPC(method, nil) }()
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	tests := []struct {
+		name   string
+		setup  func()
+		method string
+		code   int
+		res    string
+	}{
+		{
+			name:   "rate limit",
+			setup:  func() { _, _, _ = client.SendRPC("eth_chainId", nil) },
+			method: "eth_chainId",
+			code:   http.StatusTooManyRequests,
+			res:    `{"jsonrpc":"2.0","id":999,"error":{"code":-32005,"message":"request limit exceeded"}}`,
+		},
+		{
+			name:   "concurrency",
+			setup:  func() { block("debug_traceBlockByNumber") },
+			method: "debug_traceBlockByNumber",
+			code:   http.StatusServiceUnavailable,
+			res:    `{"jsonrpc":"2.0","id":999,"error":{"code":-32006,"message":"method is busy"}}`,
+		},
+		{
+			name:   "queue timeout",
+			setup:  func() { block("debug_traceTransaction") },
+			method: "debug_traceTransaction",
+			code:   http.StatusTooManyRequests,
+			res:    `{"jsonrpc":"2.0","id":999,"error":{"code":-32029,"message":"server is overloaded"}}`,
+		},
+		{
+			name:   "no healthy backends",
+			setup:  func() {},
+			method: "eth_getBalance",
+			code:   http.StatusServiceUnavailable,
+			res:    `{"jsonrpc":"2.0","id":999,"error":{"code":-32008,"message":"no backend is currently healthy to serve traffic"}}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.setup()
+			res, code, err := client.SendRPC(tt.method, nil)
+			require.NoError(t, err)
+			require.Equal(t, tt.code, code)
+			RequireEqualJSON(t, []byte(tt.res), res)
+		})
+	}
+
+	t.Run("defaults are restored", func(t *testing.T) {
+		shutdown()
+		config.Rejections = proxyd.RejectionsConfig{}
+		_, shutdown, err = proxyd.Start(config)
+		require.NoError(t, err)
+
+		_, _, err := client.SendRPC("eth_chainId", nil)
+		require.NoError(t, err)
+		res, code, err := client.SendRPC("eth_chainId", nil)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusTooManyRequests, code)
+		RequireEqualJSON(t, []byte(`{"jsonrpc":"2.0","id":999,"error":{"code":-32016,"message":"over rate limit"}}`), res)
+	})
+
+	t.Run("invalid http status", func(t *testing.T) {
+		config.Rejections.QueueTimeout.HTTPStatus = 200
+		_, _, err := proxyd.Start(config)
+		require.ErrorContains(t, err, "http_status of queue_timeout rejections")
+	})
+}
@@ -0,0 +1,100 @@
+package integration_tests
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/ethereum-optimism/infra/proxyd"
+	ms "github.com/ethereum-optimism/infra/proxyd/tools/mockserver/handler"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReorgCacheCooldown(t *testing.T) {
+	dir, err := os.Getwd()
+	require.NoError(t, err)
+	responses := path.Join(dir, "testdata/consensus_responses.yml")
+
+	blockByHash := &ms.MethodTemplate{
+		Method:   "eth_getBlockByHash",
+		Response: buildResponse(map[string]string{"number": "0x101", "hash": "hash_0x101"}),
+	}
+	h1 := ms.MockedHandler{Overrides: []*ms.MethodTemplate{blockByHash}, Autoload: true, AutoloadFile: responses}
+	h2 := ms.MockedHandler{Overrides: []*ms.MethodTemplate{blockByHash}, Autoload: true, AutoloadFile: responses}
+
+	node1 := NewMockBackend(http.HandlerFunc(h1.Handler))
+	defer node1.Close()
+	node2 := NewMockBackend(http.HandlerFunc(h2.Handler))
+	defer node2.Close()
+
+	require.NoError(t, os.Setenv("NODE1_URL", node1.URL()))
+	require.NoError(t, os.Setenv("NODE2_URL", node2.URL()))
+
+	svr, shutdown, err := proxyd.Start(ReadConfig("reorg_cache"))
+	require.NoError(t, err)
+	defer shutdown()
+	client := NewProxydClient("http://127.0.0.1:8545")
+
+	bg := svr.BackendGroups["node"]
+	ctx := context.Background()
+	update := func() {
+		for _, be := range bg.Backends {
+			bg.Consensus.UpdateBackend(ctx, be)
+		}
+		bg.Consensus.UpdateBackendGroupConsensus(ctx)
+	}
+	overrideBlockHash := func(h *ms.MockedHandler, blockRequest string, number string, hash string) {
+		h.AddOverride(&ms.MethodTemplate{
+			Method:   "eth_getBlockByNumber",
+			Block:    blockRequest,
+			Response: buildResponse(map[string]string{"number": number, "hash": hash}),
+		})
+	}
+	forwarded := func() int {
+		n := 0
+		for _, node := range []*MockBackend{node1, node2} {
+			for _, req := range node.Requests() {
+				if bytes.Contains(req.Body, []byte("eth_getBlockByHash")) {
+					n++
+				}
+			}
+		}
+		return n
+	}
+	getBlock := func() {
+		_, code, err := client.SendRPC("eth_getBlockByHash", []interface{}{"0x1234", false})
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, code)
+	}
+
+	update()
+	require.Equal(t, "0x101", bg.Consensus.GetLatestBlockNumber().String())
+
+	// served from the cache before any reorg
+	getBlock()
+	getBlock()
+	require.Equal(t, 1, forwarded())
+
+	// advance to 0x102, then make node2 diverge on its hash
+	overrideBlockHash(&h1, "latest", "0x102", "hash_0x102")
+	overrideBlockHash(&h2, "latest", "0x102", "hash_0x102")
+	update()
+	require.Equal(t, "0x102", bg.Consensus.GetLatestBlockNumber().String())
+	overrideBlockHash(&h2, "0x102", "0x102", "wrong_hash")
+	update()
+	require.Equal(t, "0x101", bg.Consensus.GetLatestBlockNumber().String())
+
+	// the cache is bypassed during the cooldown
+	getBlock()
+	getBlock()
+	require.Equal(t, 3, forwarded())
+
+	// and used again once it elapses
+	time.Sleep(time.Second)
+	getBlock()
+	require.Equal(t, 3, forwarded())
+}
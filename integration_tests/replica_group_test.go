@@ -0,0 +1,100 @@
+package integration_tests
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/ethereum-optimism/infra/proxyd"
+	ms "github.com/ethereum-optimism/infra/proxyd/tools/mockserver/handler"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReplicaGroup(t *testing.T) {
+	dir, err := os.Getwd()
+	require.NoError(t, err)
+
+	h := ms.MockedHandler{
+		Overrides: []*ms.MethodTemplate{
+			{Method: "eth_getBalance", Response: `{"jsonrpc": "2.0", "id": 67, "result": "0x1"}`},
+			{Method: "eth_getCode", Response: `{"jsonrpc": "2.0", "id": 67, "result": "0x"}`},
+		},
+		Autoload:     true,
+		AutoloadFile: path.Join(dir, "testdata/consensus_responses.yml"),
+	}
+	node1 := NewMockBackend(http.HandlerFunc(h.Handler))
+	defer node1.Close()
+
+	replicaHdlr := NewBatchRPCResponseRouter()
+	replicaHdlr.SetFallbackRoute("eth_getBalance", "0x2")
+	replicaHdlr.SetFallbackRoute("trace_block", "0x3")
+	replica := NewMockBackend(replicaHdlr)
+	defer replica.Close()
+
+	require.NoError(t, os.Setenv("NODE1_URL", node1.URL()))
+	require.NoError(t, os.Setenv("REPLICA_URL", replica.URL()))
+
+	svr, shutdown, err := proxyd.Start(ReadConfig("replica_group"))
+	require.NoError(t, err)
+	defer shutdown()
+	client := NewProxydClient("http://127.0.0.1:8545")
+
+	getBalance := func(block interface{}) string {
+		res, code, err := client.SendRPC("eth_getBalance", []interface{}{"0x0000000000000000000000000000000000000000", block})
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, code)
+		return string(res)
+	}
+	const (
+		fromPrimary = `{"jsonrpc":"2.0","id":999,"result":"0x1"}`
+		fromReplica = `{"jsonrpc":"2.0","id":999,"result":"0x2"}`
+	)
+
+	bg := svr.BackendGroups["node"]
+	bg.Consensus.UpdateBackend(context.Background(), bg.Backends[0])
+	bg.Consensus.UpdateBackendGroupConsensus(context.Background())
+	require.Equal(t, "0x101", bg.Consensus.GetLatestBlockNumber().String())
+
+	tests := []struct {
+		name  string
+		block interface{}
+		res   string
+	}{
+		{"deep block", "0x1", fromReplica},
+		{"earliest", "earliest", fromReplica},
+		{"at the min depth", "0xf1", fromReplica},
+		{"within the min depth", "0xf2", fromPrimary},
+		{"latest", "latest", fromPrimary},
+		{"pending", "pending", fromPrimary},
+		{"block hash", map[string]string{"blockHash": "0x1100000000000000000000000000000000000000000000000000000000000000"}, fromPrimary},
+		{"eip-1898 block number", map[string]string{"blockNumber": "0x1"}, fromReplica},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			RequireEqualJSON(t, []byte(tt.res), []byte(getBalance(tt.block)))
+		})
+	}
+
+	t.Run("only configured methods", func(t *testing.T) {
+		replica.Reset()
+		_, code, err := client.SendRPC("eth_getCode", []interface{}{"0x0000000000000000000000000000000000000000", "0x1"})
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, code)
+		require.Empty(t, replica.Requests())
+	})
+
+	t.Run("always replica methods", func(t *testing.T) {
+		for _, block := range []interface{}{"latest", "pending", "0x101", "0x1"} {
+			replica.Reset()
+			node1.Reset()
+			res, code, err := client.SendRPC("trace_block", []interface{}{block})
+			require.NoError(t, err)
+			require.Equal(t, http.StatusOK, code)
+			RequireEqualJSON(t, []byte(`{"jsonrpc":"2.0","id":999,"result":"0x3"}`), res)
+			require.Len(t, replica.Requests(), 1, block)
+			require.Empty(t, node1.Requests(), block)
+		}
+	})
+}
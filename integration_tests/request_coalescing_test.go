@@ -0,0 +1,177 @@
+package integration_tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ethereum-optimism/infra/proxyd"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestCoalescing(t *testing.T) {
+	const clients = 5
+
+	var calls atomic.Int64
+	// calls to the backend are held until released
+	var releaseMtx sync.Mutex
+	release := make(chan struct{})
+	released := false
+	releaseAll := func() {
+		releaseMtx.Lock()
+		defer releaseMtx.Unlock()
+		if !released {
+			close(release)
+			released = true
+		}
+	}
+	holdAll := func() {
+		releaseMtx.Lock()
+		defer releaseMtx.Unlock()
+		release = make(chan struct{})
+		released = false
+	}
+	// unlike MockBackend, httptest serves requests concurrently
+	goodBackend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		releaseMtx.Lock()
+		held := release
+		releaseMtx.Unlock()
+		<-held
+		var req proxyd.RPCReq
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		_, _ = fmt.Fprintf(w, `{"jsonrpc":"2.0","result":"0x10","id":%s}`, req.ID)
+	}))
+	defer goodBackend.Close()
+	defer releaseAll()
+	require.NoError(t, os.Setenv("GOOD_BACKEND_RPC_URL", goodBackend.URL))
+
+	_, shutdown, err := proxyd.Start(ReadConfig("request_coalescing"))
+	require.NoError(t, err)
+	defer shutdown()
+
+	client := NewProxydClient("http://127.0.0.1:8545")
+	coalescedBefore := coalescingMetric(t, "proxyd_coalesced_requests_total", "eth_blockNumber")
+
+	var wg sync.WaitGroup
+	responses := make([][]byte, clients)
+	for i := 0; i < clients; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			body, err := json.Marshal(NewRPCReq(strconv.Itoa(i+1), "eth_blockNumber", nil))
+			require.NoError(t, err)
+			res, code, err := client.SendRequest(body)
+			require.NoError(t, err)
+			require.Equal(t, http.StatusOK, code)
+			responses[i] = res
+		}(i)
+	}
+
+	// every call but the first joins the one in flight
+	require.Eventually(t, func() bool {
+		return coalescingMetric(t, "proxyd_coalesced_requests_total", "eth_blockNumber")-coalescedBefore == clients-1 &&
+			calls.Load() == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Equal(t, float64(1), coalescingMetric(t, "proxyd_coalescing_groups_in_flight", ""))
+
+	releaseAll()
+	wg.Wait()
+	// each client gets the shared response under its own id
+	for i, res := range responses {
+		RequireEqualJSON(t, []byte(`{"jsonrpc":"2.0","result":"0x10","id":`+strconv.Itoa(i+1)+`}`), res)
+	}
+	require.Equal(t, float64(0), coalescingMetric(t, "proxyd_coalescing_groups_in_flight", ""))
+
+	t.Run("joined calls outlive the client that started them", func(t *testing.T) {
+		holdAll()
+		defer releaseAll()
+		callsBefore := calls.Load()
+		coalescedBefore := coalescingMetric(t, "proxyd_coalesced_requests_total", "eth_blockNumber")
+
+		// the first client gives up while the call is in flight
+		leaderDone := make(chan struct{})
+		go func() {
+			defer close(leaderDone)
+			body, err := json.Marshal(NewRPCReq("1", "eth_blockNumber", nil))
+			require.NoError(t, err)
+			impatient := &http.Client{Timeout: 300 * time.Millisecond}
+			_, err = impatient.Post("http://127.0.0.1:8545", "application/json", bytes.NewReader(body))
+			require.Error(t, err)
+		}()
+		require.Eventually(t, func() bool {
+			return calls.Load()-callsBefore == 1
+		}, 5*time.Second, 10*time.Millisecond)
+
+		var res []byte
+		var code int
+		followerDone := make(chan struct{})
+		go func() {
+			defer close(followerDone)
+			body, err := json.Marshal(NewRPCReq("2", "eth_blockNumber", nil))
+			require.NoError(t, err)
+			res, code, err = client.SendRequest(body)
+			require.NoError(t, err)
+		}()
+		require.Eventually(t, func() bool {
+			return coalescingMetric(t, "proxyd_coalesced_requests_total", "eth_blockNumber")-coalescedBefore == 1
+		}, 5*time.Second, 10*time.Millisecond)
+
+		<-leaderDone
+		releaseAll()
+		<-followerDone
+		require.Equal(t, http.StatusOK, code)
+		RequireEqualJSON(t, []byte(`{"jsonrpc":"2.0","result":"0x10","id":2}`), res)
+		require.Equal(t, int64(1), calls.Load()-callsBefore)
+	})
+
+	t.Run("other methods aren't coalesced", func(t *testing.T) {
+		callsBefore := calls.Load()
+		var wg sync.WaitGroup
+		for i := 0; i < 2; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, code, err := client.SendRPC("eth_chainId", nil)
+				require.NoError(t, err)
+				require.Equal(t, http.StatusOK, code)
+			}()
+		}
+		wg.Wait()
+		require.Equal(t, int64(2), calls.Load()-callsBefore)
+	})
+}
+
+// coalescingMetric sums the values of the metric name, for method if set.
+func coalescingMetric(t *testing.T, name string, method string) float64 {
+	families, err := prometheus.DefaultGatherer.Gather()
+	require.NoError(t, err)
+	var value float64
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			matched := method == ""
+			for _, label := range metric.GetLabel() {
+				if label.GetName() == "method" && label.GetValue() == method {
+					matched = true
+				}
+			}
+			if !matched {
+				continue
+			}
+			value += metric.GetCounter().GetValue() + metric.GetGauge().GetValue()
+		}
+	}
+	return value
+}
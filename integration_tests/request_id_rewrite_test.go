@@ -0,0 +1,98 @@
+package integration_tests
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"testing"
+
+	"github.com/ethereum-optimism/infra/proxyd"
+	"github.com/stretchr/testify/require"
+)
+
+// echoResultHandler answers every request in a (possibly batched) body
+// with the method name as result, echoing back whatever ID it received.
+func echoResultHandler(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		panic(err)
+	}
+
+	respond := func(raw json.RawMessage) *proxyd.RPCRes {
+		req, err := proxyd.ParseRPCReq(raw)
+		if err != nil {
+			panic(err)
+		}
+		return proxyd.NewRPCRes(req.ID, req.Method)
+	}
+
+	if !proxyd.IsBatch(body) {
+		_ = json.NewEncoder(w).Encode(respond(body))
+		return
+	}
+	batch, err := proxyd.ParseBatchRPCReq(body)
+	if err != nil {
+		panic(err)
+	}
+	out := make([]*proxyd.RPCRes, len(batch))
+	for i := range batch {
+		out[i] = respond(batch[i])
+	}
+	_ = json.NewEncoder(w).Encode(out)
+}
+
+func TestRequestIDRewriting(t *testing.T) {
+	goodBackend := NewMockBackend(http.HandlerFunc(echoResultHandler))
+	defer goodBackend.Close()
+
+	require.NoError(t, os.Setenv("GOOD_BACKEND_RPC_URL", goodBackend.URL()))
+
+	config := ReadConfig("request_id_rewrite")
+	client := NewProxydClient("http://127.0.0.1:8545")
+	_, shutdown, err := proxyd.Start(config)
+	require.NoError(t, err)
+	defer shutdown()
+
+	t.Run("single request", func(t *testing.T) {
+		goodBackend.Reset()
+		res, code, err := client.SendRPC("eth_chainId", nil)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, code)
+		RequireEqualJSON(t, []byte(`{"jsonrpc":"2.0","result":"eth_chainId","id":999}`), res)
+
+		require.Len(t, goodBackend.Requests(), 1)
+		var sent proxyd.RPCReq
+		require.NoError(t, json.Unmarshal(goodBackend.Requests()[0].Body, &sent))
+		require.NotEqual(t, "999", string(sent.ID))
+	})
+
+	t.Run("batch round trip", func(t *testing.T) {
+		goodBackend.Reset()
+		res, code, err := client.SendBatchRPC(
+			NewRPCReq("1", "eth_chainId", nil),
+			NewRPCReq(`"a"`, "net_version", nil),
+			NewRPCReq("3", "eth_chainId", nil),
+		)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, code)
+		RequireEqualJSON(t, []byte(asArray(
+			`{"jsonrpc":"2.0","result":"eth_chainId","id":1}`,
+			`{"jsonrpc":"2.0","result":"net_version","id":"a"}`,
+			`{"jsonrpc":"2.0","result":"eth_chainId","id":3}`,
+		)), res)
+
+		require.Len(t, goodBackend.Requests(), 1)
+		var sent []*proxyd.RPCReq
+		require.NoError(t, json.Unmarshal(goodBackend.Requests()[0].Body, &sent))
+		require.Len(t, sent, 3)
+		seen := make(map[string]bool)
+		for _, req := range sent {
+			_, err := strconv.ParseUint(string(req.ID), 10, 64)
+			require.NoError(t, err, "backend should only see proxyd-assigned IDs")
+			require.False(t, seen[string(req.ID)])
+			seen[string(req.ID)] = true
+		}
+	})
+}
@@ -0,0 +1,67 @@
+package integration_tests
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/ethereum-optimism/infra/proxyd"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestLimits(t *testing.T) {
+	goodBackend := NewMockBackend(BatchedResponseHandler(200, goodResponse))
+	defer goodBackend.Close()
+	require.NoError(t, os.Setenv("GOOD_BACKEND_RPC_URL", goodBackend.URL()))
+
+	config := ReadConfig("request_limits")
+	_, shutdown, err := proxyd.Start(config)
+	require.NoError(t, err)
+	defer shutdown()
+
+	t.Run("within limits", func(t *testing.T) {
+		goodBackend.Reset()
+		client := NewProxydClient("http://127.0.0.1:8545")
+		res, code, err := client.SendRPC("eth_chainId", nil)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, code)
+		RequireEqualJSON(t, []byte(goodResponse), res)
+	})
+
+	t.Run("too many headers", func(t *testing.T) {
+		goodBackend.Reset()
+		headers := http.Header{}
+		for i := 0; i < 10; i++ {
+			headers.Set(fmt.Sprintf("X-Junk-%d", i), "1")
+		}
+		client := NewProxydClientWithHeaders("http://127.0.0.1:8545", headers)
+		res, code, err := client.SendRPC("eth_chainId", nil)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusRequestHeaderFieldsTooLarge, code)
+		require.Contains(t, string(res), proxyd.ErrRequestHeadersTooLarge.Message)
+		require.Empty(t, goodBackend.Requests())
+	})
+
+	t.Run("headers too large", func(t *testing.T) {
+		goodBackend.Reset()
+		client := NewProxydClientWithHeaders("http://127.0.0.1:8545", http.Header{
+			"X-Junk": []string{strings.Repeat("a", 2048)},
+		})
+		_, code, err := client.SendRPC("eth_chainId", nil)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusRequestHeaderFieldsTooLarge, code)
+		require.Empty(t, goodBackend.Requests())
+	})
+
+	t.Run("url too long", func(t *testing.T) {
+		goodBackend.Reset()
+		client := NewProxydClient("http://127.0.0.1:8545/" + strings.Repeat("a", 64))
+		res, code, err := client.SendRPC("eth_chainId", nil)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusRequestURITooLong, code)
+		require.Contains(t, string(res), proxyd.ErrRequestURITooLong.Message)
+		require.Empty(t, goodBackend.Requests())
+	})
+}
@@ -0,0 +1,56 @@
+package integration_tests
+
+import (
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/ethereum-optimism/infra/proxyd"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestOverhead(t *testing.T) {
+	const backendDelay = 200 * time.Millisecond
+	goodBackend := NewMockBackend(SingleResponseHandlerWithSleep(200, goodResponse, backendDelay))
+	defer goodBackend.Close()
+
+	require.NoError(t, os.Setenv("GOOD_BACKEND_RPC_URL", goodBackend.URL()))
+
+	config := ReadConfig("request_overhead")
+	client := NewProxydClient("http://127.0.0.1:8545")
+	_, shutdown, err := proxyd.Start(config)
+	require.NoError(t, err)
+	defer shutdown()
+
+	countBefore, sumBefore := requestOverhead(t)
+
+	start := time.Now()
+	res, code, err := client.SendRPC("eth_chainId", nil)
+	latency := time.Since(start)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, code)
+	RequireEqualJSON(t, []byte(goodResponse), res)
+
+	count, sum := requestOverhead(t)
+	require.Equal(t, countBefore+1, count)
+	overhead := time.Duration((sum - sumBefore) * float64(time.Millisecond))
+	require.GreaterOrEqual(t, overhead, time.Duration(0))
+	// The backend round trip is excluded from the overhead.
+	require.LessOrEqual(t, overhead, latency-backendDelay)
+}
+
+// requestOverhead returns the sample count and sum of the request overhead
+// histogram.
+func requestOverhead(t *testing.T) (uint64, float64) {
+	families, err := prometheus.DefaultGatherer.Gather()
+	require.NoError(t, err)
+	for _, family := range families {
+		if family.GetName() == "proxyd_request_overhead_milliseconds" {
+			histogram := family.GetMetric()[0].GetHistogram()
+			return histogram.GetSampleCount(), histogram.GetSampleSum()
+		}
+	}
+	return 0, 0
+}
@@ -0,0 +1,87 @@
+package integration_tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/ethereum-optimism/infra/proxyd"
+	"github.com/stretchr/testify/require"
+)
+
+const unfilteredBlock = `{"number":"0x1","logsBloom":"0x00","transactions":[{"hash":"0xa","input":"0x1234"},{"hash":"0xb","input":"0x5678"}]}`
+
+func TestResponseFieldFilters(t *testing.T) {
+	goodBackend := NewMockBackend(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var raw json.RawMessage
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&raw))
+		isBatch := raw[0] == '['
+		var reqs []*proxyd.RPCReq
+		if isBatch {
+			require.NoError(t, json.Unmarshal(raw, &reqs))
+		} else {
+			reqs = make([]*proxyd.RPCReq, 1)
+			require.NoError(t, json.Unmarshal(raw, &reqs[0]))
+		}
+		body := make([]byte, 0)
+		for i, req := range reqs {
+			if i > 0 {
+				body = append(body, ',')
+			}
+			var res string
+			switch {
+			case strings.Contains(string(req.Params), "0xbad"):
+				res = fmt.Sprintf(`{"jsonrpc":"2.0","error":{"code":-32000,"message":"bad block","data":{"logsBloom":"0x00"}},"id":%s}`, req.ID)
+			case req.Method == "eth_getBlockByNumber":
+				res = fmt.Sprintf(`{"jsonrpc":"2.0","result":%s,"id":%s}`, unfilteredBlock, req.ID)
+			default:
+				res = fmt.Sprintf(`{"jsonrpc":"2.0","result":{"logsBloom":"0x00"},"id":%s}`, req.ID)
+			}
+			body = append(body, res...)
+		}
+		if isBatch {
+			body = append(append([]byte{'['}, body...), ']')
+		}
+		_, _ = w.Write(body)
+	}))
+	defer goodBackend.Close()
+	require.NoError(t, os.Setenv("GOOD_BACKEND_RPC_URL", goodBackend.URL()))
+
+	_, shutdown, err := proxyd.Start(ReadConfig("response_field_filters"))
+	require.NoError(t, err)
+	defer shutdown()
+
+	client := NewProxydClient("http://127.0.0.1:8545")
+
+	t.Run("fields are removed", func(t *testing.T) {
+		res, code, err := client.SendRPC("eth_getBlockByNumber", []interface{}{"0x1", true})
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, code)
+		RequireEqualJSON(t, []byte(`{"jsonrpc":"2.0","result":{"number":"0x1","transactions":[{"hash":"0xa"},{"hash":"0xb"}]},"id":999}`), res)
+	})
+
+	t.Run("domain filters replace the others", func(t *testing.T) {
+		res, code, err := client.SendRequestWithHeaders(NewRPCReq("1", "eth_getBlockByNumber", []interface{}{"0x1", true}), map[string]string{"X-Forwarded-Host": "light.example.com"})
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, code)
+		RequireEqualJSON(t, []byte(`{"jsonrpc":"2.0","result":{"number":"0x1","logsBloom":"0x00"},"id":1}`), res)
+	})
+
+	t.Run("errors and other methods are untouched", func(t *testing.T) {
+		res, code, err := client.SendBatchRPC(
+			NewRPCReq("1", "eth_getBlockByNumber", []interface{}{"0xbad", true}),
+			NewRPCReq("2", "eth_getTransactionReceipt", []interface{}{"0xa"}),
+			NewRPCReq("3", "eth_getBlockByNumber", []interface{}{"0x1", true}),
+		)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, code)
+		RequireEqualJSON(t, []byte(`[
+			{"jsonrpc":"2.0","error":{"code":-32000,"message":"bad block","data":{"logsBloom":"0x00"}},"id":1},
+			{"jsonrpc":"2.0","result":{"logsBloom":"0x00"},"id":2},
+			{"jsonrpc":"2.0","result":{"number":"0x1","transactions":[{"hash":"0xa"},{"hash":"0xb"}]},"id":3}
+		]`), res)
+	})
+}
@@ -0,0 +1,73 @@
+package integration_tests
+
+import (
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/ethereum-optimism/infra/proxyd"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResponseSizeByMethod(t *testing.T) {
+	hdlr := NewBatchRPCResponseRouter()
+	hdlr.SetFallbackRoute("eth_chainId", "0x38")
+	hdlr.SetFallbackRoute("net_version", "56000000000")
+	goodBackend := NewMockBackend(hdlr)
+	defer goodBackend.Close()
+
+	require.NoError(t, os.Setenv("GOOD_BACKEND_RPC_URL", goodBackend.URL()))
+
+	config := ReadConfig("batching")
+	_, shutdown, err := proxyd.Start(config)
+	require.NoError(t, err)
+	defer shutdown()
+
+	client := NewProxydClient("http://127.0.0.1:8545")
+	chainIDCount, chainIDSum := responseSizes(t, "eth_chainId")
+	netVersionCount, netVersionSum := responseSizes(t, "net_version")
+	unknownCount, _ := responseSizes(t, proxyd.MethodUnknown)
+
+	_, code, err := client.SendRPC("eth_chainId", nil)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, code)
+	_, code, err = client.SendBatchRPC(
+		NewRPCReq("1", "eth_chainId", nil),
+		NewRPCReq("2", "net_version", nil),
+		NewRPCReq("3", "eth_notWhitelisted", nil),
+	)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, code)
+
+	count, sum := responseSizes(t, "eth_chainId")
+	require.Equal(t, chainIDCount+2, count)
+	// {"jsonrpc":"2.0","result":"0x38","id":999}\n and {"jsonrpc":"2.0","result":"0x38","id":1}
+	require.Equal(t, chainIDSum+43+40, sum)
+
+	count, sum = responseSizes(t, "net_version")
+	require.Equal(t, netVersionCount+1, count)
+	// {"jsonrpc":"2.0","result":"56000000000","id":2}
+	require.Equal(t, netVersionSum+47, sum)
+
+	count, _ = responseSizes(t, proxyd.MethodUnknown)
+	require.Equal(t, unknownCount+1, count)
+}
+
+func responseSizes(t *testing.T, method string) (uint64, float64) {
+	families, err := prometheus.DefaultGatherer.Gather()
+	require.NoError(t, err)
+	for _, family := range families {
+		if family.GetName() != "proxyd_response_sizes_by_method" {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			for _, label := range metric.GetLabel() {
+				if label.GetName() == "method" && label.GetValue() == method {
+					return metric.GetHistogram().GetSampleCount(), metric.GetHistogram().GetSampleSum()
+				}
+			}
+		}
+	}
+	return 0, 0
+}
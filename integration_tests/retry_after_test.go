@@ -0,0 +1,57 @@
+package integration_tests
+
+import (
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/ethereum-optimism/infra/proxyd"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetryAfter(t *testing.T) {
+	limited := NewMockBackend(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "1")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer limited.Close()
+	other := NewMockBackend(BatchedResponseHandler(200, goodResponse))
+	defer other.Close()
+
+	require.NoError(t, os.Setenv("LIMITED_BACKEND_RPC_URL", limited.URL()))
+	require.NoError(t, os.Setenv("OTHER_BACKEND_RPC_URL", other.URL()))
+
+	config := ReadConfig("retry_after")
+	svr, shutdown, err := proxyd.Start(config)
+	require.NoError(t, err)
+	defer shutdown()
+
+	client := NewProxydClient("http://127.0.0.1:8545")
+	send := func() {
+		res, code, err := client.SendRPC("eth_chainId", nil)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, code)
+		RequireEqualJSON(t, []byte(goodResponse), res)
+	}
+
+	// the 429 isn't retried, and the request fails over to the other backend
+	send()
+	require.Equal(t, 1, len(limited.Requests()))
+	require.Equal(t, 1, len(other.Requests()))
+	require.True(t, svr.BackendGroups["main"].Backends[0].IsBackingOff())
+
+	// the backend is avoided until Retry-After elapses
+	send()
+	send()
+	require.Equal(t, 1, len(limited.Requests()))
+	require.Equal(t, 3, len(other.Requests()))
+
+	limited.SetHandler(BatchedResponseHandler(200, goodResponse))
+	require.Eventually(t, func() bool {
+		return !svr.BackendGroups["main"].Backends[0].IsBackingOff()
+	}, 2*time.Second, 50*time.Millisecond)
+	send()
+	require.Equal(t, 2, len(limited.Requests()))
+	require.Equal(t, 3, len(other.Requests()))
+}
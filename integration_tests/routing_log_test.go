@@ -0,0 +1,94 @@
+package integration_tests
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/ethereum-optimism/infra/proxyd"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/stretchr/testify/require"
+)
+
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+// records returns the logged records with the given message, and clears
+// the buffer.
+func (b *syncBuffer) records(t *testing.T, msg string) []map[string]interface{} {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var records []map[string]interface{}
+	scanner := bufio.NewScanner(&b.buf)
+	for scanner.Scan() {
+		var record map[string]interface{}
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &record))
+		if record["msg"] == msg {
+			records = append(records, record)
+		}
+	}
+	b.buf.Reset()
+	return records
+}
+
+func TestRoutingLog(t *testing.T) {
+	goodBackend1 := NewMockBackend(BatchedResponseHandler(200, goodResponse))
+	defer goodBackend1.Close()
+	goodBackend2 := NewMockBackend(BatchedResponseHandler(200, goodResponse))
+	defer goodBackend2.Close()
+
+	require.NoError(t, os.Setenv("GOOD_BACKEND_RPC_URL_1", goodBackend1.URL()))
+	require.NoError(t, os.Setenv("GOOD_BACKEND_RPC_URL_2", goodBackend2.URL()))
+
+	var logs syncBuffer
+	defer log.SetDefault(log.Root())
+	log.SetDefault(log.NewLogger(slog.NewJSONHandler(&logs, &slog.HandlerOptions{Level: slog.LevelInfo})))
+
+	config := ReadConfig("routing_log")
+	_, shutdown, err := proxyd.Start(config)
+	require.NoError(t, err)
+	defer shutdown()
+
+	client := NewProxydClient("http://127.0.0.1:8545")
+	send := func(method string, headers map[string]string, expectedCode int) {
+		_, code, err := client.SendRequestWithHeaders(NewRPCReq("1", method, nil), headers)
+		require.NoError(t, err)
+		require.Equal(t, expectedCode, code)
+	}
+	requireRoute := func(domain, mapping, method, group, rule string, shortCircuit bool) {
+		records := logs.records(t, "routed RPC call")
+		require.Len(t, records, 1)
+		require.NotEmpty(t, records[0]["req_id"])
+		require.Equal(t, domain, records[0]["domain"])
+		require.Equal(t, mapping, records[0]["mapping"])
+		require.Equal(t, method, records[0]["method"])
+		require.Equal(t, group, records[0]["backend_group"])
+		require.Equal(t, rule, records[0]["rule"])
+		require.Equal(t, shortCircuit, records[0]["short_circuit"])
+	}
+
+	send("eth_blockNumber", nil, 200)
+	requireRoute("", "default", "eth_blockNumber", "group1", "method_mapping", false)
+
+	send("eth_blockNumber", map[string]string{"X-Forwarded-Host": "domain1.example.com"}, 200)
+	requireRoute("domain1.example.com", "domain", "eth_blockNumber", "group2", "method_mapping", false)
+	require.Equal(t, 1, len(goodBackend2.Requests()))
+
+	send("eth_accounts", map[string]string{"X-Forwarded-Host": "domain1.example.com"}, 200)
+	requireRoute("domain1.example.com", "domain", "eth_accounts", "", "eth_accounts", true)
+
+	send("eth_notWhitelisted", nil, 403)
+	requireRoute("", "default", "eth_notWhitelisted", "", "not_whitelisted", true)
+}
@@ -0,0 +1,40 @@
+package integration_tests
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/ethereum-optimism/infra/proxyd"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSelfPing(t *testing.T) {
+	goodBackend := NewMockBackend(BatchedResponseHandler(200, goodResponse))
+	defer goodBackend.Close()
+
+	require.NoError(t, os.Setenv("GOOD_BACKEND_RPC_URL", goodBackend.URL()))
+
+	proxyd.RecordSelfPing(0)
+	_, shutdown, err := proxyd.Start(ReadConfig("self_ping"))
+	require.NoError(t, err)
+	defer shutdown()
+
+	require.Eventually(t, func() bool {
+		return selfPingLatency(t) > 0
+	}, 2*time.Second, 10*time.Millisecond)
+	// the pings never reach the backends
+	require.Empty(t, goodBackend.Requests())
+}
+
+func selfPingLatency(t *testing.T) float64 {
+	families, err := prometheus.DefaultGatherer.Gather()
+	require.NoError(t, err)
+	for _, family := range families {
+		if family.GetName() == "proxyd_self_ping_latency_milliseconds" {
+			return family.GetMetric()[0].GetGauge().GetValue()
+		}
+	}
+	return 0
+}
@@ -0,0 +1,40 @@
+package integration_tests
+
+import (
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/ethereum-optimism/infra/proxyd"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSLOTimeout(t *testing.T) {
+	slowBackend := NewMockBackend(SingleResponseHandlerWithSleep(200, goodResponse, 500*time.Millisecond))
+	defer slowBackend.Close()
+
+	require.NoError(t, os.Setenv("GOOD_BACKEND_RPC_URL", slowBackend.URL()))
+
+	config := ReadConfig("slo_timeout")
+	client := NewProxydClient("http://127.0.0.1:8545")
+	_, shutdown, err := proxyd.Start(config)
+	require.NoError(t, err)
+	defer shutdown()
+
+	t.Run("exceeded", func(t *testing.T) {
+		start := time.Now()
+		res, code, err := client.SendRPC("eth_chainId", nil)
+		require.NoError(t, err)
+		require.Less(t, time.Since(start), 500*time.Millisecond)
+		require.Equal(t, http.StatusGatewayTimeout, code)
+		RequireEqualJSON(t, []byte(`{"jsonrpc":"2.0","error":{"code":-32015,"message":"gateway timeout"},"id":999}`), res)
+	})
+
+	t.Run("group without slo", func(t *testing.T) {
+		res, code, err := client.SendRPC("debug_traceTransaction", nil)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, code)
+		RequireEqualJSON(t, []byte(goodResponse), res)
+	})
+}
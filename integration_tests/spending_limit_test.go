@@ -0,0 +1,104 @@
+package integration_tests
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/alicebob/miniredis"
+	"github.com/ethereum-optimism/infra/proxyd"
+	"github.com/stretchr/testify/require"
+)
+
+const overSpendingLimitResponse = `{"jsonrpc":"2.0","error":{"code":-32037,"message":"over spending limit"},"id":999}`
+
+func TestSpendingLimit(t *testing.T) {
+	redis, err := miniredis.Run()
+	require.NoError(t, err)
+	defer redis.Close()
+
+	hdlr := NewBatchRPCResponseRouter()
+	hdlr.SetFallbackRoute("eth_chainId", "0x1")
+	hdlr.SetFallbackRoute("eth_call", "0x")
+	hdlr.SetFallbackRoute("net_version", "1")
+	backend := NewMockBackend(hdlr)
+	defer backend.Close()
+
+	require.NoError(t, os.Setenv("GOOD_BACKEND_RPC_URL", backend.URL()))
+	require.NoError(t, os.Setenv("REDIS_URL", fmt.Sprintf("redis://127.0.0.1:%s", redis.Port())))
+
+	config := ReadConfig("spending_limit")
+	_, shutdown, err := proxyd.Start(config)
+	require.NoError(t, err)
+	defer shutdown()
+
+	metered := NewProxydClient("http://127.0.0.1:8545/metered-secret")
+	unmetered := NewProxydClient("http://127.0.0.1:8545/unmetered-secret")
+
+	send := func(client *ProxydHTTPClient, method string) (string, int) {
+		res, code, err := client.SendRPC(method, nil)
+		require.NoError(t, err)
+		return string(res), code
+	}
+
+	// eth_call costs 5 and the others 2, so the call taking the spending
+	// from 9 to 11 is the last one within the budget of 10
+	for _, method := range []string{"eth_call", "eth_chainId", "eth_chainId", "eth_chainId"} {
+		_, code := send(metered, method)
+		require.Equal(t, http.StatusOK, code, method)
+	}
+
+	t.Run("calls over budget are rejected", func(t *testing.T) {
+		res, code := send(metered, "eth_call")
+		require.Equal(t, http.StatusTooManyRequests, code)
+		RequireEqualJSON(t, []byte(overSpendingLimitResponse), []byte(res))
+		_, code = send(metered, "eth_chainId")
+		require.Equal(t, http.StatusTooManyRequests, code)
+	})
+
+	t.Run("free methods are still served", func(t *testing.T) {
+		_, code := send(metered, "net_version")
+		require.Equal(t, http.StatusOK, code)
+	})
+
+	t.Run("keys without a budget are not limited", func(t *testing.T) {
+		for i := 0; i < 10; i++ {
+			_, code := send(unmetered, "eth_call")
+			require.Equal(t, http.StatusOK, code)
+		}
+	})
+
+	t.Run("spending is kept in redis without the rejected calls", func(t *testing.T) {
+		var keys []string
+		for _, key := range redis.Keys() {
+			if strings.Contains(key, "spending:") {
+				keys = append(keys, key)
+			}
+		}
+		require.Len(t, keys, 1)
+		require.Contains(t, keys[0], "spending:metered:")
+		spent, err := redis.Get(keys[0])
+		require.NoError(t, err)
+		require.Equal(t, "11", spent)
+	})
+}
+
+func TestSpendingLimitConfig(t *testing.T) {
+	redis, err := miniredis.Run()
+	require.NoError(t, err)
+	defer redis.Close()
+	require.NoError(t, os.Setenv("GOOD_BACKEND_RPC_URL", "http://127.0.0.1:1"))
+	require.NoError(t, os.Setenv("REDIS_URL", fmt.Sprintf("redis://127.0.0.1:%s", redis.Port())))
+
+	config := ReadConfig("spending_limit")
+	config.SpendingLimit.Budgets["unknown"] = 10
+	_, _, err = proxyd.Start(config)
+	require.ErrorContains(t, err, "spending_limit budget of unknown is not for an authentication alias")
+
+	config = ReadConfig("spending_limit")
+	config.SpendingLimit.Window = 0
+	_, _, err = proxyd.Start(config)
+	require.ErrorContains(t, err, "spending_limit window must be > 0")
+}
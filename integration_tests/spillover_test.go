@@ -0,0 +1,38 @@
+package integration_tests
+
+import (
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/ethereum-optimism/infra/proxyd"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSpillover(t *testing.T) {
+	primary := NewMockBackend(BatchedResponseHandler(200, goodResponse))
+	defer primary.Close()
+	secondary := NewMockBackend(BatchedResponseHandler(200, goodResponse))
+	defer secondary.Close()
+
+	require.NoError(t, os.Setenv("PRIMARY_BACKEND_RPC_URL", primary.URL()))
+	require.NoError(t, os.Setenv("SECONDARY_BACKEND_RPC_URL", secondary.URL()))
+
+	config := ReadConfig("spillover")
+	client := NewProxydClient("http://127.0.0.1:8545")
+	_, shutdown, err := proxyd.Start(config)
+	require.NoError(t, err)
+	defer shutdown()
+
+	const total = 2000
+	for i := 0; i < total; i++ {
+		res, code, err := client.SendRPC("eth_chainId", nil)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, code)
+		RequireEqualJSON(t, []byte(goodResponse), res)
+	}
+
+	require.Equal(t, total, len(primary.Requests())+len(secondary.Requests()))
+	ratio := float64(len(secondary.Requests())) / total
+	require.InDelta(t, 0.3, ratio, 0.05)
+}
@@ -0,0 +1,126 @@
+package integration_tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum-optimism/infra/proxyd"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStreamBackendResponses(t *testing.T) {
+	const numLogs = 20000
+	logEntry := `{"address":"0x0000000000000000000000000000000000000001","data":"0x` + strings.Repeat("ab", 64) + `"}`
+	head := []byte(`{"jsonrpc":"2.0","id":999,"result":[`)
+	for i := 0; i < numLogs; i++ {
+		if i > 0 {
+			head = append(head, ',')
+		}
+		head = append(head, logEntry...)
+	}
+	tail := []byte(`]}`)
+
+	// the backend sends everything but the end of the response, and waits for
+	// the client to have received it before finishing
+	release := make(chan struct{})
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req, err := proxyd.ParseRPCReq(mustReadAll(t, r.Body))
+		require.NoError(t, err)
+		w.Header().Set("content-type", "application/json")
+		if req.Method != "eth_getLogs" {
+			_, _ = w.Write(head)
+			_, _ = w.Write(tail)
+			return
+		}
+		_, _ = w.Write(head)
+		w.(http.Flusher).Flush()
+		select {
+		case <-release:
+		case <-r.Context().Done():
+			return
+		}
+		_, _ = w.Write(tail)
+	}))
+	defer backend.Close()
+
+	require.NoError(t, os.Setenv("GOOD_BACKEND_RPC_URL", backend.URL))
+
+	_, shutdown, err := proxyd.Start(ReadConfig("stream_backend_responses"))
+	require.NoError(t, err)
+	defer shutdown()
+
+	post := func(method string) *http.Response {
+		body := fmt.Sprintf(`{"jsonrpc":"2.0","method":"%s","params":[],"id":999}`, method)
+		res, err := http.Post("http://127.0.0.1:8545", "application/json", strings.NewReader(body))
+		require.NoError(t, err)
+		return res
+	}
+
+	t.Run("streams uncacheable responses", func(t *testing.T) {
+		res := post("eth_getLogs")
+		defer res.Body.Close()
+		require.Equal(t, http.StatusOK, res.StatusCode)
+
+		received := make(chan error, 1)
+		buf := make([]byte, len(head))
+		go func() {
+			_, err := io.ReadFull(res.Body, buf)
+			received <- err
+		}()
+		select {
+		case err := <-received:
+			require.NoError(t, err)
+		case <-time.After(3 * time.Second):
+			close(release)
+			t.Fatal("response was buffered until the backend finished it")
+		}
+		require.Equal(t, head, buf)
+		close(release)
+
+		rest, err := io.ReadAll(res.Body)
+		require.NoError(t, err)
+		var rpcRes struct {
+			ID     int               `json:"id"`
+			Result []json.RawMessage `json:"result"`
+		}
+		require.NoError(t, json.Unmarshal(append(buf, rest...), &rpcRes))
+		require.Equal(t, 999, rpcRes.ID)
+		require.Len(t, rpcRes.Result, numLogs)
+	})
+
+	t.Run("buffers cacheable responses", func(t *testing.T) {
+		for _, cacheStatus := range []string{"MISS", "HIT"} {
+			res := post("eth_getBlockByHash")
+			body := mustReadAll(t, res.Body)
+			require.NoError(t, res.Body.Close())
+			require.Equal(t, http.StatusOK, res.StatusCode)
+			require.Equal(t, cacheStatus, res.Header.Get("X-Proxyd-Cache-Status"))
+			var rpcRes struct {
+				Result []json.RawMessage `json:"result"`
+			}
+			require.NoError(t, json.Unmarshal(body, &rpcRes))
+			require.Len(t, rpcRes.Result, numLogs)
+		}
+	})
+
+	t.Run("buffers small responses", func(t *testing.T) {
+		res := post("eth_chainId")
+		body := mustReadAll(t, res.Body)
+		require.NoError(t, res.Body.Close())
+		require.True(t, bytes.HasSuffix(body, []byte("}\n")), "written by proxyd")
+	})
+}
+
+func mustReadAll(t *testing.T, r io.Reader) []byte {
+	body, err := io.ReadAll(r)
+	require.NoError(t, err)
+	return body
+}
@@ -0,0 +1,64 @@
+package integration_tests
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/ethereum-optimism/infra/proxyd"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStrictContentType(t *testing.T) {
+	goodBackend := NewMockBackend(BatchedResponseHandler(200, goodResponse))
+	defer goodBackend.Close()
+	require.NoError(t, os.Setenv("GOOD_BACKEND_RPC_URL", goodBackend.URL()))
+
+	config := ReadConfig("strict_content_type")
+	_, shutdown, err := proxyd.Start(config)
+	require.NoError(t, err)
+	defer shutdown()
+
+	body := []byte(`{"jsonrpc": "2.0", "method": "eth_chainId", "params": [], "id": 999}`)
+	send := func(contentType string) ([]byte, int) {
+		req, err := http.NewRequest("POST", "http://127.0.0.1:8545", bytes.NewReader(body))
+		require.NoError(t, err)
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		res, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer res.Body.Close()
+		resBody, err := io.ReadAll(res.Body)
+		require.NoError(t, err)
+		return resBody, res.StatusCode
+	}
+
+	unsupportedResponse := `{"jsonrpc":"2.0","error":{"code":-32030,"message":"content type must be application/json"},"id":null}`
+
+	t.Run("wrong content type", func(t *testing.T) {
+		goodBackend.Reset()
+		res, code := send("text/plain")
+		require.Equal(t, http.StatusUnsupportedMediaType, code)
+		RequireEqualJSON(t, []byte(unsupportedResponse), res)
+		require.Empty(t, goodBackend.Requests())
+	})
+
+	t.Run("missing content type", func(t *testing.T) {
+		goodBackend.Reset()
+		res, code := send("")
+		require.Equal(t, http.StatusUnsupportedMediaType, code)
+		RequireEqualJSON(t, []byte(unsupportedResponse), res)
+		require.Empty(t, goodBackend.Requests())
+	})
+
+	t.Run("json with charset", func(t *testing.T) {
+		goodBackend.Reset()
+		res, code := send("application/json; charset=utf-8")
+		require.Equal(t, http.StatusOK, code)
+		RequireEqualJSON(t, []byte(goodResponse), res)
+		require.Len(t, goodBackend.Requests(), 1)
+	})
+}
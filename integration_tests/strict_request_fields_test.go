@@ -0,0 +1,54 @@
+package integration_tests
+
+import (
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/ethereum-optimism/infra/proxyd"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStrictRequestFields(t *testing.T) {
+	goodBackend := NewMockBackend(BatchedResponseHandler(200, goodResponse))
+	defer goodBackend.Close()
+	require.NoError(t, os.Setenv("GOOD_BACKEND_RPC_URL", goodBackend.URL()))
+
+	config := ReadConfig("strict_request_fields")
+	_, shutdown, err := proxyd.Start(config)
+	require.NoError(t, err)
+	defer shutdown()
+
+	body := []byte(`{"jsonrpc": "2.0", "method": "eth_chainId", "params": [], "id": 999, "junk": true}`)
+
+	t.Run("strict", func(t *testing.T) {
+		goodBackend.Reset()
+		client := NewProxydClient("http://127.0.0.1:8545")
+		res, code, err := client.SendRequest(body)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusBadRequest, code)
+		RequireEqualJSON(t, []byte(`{"error":{"code":-32600,"message":"unknown request fields: junk"},"id":null,"jsonrpc":"2.0"}`), res)
+		require.Empty(t, goodBackend.Requests())
+	})
+
+	t.Run("strict allows known fields", func(t *testing.T) {
+		goodBackend.Reset()
+		client := NewProxydClient("http://127.0.0.1:8545")
+		res, code, err := client.SendRPC("eth_chainId", nil)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, code)
+		RequireEqualJSON(t, []byte(goodResponse), res)
+	})
+
+	t.Run("lenient domain", func(t *testing.T) {
+		goodBackend.Reset()
+		client := NewProxydClientWithHeaders("http://127.0.0.1:8545", http.Header{
+			"X-Forwarded-Host": []string{"lenient.example.com"},
+		})
+		res, code, err := client.SendRequest(body)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, code)
+		RequireEqualJSON(t, []byte(goodResponse), res)
+		require.Len(t, goodBackend.Requests(), 1)
+	})
+}
@@ -0,0 +1,200 @@
+package integration_tests
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum-optimism/infra/proxyd"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTLSCertificateBySNI(t *testing.T) {
+	goodBackend := NewMockBackend(BatchedResponseHandler(200, goodResponse))
+	defer goodBackend.Close()
+
+	require.NoError(t, os.Setenv("GOOD_BACKEND_RPC_URL", goodBackend.URL()))
+
+	dir := t.TempDir()
+	certA, keyA := writeCertificate(t, dir, "a", "a.example.com", 1)
+	certB, keyB := writeCertificate(t, dir, "b", "*.b.example.com", 2)
+
+	config := ReadConfig("tls")
+	config.Server.TLS = proxyd.ServerTLSConfig{
+		Certificates: map[string]proxyd.TLSCertificateConfig{
+			"a.example.com":   {CertFile: certA, KeyFile: keyA},
+			"*.b.example.com": {CertFile: certB, KeyFile: keyB},
+		},
+		DefaultCertificate: "a.example.com",
+	}
+	srv, shutdown, err := proxyd.Start(config)
+	require.NoError(t, err)
+	defer shutdown()
+
+	// serial dials with serverName as SNI and returns the serial number of
+	// the certificate served.
+	serial := func(t *testing.T, serverName string) int64 {
+		conn, err := tls.Dial("tcp", "127.0.0.1:8545", &tls.Config{
+			ServerName:         serverName,
+			InsecureSkipVerify: true,
+		})
+		require.NoError(t, err)
+		defer conn.Close()
+		return conn.ConnectionState().PeerCertificates[0].SerialNumber.Int64()
+	}
+
+	t.Run("exact name", func(t *testing.T) {
+		require.EqualValues(t, 1, serial(t, "a.example.com"))
+		require.EqualValues(t, 1, serial(t, "A.Example.com"))
+	})
+
+	t.Run("wildcard name", func(t *testing.T) {
+		require.EqualValues(t, 2, serial(t, "tenant.b.example.com"))
+	})
+
+	t.Run("default certificate", func(t *testing.T) {
+		require.EqualValues(t, 1, serial(t, "other.example.com"))
+	})
+
+	t.Run("serves rpc", func(t *testing.T) {
+		pool := x509.NewCertPool()
+		certPEM, err := os.ReadFile(certB)
+		require.NoError(t, err)
+		require.True(t, pool.AppendCertsFromPEM(certPEM))
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{
+			RootCAs:    pool,
+			ServerName: "tenant.b.example.com",
+		}}}
+		res, err := client.Post("https://127.0.0.1:8545", "application/json",
+			strings.NewReader(`{"jsonrpc":"2.0","method":"eth_chainId","params":[],"id":999}`))
+		require.NoError(t, err)
+		defer res.Body.Close()
+		require.Equal(t, http.StatusOK, res.StatusCode)
+	})
+
+	t.Run("reload", func(t *testing.T) {
+		writeCertificate(t, dir, "b", "*.b.example.com", 3)
+		require.EqualValues(t, 2, serial(t, "tenant.b.example.com"))
+		require.NoError(t, srv.ReloadTLSCertificates())
+		require.EqualValues(t, 3, serial(t, "tenant.b.example.com"))
+	})
+
+	t.Run("reload keeps certificates on error", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(certB, []byte("not a certificate"), 0o600))
+		require.Error(t, srv.ReloadTLSCertificates())
+		require.EqualValues(t, 3, serial(t, "tenant.b.example.com"))
+	})
+}
+
+func TestTLSVersionAndCipherSuites(t *testing.T) {
+	goodBackend := NewMockBackend(BatchedResponseHandler(200, goodResponse))
+	defer goodBackend.Close()
+
+	require.NoError(t, os.Setenv("GOOD_BACKEND_RPC_URL", goodBackend.URL()))
+
+	dir := t.TempDir()
+	cert, key := writeCertificate(t, dir, "rpc", "rpc.example.com", 1)
+	start := func(minVersion string, cipherSuites []string) (func(), error) {
+		config := ReadConfig("tls")
+		config.Server.TLS = proxyd.ServerTLSConfig{
+			Certificates: map[string]proxyd.TLSCertificateConfig{
+				"rpc.example.com": {CertFile: cert, KeyFile: key},
+			},
+			MinTLSVersion: minVersion,
+			CipherSuites:  cipherSuites,
+		}
+		_, shutdown, err := proxyd.Start(config)
+		return shutdown, err
+	}
+	dial := func(minVersion, maxVersion uint16, cipherSuites ...uint16) error {
+		conn, err := tls.Dial("tcp", "127.0.0.1:8545", &tls.Config{
+			ServerName:         "rpc.example.com",
+			InsecureSkipVerify: true,
+			MinVersion:         minVersion,
+			MaxVersion:         maxVersion,
+			CipherSuites:       cipherSuites,
+		})
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	}
+
+	t.Run("defaults to tls 1.2", func(t *testing.T) {
+		shutdown, err := start("", nil)
+		require.NoError(t, err)
+		defer shutdown()
+
+		require.Error(t, dial(tls.VersionTLS10, tls.VersionTLS11))
+		require.NoError(t, dial(tls.VersionTLS12, tls.VersionTLS12))
+		require.NoError(t, dial(tls.VersionTLS13, tls.VersionTLS13))
+	})
+
+	t.Run("min version", func(t *testing.T) {
+		shutdown, err := start("1.3", nil)
+		require.NoError(t, err)
+		defer shutdown()
+
+		require.Error(t, dial(tls.VersionTLS12, tls.VersionTLS12))
+		require.NoError(t, dial(tls.VersionTLS13, tls.VersionTLS13))
+	})
+
+	t.Run("cipher suites", func(t *testing.T) {
+		shutdown, err := start("", []string{"TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384"})
+		require.NoError(t, err)
+		defer shutdown()
+
+		require.Error(t, dial(tls.VersionTLS12, tls.VersionTLS12, tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256))
+		require.NoError(t, dial(tls.VersionTLS12, tls.VersionTLS12, tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384))
+	})
+
+	t.Run("invalid config", func(t *testing.T) {
+		_, err := start("1.4", nil)
+		require.ErrorContains(t, err, "invalid min_tls_version")
+		_, err = start("", []string{"TLS_NOT_A_SUITE"})
+		require.ErrorContains(t, err, "unknown cipher suite")
+		_, err = start("", []string{"TLS_RSA_WITH_RC4_128_SHA"})
+		require.ErrorContains(t, err, "insecure cipher suite")
+		_, err = start("", []string{"TLS_AES_128_GCM_SHA256"})
+		require.ErrorContains(t, err, "can't be configured")
+	})
+}
+
+// writeCertificate writes a self-signed certificate for dnsName and its key
+// to dir, returning their paths.
+func writeCertificate(t *testing.T, dir string, name string, dnsName string, serial int64) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(serial),
+		Subject:               pkix.Name{CommonName: dnsName},
+		DNSNames:              []string{dnsName},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	certFile := filepath.Join(dir, name+".crt")
+	keyFile := filepath.Join(dir, name+".key")
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600))
+	return certFile, keyFile
+}
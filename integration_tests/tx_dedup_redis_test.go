@@ -0,0 +1,158 @@
+package integration_tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis"
+	"github.com/ethereum-optimism/infra/proxyd"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSendRawTxDedupRedis(t *testing.T) {
+	sent := `{"jsonrpc":"2.0","result":"0x4fa5c6a0f2a9d4b5d6f4e2a1c3b5d7e9f0a1b2c3d4e5f6a7b8c9d0e1f2a3b4c5","id":999}`
+	failed := `{"jsonrpc":"2.0","error":{"code":-32000,"message":"nonce too low"},"id":999}`
+	inProgress := `{"jsonrpc":"2.0","error":{"code":-32038,"message":"the same transaction is being sent by another request"},"id":999}`
+
+	redis, err := miniredis.Run()
+	require.NoError(t, err)
+	defer redis.Close()
+
+	backend := NewMockBackend(SingleResponseHandler(200, sent))
+	defer backend.Close()
+
+	require.NoError(t, os.Setenv("GOOD_BACKEND_RPC_URL", backend.URL()))
+	require.NoError(t, os.Setenv("REDIS_URL", fmt.Sprintf("redis://127.0.0.1:%s", redis.Port())))
+
+	// two instances sharing the same redis
+	_, shutdownA, err := proxyd.Start(ReadConfig("tx_dedup_redis"))
+	require.NoError(t, err)
+	defer shutdownA()
+	configB := ReadConfig("tx_dedup_redis")
+	configB.Server.RPCPort = 8547
+	_, shutdownB, err := proxyd.Start(configB)
+	require.NoError(t, err)
+	defer shutdownB()
+
+	clientA := NewProxydClient("http://127.0.0.1:8545")
+	clientB := NewProxydClient("http://127.0.0.1:8547")
+
+	send := func(client *ProxydHTTPClient, rawTx string, idempotencyKey string) ([]byte, int) {
+		var headers map[string]string
+		if idempotencyKey != "" {
+			headers = map[string]string{"Idempotency-Key": idempotencyKey}
+		}
+		req := NewRPCReq("999", "eth_sendRawTransaction", []interface{}{rawTx})
+		res, code, err := client.SendRequestWithHeaders(req, headers)
+		require.NoError(t, err)
+		return res, code
+	}
+
+	t.Run("retry on another instance", func(t *testing.T) {
+		backend.Reset()
+		res, code := send(clientA, "0x01", "")
+		require.Equal(t, http.StatusOK, code)
+		RequireEqualJSON(t, []byte(sent), res)
+
+		res, code = send(clientB, "0x01", "")
+		require.Equal(t, http.StatusOK, code)
+		RequireEqualJSON(t, []byte(sent), res)
+		require.Equal(t, 1, len(backend.Requests()))
+
+		// a different transaction is broadcast
+		send(clientB, "0x02", "")
+		require.Equal(t, 2, len(backend.Requests()))
+	})
+
+	t.Run("retry with the same idempotency key", func(t *testing.T) {
+		backend.Reset()
+		send(clientA, "0x03", "order-1")
+		// a re-signed transaction under the same key isn't broadcast
+		res, code := send(clientB, "0x04", "order-1")
+		require.Equal(t, http.StatusOK, code)
+		RequireEqualJSON(t, []byte(sent), res)
+		require.Equal(t, 1, len(backend.Requests()))
+
+		send(clientB, "0x04", "order-2")
+		require.Equal(t, 2, len(backend.Requests()))
+	})
+
+	t.Run("failed send is retried", func(t *testing.T) {
+		backend.Reset()
+		backend.SetHandler(SingleResponseHandler(200, failed))
+		res, _ := send(clientA, "0x05", "")
+		RequireEqualJSON(t, []byte(failed), res)
+		backend.SetHandler(SingleResponseHandler(200, sent))
+
+		res, code := send(clientB, "0x05", "")
+		require.Equal(t, http.StatusOK, code)
+		RequireEqualJSON(t, []byte(sent), res)
+		require.Equal(t, 2, len(backend.Requests()))
+	})
+
+	t.Run("retry while the send is in progress", func(t *testing.T) {
+		backend.Reset()
+		started := make(chan struct{})
+		release := make(chan struct{})
+		backend.SetHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			close(started)
+			<-release
+			SingleResponseHandler(200, sent)(w, r)
+		}))
+		defer backend.SetHandler(SingleResponseHandler(200, sent))
+
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			res, _ := send(clientA, "0x06", "")
+			RequireEqualJSON(t, []byte(sent), res)
+		}()
+		<-started
+
+		res, code := send(clientB, "0x06", "")
+		require.Equal(t, http.StatusConflict, code)
+		RequireEqualJSON(t, []byte(inProgress), res)
+
+		close(release)
+		wg.Wait()
+		res, code = send(clientB, "0x06", "")
+		require.Equal(t, http.StatusOK, code)
+		RequireEqualJSON(t, []byte(sent), res)
+		require.Equal(t, 1, len(backend.Requests()))
+	})
+
+	t.Run("retry after the client timed out", func(t *testing.T) {
+		backend.Reset()
+		backend.SetHandler(SingleResponseHandlerWithSleep(200, sent, 300*time.Millisecond))
+		defer backend.SetHandler(SingleResponseHandler(200, sent))
+
+		body, err := json.Marshal(NewRPCReq("999", "eth_sendRawTransaction", []interface{}{"0x07"}))
+		require.NoError(t, err)
+		impatient := &http.Client{Timeout: 100 * time.Millisecond}
+		_, err = impatient.Post("http://127.0.0.1:8545", "application/json", bytes.NewReader(body))
+		require.Error(t, err)
+
+		// once the first send is over, its retry is answered rather than
+		// rejected for the rest of the window
+		backend.SetHandler(SingleResponseHandler(200, sent))
+		require.Eventually(t, func() bool {
+			res, code := send(clientB, "0x07", "")
+			return code == http.StatusOK && string(canonicalizeJSON(t, res)) == string(canonicalizeJSON(t, []byte(sent)))
+		}, 2*time.Second, 50*time.Millisecond)
+	})
+}
+
+func TestSendRawTxDedupRedisRequiresRedis(t *testing.T) {
+	require.NoError(t, os.Setenv("GOOD_BACKEND_RPC_URL", "http://127.0.0.1:0"))
+	config := ReadConfig("tx_dedup_redis")
+	config.Redis.URL = ""
+	_, _, err := proxyd.Start(config)
+	require.Error(t, err)
+}
@@ -0,0 +1,68 @@
+package integration_tests
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/ethereum-optimism/infra/proxyd"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSendRawTxDedup(t *testing.T) {
+	sent := `{"jsonrpc":"2.0","result":"0x4fa5c6a0f2a9d4b5d6f4e2a1c3b5d7e9f0a1b2c3d4e5f6a7b8c9d0e1f2a3b4c5","id":999}`
+	alreadyKnown := `{"jsonrpc":"2.0","error":{"code":-32000,"message":"already known"},"id":999}`
+
+	backend := NewMockBackend(SingleResponseHandler(200, sent))
+	defer backend.Close()
+
+	require.NoError(t, os.Setenv("GOOD_BACKEND_RPC_URL", backend.URL()))
+
+	config := ReadConfig("tx_dedup")
+	client := NewProxydClient("http://127.0.0.1:8545")
+	_, shutdown, err := proxyd.Start(config)
+	require.NoError(t, err)
+	defer shutdown()
+
+	send := func(rawTx string) []byte {
+		res, code, err := client.SendRPC("eth_sendRawTransaction", []interface{}{rawTx})
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, code)
+		return res
+	}
+
+	t.Run("repeated send within the window", func(t *testing.T) {
+		backend.Reset()
+		RequireEqualJSON(t, []byte(sent), send("0x01"))
+		RequireEqualJSON(t, []byte(sent), send("0x01"))
+		require.Equal(t, 1, len(backend.Requests()))
+
+		// a different transaction is broadcast
+		send("0x02")
+		require.Equal(t, 2, len(backend.Requests()))
+	})
+
+	t.Run("repeated send after the window", func(t *testing.T) {
+		backend.Reset()
+		send("0x03")
+		time.Sleep(1100 * time.Millisecond)
+		send("0x03")
+		require.Equal(t, 2, len(backend.Requests()))
+	})
+
+	t.Run("already known", func(t *testing.T) {
+		backend.Reset()
+		backend.SetHandler(SingleResponseHandler(200, alreadyKnown))
+		defer backend.SetHandler(SingleResponseHandler(200, sent))
+
+		txHash := crypto.Keccak256Hash(hexutil.MustDecode("0x04")).Hex()
+		expected := fmt.Sprintf(`{"jsonrpc":"2.0","result":"%s","id":999}`, txHash)
+		RequireEqualJSON(t, []byte(expected), send("0x04"))
+		RequireEqualJSON(t, []byte(expected), send("0x04"))
+		require.Equal(t, 1, len(backend.Requests()))
+	})
+}
@@ -0,0 +1,54 @@
+package integration_tests
+
+import (
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/ethereum-optimism/infra/proxyd"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBackendUserAgent(t *testing.T) {
+	overridden := NewMockBackend(BatchedResponseHandler(200, goodResponse))
+	defer overridden.Close()
+	plain := NewMockBackend(BatchedResponseHandler(200, goodResponse))
+	defer plain.Close()
+
+	require.NoError(t, os.Setenv("OVERRIDDEN_BACKEND_RPC_URL", overridden.URL()))
+	require.NoError(t, os.Setenv("PLAIN_BACKEND_RPC_URL", plain.URL()))
+
+	config := ReadConfig("user_agent")
+	client := NewProxydClient("http://127.0.0.1:8545")
+	_, shutdown, err := proxyd.Start(config)
+	require.NoError(t, err)
+
+	clientHeaders := map[string]string{"User-Agent": "some-client/2.0"}
+	_, code, err := client.SendRequestWithHeaders(NewRPCReq("1", "eth_chainId", nil), clientHeaders)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, code)
+	require.Len(t, overridden.Requests(), 1)
+	require.Equal(t, "proxyd-test-overridden/1.0", overridden.Requests()[0].Headers.Get("User-Agent"))
+
+	_, code, err = client.SendRequestWithHeaders(NewRPCReq("1", "net_version", nil), clientHeaders)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, code)
+	require.Len(t, plain.Requests(), 1)
+	require.Equal(t, "proxyd-test/1.0", plain.Requests()[0].Headers.Get("User-Agent"))
+	shutdown()
+
+	// without a configured user agent, the proxyd version is sent
+	proxyd.Version = "v1.2.3"
+	defer func() { proxyd.Version = "" }()
+	config.BackendOptions.UserAgent = ""
+	plain.Reset()
+	_, shutdown, err = proxyd.Start(config)
+	require.NoError(t, err)
+	defer shutdown()
+
+	_, code, err = client.SendRPC("net_version", nil)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, code)
+	require.Len(t, plain.Requests(), 1)
+	require.Equal(t, "proxyd/v1.2.3", plain.Requests()[0].Headers.Get("User-Agent"))
+}
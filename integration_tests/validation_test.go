@@ -129,6 +129,13 @@ func TestBatchRPCValidation(t *testing.T) {
 			400,
 			0,
 		},
+		{
+			"empty batch with whitespace",
+			"[ \n\t]",
+			invalidBatchLenResponse,
+			400,
+			0,
+		},
 		{
 			"bad json",
 			"[{,]",
@@ -216,6 +223,25 @@ func TestBatchRPCValidation(t *testing.T) {
 			200,
 			1,
 		},
+		{
+			"mixed with structurally invalid entries",
+			asArray(
+				"{\"jsonrpc\": \"2.0\", \"method\": \"eth_chainId\", \"params\": [], \"id\": 123}",
+				"\"eth_chainId\"",
+				"[]",
+				"{\"jsonrpc\": \"2.0\", \"params\": [], \"id\": 1}",
+				"{\"jsonrpc\": \"2.0\", \"method\": \"eth_chainId\", \"params\": [], \"id\": []}",
+			),
+			asArray(
+				goodResponse,
+				parseErrResponse,
+				parseErrResponse,
+				invalidMethodResponse,
+				invalidIDResponse,
+			),
+			200,
+			2,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -0,0 +1,136 @@
+package integration_tests
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/ethereum-optimism/infra/proxyd"
+	ms "github.com/ethereum-optimism/infra/proxyd/tools/mockserver/handler"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerboseHealthz(t *testing.T) {
+	dir, err := os.Getwd()
+	require.NoError(t, err)
+	responses := path.Join(dir, "testdata/consensus_responses.yml")
+
+	for _, name := range []string{"NODE1_URL", "NODE2_URL"} {
+		h := ms.MockedHandler{
+			Overrides:    []*ms.MethodTemplate{},
+			Autoload:     true,
+			AutoloadFile: responses,
+		}
+		node := NewMockBackend(http.HandlerFunc(h.Handler))
+		defer node.Close()
+		// the path holds an API key, which must not be exposed
+		require.NoError(t, os.Setenv(name, node.URL()+"/secret-api-key"))
+	}
+	plain := NewMockBackend(BatchedResponseHandler(200, goodResponse))
+	defer plain.Close()
+	require.NoError(t, os.Setenv("PLAIN_URL", plain.URL()))
+
+	config := ReadConfig("verbose_healthz")
+	svr, shutdown, err := proxyd.Start(config)
+	require.NoError(t, err)
+	defer shutdown()
+
+	// node1 is polled, node2 is banned before its first poll
+	bg := svr.BackendGroups["node"]
+	ctx := context.Background()
+	bg.Consensus.UpdateBackend(ctx, bg.Backends[0])
+	bg.Consensus.Ban(bg.Backends[1])
+	bg.Consensus.UpdateBackendGroupConsensus(ctx)
+
+	get := func(url string) (int, []byte) {
+		res, err := http.Get(url)
+		require.NoError(t, err)
+		defer res.Body.Close()
+		body, err := io.ReadAll(res.Body)
+		require.NoError(t, err)
+		return res.StatusCode, body
+	}
+
+	t.Run("plain healthz", func(t *testing.T) {
+		code, body := get("http://127.0.0.1:8545/healthz")
+		require.Equal(t, http.StatusOK, code)
+		require.Equal(t, "OK", string(body))
+	})
+
+	t.Run("verbose healthz", func(t *testing.T) {
+		code, body := get("http://127.0.0.1:8545/healthz?verbose=true")
+		require.Equal(t, http.StatusOK, code)
+		require.NotContains(t, string(body), "secret-api-key")
+
+		var res struct {
+			BackendGroups map[string]struct {
+				ConsensusBlock string                   `json:"consensus_block"`
+				Backends       []map[string]interface{} `json:"backends"`
+			} `json:"backend_groups"`
+		}
+		require.NoError(t, json.Unmarshal(body, &res))
+
+		node := res.BackendGroups["node"]
+		require.Equal(t, "0x101", node.ConsensusBlock)
+		require.Len(t, node.Backends, 2)
+
+		polled := node.Backends[0]
+		require.Equal(t, "node1", polled["name"])
+		require.Contains(t, polled["url"], "/[redacted]")
+		require.Equal(t, true, polled["healthy"])
+		require.Equal(t, false, polled["degraded"])
+		require.Equal(t, float64(0), polled["in_flight"])
+		require.Equal(t, false, polled["backing_off"])
+		require.Contains(t, polled, "error_rate")
+		require.Contains(t, polled, "avg_latency")
+		consensus := polled["consensus"].(map[string]interface{})
+		require.Equal(t, true, consensus["in_consensus_group"])
+		require.Equal(t, true, consensus["in_sync"])
+		require.Equal(t, "0x101", consensus["latest_block"])
+		require.Equal(t, float64(0), consensus["lag"])
+		require.NotEmpty(t, consensus["last_poll"])
+		require.Equal(t, false, consensus["banned"])
+		require.NotContains(t, consensus, "banned_until")
+
+		banned := node.Backends[1]
+		require.Equal(t, "node2", banned["name"])
+		consensus = banned["consensus"].(map[string]interface{})
+		require.Equal(t, false, consensus["in_consensus_group"])
+		require.Equal(t, "0x0", consensus["latest_block"])
+		require.Equal(t, float64(0x101), consensus["lag"])
+		require.NotContains(t, consensus, "last_poll")
+		require.Equal(t, true, consensus["banned"])
+		require.NotEmpty(t, consensus["banned_until"])
+
+		main := res.BackendGroups["main"]
+		require.Empty(t, main.ConsensusBlock)
+		require.Len(t, main.Backends, 1)
+		require.Equal(t, "plain", main.Backends[0]["name"])
+		require.NotContains(t, main.Backends[0], "consensus")
+	})
+}
+
+func TestVerboseHealthzDisabled(t *testing.T) {
+	plain := NewMockBackend(BatchedResponseHandler(200, goodResponse))
+	defer plain.Close()
+	for _, name := range []string{"NODE1_URL", "NODE2_URL", "PLAIN_URL"} {
+		require.NoError(t, os.Setenv(name, plain.URL()))
+	}
+
+	config := ReadConfig("verbose_healthz")
+	config.Server.EnableVerboseHealthz = false
+	_, shutdown, err := proxyd.Start(config)
+	require.NoError(t, err)
+	defer shutdown()
+
+	res, err := http.Get("http://127.0.0.1:8545/healthz?verbose=true")
+	require.NoError(t, err)
+	defer res.Body.Close()
+	body, err := io.ReadAll(res.Body)
+	require.NoError(t, err)
+	require.Equal(t, "OK", string(body))
+}
@@ -0,0 +1,40 @@
+package integration_tests
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/ethereum-optimism/infra/proxyd"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWarmup(t *testing.T) {
+	goodBackend := NewMockBackend(BatchedResponseHandler(200, goodResponse))
+	defer goodBackend.Close()
+	// The bad backend never answers in time, which must neither fail nor
+	// hold up startup beyond the warmup timeout.
+	badBackend := NewMockBackend(SingleResponseHandlerWithSleep(200, goodResponse, time.Second))
+	defer badBackend.Close()
+
+	require.NoError(t, os.Setenv("GOOD_BACKEND_RPC_URL", goodBackend.URL()))
+	require.NoError(t, os.Setenv("BAD_BACKEND_RPC_URL", badBackend.URL()))
+
+	config := ReadConfig("warmup")
+	start := time.Now()
+	_, shutdown, err := proxyd.Start(config)
+	require.NoError(t, err)
+	defer shutdown()
+	require.Less(t, time.Since(start), time.Second)
+
+	var methods []string
+	for _, req := range goodBackend.Requests() {
+		var rpcReq proxyd.RPCReq
+		require.NoError(t, json.Unmarshal(req.Body, &rpcReq))
+		require.JSONEq(t, `[]`, string(rpcReq.Params))
+		methods = append(methods, rpcReq.Method)
+	}
+	require.Equal(t, []string{"eth_chainId", "eth_blockNumber"}, methods)
+	require.NotEmpty(t, badBackend.Requests())
+}
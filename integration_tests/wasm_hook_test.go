@@ -0,0 +1,179 @@
+package integration_tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum-optimism/infra/proxyd"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWASMHook(t *testing.T) {
+	goodBackend := NewMockBackend(BatchedResponseHandler(200, goodResponse))
+	defer goodBackend.Close()
+
+	require.NoError(t, os.Setenv("GOOD_BACKEND_RPC_URL", goodBackend.URL()))
+
+	client := NewProxydClient("http://127.0.0.1:8545")
+	start := func(t *testing.T, module []byte, failOpen bool) func() {
+		path := filepath.Join(t.TempDir(), "hook.wasm")
+		require.NoError(t, os.WriteFile(path, module, 0o644))
+		config := ReadConfig("wasm_hook")
+		config.WASMHook.ModulePath = path
+		config.WASMHook.FailOpen = failOpen
+		_, shutdown, err := proxyd.Start(config)
+		require.NoError(t, err)
+		return shutdown
+	}
+
+	t.Run("pass", func(t *testing.T) {
+		defer start(t, constantActionModule(`{"action":"pass"}`), false)()
+		goodBackend.Reset()
+
+		res, code, err := client.SendRPC("eth_chainId", nil)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, code)
+		RequireEqualJSON(t, []byte(goodResponse), res)
+		require.Len(t, goodBackend.Requests(), 1)
+	})
+
+	t.Run("reject", func(t *testing.T) {
+		defer start(t, constantActionModule(`{"action":"reject","code":-32001,"message":"blocked by policy"}`), false)()
+		goodBackend.Reset()
+
+		res, code, err := client.SendRPC("eth_chainId", nil)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusForbidden, code)
+		RequireEqualJSON(t, []byte(`{"jsonrpc":"2.0","error":{"code":-32001,"message":"blocked by policy"},"id":999}`), res)
+		require.Empty(t, goodBackend.Requests())
+	})
+
+	t.Run("rewrite", func(t *testing.T) {
+		defer start(t, constantActionModule(`{"action":"rewrite","method":"eth_blockNumber","params":[]}`), false)()
+		goodBackend.Reset()
+
+		res, code, err := client.SendRPC("eth_chainId", nil)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, code)
+		RequireEqualJSON(t, []byte(goodResponse), res)
+		require.Len(t, goodBackend.Requests(), 1)
+
+		var req proxyd.RPCReq
+		require.NoError(t, json.Unmarshal(goodBackend.Requests()[0].Body, &req))
+		require.Equal(t, "eth_blockNumber", req.Method)
+		require.JSONEq(t, `[]`, string(req.Params))
+	})
+
+	t.Run("time budget exceeded", func(t *testing.T) {
+		defer start(t, loopingModule(), false)()
+		goodBackend.Reset()
+
+		res, code, err := client.SendRPC("eth_chainId", nil)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusInternalServerError, code)
+		require.Contains(t, string(res), proxyd.ErrWASMHookFailed.Message)
+		require.Empty(t, goodBackend.Requests())
+	})
+
+	t.Run("time budget exceeded with fail open", func(t *testing.T) {
+		defer start(t, loopingModule(), true)()
+		goodBackend.Reset()
+
+		res, code, err := client.SendRPC("eth_chainId", nil)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, code)
+		RequireEqualJSON(t, []byte(goodResponse), res)
+		require.Len(t, goodBackend.Requests(), 1)
+	})
+
+	t.Run("invalid module", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "hook.wasm")
+		require.NoError(t, os.WriteFile(path, []byte("not wasm"), 0o644))
+		config := ReadConfig("wasm_hook")
+		config.WASMHook.ModulePath = path
+		_, _, err := proxyd.Start(config)
+		require.Error(t, err)
+	})
+}
+
+// constantActionModule builds a module whose handle returns action, stored in
+// a data segment at offset 16, whatever the request.
+func constantActionModule(action string) []byte {
+	packed := int64(16)<<32 | int64(len(action))
+	handle := append([]byte{0x00, 0x42}, sleb128(packed)...) // no locals, i64.const
+	handle = append(handle, 0x0b)
+	data := append([]byte{0x01, 0x00, 0x41, 0x10, 0x0b}, uleb128(uint64(len(action)))...)
+	data = append(data, action...)
+	return wasmModule(handle, data)
+}
+
+// loopingModule builds a module whose handle never returns.
+func loopingModule() []byte {
+	// no locals, loop, br 0, end, i64.const 0, end
+	handle := []byte{0x00, 0x03, 0x40, 0x0c, 0x00, 0x0b, 0x42, 0x00, 0x0b}
+	return wasmModule(handle, nil)
+}
+
+// wasmModule assembles a module exporting memory, an alloc that always
+// returns offset 1024, and a handle with the given body.
+func wasmModule(handleBody []byte, dataSection []byte) []byte {
+	section := func(id byte, content []byte) []byte {
+		return append(append([]byte{id}, uleb128(uint64(len(content)))...), content...)
+	}
+	name := func(s string) []byte {
+		return append(uleb128(uint64(len(s))), s...)
+	}
+
+	allocBody := []byte{0x00, 0x41, 0x80, 0x08, 0x0b} // no locals, i32.const 1024, end
+	var code []byte
+	code = append(code, 0x02)
+	code = append(code, uleb128(uint64(len(allocBody)))...)
+	code = append(code, allocBody...)
+	code = append(code, uleb128(uint64(len(handleBody)))...)
+	code = append(code, handleBody...)
+
+	var exports []byte
+	exports = append(exports, 0x03)
+	exports = append(append(exports, name("memory")...), 0x02, 0x00)
+	exports = append(append(exports, name("alloc")...), 0x00, 0x00)
+	exports = append(append(exports, name("handle")...), 0x00, 0x01)
+
+	mod := []byte{0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00}
+	// (i32) -> i32 and (i32, i32) -> i64
+	mod = append(mod, section(0x01, []byte{0x02, 0x60, 0x01, 0x7f, 0x01, 0x7f, 0x60, 0x02, 0x7f, 0x7f, 0x01, 0x7e})...)
+	mod = append(mod, section(0x03, []byte{0x02, 0x00, 0x01})...)
+	mod = append(mod, section(0x05, []byte{0x01, 0x00, 0x01})...)
+	mod = append(mod, section(0x07, exports)...)
+	mod = append(mod, section(0x0a, code)...)
+	if dataSection != nil {
+		mod = append(mod, section(0x0b, dataSection)...)
+	}
+	return mod
+}
+
+func uleb128(v uint64) []byte {
+	var out []byte
+	for {
+		b := byte(v & 0x7f)
+		v >>= 7
+		if v == 0 {
+			return append(out, b)
+		}
+		out = append(out, b|0x80)
+	}
+}
+
+func sleb128(v int64) []byte {
+	var out []byte
+	for {
+		b := byte(v & 0x7f)
+		v >>= 7
+		if (v == 0 && b&0x40 == 0) || (v == -1 && b&0x40 != 0) {
+			return append(out, b)
+		}
+		out = append(out, b|0x80)
+	}
+}
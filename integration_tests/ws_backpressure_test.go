@@ -0,0 +1,154 @@
+package integration_tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ethereum-optimism/infra/proxyd"
+	"github.com/gorilla/websocket"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWSBackpressure(t *testing.T) {
+	// far more than the socket buffers between proxyd and a client hold
+	const notifications = 300
+	padding := strings.Repeat("a", 128*1024)
+	subscribeReq := []byte(`{"jsonrpc":"2.0","id":1,"method":"eth_subscribe","params":["newHeads"]}`)
+
+	sent := make(chan struct{}, 2)
+	backend := NewMockWSBackend(nil, func(conn *websocket.Conn, msgType int, data []byte) {
+		if err := conn.WriteMessage(websocket.TextMessage, []byte(`{"jsonrpc":"2.0","id":1,"result":"0x1"}`)); err != nil {
+			return
+		}
+		for i := 0; i < notifications; i++ {
+			msg := fmt.Sprintf(`{"jsonrpc":"2.0","method":"eth_subscription","params":{"subscription":"0x1","result":{"n":%d,"padding":"%s"}}}`, i, padding)
+			if err := conn.WriteMessage(websocket.TextMessage, []byte(msg)); err != nil {
+				return
+			}
+		}
+		sent <- struct{}{}
+	}, nil)
+	defer backend.Close()
+
+	require.NoError(t, os.Setenv("GOOD_BACKEND_RPC_URL", backend.URL()))
+
+	notificationNumber := func(t *testing.T, data []byte) int {
+		var msg struct {
+			Params struct {
+				Result struct {
+					N int `json:"n"`
+				} `json:"result"`
+			} `json:"params"`
+		}
+		require.NoError(t, json.Unmarshal(data, &msg))
+		return msg.Params.Result.N
+	}
+
+	t.Run("drop oldest", func(t *testing.T) {
+		_, shutdown, err := proxyd.Start(ReadConfig("ws_backpressure"))
+		require.NoError(t, err)
+		defer shutdown()
+		droppedBefore := wsBackpressureCount(t, "proxyd_ws_dropped_notifications_total")
+
+		var fastLast atomic.Int64
+		fast, err := NewProxydWSClient("ws://127.0.0.1:8546", func(msgType int, data []byte) {
+			fastLast.Store(int64(notificationNumber(t, data)))
+		}, nil)
+		require.NoError(t, err)
+		defer fast.HardClose()
+
+		// the slow client doesn't read anything until the backends are done
+		slow, _, err := websocket.DefaultDialer.Dial("ws://127.0.0.1:8546", nil) // nolint:bodyclose
+		require.NoError(t, err)
+		defer slow.Close()
+
+		require.NoError(t, slow.WriteMessage(websocket.TextMessage, subscribeReq))
+		require.NoError(t, fast.WriteMessage(websocket.TextMessage, subscribeReq))
+		for i := 0; i < 2; i++ {
+			select {
+			case <-sent:
+			case <-time.After(5 * time.Second):
+				t.Fatal("backend blocked on the slow client")
+			}
+		}
+
+		// the fast client keeps up with the backend
+		require.Eventually(t, func() bool {
+			return fastLast.Load() == notifications-1
+		}, 5*time.Second, 10*time.Millisecond)
+
+		// the slow client gets the response to its call and the latest
+		// notifications, with older ones dropped
+		require.NoError(t, slow.SetReadDeadline(time.Now().Add(5*time.Second)))
+		_, msg, err := slow.ReadMessage()
+		require.NoError(t, err)
+		RequireEqualJSON(t, []byte(`{"jsonrpc":"2.0","id":1,"result":"0x1"}`), msg)
+		received := 0
+		for last := -1; last != notifications-1; {
+			_, msg, err := slow.ReadMessage()
+			require.NoError(t, err)
+			n := notificationNumber(t, msg)
+			require.Greater(t, n, last)
+			last = n
+			received++
+		}
+		require.Less(t, received, notifications)
+		require.Greater(t, wsBackpressureCount(t, "proxyd_ws_dropped_notifications_total")-droppedBefore, float64(0))
+	})
+
+	t.Run("disconnect", func(t *testing.T) {
+		config := ReadConfig("ws_backpressure")
+		config.Server.WSBackpressurePolicy = proxyd.WSBackpressureDisconnect
+		_, shutdown, err := proxyd.Start(config)
+		require.NoError(t, err)
+		defer shutdown()
+		disconnectsBefore := wsBackpressureCount(t, "proxyd_ws_backpressure_disconnects_total")
+
+		slow, _, err := websocket.DefaultDialer.Dial("ws://127.0.0.1:8546", nil) // nolint:bodyclose
+		require.NoError(t, err)
+		defer slow.Close()
+		require.NoError(t, slow.WriteMessage(websocket.TextMessage, subscribeReq))
+
+		require.Eventually(t, func() bool {
+			return wsBackpressureCount(t, "proxyd_ws_backpressure_disconnects_total") == disconnectsBefore+1
+		}, 5*time.Second, 10*time.Millisecond)
+
+		// the connection is closed once the client catches up with what was
+		// already sent
+		require.NoError(t, slow.SetReadDeadline(time.Now().Add(5*time.Second)))
+		for {
+			if _, _, err := slow.ReadMessage(); err != nil {
+				require.False(t, os.IsTimeout(err), "connection not closed")
+				break
+			}
+		}
+	})
+
+	t.Run("invalid policy", func(t *testing.T) {
+		config := ReadConfig("ws_backpressure")
+		config.Server.WSBackpressurePolicy = "drop_newest"
+		_, _, err := proxyd.Start(config)
+		require.Error(t, err)
+	})
+}
+
+func wsBackpressureCount(t *testing.T, name string) float64 {
+	families, err := prometheus.DefaultGatherer.Gather()
+	require.NoError(t, err)
+	var count float64
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			count += metric.GetCounter().GetValue()
+		}
+	}
+	return count
+}
@@ -1,6 +1,7 @@
 package integration_tests
 
 import (
+	"net/http"
 	"os"
 	"strings"
 	"sync/atomic"
@@ -116,6 +117,12 @@ func TestWS(t *testing.T) {
 			"{\"jsonrpc\":\"2.0\",\"error\":{\"code\":-32700,\"message\":\"parse error\"},\"id\":null}",
 			"{\"jsonrpc\": \"2.0\", \"method\": true}",
 		},
+		{
+			"empty batch",
+			"{}",
+			"{\"jsonrpc\":\"2.0\",\"error\":{\"code\":-32600,\"message\":\"must specify at least one batch call\"},\"id\":null}",
+			"[]",
+		},
 		{
 			"eth_accounts",
 			"{}",
@@ -239,3 +246,89 @@ func TestWSClientExceedReadLimit(t *testing.T) {
 	require.True(t, closed)
 
 }
+
+func TestWSMaxConnections(t *testing.T) {
+	backend := NewMockWSBackend(nil, nil, nil)
+	defer backend.Close()
+
+	require.NoError(t, os.Setenv("GOOD_BACKEND_RPC_URL", backend.URL()))
+
+	config := ReadConfig("ws_max_connections")
+	_, shutdown, err := proxyd.Start(config)
+	require.NoError(t, err)
+	defer shutdown()
+
+	var clients []*ProxydWSClient
+	for i := 0; i < 2; i++ {
+		client, err := NewProxydWSClient("ws://127.0.0.1:8546", nil, nil)
+		require.NoError(t, err)
+		clients = append(clients, client)
+	}
+
+	_, res, err := websocket.DefaultDialer.Dial("ws://127.0.0.1:8546", nil) // nolint:bodyclose
+	require.ErrorIs(t, err, websocket.ErrBadHandshake)
+	require.Equal(t, http.StatusServiceUnavailable, res.StatusCode)
+
+	clients[0].HardClose()
+	require.Eventually(t, func() bool {
+		client, err := NewProxydWSClient("ws://127.0.0.1:8546", nil, nil)
+		if err != nil {
+			return false
+		}
+		clients[0] = client
+		return true
+	}, 5*time.Second, 50*time.Millisecond)
+
+	for _, client := range clients {
+		client.HardClose()
+	}
+}
+
+func TestWSBackendGroup(t *testing.T) {
+	httpBackend := NewMockBackend(BatchedResponseHandler(200, goodResponse))
+	defer httpBackend.Close()
+
+	subscribeRes := `{"jsonrpc":"2.0","id":1,"result":"0x1"}`
+	notification := `{"jsonrpc":"2.0","method":"eth_subscription","params":{"subscription":"0x1","result":{"number":"0x2"}}}`
+	wsBackend := NewMockWSBackend(nil, func(conn *websocket.Conn, msgType int, data []byte) {
+		require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(subscribeRes)))
+		require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(notification)))
+	}, nil)
+	defer wsBackend.Close()
+
+	require.NoError(t, os.Setenv("HTTP_BACKEND_RPC_URL", httpBackend.URL()))
+	require.NoError(t, os.Setenv("WS_BACKEND_RPC_URL", wsBackend.URL()))
+
+	config := ReadConfig("ws_backend_group")
+	_, shutdown, err := proxyd.Start(config)
+	require.NoError(t, err)
+	defer shutdown()
+
+	msgs := make(chan string, 2)
+	client, err := NewProxydWSClient("ws://127.0.0.1:8546", func(msgType int, data []byte) {
+		msgs <- string(data)
+	}, nil)
+	require.NoError(t, err)
+	defer client.HardClose()
+
+	require.NoError(t, client.WriteMessage(
+		websocket.TextMessage,
+		[]byte(`{"jsonrpc":"2.0","id":1,"method":"eth_subscribe","params":["newHeads"]}`),
+	))
+	for _, expected := range []string{subscribeRes, notification} {
+		select {
+		case msg := <-msgs:
+			require.Equal(t, expected, msg)
+		case <-time.After(10 * time.Second):
+			t.Fatalf("timed out")
+		}
+	}
+	require.Empty(t, httpBackend.Requests())
+
+	httpClient := NewProxydClient("http://127.0.0.1:8545")
+	res, code, err := httpClient.SendRPC("eth_chainId", nil)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, code)
+	RequireEqualJSON(t, []byte(goodResponse), res)
+	require.Len(t, httpBackend.Requests(), 1)
+}
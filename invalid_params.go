@@ -0,0 +1,56 @@
+package proxyd
+
+import "fmt"
+
+// invalidParamsPolicy decides whether calls whose params proxyd fails to
+// parse while validating them, such as undecodable raw transactions or block
+// params, are forwarded for the backend to reject or rejected by proxyd.
+type invalidParamsPolicy struct {
+	policy  InvalidParamsPolicy
+	methods map[string]InvalidParamsPolicy
+}
+
+func newInvalidParamsPolicy(config InvalidParamsConfig) (*invalidParamsPolicy, error) {
+	if err := validateInvalidParamsPolicy(config.Policy); err != nil {
+		return nil, err
+	}
+	for method, policy := range config.Methods {
+		if err := validateInvalidParamsPolicy(policy); err != nil {
+			return nil, fmt.Errorf("method %s: %w", method, err)
+		}
+	}
+	return &invalidParamsPolicy{
+		policy:  config.Policy,
+		methods: config.Methods,
+	}, nil
+}
+
+func validateInvalidParamsPolicy(policy InvalidParamsPolicy) error {
+	switch policy {
+	case "", InvalidParamsPolicyForward, InvalidParamsPolicyReject:
+		return nil
+	default:
+		return fmt.Errorf("invalid invalid_params policy %q, must be forward or reject", policy)
+	}
+}
+
+// forward returns whether a call to method whose params failed to parse is
+// forwarded rather than rejected. byDefault is what the validation does when
+// no policy is configured for the method.
+func (p *invalidParamsPolicy) forward(method string, byDefault bool) bool {
+	forward := byDefault
+	if p != nil {
+		policy, ok := p.methods[method]
+		if !ok {
+			policy = p.policy
+		}
+		switch policy {
+		case InvalidParamsPolicyForward:
+			forward = true
+		case InvalidParamsPolicyReject:
+			forward = false
+		}
+	}
+	RecordInvalidParams(method, forward)
+	return forward
+}
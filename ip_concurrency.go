@@ -0,0 +1,56 @@
+package proxyd
+
+import (
+	"sync"
+)
+
+// maxIPConcurrencyMetricIPs bounds how many client IPs rejections are
+// reported for by IP. Rejections of any other IP are reported as "other".
+const maxIPConcurrencyMetricIPs = 100
+
+// ipConcurrencyLimiter caps how many requests each client IP has in flight,
+// independently of the rate limits, so that a single IP can't exhaust the
+// server by opening many slow requests at once.
+type ipConcurrencyLimiter struct {
+	max int
+
+	mtx       sync.Mutex
+	inFlight  map[string]int
+	metricIPs map[string]bool
+}
+
+func newIPConcurrencyLimiter(max int) *ipConcurrencyLimiter {
+	return &ipConcurrencyLimiter{
+		max:       max,
+		inFlight:  make(map[string]int),
+		metricIPs: make(map[string]bool),
+	}
+}
+
+// acquire takes a slot for a request of ip, returning false if it already
+// has max requests in flight. Acquired slots must be released.
+func (l *ipConcurrencyLimiter) acquire(ip string) bool {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	if l.inFlight[ip] >= l.max {
+		label := "other"
+		if l.metricIPs[ip] || len(l.metricIPs) < maxIPConcurrencyMetricIPs {
+			l.metricIPs[ip] = true
+			label = ip
+		}
+		RecordIPConcurrencyRejection(label)
+		return false
+	}
+	l.inFlight[ip]++
+	return true
+}
+
+func (l *ipConcurrencyLimiter) release(ip string) {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	if l.inFlight[ip] <= 1 {
+		delete(l.inFlight, ip)
+		return
+	}
+	l.inFlight[ip]--
+}
@@ -0,0 +1,194 @@
+package proxyd
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// logModules maps the subsystems whose log level can be changed at runtime to
+// the source files and functions they log from.
+var logModules = map[string][]string{
+	"routing":    {"backend.go", "param_routing.go", "drift_sampler.go"},
+	"cache":      {"cache.go", "cache_key.go", "memory_cache.go"},
+	"consensus":  {"consensus_poller.go", "consensus_tracker.go"},
+	"rate-limit": {"frontend_rate_limiter.go", "(*Server).rateLimitSender"},
+}
+
+// logLevels holds the default log level and the per-module overrides of the
+// process wide logger.
+var logLevels = newModuleLevels()
+
+type moduleLevels struct {
+	mu      sync.RWMutex
+	def     slog.Level
+	modules map[string]slog.Level
+	// callers caches the module of each logging call site.
+	callers sync.Map
+}
+
+func newModuleLevels() *moduleLevels {
+	return &moduleLevels{
+		def:     slog.LevelInfo,
+		modules: make(map[string]slog.Level),
+	}
+}
+
+// minLevel is the lowest level any module logs at.
+func (m *moduleLevels) minLevel() slog.Level {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	min := m.def
+	for _, level := range m.modules {
+		if level < min {
+			min = level
+		}
+	}
+	return min
+}
+
+func (m *moduleLevels) level(module string) slog.Level {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if level, ok := m.modules[module]; ok {
+		return level
+	}
+	return m.def
+}
+
+func (m *moduleLevels) setDefault(level slog.Level) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.def = level
+}
+
+func (m *moduleLevels) set(module string, level slog.Level) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.modules[module] = level
+}
+
+func (m *moduleLevels) reset(module string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.modules, module)
+}
+
+// snapshot returns the default level and the overrides by module name.
+func (m *moduleLevels) snapshot() (string, map[string]string) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	modules := make(map[string]string, len(m.modules))
+	for module, level := range m.modules {
+		modules[module] = log.LevelString(level)
+	}
+	return log.LevelString(m.def), modules
+}
+
+// moduleOf returns the module that logged from pc, or an empty string.
+func (m *moduleLevels) moduleOf(pc uintptr) string {
+	if module, ok := m.callers.Load(pc); ok {
+		return module.(string)
+	}
+	var module string
+	if fn := runtime.FuncForPC(pc); fn != nil {
+		file, _ := fn.FileLine(pc)
+		file = filepath.Base(file)
+	search:
+		for name, sources := range logModules {
+			for _, source := range sources {
+				if source == file || strings.HasSuffix(fn.Name(), "."+source) {
+					module = name
+					break search
+				}
+			}
+		}
+	}
+	m.callers.Store(pc, module)
+	return module
+}
+
+// moduleLevelHandler drops records below the level of the module that logged
+// them.
+type moduleLevelHandler struct {
+	inner  slog.Handler
+	levels *moduleLevels
+}
+
+func (h *moduleLevelHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return level >= h.levels.minLevel()
+}
+
+func (h *moduleLevelHandler) Handle(ctx context.Context, r slog.Record) error {
+	if r.Level < h.levels.level(h.levels.moduleOf(r.PC)) {
+		return nil
+	}
+	return h.inner.Handle(ctx, r)
+}
+
+func (h *moduleLevelHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &moduleLevelHandler{inner: h.inner.WithAttrs(attrs), levels: h.levels}
+}
+
+func (h *moduleLevelHandler) WithGroup(name string) slog.Handler {
+	return &moduleLevelHandler{inner: h.inner.WithGroup(name), levels: h.levels}
+}
+
+// SetModuleLogLevel overrides the log level of a module.
+func SetModuleLogLevel(module string, level slog.Level) error {
+	if _, ok := logModules[module]; !ok {
+		return fmt.Errorf("unknown log module %q, expected one of %s", module, strings.Join(logModuleNames(), ", "))
+	}
+	logLevels.set(module, level)
+	return nil
+}
+
+// ResetModuleLogLevel makes a module log at the default level again.
+func ResetModuleLogLevel(module string) error {
+	if _, ok := logModules[module]; !ok {
+		return fmt.Errorf("unknown log module %q, expected one of %s", module, strings.Join(logModuleNames(), ", "))
+	}
+	logLevels.reset(module)
+	return nil
+}
+
+// logModuleNames returns the names of the modules whose level can be set.
+func logModuleNames() []string {
+	modules := make([]string, 0, len(logModules))
+	for module := range logModules {
+		modules = append(modules, module)
+	}
+	sort.Strings(modules)
+	return modules
+}
+
+// LevelFromString returns the appropriate Level from a string name.
+// Useful for parsing command line args and configuration files.
+// It also converts strings to lowercase.
+// Note: copied from op-service/log to avoid monorepo dependency
+func LevelFromString(lvlString string) (slog.Level, error) {
+	lvlString = strings.ToLower(lvlString) // ignore case
+	switch lvlString {
+	case "trace", "trce":
+		return log.LevelTrace, nil
+	case "debug", "dbug":
+		return log.LevelDebug, nil
+	case "info":
+		return log.LevelInfo, nil
+	case "warn":
+		return log.LevelWarn, nil
+	case "error", "eror":
+		return log.LevelError, nil
+	case "crit":
+		return log.LevelCrit, nil
+	default:
+		return log.LevelDebug, fmt.Errorf("unknown level: %v", lvlString)
+	}
+}
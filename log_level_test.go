@@ -0,0 +1,54 @@
+package proxyd
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/stretchr/testify/require"
+)
+
+func TestModuleLevelHandler(t *testing.T) {
+	var buf bytes.Buffer
+	levels := newModuleLevels()
+	h := &moduleLevelHandler{
+		inner:  slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: log.LevelTrace}),
+		levels: levels,
+	}
+
+	// records carry the pc of their call site, here functions of each module
+	consensusPC := reflect.ValueOf(NewConsensusPoller).Pointer()
+	cachePC := reflect.ValueOf(newMemoryCache).Pointer()
+	otherPC := reflect.ValueOf(NewServer).Pointer()
+	require.Equal(t, "consensus", levels.moduleOf(consensusPC))
+	require.Equal(t, "cache", levels.moduleOf(cachePC))
+	require.Equal(t, "", levels.moduleOf(otherPC))
+
+	logged := func(pc uintptr, level slog.Level) bool {
+		buf.Reset()
+		if !h.Enabled(context.Background(), level) {
+			return false
+		}
+		require.NoError(t, h.Handle(context.Background(), slog.NewRecord(time.Now(), level, "msg", pc)))
+		return buf.Len() > 0
+	}
+
+	require.True(t, logged(consensusPC, slog.LevelInfo))
+	require.False(t, logged(consensusPC, slog.LevelDebug))
+
+	levels.set("consensus", slog.LevelDebug)
+	require.True(t, logged(consensusPC, slog.LevelDebug))
+	require.False(t, logged(cachePC, slog.LevelDebug))
+	require.False(t, logged(otherPC, slog.LevelDebug))
+
+	levels.set("cache", slog.LevelError)
+	require.False(t, logged(cachePC, slog.LevelWarn))
+	require.True(t, logged(otherPC, slog.LevelWarn))
+
+	levels.reset("consensus")
+	require.False(t, logged(consensusPC, slog.LevelDebug))
+}
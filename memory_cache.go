@@ -0,0 +1,246 @@
+package proxyd
+
+import (
+	"container/heap"
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// assuming an average RPCRes size of 3 KB
+	memoryCacheLimit = 4096
+)
+
+const (
+	evictionReasonMaxEntries = "max_entries"
+	evictionReasonMaxBytes   = "max_bytes"
+	evictionReasonExpired    = "expired"
+)
+
+// cache is the in-memory cache. Entries put with a TTL, and with the ttl
+// policy every entry, expire. Once over maxEntries or maxBytes, entries are
+// evicted in the order of the policy, kept in a heap.
+type cache struct {
+	mtx        sync.Mutex
+	policy     MemoryCacheEvictionPolicy
+	ttl        time.Duration
+	maxEntries int
+	maxBytes   int64
+
+	entries map[string]*memoryCacheEntry
+	order   memoryCacheHeap
+	bytes   int64
+	clock   uint64
+}
+
+type memoryCacheEntry struct {
+	key       string
+	value     string
+	expiresAt time.Time
+	added     uint64
+	lastUsed  uint64
+	hits      uint64
+	index     int
+}
+
+func (e *memoryCacheEntry) size() int64 {
+	return int64(len(e.key) + len(e.value))
+}
+
+func (e *memoryCacheEntry) expired(now time.Time) bool {
+	return !e.expiresAt.IsZero() && now.After(e.expiresAt)
+}
+
+func newMemoryCache() *cache {
+	c, _ := newConfiguredMemoryCache(MemoryCacheConfig{}, defaultCacheTtl)
+	return c
+}
+
+// newConfiguredMemoryCache returns an in-memory cache evicting entries as
+// configured. ttl is the TTL of every entry with the ttl policy.
+func newConfiguredMemoryCache(config MemoryCacheConfig, ttl time.Duration) (*cache, error) {
+	c := &cache{
+		policy:     config.EvictionPolicy,
+		ttl:        ttl,
+		maxEntries: config.MaxEntries,
+		maxBytes:   config.MaxBytes,
+		entries:    make(map[string]*memoryCacheEntry),
+	}
+	if c.policy == "" {
+		c.policy = MemoryCacheEvictionLRU
+	}
+	if c.maxEntries == 0 {
+		c.maxEntries = memoryCacheLimit
+	}
+	if c.maxEntries < 0 || c.maxBytes < 0 {
+		return nil, fmt.Errorf("memory cache max_entries and max_bytes must be >= 0")
+	}
+
+	switch c.policy {
+	case MemoryCacheEvictionLRU:
+		c.order.less = func(a, b *memoryCacheEntry) bool {
+			return a.lastUsed < b.lastUsed
+		}
+	case MemoryCacheEvictionLFU:
+		c.order.less = func(a, b *memoryCacheEntry) bool {
+			if a.hits != b.hits {
+				return a.hits < b.hits
+			}
+			return a.lastUsed < b.lastUsed
+		}
+	case MemoryCacheEvictionTTL:
+		if ttl <= 0 {
+			return nil, fmt.Errorf("memory cache eviction policy %s requires a cache ttl", c.policy)
+		}
+		c.order.less = func(a, b *memoryCacheEntry) bool {
+			if !a.expiresAt.Equal(b.expiresAt) {
+				return a.expiresAt.Before(b.expiresAt)
+			}
+			return a.added < b.added
+		}
+	default:
+		return nil, fmt.Errorf("invalid memory cache eviction policy: %s", c.policy)
+	}
+	return c, nil
+}
+
+func (c *cache) Get(ctx context.Context, key string) (string, error) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	e, ok := c.entries[key]
+	if !ok {
+		return "", nil
+	}
+	if e.expired(time.Now()) {
+		c.remove(e)
+		RecordMemoryCacheEviction(c.policy, evictionReasonExpired)
+		return "", nil
+	}
+	c.clock++
+	e.lastUsed = c.clock
+	e.hits++
+	heap.Fix(&c.order, e.index)
+	return e.value, nil
+}
+
+func (c *cache) Put(ctx context.Context, key string, value string) error {
+	var ttl time.Duration
+	if c.policy == MemoryCacheEvictionTTL {
+		ttl = c.ttl
+	}
+	c.put(key, value, ttl)
+	return nil
+}
+
+func (c *cache) PutWithTTL(ctx context.Context, key string, value string, ttl time.Duration) error {
+	c.put(key, value, ttl)
+	return nil
+}
+
+// put stores value under key, expiring after ttl unless it is zero, after
+// evicting entries until it fits within the caps.
+func (c *cache) put(key string, value string, ttl time.Duration) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	now := time.Now()
+	var expiresAt time.Time
+	if ttl > 0 {
+		expiresAt = now.Add(ttl)
+	}
+
+	// a value over the byte cap by itself would evict everything else
+	if c.maxBytes > 0 && int64(len(key)+len(value)) > c.maxBytes {
+		if e, ok := c.entries[key]; ok {
+			c.remove(e)
+		}
+		return
+	}
+
+	// the entry put is kept out of the eviction, even if it would be next,
+	// such as a new entry with the lfu policy
+	var hits uint64
+	if e, ok := c.entries[key]; ok {
+		hits = e.hits
+		c.remove(e)
+	}
+	c.clock++
+	e := &memoryCacheEntry{
+		key:       key,
+		value:     value,
+		expiresAt: expiresAt,
+		added:     c.clock,
+		lastUsed:  c.clock,
+		hits:      hits,
+	}
+	for len(c.entries) >= c.maxEntries || (c.maxBytes > 0 && c.bytes+e.size() > c.maxBytes) {
+		reason := evictionReasonMaxBytes
+		if len(c.entries) >= c.maxEntries {
+			reason = evictionReasonMaxEntries
+		}
+		evicted := c.order.entries[0]
+		if evicted.expired(now) {
+			reason = evictionReasonExpired
+		}
+		c.remove(evicted)
+		RecordMemoryCacheEviction(c.policy, reason)
+	}
+	c.entries[key] = e
+	c.bytes += e.size()
+	heap.Push(&c.order, e)
+}
+
+func (c *cache) remove(e *memoryCacheEntry) {
+	heap.Remove(&c.order, e.index)
+	delete(c.entries, e.key)
+	c.bytes -= e.size()
+}
+
+func (c *cache) Flush(ctx context.Context, prefix string, includeRemote bool) (int, error) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	removed := 0
+	for key, e := range c.entries {
+		if strings.HasPrefix(key, prefix) {
+			c.remove(e)
+			removed++
+		}
+	}
+	return removed, nil
+}
+
+// memoryCacheHeap orders entries by eviction priority, the next entry to
+// evict first.
+type memoryCacheHeap struct {
+	entries []*memoryCacheEntry
+	less    func(a, b *memoryCacheEntry) bool
+}
+
+func (h *memoryCacheHeap) Len() int { return len(h.entries) }
+
+func (h *memoryCacheHeap) Less(i, j int) bool { return h.less(h.entries[i], h.entries[j]) }
+
+func (h *memoryCacheHeap) Swap(i, j int) {
+	h.entries[i], h.entries[j] = h.entries[j], h.entries[i]
+	h.entries[i].index = i
+	h.entries[j].index = j
+}
+
+func (h *memoryCacheHeap) Push(x any) {
+	e := x.(*memoryCacheEntry)
+	e.index = len(h.entries)
+	h.entries = append(h.entries, e)
+}
+
+func (h *memoryCacheHeap) Pop() any {
+	n := len(h.entries)
+	e := h.entries[n-1]
+	h.entries[n-1] = nil
+	h.entries = h.entries[:n-1]
+	return e
+}
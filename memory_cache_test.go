@@ -0,0 +1,124 @@
+package proxyd
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryCacheEviction(t *testing.T) {
+	ctx := context.Background()
+
+	get := func(c *cache, key string) string {
+		val, err := c.Get(ctx, key)
+		require.NoError(t, err)
+		return val
+	}
+	evictions := func(policy MemoryCacheEvictionPolicy, reason string) float64 {
+		return testutil.ToFloat64(memoryCacheEvictionsTotal.WithLabelValues(string(policy), reason))
+	}
+
+	t.Run("lru", func(t *testing.T) {
+		c, err := newConfiguredMemoryCache(MemoryCacheConfig{EvictionPolicy: MemoryCacheEvictionLRU, MaxEntries: 3}, time.Minute)
+		require.NoError(t, err)
+		before := evictions(MemoryCacheEvictionLRU, evictionReasonMaxEntries)
+
+		for _, key := range []string{"a", "b", "c"} {
+			require.NoError(t, c.Put(ctx, key, "value"))
+		}
+		// a is used after b, which becomes the least recently used
+		require.Equal(t, "value", get(c, "a"))
+		require.NoError(t, c.Put(ctx, "d", "value"))
+
+		require.Empty(t, get(c, "b"))
+		for _, key := range []string{"a", "c", "d"} {
+			require.Equal(t, "value", get(c, key), key)
+		}
+		require.Equal(t, before+1, evictions(MemoryCacheEvictionLRU, evictionReasonMaxEntries))
+	})
+
+	t.Run("lfu", func(t *testing.T) {
+		c, err := newConfiguredMemoryCache(MemoryCacheConfig{EvictionPolicy: MemoryCacheEvictionLFU, MaxEntries: 3}, time.Minute)
+		require.NoError(t, err)
+		before := evictions(MemoryCacheEvictionLFU, evictionReasonMaxEntries)
+
+		for _, key := range []string{"a", "b", "c"} {
+			require.NoError(t, c.Put(ctx, key, "value"))
+		}
+		// a and c are hot, b was used once but most recently
+		for i := 0; i < 3; i++ {
+			get(c, "a")
+			get(c, "c")
+		}
+		get(c, "b")
+		require.NoError(t, c.Put(ctx, "d", "value"))
+
+		require.Empty(t, get(c, "b"))
+		for _, key := range []string{"a", "c", "d"} {
+			require.Equal(t, "value", get(c, key), key)
+		}
+		require.Equal(t, before+1, evictions(MemoryCacheEvictionLFU, evictionReasonMaxEntries))
+	})
+
+	t.Run("ttl", func(t *testing.T) {
+		ttl := 100 * time.Millisecond
+		c, err := newConfiguredMemoryCache(MemoryCacheConfig{EvictionPolicy: MemoryCacheEvictionTTL, MaxEntries: 3}, ttl)
+		require.NoError(t, err)
+		beforeCap := evictions(MemoryCacheEvictionTTL, evictionReasonMaxEntries)
+		beforeExpired := evictions(MemoryCacheEvictionTTL, evictionReasonExpired)
+
+		// a expires last despite being put first, and usage doesn't matter
+		require.NoError(t, c.PutWithTTL(ctx, "a", "value", time.Minute))
+		require.NoError(t, c.Put(ctx, "b", "value"))
+		require.NoError(t, c.Put(ctx, "c", "value"))
+		get(c, "b")
+		require.NoError(t, c.Put(ctx, "d", "value"))
+
+		require.Empty(t, get(c, "b"))
+		for _, key := range []string{"a", "c", "d"} {
+			require.Equal(t, "value", get(c, key), key)
+		}
+		require.Equal(t, beforeCap+1, evictions(MemoryCacheEvictionTTL, evictionReasonMaxEntries))
+
+		// entries put without a TTL expire after the cache TTL
+		time.Sleep(ttl)
+		require.Empty(t, get(c, "c"))
+		require.Equal(t, "value", get(c, "a"))
+		require.Equal(t, beforeExpired+1, evictions(MemoryCacheEvictionTTL, evictionReasonExpired))
+	})
+
+	t.Run("max bytes", func(t *testing.T) {
+		c, err := newConfiguredMemoryCache(MemoryCacheConfig{MaxBytes: 100}, time.Minute)
+		require.NoError(t, err)
+		before := evictions(MemoryCacheEvictionLRU, evictionReasonMaxBytes)
+
+		value := fmt.Sprintf("%039d", 0)
+		for _, key := range []string{"a", "b"} {
+			require.NoError(t, c.Put(ctx, key, value))
+		}
+		require.NoError(t, c.Put(ctx, "c", value))
+
+		require.Empty(t, get(c, "a"))
+		require.Equal(t, value, get(c, "b"))
+		require.Equal(t, value, get(c, "c"))
+		require.Equal(t, int64(80), c.bytes)
+		require.Equal(t, before+1, evictions(MemoryCacheEvictionLRU, evictionReasonMaxBytes))
+
+		// values over the cap by themselves aren't cached
+		require.NoError(t, c.Put(ctx, "d", fmt.Sprintf("%0100d", 0)))
+		require.Empty(t, get(c, "d"))
+		require.Equal(t, value, get(c, "b"))
+		require.Equal(t, value, get(c, "c"))
+	})
+
+	t.Run("invalid config", func(t *testing.T) {
+		_, err := newConfiguredMemoryCache(MemoryCacheConfig{EvictionPolicy: "fifo"}, time.Minute)
+		require.ErrorContains(t, err, "invalid memory cache eviction policy")
+		_, err = newConfiguredMemoryCache(MemoryCacheConfig{MaxEntries: -1}, time.Minute)
+		require.Error(t, err)
+	})
+}
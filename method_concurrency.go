@@ -0,0 +1,89 @@
+package proxyd
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"golang.org/x/sync/semaphore"
+)
+
+// methodLimiter caps how many calls to each of its methods a backend group
+// forwards concurrently, across all of its backends. Calls over the cap wait
+// for a slot, for up to the queue timeout of the group if it has one, or are
+// rejected right away.
+type methodLimiter struct {
+	backendGroup string
+	limits       map[string]int64
+	sems         map[string]*semaphore.Weighted
+	reject       bool
+}
+
+func newMethodLimiter(backendGroup string, limits map[string]int, reject bool) *methodLimiter {
+	l := &methodLimiter{
+		backendGroup: backendGroup,
+		limits:       make(map[string]int64, len(limits)),
+		sems:         make(map[string]*semaphore.Weighted, len(limits)),
+		reject:       reject,
+	}
+	for method, limit := range limits {
+		l.limits[method] = int64(limit)
+		l.sems[method] = semaphore.NewWeighted(int64(limit))
+	}
+	return l
+}
+
+// acquire takes a slot for every call in reqs to a limited method, and returns
+// a function giving them back. A batch holds at most all the slots of a
+// method, so that it doesn't wait forever.
+func (l *methodLimiter) acquire(ctx context.Context, reqs []*RPCReq, queueTimeout time.Duration) (func(), error) {
+	weights := make(map[string]int64)
+	for _, req := range reqs {
+		if limit, ok := l.limits[req.Method]; ok && weights[req.Method] < limit {
+			weights[req.Method]++
+		}
+	}
+	if len(weights) == 0 {
+		return func() {}, nil
+	}
+	// acquire in a fixed order so that batches don't deadlock each other
+	methods := make([]string, 0, len(weights))
+	for method := range weights {
+		methods = append(methods, method)
+	}
+	sort.Strings(methods)
+
+	acquireCtx := ctx
+	if queueTimeout > 0 {
+		var cancel context.CancelFunc
+		acquireCtx, cancel = context.WithTimeout(ctx, queueTimeout)
+		defer cancel()
+	}
+
+	release := func(acquired []string) {
+		for _, method := range acquired {
+			l.sems[method].Release(weights[method])
+			RecordMethodInFlight(l.backendGroup, method, -weights[method])
+		}
+	}
+	for i, method := range methods {
+		var err error
+		if l.reject {
+			if !l.sems[method].TryAcquire(weights[method]) {
+				err = ErrMethodConcurrencyLimit
+			}
+		} else if err = l.sems[method].Acquire(acquireCtx, weights[method]); err != nil {
+			if acquireCtx.Err() == context.DeadlineExceeded && ctx.Err() == nil {
+				RecordQueueTimeout(l.backendGroup)
+				err = ErrQueueTimeout
+			}
+		}
+		if err != nil {
+			RecordMethodConcurrencyLimited(l.backendGroup, method)
+			release(methods[:i])
+			return nil, err
+		}
+		RecordMethodInFlight(l.backendGroup, method, weights[method])
+	}
+	return func() { release(methods) }, nil
+}
@@ -7,10 +7,17 @@ import (
 	"fmt"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rpc"
 )
 
+// cacheKeyPrefix is prepended to the key of every cached RPC response.
+const cacheKeyPrefix = "cache"
+
 type RPCMethodHandler interface {
 	GetRPCMethod(context.Context, *RPCReq) (*RPCRes, error)
 	PutRPCMethod(context.Context, *RPCReq, *RPCRes) error
@@ -21,14 +28,20 @@ type StaticMethodHandler struct {
 	m         sync.RWMutex
 	filterGet func(*RPCReq) bool
 	filterPut func(*RPCReq, *RPCRes) bool
+
+	normalizeKeys bool
 }
 
 func (e *StaticMethodHandler) key(req *RPCReq) string {
+	params := req.Params
+	if e.normalizeKeys {
+		params = normalizeCacheKeyParams(req.Method, params)
+	}
 	// signature is the hashed json.RawMessage param contents
 	h := sha256.New()
-	h.Write(req.Params)
+	h.Write(params)
 	signature := fmt.Sprintf("%x", h.Sum(nil))
-	return strings.Join([]string{"cache", req.Method, signature}, ":")
+	return strings.Join([]string{cacheKeyPrefix, req.Method, signature}, ":")
 }
 
 func (e *StaticMethodHandler) GetRPCMethod(ctx context.Context, req *RPCReq) (*RPCRes, error) {
@@ -53,7 +66,7 @@ func (e *StaticMethodHandler) GetRPCMethod(ctx context.Context, req *RPCReq) (*R
 	}
 
 	var result interface{}
-	if err := json.Unmarshal([]byte(val), &result); err != nil {
+	if err := unmarshalJSONNumbers([]byte(val), &result); err != nil {
 		log.Error("error unmarshalling value from cache", "key", key, "method", req.Method, "err", err)
 		return nil, err
 	}
@@ -90,3 +103,188 @@ func (e *StaticMethodHandler) PutRPCMethod(ctx context.Context, req *RPCReq, res
 	}
 	return nil
 }
+
+// BlockScopedMethodHandler caches responses until the consensus block
+// changes. Entries are keyed by the block they were cached at, so that moving
+// the head invalidates all of them at once. Nothing is cached before the
+// first head is known.
+type BlockScopedMethodHandler struct {
+	cache         Cache
+	normalizeKeys bool
+	head          atomic.Uint64
+}
+
+// SetHead moves the block that responses are cached for.
+func (e *BlockScopedMethodHandler) SetHead(blockNumber hexutil.Uint64) {
+	e.head.Store(uint64(blockNumber))
+}
+
+func (e *BlockScopedMethodHandler) key(req *RPCReq, head uint64) string {
+	signature := paramsSignature(req, e.normalizeKeys)
+	return strings.Join([]string{cacheKeyPrefix, req.Method, hexutil.EncodeUint64(head), signature}, ":")
+}
+
+func (e *BlockScopedMethodHandler) GetRPCMethod(ctx context.Context, req *RPCReq) (*RPCRes, error) {
+	head := e.head.Load()
+	if e.cache == nil || head == 0 {
+		return nil, nil
+	}
+	return getCachedRPCRes(ctx, e.cache, e.key(req, head), req)
+}
+
+func (e *BlockScopedMethodHandler) PutRPCMethod(ctx context.Context, req *RPCReq, res *RPCRes) error {
+	head := e.head.Load()
+	if e.cache == nil || head == 0 {
+		return nil
+	}
+
+	key := e.key(req, head)
+	if err := e.cache.Put(ctx, key, string(mustMarshalJSON(res.Result))); err != nil {
+		log.Error("error putting into cache", "key", key, "method", req.Method, "err", err)
+		return err
+	}
+	return nil
+}
+
+// paramsSignature hashes the params of req for use in cache keys.
+func paramsSignature(req *RPCReq, normalizeKeys bool) string {
+	params := req.Params
+	if normalizeKeys {
+		params = normalizeCacheKeyParams(req.Method, params)
+	}
+	h := sha256.New()
+	h.Write(params)
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
+
+func getCachedRPCRes(ctx context.Context, cache Cache, key string, req *RPCReq) (*RPCRes, error) {
+	val, err := cache.Get(ctx, key)
+	if err != nil {
+		log.Error("error reading from cache", "key", key, "method", req.Method, "err", err)
+		return nil, err
+	}
+	if val == "" {
+		return nil, nil
+	}
+
+	var result interface{}
+	if err := unmarshalJSONNumbers([]byte(val), &result); err != nil {
+		log.Error("error unmarshalling value from cache", "key", key, "method", req.Method, "err", err)
+		return nil, err
+	}
+	return &RPCRes{
+		JSONRPC: req.JSONRPC,
+		Result:  result,
+		ID:      req.ID,
+	}, nil
+}
+
+// DepthTTLMethodHandler caches responses to requests for a block by number
+// for longer the deeper the block is below the consensus head, since deeper
+// blocks are less likely to be reorged. Requests for a block tag, a block
+// hash or a block within MinDepth of the head aren't cached.
+type DepthTTLMethodHandler struct {
+	cache         Cache
+	normalizeKeys bool
+	config        DepthTTLConfig
+	head          atomic.Uint64
+}
+
+func newDepthTTLMethodHandler(cache Cache, normalizeKeys bool, config DepthTTLConfig) *DepthTTLMethodHandler {
+	return &DepthTTLMethodHandler{cache: cache, normalizeKeys: normalizeKeys, config: config}
+}
+
+// SetHead moves the block that depths are measured from.
+func (e *DepthTTLMethodHandler) SetHead(blockNumber hexutil.Uint64) {
+	e.head.Store(uint64(blockNumber))
+}
+
+// TTL returns how long to cache a response for a block depth blocks below the
+// head: BaseTTL at MinDepth, growing by TTLPerBlock for every block deeper,
+// up to MaxTTL. It returns false if the block is too close to the head.
+func (e *DepthTTLMethodHandler) TTL(depth uint64) (time.Duration, bool) {
+	if depth < e.config.MinDepth {
+		return 0, false
+	}
+	ttl := time.Duration(e.config.BaseTTL)
+	maxTTL := time.Duration(e.config.MaxTTL)
+	if perBlock := time.Duration(e.config.TTLPerBlock); perBlock > 0 {
+		blocks := depth - e.config.MinDepth
+		// cap the blocks counted so that the product doesn't overflow
+		if blocks > uint64(maxTTL/perBlock) {
+			blocks = uint64(maxTTL/perBlock) + 1
+		}
+		ttl += time.Duration(blocks) * perBlock
+	}
+	if maxTTL > 0 && ttl > maxTTL {
+		ttl = maxTTL
+	}
+	return ttl, ttl > 0
+}
+
+// depth returns how far below the head the block requested by req is.
+func (e *DepthTTLMethodHandler) depth(req *RPCReq) (uint64, bool) {
+	return blockDepth(req, e.head.Load())
+}
+
+// blockDepth returns how far below head the block requested by req by number
+// is. It returns false for block tags other than "earliest", block hashes and
+// unknown heads.
+func blockDepth(req *RPCReq, head uint64) (uint64, bool) {
+	pos, ok := pendingTagParamPositions[req.Method]
+	if head == 0 || !ok {
+		return 0, false
+	}
+	var params []json.RawMessage
+	if err := json.Unmarshal(req.Params, &params); err != nil || pos >= len(params) {
+		return 0, false
+	}
+	var bnh rpc.BlockNumberOrHash
+	if err := json.Unmarshal(params[pos], &bnh); err != nil {
+		return 0, false
+	}
+	number, ok := bnh.Number()
+	if !ok || number < 0 || uint64(number) > head {
+		return 0, false
+	}
+	return head - uint64(number), true
+}
+
+func (e *DepthTTLMethodHandler) key(req *RPCReq) string {
+	return strings.Join([]string{cacheKeyPrefix, req.Method, paramsSignature(req, e.normalizeKeys)}, ":")
+}
+
+func (e *DepthTTLMethodHandler) GetRPCMethod(ctx context.Context, req *RPCReq) (*RPCRes, error) {
+	if e.cache == nil {
+		return nil, nil
+	}
+	depth, ok := e.depth(req)
+	if !ok {
+		return nil, nil
+	}
+	if _, ok := e.TTL(depth); !ok {
+		return nil, nil
+	}
+	return getCachedRPCRes(ctx, e.cache, e.key(req), req)
+}
+
+func (e *DepthTTLMethodHandler) PutRPCMethod(ctx context.Context, req *RPCReq, res *RPCRes) error {
+	if e.cache == nil {
+		return nil
+	}
+	depth, ok := e.depth(req)
+	if !ok {
+		return nil
+	}
+	ttl, ok := e.TTL(depth)
+	if !ok {
+		return nil
+	}
+
+	key := e.key(req)
+	if err := putCacheWithTTL(ctx, e.cache, key, string(mustMarshalJSON(res.Result)), ttl); err != nil {
+		log.Error("error putting into cache", "key", key, "method", req.Method, "err", err)
+		return err
+	}
+	return nil
+}
@@ -101,6 +101,12 @@ var (
 		"auth",
 	})
 
+	wsConnsGauge = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: MetricsNamespace,
+		Name:      "ws_conns",
+		Help:      "Gauge of open client WS connections across all auth keys.",
+	})
+
 	activeBackendWsConnsGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
 		Namespace: MetricsNamespace,
 		Name:      "active_backend_ws_conns",
@@ -143,6 +149,24 @@ var (
 		"source",
 	})
 
+	wsDroppedNotificationsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: MetricsNamespace,
+		Name:      "ws_dropped_notifications_total",
+		Help:      "Count of subscription notifications dropped because the WS client's queue was full.",
+	}, []string{
+		"auth",
+		"backend_name",
+	})
+
+	wsBackpressureDisconnectsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: MetricsNamespace,
+		Name:      "ws_backpressure_disconnects_total",
+		Help:      "Count of WS clients disconnected because their queue was full.",
+	}, []string{
+		"auth",
+		"backend_name",
+	})
+
 	redisErrorsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
 		Namespace: MetricsNamespace,
 		Name:      "redis_errors_total",
@@ -169,6 +193,15 @@ var (
 		"auth",
 	})
 
+	responseSizesByMethod = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: MetricsNamespace,
+		Name:      "response_sizes_by_method",
+		Help:      "Histogram of the encoded size of responses to each call, by method. Methods that aren't whitelisted are labeled unknown.",
+		Buckets:   PayloadSizeBuckets,
+	}, []string{
+		"method",
+	})
+
 	cacheHitsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
 		Namespace: MetricsNamespace,
 		Name:      "cache_hits_total",
@@ -193,6 +226,39 @@ var (
 		"method",
 	})
 
+	memoryCacheEvictionsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: MetricsNamespace,
+		Name:      "memory_cache_evictions_total",
+		Help:      "Number of entries evicted from the in-memory cache, by eviction policy and reason.",
+	}, []string{
+		"policy",
+		"reason",
+	})
+
+	cacheReorgBypassesTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: MetricsNamespace,
+		Name:      "cache_reorg_bypasses_total",
+		Help:      "Number of cache lookups skipped during a reorg cooldown.",
+	}, []string{
+		"method",
+	})
+
+	sendRawTxDedupHitsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: MetricsNamespace,
+		Name:      "send_raw_tx_dedup_hits_total",
+		Help:      "Number of eth_sendRawTransaction calls answered from the dedup window.",
+	}, []string{
+		"backend_group",
+	})
+
+	balanceSnapshotHitsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: MetricsNamespace,
+		Name:      "balance_snapshot_hits_total",
+		Help:      "Number of eth_getBalance calls at the finalized block answered from the balance snapshot.",
+	}, []string{
+		"backend_group",
+	})
+
 	batchRPCShortCircuitsTotal = promauto.NewCounter(prometheus.CounterOpts{
 		Namespace: MetricsNamespace,
 		Name:      "batch_rpc_short_circuits_total",
@@ -225,6 +291,164 @@ var (
 		Buckets:   MillisecondDurationBuckets,
 	}, []string{"command"})
 
+	priorityWaitDurationSumm = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: MetricsNamespace,
+		Name:      "priority_wait_duration_milliseconds",
+		Help:      "Histogram of time spent waiting for an rpc slot, in milliseconds, by priority level.",
+		Buckets:   MillisecondDurationBuckets,
+	}, []string{"priority"})
+
+	wasmHookDurationSumm = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: MetricsNamespace,
+		Name:      "wasm_hook_duration_milliseconds",
+		Help:      "Histogram of time spent running the wasm hook, in milliseconds, by the action taken.",
+		Buckets:   MillisecondDurationBuckets,
+	}, []string{"action"})
+
+	proxydOverheadDurationSumm = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: MetricsNamespace,
+		Name:      "request_overhead_milliseconds",
+		Help:      "Histogram of HTTP RPC request latency minus the time spent waiting on backends, in milliseconds.",
+		Buckets:   []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 50, 100, 500, 1000},
+	})
+
+	clientRequestsByCountryTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: MetricsNamespace,
+		Name:      "client_requests_by_country_total",
+		Help:      "Count of client HTTP requests and websocket connections by the geoip country of the client.",
+	}, []string{
+		"country",
+	})
+
+	selfPingLatency = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: MetricsNamespace,
+		Name:      "self_ping_latency_milliseconds",
+		Help:      "Latency of the last request proxyd sent to its own loopback handler, in milliseconds.",
+	})
+
+	selfPingErrorsTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: MetricsNamespace,
+		Name:      "self_ping_errors_total",
+		Help:      "Count of failed requests to the self ping loopback handler.",
+	})
+
+	sloViolationsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: MetricsNamespace,
+		Name:      "slo_violations_total",
+		Help:      "Count of requests cut off after exceeding the slo_timeout of their backend group.",
+	}, []string{
+		"backend_group",
+	})
+
+	queueTimeoutsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: MetricsNamespace,
+		Name:      "queue_timeouts_total",
+		Help:      "Count of requests rejected after waiting queue_timeout for an rpc slot.",
+	}, []string{
+		"backend_group",
+	})
+
+	methodInFlightRequests = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: MetricsNamespace,
+		Name:      "method_in_flight_requests",
+		Help:      "Number of in flight calls to methods with a concurrency limit, by backend group and method.",
+	}, []string{
+		"backend_group",
+		"method",
+	})
+
+	methodConcurrencyLimitedTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: MetricsNamespace,
+		Name:      "method_concurrency_limited_total",
+		Help:      "Count of requests rejected by a method concurrency limit, by backend group and method.",
+	}, []string{
+		"backend_group",
+		"method",
+	})
+
+	backendThrottledRequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: MetricsNamespace,
+		Name:      "backend_throttled_requests_total",
+		Help:      "Count of requests held back by the max_rps of a backend, by backend and whether they were queued or skipped it.",
+	}, []string{
+		"backend_name",
+		"action",
+	})
+
+	backendRetryAfterTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: MetricsNamespace,
+		Name:      "backend_retry_after_total",
+		Help:      "Count of 429 responses with a Retry-After header that made proxyd back off from a backend.",
+	}, []string{
+		"backend_name",
+	})
+
+	canaryRequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: MetricsNamespace,
+		Name:      "canary_requests_total",
+		Help:      "Count of calls to a canaried method, by backend group, method, target (canary or baseline) and whether they failed.",
+	}, []string{
+		"backend_group",
+		"method",
+		"target",
+		"error",
+	})
+
+	canaryErrorRate = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: MetricsNamespace,
+		Name:      "canary_error_rate",
+		Help:      "Share of failed calls to a canaried method over the comparison window, by backend group, method and target (canary or baseline).",
+	}, []string{
+		"backend_group",
+		"method",
+		"target",
+	})
+
+	codeHashLookupsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: MetricsNamespace,
+		Name:      "code_hash_lookups_total",
+		Help:      "Count of code hash lookups for caching eth_call by code hash, by source (cached, fetched or failed).",
+	}, []string{
+		"source",
+	})
+
+	backoffCacheResponsesTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: MetricsNamespace,
+		Name:      "backoff_cache_responses_total",
+		Help:      "Count of cacheable reads looked up in the cache while the preferred backend of their backend group was backing off, by backend group, method and whether they were served from cache.",
+	}, []string{
+		"backend_group",
+		"method",
+		"cached",
+	})
+
+	cacheOnlyOutageResponsesTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: MetricsNamespace,
+		Name:      "cache_only_outage_responses_total",
+		Help:      "Count of calls answered while a backend group had no healthy backend, by backend group, method and whether they were served from cache.",
+	}, []string{
+		"backend_group",
+		"method",
+		"cached",
+	})
+
+	invalidParamsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: MetricsNamespace,
+		Name:      "invalid_params_total",
+		Help:      "Count of calls whose params failed to parse for validation, by method and whether they were forwarded.",
+	}, []string{
+		"method",
+		"forwarded",
+	})
+
+	streamedResponsesTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: MetricsNamespace,
+		Name:      "backend_streamed_responses_total",
+		Help:      "Count of backend responses streamed to clients without buffering, by backend.",
+	}, []string{
+		"backend_name",
+	})
+
 	tooManyRequestErrorsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
 		Namespace: MetricsNamespace,
 		Name:      "too_many_request_errors_total",
@@ -247,10 +471,45 @@ var (
 		},
 	})
 
-	frontendRateLimitTakeErrors = promauto.NewCounter(prometheus.CounterOpts{
+	frontendRateLimitTakeErrors = promauto.NewCounterVec(prometheus.CounterOpts{
 		Namespace: MetricsNamespace,
 		Name:      "rate_limit_take_errors",
 		Help:      "Count of errors taking frontend rate limits",
+	}, []string{
+		"limiter",
+	})
+
+	apiKeySpendingTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: MetricsNamespace,
+		Name:      "api_key_spending_total",
+		Help:      "Cost charged to each API key with a spending limit.",
+	}, []string{
+		"auth",
+	})
+
+	spendingLimitRejectionsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: MetricsNamespace,
+		Name:      "spending_limit_rejections_total",
+		Help:      "Count of calls rejected because their API key spent its budget.",
+	}, []string{
+		"auth",
+	})
+
+	frontendRateLimitTakesTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: MetricsNamespace,
+		Name:      "rate_limit_takes_total",
+		Help:      "Count of frontend rate limit takes, by whether they were allowed",
+	}, []string{
+		"limiter",
+		"allowed",
+	})
+
+	frontendRateLimitAvailableTokens = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: MetricsNamespace,
+		Name:      "rate_limit_available_tokens",
+		Help:      "Tokens left in the current window to the busiest key of a frontend rate limiter",
+	}, []string{
+		"limiter",
 	})
 
 	consensusLatestBlock = promauto.NewGaugeVec(prometheus.GaugeOpts{
@@ -360,6 +619,30 @@ var (
 		"backend_group_name",
 	})
 
+	backendHeadSkew = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: MetricsNamespace,
+		Name:      "backend_head_skew_seconds",
+		Help:      "Age of the latest block of a backend relative to local time, negative if in the future",
+	}, []string{
+		"backend_name",
+	})
+
+	consensusGroupStandbyHead = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: MetricsNamespace,
+		Name:      "group_consensus_standby_head",
+		Help:      "1 while the consensus head of a group is taken from its standby head source, as no backend is a consensus candidate",
+	}, []string{
+		"backend_group_name",
+	})
+
+	consensusGroupQuorum = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: MetricsNamespace,
+		Name:      "group_consensus_quorum",
+		Help:      "1 while the consensus group of a group has at least its configured quorum of backends",
+	}, []string{
+		"backend_group_name",
+	})
+
 	consensusGroupTotalCount = promauto.NewGaugeVec(prometheus.GaugeOpts{
 		Namespace: MetricsNamespace,
 		Name:      "group_consensus_total_count",
@@ -376,6 +659,22 @@ var (
 		"backend_name",
 	})
 
+	consensusBanCountBackend = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: MetricsNamespace,
+		Name:      "consensus_backend_ban_count",
+		Help:      "Number of bans counting towards the ban backoff",
+	}, []string{
+		"backend_name",
+	})
+
+	consensusBanBackoffBackend = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: MetricsNamespace,
+		Name:      "consensus_backend_ban_backoff_seconds",
+		Help:      "Duration of the latest ban",
+	}, []string{
+		"backend_name",
+	})
+
 	consensusPeerCountBackend = promauto.NewGaugeVec(prometheus.GaugeOpts{
 		Namespace: MetricsNamespace,
 		Name:      "consensus_backend_peer_count",
@@ -460,6 +759,106 @@ var (
 		"backend_name",
 		"error",
 	})
+
+	backendGroupSpilloverRequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: MetricsNamespace,
+		Name:      "backend_group_spillover_requests_total",
+		Help:      "Count of requests for a backend group with a spillover group, by the group they were routed to",
+	}, []string{
+		"backend_group",
+		"routed_to",
+	})
+
+	backendGroupDriftSamplesTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: MetricsNamespace,
+		Name:      "backend_group_drift_samples_total",
+		Help:      "Count of drift samples of a backend group, by whether backend responses diverged",
+	}, []string{
+		"backend_group",
+		"diverged",
+	})
+
+	backendResponseDrift = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: MetricsNamespace,
+		Name:      "backend_response_drift",
+		Help:      "Bool gauge for backends whose response diverged in the last drift sample",
+	}, []string{
+		"backend_group",
+		"backend_name",
+	})
+
+	rpcErrorsMappedTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: MetricsNamespace,
+		Name:      "rpc_errors_mapped_total",
+		Help:      "Count of backend errors rewritten by an error mapping, by their original code",
+	}, []string{
+		"code",
+	})
+
+	paramRoutedRequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: MetricsNamespace,
+		Name:      "param_routed_requests_total",
+		Help:      "Count of requests routed by a param route, by method and the group they were routed to",
+	}, []string{
+		"method",
+		"backend_group",
+	})
+
+	replicaRoutedRequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: MetricsNamespace,
+		Name:      "replica_routed_requests_total",
+		Help:      "Count of requests for historical blocks routed to a replica group, by method and replica group",
+	}, []string{
+		"method",
+		"backend_group",
+	})
+
+	groupOverridesTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: MetricsNamespace,
+		Name:      "group_overrides_total",
+		Help:      "Count of requests choosing their backend group with the X-Proxyd-Group header, by backend group",
+	}, []string{
+		"backend_group",
+	})
+
+	ipConcurrencyRejectionsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: MetricsNamespace,
+		Name:      "ip_concurrency_rejections_total",
+		Help:      "Count of requests rejected for exceeding max_concurrent_rpcs_per_ip, by client IP for the first 100 IPs rejected and \"other\" for the rest",
+	}, []string{
+		"client_ip",
+	})
+
+	configReloadsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: MetricsNamespace,
+		Name:      "config_reloads_total",
+		Help:      "Count of config reloads, by whether they succeeded",
+	}, []string{
+		"success",
+	})
+
+	configReloadsCoalescedTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: MetricsNamespace,
+		Name:      "config_reloads_coalesced_total",
+		Help:      "Count of config reloads requested while another was pending, and served by it",
+	})
+
+	coalescedRequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: MetricsNamespace,
+		Name:      "coalesced_requests_total",
+		Help:      "Count of backend calls avoided by sharing the response of an identical call in flight",
+	}, []string{
+		"backend_group",
+		"method",
+	})
+
+	coalescingGroupsInFlight = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: MetricsNamespace,
+		Name:      "coalescing_groups_in_flight",
+		Help:      "Number of backend calls in flight that identical calls may join",
+	}, []string{
+		"backend_group",
+	})
 )
 
 func RecordRedisError(source string) {
@@ -483,6 +882,14 @@ func RecordWSMessage(ctx context.Context, backendName, source string) {
 	wsMessagesTotal.WithLabelValues(GetAuthCtx(ctx), backendName, source).Inc()
 }
 
+func RecordWSDroppedNotifications(ctx context.Context, backendName string, n int) {
+	wsDroppedNotificationsTotal.WithLabelValues(GetAuthCtx(ctx), backendName).Add(float64(n))
+}
+
+func RecordWSBackpressureDisconnect(ctx context.Context, backendName string) {
+	wsBackpressureDisconnectsTotal.WithLabelValues(GetAuthCtx(ctx), backendName).Inc()
+}
+
 func RecordUnserviceableRequest(ctx context.Context, source string) {
 	unserviceableRequestsTotal.WithLabelValues(GetAuthCtx(ctx), source).Inc()
 }
@@ -525,6 +932,98 @@ func RecordCacheError(method string) {
 	cacheErrorsTotal.WithLabelValues(method).Inc()
 }
 
+func RecordMemoryCacheEviction(policy MemoryCacheEvictionPolicy, reason string) {
+	memoryCacheEvictionsTotal.WithLabelValues(string(policy), reason).Inc()
+}
+
+func RecordCacheReorgBypass(method string) {
+	cacheReorgBypassesTotal.WithLabelValues(method).Inc()
+}
+
+func RecordPriorityWait(priority int, dur time.Duration) {
+	priorityWaitDurationSumm.WithLabelValues(strconv.Itoa(priority)).Observe(float64(dur.Milliseconds()))
+}
+
+func RecordWASMHook(action string, dur time.Duration) {
+	wasmHookDurationSumm.WithLabelValues(action).Observe(float64(dur) / float64(time.Millisecond))
+}
+
+func RecordProxydOverhead(dur time.Duration) {
+	if dur < 0 {
+		dur = 0
+	}
+	proxydOverheadDurationSumm.Observe(float64(dur) / float64(time.Millisecond))
+}
+
+func RecordClientCountry(country string) {
+	clientRequestsByCountryTotal.WithLabelValues(country).Inc()
+}
+
+func RecordSelfPing(dur time.Duration) {
+	selfPingLatency.Set(float64(dur) / float64(time.Millisecond))
+}
+
+func RecordSelfPingError() {
+	selfPingErrorsTotal.Inc()
+}
+
+func RecordSLOViolation(backendGroup string) {
+	sloViolationsTotal.WithLabelValues(backendGroup).Inc()
+}
+
+func RecordQueueTimeout(backendGroup string) {
+	queueTimeoutsTotal.WithLabelValues(backendGroup).Inc()
+}
+
+func RecordMethodInFlight(backendGroup, method string, delta int64) {
+	methodInFlightRequests.WithLabelValues(backendGroup, method).Add(float64(delta))
+}
+
+func RecordMethodConcurrencyLimited(backendGroup, method string) {
+	methodConcurrencyLimitedTotal.WithLabelValues(backendGroup, method).Inc()
+}
+
+func RecordBackendThrottled(backendName, action string) {
+	backendThrottledRequestsTotal.WithLabelValues(backendName, action).Inc()
+}
+
+func RecordBackendRetryAfter(backendName string) {
+	backendRetryAfterTotal.WithLabelValues(backendName).Inc()
+}
+
+func RecordCanaryRequest(backendGroup, method, target string, failed bool, errorRate float64) {
+	canaryRequestsTotal.WithLabelValues(backendGroup, method, target, strconv.FormatBool(failed)).Inc()
+	canaryErrorRate.WithLabelValues(backendGroup, method, target).Set(errorRate)
+}
+
+func RecordCodeHashLookup(source string) {
+	codeHashLookupsTotal.WithLabelValues(source).Inc()
+}
+
+func RecordCacheOnlyOutageResponse(backendGroup, method string, cached bool) {
+	cacheOnlyOutageResponsesTotal.WithLabelValues(backendGroup, method, strconv.FormatBool(cached)).Inc()
+}
+
+func RecordBackoffCacheResponse(backendGroup, method string, cached bool) {
+	backoffCacheResponsesTotal.WithLabelValues(backendGroup, method, strconv.FormatBool(cached)).Inc()
+}
+
+func RecordInvalidParams(method string, forwarded bool) {
+	invalidParamsTotal.WithLabelValues(method, strconv.FormatBool(forwarded)).Inc()
+}
+
+func RecordStreamedResponse(backendName string) {
+	streamedResponsesTotal.WithLabelValues(backendName).Inc()
+}
+
+func RecordSendRawTxDedupHit(backendGroup string) {
+	sendRawTxDedupHitsTotal.WithLabelValues(backendGroup).Inc()
+}
+
+func RecordBalanceSnapshotHit(backendGroup string) {
+	balanceSnapshotHitsTotal.WithLabelValues(backendGroup).Inc()
+}
+
 func RecordBatchSize(size int) {
 	batchSizeHistogram.Observe(float64(size))
 }
@@ -571,6 +1070,18 @@ func RecordGroupConsensusFilteredCount(group *BackendGroup, count int) {
 	consensusGroupFilteredCount.WithLabelValues(group.Name).Set(float64(count))
 }
 
+func RecordBackendHeadSkew(b *Backend, skew time.Duration) {
+	backendHeadSkew.WithLabelValues(b.Name).Set(skew.Seconds())
+}
+
+func RecordGroupConsensusStandbyHead(group *BackendGroup, degraded bool) {
+	consensusGroupStandbyHead.WithLabelValues(group.Name).Set(boolToFloat64(degraded))
+}
+
+func RecordGroupConsensusQuorum(group *BackendGroup, hasQuorum bool) {
+	consensusGroupQuorum.WithLabelValues(group.Name).Set(boolToFloat64(hasQuorum))
+}
+
 func RecordGroupTotalCount(group *BackendGroup, count int) {
 	consensusGroupTotalCount.WithLabelValues(group.Name).Set(float64(count))
 }
@@ -595,6 +1106,11 @@ func RecordConsensusBackendBanned(b *Backend, banned bool) {
 	consensusBannedBackends.WithLabelValues(b.Name).Set(boolToFloat64(banned))
 }
 
+func RecordConsensusBackendBanBackoff(b *Backend, banCount int, period time.Duration) {
+	consensusBanCountBackend.WithLabelValues(b.Name).Set(float64(banCount))
+	consensusBanBackoffBackend.WithLabelValues(b.Name).Set(period.Seconds())
+}
+
 func RecordHealthyCandidates(b *BackendGroup, candidates int) {
 	healthyPrimaryCandidates.WithLabelValues(b.Name).Set(float64(candidates))
 }
@@ -637,6 +1153,70 @@ func RecordBackendGroupMulticallCompletion(bg *BackendGroup, backendName string,
 	backendGroupMulticallCompletionCounter.WithLabelValues(bg.Name, backendName, error).Inc()
 }
 
+func RecordBackendGroupSpillover(bg *BackendGroup, routedTo string) {
+	backendGroupSpilloverRequestsTotal.WithLabelValues(bg.Name, routedTo).Inc()
+}
+
+func RecordDriftSample(bg *BackendGroup, diverged bool) {
+	backendGroupDriftSamplesTotal.WithLabelValues(bg.Name, strconv.FormatBool(diverged)).Inc()
+}
+
+func RecordBackendDrift(bg *BackendGroup, b *Backend, diverged bool) {
+	backendResponseDrift.WithLabelValues(bg.Name, b.Name).Set(boolToFloat64(diverged))
+}
+
+func RecordErrorMapped(code int) {
+	rpcErrorsMappedTotal.WithLabelValues(strconv.Itoa(code)).Inc()
+}
+
+func RecordParamRouted(method, backendGroup string) {
+	paramRoutedRequestsTotal.WithLabelValues(method, backendGroup).Inc()
+}
+
+func RecordReplicaRouted(method, backendGroup string) {
+	replicaRoutedRequestsTotal.WithLabelValues(method, backendGroup).Inc()
+}
+
+func RecordGroupOverride(backendGroup string) {
+	groupOverridesTotal.WithLabelValues(backendGroup).Inc()
+}
+
+func RecordIPConcurrencyRejection(clientIP string) {
+	ipConcurrencyRejectionsTotal.WithLabelValues(clientIP).Inc()
+}
+
+func RecordConfigReload(success bool) {
+	configReloadsTotal.WithLabelValues(strconv.FormatBool(success)).Inc()
+}
+
+func RecordConfigReloadCoalesced() {
+	configReloadsCoalescedTotal.Inc()
+}
+
+func RecordCoalescedRequest(backendGroup string, method string) {
+	coalescedRequestsTotal.WithLabelValues(backendGroup, method).Inc()
+}
+
+func RecordFrontendRateLimitTake(limiter string, allowed bool) {
+	frontendRateLimitTakesTotal.WithLabelValues(limiter, strconv.FormatBool(allowed)).Inc()
+}
+
+func RecordFrontendRateLimitAvailableTokens(limiter string, tokens int64) {
+	frontendRateLimitAvailableTokens.WithLabelValues(limiter).Set(float64(tokens))
+}
+
+func RecordFrontendRateLimitTakeError(limiter string) {
+	frontendRateLimitTakeErrors.WithLabelValues(limiter).Inc()
+}
+
+func RecordSpending(auth string, cost int64, charged bool) {
+	if charged {
+		apiKeySpendingTotal.WithLabelValues(auth).Add(float64(cost))
+	} else {
+		spendingLimitRejectionsTotal.WithLabelValues(auth).Inc()
+	}
+}
+
 func boolToFloat64(b bool) float64 {
 	if b {
 		return 1
@@ -0,0 +1,138 @@
+package proxyd
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+)
+
+const defaultCacheOnlyMaxStale = time.Hour
+
+// outageCache keeps the last response to each cacheable read served by a
+// backend group for up to maxStale, regardless of cache TTLs, to answer
+// them while none of its backends is healthy.
+type outageCache struct {
+	cache         Cache
+	backendGroup  string
+	maxStale      time.Duration
+	normalizeKeys bool
+}
+
+type outageCacheEntry struct {
+	StoredAt int64           `json:"stored_at"`
+	Result   json.RawMessage `json:"result"`
+}
+
+func newOutageCache(cache Cache, backendGroup string, maxStale time.Duration, normalizeKeys bool) *outageCache {
+	return &outageCache{
+		cache:         cache,
+		backendGroup:  backendGroup,
+		maxStale:      maxStale,
+		normalizeKeys: normalizeKeys,
+	}
+}
+
+// key is under the cache key prefix of the method, so that flushing the
+// method flushes its outage copies too.
+func (c *outageCache) key(req *RPCReq) string {
+	return strings.Join([]string{cacheKeyPrefix, req.Method, "outage", c.backendGroup, paramsSignature(req, c.normalizeKeys)}, ":")
+}
+
+func (c *outageCache) Put(ctx context.Context, req *RPCReq, res *RPCRes) {
+	value := mustMarshalJSON(&outageCacheEntry{
+		StoredAt: time.Now().UnixNano(),
+		Result:   mustMarshalJSON(res.Result),
+	})
+	if err := putCacheWithTTL(ctx, c.cache, c.key(req), string(value), c.maxStale); err != nil {
+		log.Warn("error putting outage cache entry", "method", req.Method, "backend_group", c.backendGroup, "err", err)
+	}
+}
+
+// Get returns the last response to req, or nil if there is none stored
+// within maxStale.
+func (c *outageCache) Get(ctx context.Context, req *RPCReq) *RPCRes {
+	val, err := c.cache.Get(ctx, c.key(req))
+	if err != nil || val == "" {
+		return nil
+	}
+	var entry outageCacheEntry
+	if err := json.Unmarshal([]byte(val), &entry); err != nil {
+		return nil
+	}
+	if time.Since(time.Unix(0, entry.StoredAt)) > c.maxStale {
+		return nil
+	}
+	var result interface{}
+	if err := unmarshalJSONNumbers(entry.Result, &result); err != nil {
+		return nil
+	}
+	return &RPCRes{
+		JSONRPC: req.JSONRPC,
+		Result:  result,
+		ID:      req.ID,
+	}
+}
+
+// serveFromOutageCache answers reqs from the outage cache of bg, or with
+// ErrNoBackends, and returns whether any was answered from the cache.
+func serveFromOutageCache(ctx context.Context, bg *BackendGroup, reqs []batchElem, responses []*RPCRes) bool {
+	cached := false
+	for _, req := range reqs {
+		if res := bg.outageCache.Get(ctx, req.Req); res != nil {
+			RecordCacheOnlyOutageResponse(bg.Name, req.Req.Method, true)
+			responses[req.Index] = res
+			cached = true
+			continue
+		}
+		RecordCacheOnlyOutageResponse(bg.Name, req.Req.Method, false)
+		responses[req.Index] = NewRPCErrorRes(req.Req.ID, ErrNoBackends)
+	}
+	return cached
+}
+
+// hasHealthyBackends returns whether any backend of the group may serve
+// requests.
+func (bg *BackendGroup) hasHealthyBackends() bool {
+	backends := bg.Backends
+	if bg.Consensus != nil {
+		backends = bg.Consensus.GetConsensusGroup()
+	}
+	for _, be := range backends {
+		if be.IsHealthy() && !be.IsBackingOff() {
+			return true
+		}
+	}
+	return false
+}
+
+// preferredBackendBackingOff returns whether the first backend of the group,
+// the one it prefers unless weighted routing shuffles them, is backing off.
+func (bg *BackendGroup) preferredBackendBackingOff() bool {
+	return len(bg.Backends) > 0 && bg.Backends[0].IsBackingOff()
+}
+
+// serveFromBackoffCache answers the cacheable reads of reqs from the outage
+// cache of bg, and returns the others, to be forwarded, and whether any was
+// answered from the cache.
+func (s *Server) serveFromBackoffCache(ctx context.Context, bg *BackendGroup, reqs []batchElem, responses []*RPCRes) ([]batchElem, bool) {
+	cached := false
+	misses := make([]batchElem, 0, len(reqs))
+	for _, req := range reqs {
+		if !s.cache.IsCacheable(req.Req.Method) || s.isReorgCacheBypassed(req.Req.Method) {
+			misses = append(misses, req)
+			continue
+		}
+		if res := bg.outageCache.Get(ctx, req.Req); res != nil {
+			RecordBackoffCacheResponse(bg.Name, req.Req.Method, true)
+			responses[req.Index] = res
+			cached = true
+			continue
+		}
+		RecordBackoffCacheResponse(bg.Name, req.Req.Method, false)
+		misses = append(misses, req)
+	}
+	return misses, cached
+}
@@ -0,0 +1,56 @@
+package proxyd
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// backendTime measures the wall-clock time a request spends waiting on
+// backends. Calls overlapping in time, as in multicall, are counted once, so
+// that subtracting it from the request latency leaves proxyd's own overhead.
+type backendTime struct {
+	mu       sync.Mutex
+	inFlight int
+	since    time.Time
+	total    time.Duration
+}
+
+func withBackendTime(ctx context.Context) (context.Context, *backendTime) {
+	bt := &backendTime{}
+	return context.WithValue(ctx, ContextKeyBackendTime, bt), bt // nolint:staticcheck
+}
+
+// trackBackendTime starts counting backend time for the request of ctx, and
+// returns a function stopping it.
+func trackBackendTime(ctx context.Context) func() {
+	bt, ok := ctx.Value(ContextKeyBackendTime).(*backendTime)
+	if !ok {
+		return func() {}
+	}
+	bt.mu.Lock()
+	if bt.inFlight == 0 {
+		bt.since = time.Now()
+	}
+	bt.inFlight++
+	bt.mu.Unlock()
+
+	return func() {
+		bt.mu.Lock()
+		defer bt.mu.Unlock()
+		bt.inFlight--
+		if bt.inFlight == 0 {
+			bt.total += time.Since(bt.since)
+		}
+	}
+}
+
+// Elapsed returns the backend time so far, including calls still in flight.
+func (bt *backendTime) Elapsed() time.Duration {
+	bt.mu.Lock()
+	defer bt.mu.Unlock()
+	if bt.inFlight > 0 {
+		return bt.total + time.Since(bt.since)
+	}
+	return bt.total
+}
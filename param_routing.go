@@ -0,0 +1,141 @@
+package proxyd
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// paramRouter picks backend groups for calls based on their params. Routes
+// are checked in order and the first match wins; calls matching no route keep
+// the group of their method mapping.
+type paramRouter map[string][]paramRoute
+
+type paramRoute struct {
+	group    string
+	param    int
+	field    string
+	equals   map[string]bool
+	blockTag ParamRouteBlockTag
+}
+
+func newParamRouter(config map[string][]ParamRouteConfig) (paramRouter, error) {
+	router := make(paramRouter, len(config))
+	for method, routes := range config {
+		for i, route := range routes {
+			if route.BackendGroup == "" {
+				return nil, fmt.Errorf("param route %d of %s must set a backend_group", i, method)
+			}
+			if route.Param < 0 {
+				return nil, fmt.Errorf("param route %d of %s must have a param >= 0", i, method)
+			}
+			switch route.BlockTag {
+			case "", ParamRouteBlockTagHistorical, ParamRouteBlockTagRecent:
+			default:
+				return nil, fmt.Errorf("invalid block_tag %s in param route %d of %s", route.BlockTag, i, method)
+			}
+			if len(route.Equals) == 0 && route.BlockTag == "" {
+				return nil, fmt.Errorf("param route %d of %s must set equals or block_tag", i, method)
+			}
+
+			var equals map[string]bool
+			if len(route.Equals) > 0 {
+				equals = make(map[string]bool, len(route.Equals))
+				for _, v := range route.Equals {
+					equals[strings.ToLower(v)] = true
+				}
+			}
+			router[method] = append(router[method], paramRoute{
+				group:    route.BackendGroup,
+				param:    route.Param,
+				field:    route.Field,
+				equals:   equals,
+				blockTag: route.BlockTag,
+			})
+		}
+	}
+	return router, nil
+}
+
+// Route returns the backend group of the first route matching req, or an
+// empty string if none does.
+func (r paramRouter) Route(req *RPCReq) string {
+	routes := r[req.Method]
+	if len(routes) == 0 {
+		return ""
+	}
+	var params []json.RawMessage
+	if err := json.Unmarshal(req.Params, &params); err != nil {
+		return ""
+	}
+	for _, route := range routes {
+		if route.matches(params) {
+			return route.group
+		}
+	}
+	return ""
+}
+
+func (p *paramRoute) matches(params []json.RawMessage) bool {
+	var raw json.RawMessage
+	if p.param < len(params) {
+		raw = params[p.param]
+	}
+	if p.field != "" && raw != nil {
+		var obj map[string]json.RawMessage
+		if err := json.Unmarshal(raw, &obj); err != nil {
+			return false
+		}
+		raw = obj[p.field]
+	}
+
+	if p.equals != nil {
+		var s string
+		if err := json.Unmarshal(raw, &s); err != nil || !p.equals[strings.ToLower(s)] {
+			return false
+		}
+	}
+	if p.blockTag != "" && blockTagClass(raw) != p.blockTag {
+		return false
+	}
+	return true
+}
+
+// blockTagClass classifies a block param, either a tag, a number or an
+// EIP-1898 object. It returns an empty string for anything else.
+func blockTagClass(raw json.RawMessage) ParamRouteBlockTag {
+	if raw == nil || string(raw) == "null" {
+		return ParamRouteBlockTagRecent
+	}
+
+	var tag string
+	if err := json.Unmarshal(raw, &tag); err == nil {
+		switch tag {
+		case "latest", "pending", "safe", "finalized":
+			return ParamRouteBlockTagRecent
+		case "earliest":
+			return ParamRouteBlockTagHistorical
+		}
+		if _, err := hexutil.DecodeUint64(tag); err == nil {
+			return ParamRouteBlockTagHistorical
+		}
+		return ""
+	}
+
+	var obj struct {
+		BlockHash   json.RawMessage `json:"blockHash"`
+		BlockNumber json.RawMessage `json:"blockNumber"`
+	}
+	if err := json.Unmarshal(raw, &obj); err != nil {
+		return ""
+	}
+	if obj.BlockHash != nil {
+		return ParamRouteBlockTagHistorical
+	}
+	if obj.BlockNumber != nil {
+		return blockTagClass(obj.BlockNumber)
+	}
+	return ""
+}
@@ -0,0 +1,115 @@
+package proxyd
+
+import (
+	"container/heap"
+	"context"
+	"sync"
+	"time"
+)
+
+// PrioritySemaphore limits concurrency like a semaphore, but hands freed
+// slots to the highest priority waiter first. Waiters of equal priority are
+// served in arrival order.
+type PrioritySemaphore struct {
+	mu      sync.Mutex
+	size    int64
+	cur     int64
+	seq     uint64
+	waiters priorityWaiters
+}
+
+type priorityWaiter struct {
+	priority int
+	seq      uint64
+	index    int
+	ready    chan struct{}
+}
+
+func NewPrioritySemaphore(size int64) *PrioritySemaphore {
+	return &PrioritySemaphore{size: size}
+}
+
+// Acquire blocks until a slot is available for the given priority or ctx is
+// done.
+func (s *PrioritySemaphore) Acquire(ctx context.Context, priority int) error {
+	start := time.Now()
+	defer func() {
+		RecordPriorityWait(priority, time.Since(start))
+	}()
+
+	s.mu.Lock()
+	if s.cur < s.size && len(s.waiters) == 0 {
+		s.cur++
+		s.mu.Unlock()
+		return nil
+	}
+	w := &priorityWaiter{
+		priority: priority,
+		seq:      s.seq,
+		ready:    make(chan struct{}),
+	}
+	s.seq++
+	heap.Push(&s.waiters, w)
+	s.mu.Unlock()
+
+	select {
+	case <-w.ready:
+		return nil
+	case <-ctx.Done():
+		s.mu.Lock()
+		select {
+		case <-w.ready:
+			// acquired concurrently with the cancellation, hand the slot on
+			s.mu.Unlock()
+			s.Release()
+		default:
+			heap.Remove(&s.waiters, w.index)
+			s.mu.Unlock()
+		}
+		return ctx.Err()
+	}
+}
+
+// Release frees a slot, passing it to the highest priority waiter if any.
+func (s *PrioritySemaphore) Release() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.waiters) > 0 {
+		w := heap.Pop(&s.waiters).(*priorityWaiter)
+		close(w.ready)
+		return
+	}
+	s.cur--
+}
+
+type priorityWaiters []*priorityWaiter
+
+func (pw priorityWaiters) Len() int { return len(pw) }
+
+func (pw priorityWaiters) Less(i, j int) bool {
+	if pw[i].priority != pw[j].priority {
+		return pw[i].priority > pw[j].priority
+	}
+	return pw[i].seq < pw[j].seq
+}
+
+func (pw priorityWaiters) Swap(i, j int) {
+	pw[i], pw[j] = pw[j], pw[i]
+	pw[i].index = i
+	pw[j].index = j
+}
+
+func (pw *priorityWaiters) Push(x any) {
+	w := x.(*priorityWaiter)
+	w.index = len(*pw)
+	*pw = append(*pw, w)
+}
+
+func (pw *priorityWaiters) Pop() any {
+	old := *pw
+	n := len(old)
+	w := old[n-1]
+	old[n-1] = nil
+	*pw = old[:n-1]
+	return w
+}
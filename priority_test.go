@@ -0,0 +1,41 @@
+package proxyd
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrioritySemaphore(t *testing.T) {
+	sem := NewPrioritySemaphore(1)
+	ctx := context.Background()
+	require.NoError(t, sem.Acquire(ctx, 0))
+
+	order := make(chan int, 3)
+	acquire := func(priority int) {
+		go func() {
+			require.NoError(t, sem.Acquire(ctx, priority))
+			order <- priority
+			sem.Release()
+		}()
+		time.Sleep(20 * time.Millisecond)
+	}
+	acquire(1)
+	acquire(5)
+	acquire(3)
+
+	// a cancelled waiter gives up its place in the queue
+	cancelCtx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	require.ErrorIs(t, sem.Acquire(cancelCtx, 10), context.DeadlineExceeded)
+
+	sem.Release()
+	require.Equal(t, 5, <-order)
+	require.Equal(t, 3, <-order)
+	require.Equal(t, 1, <-order)
+
+	// every slot is released again
+	require.NoError(t, sem.Acquire(ctx, 0))
+}
@@ -0,0 +1,178 @@
+package proxyd
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+)
+
+const (
+	proxyProtocolHeaderTimeout = 5 * time.Second
+	// proxyProtocolV1MaxLen is the longest v1 header, CRLF included.
+	proxyProtocolV1MaxLen = 107
+)
+
+var proxyProtocolV2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+// proxyProtocolListener accepts connections starting with a PROXY protocol v1
+// or v2 header, as sent by L4 load balancers, and reports the client address
+// it carries as their remote address. Connections without a valid header are
+// closed.
+type proxyProtocolListener struct {
+	net.Listener
+}
+
+func (l *proxyProtocolListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return &proxyProtocolConn{Conn: conn, r: bufio.NewReader(conn)}, nil
+}
+
+// proxyProtocolConn reads the PROXY header lazily, so that a slow client
+// doesn't hold up Accept.
+type proxyProtocolConn struct {
+	net.Conn
+	r *bufio.Reader
+
+	once    sync.Once
+	srcAddr net.Addr
+	err     error
+}
+
+func (c *proxyProtocolConn) readHeader() {
+	c.once.Do(func() {
+		_ = c.Conn.SetReadDeadline(time.Now().Add(proxyProtocolHeaderTimeout))
+		c.srcAddr, c.err = readProxyProtocolHeader(c.r)
+		_ = c.Conn.SetReadDeadline(time.Time{})
+		if c.err != nil {
+			log.Warn("invalid proxy protocol header", "remote_addr", c.Conn.RemoteAddr(), "err", c.err)
+			_ = c.Conn.Close()
+		}
+	})
+}
+
+func (c *proxyProtocolConn) Read(b []byte) (int, error) {
+	c.readHeader()
+	if c.err != nil {
+		return 0, c.err
+	}
+	return c.r.Read(b)
+}
+
+// RemoteAddr returns the client address of the PROXY header, or the address
+// of the peer for headers that carry none, such as load balancer health checks.
+func (c *proxyProtocolConn) RemoteAddr() net.Addr {
+	c.readHeader()
+	if c.srcAddr != nil {
+		return c.srcAddr
+	}
+	return c.Conn.RemoteAddr()
+}
+
+// readProxyProtocolHeader consumes a v1 or v2 header from r, returning the
+// source address it carries, if any.
+func readProxyProtocolHeader(r *bufio.Reader) (net.Addr, error) {
+	sig, err := r.Peek(len(proxyProtocolV2Signature))
+	if err != nil {
+		return nil, fmt.Errorf("error reading header: %w", err)
+	}
+	if bytes.Equal(sig, proxyProtocolV2Signature) {
+		return readProxyProtocolV2(r)
+	}
+	if bytes.HasPrefix(sig, []byte("PROXY ")) {
+		return readProxyProtocolV1(r)
+	}
+	return nil, errors.New("missing header")
+}
+
+func readProxyProtocolV1(r *bufio.Reader) (net.Addr, error) {
+	var line []byte
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return nil, fmt.Errorf("error reading v1 header: %w", err)
+		}
+		line = append(line, b)
+		if b == '\n' {
+			break
+		}
+		if len(line) >= proxyProtocolV1MaxLen {
+			return nil, errors.New("v1 header too long")
+		}
+	}
+	if !bytes.HasSuffix(line, []byte("\r\n")) {
+		return nil, errors.New("v1 header not terminated by CRLF")
+	}
+
+	fields := strings.Split(string(line[:len(line)-2]), " ")
+	if len(fields) >= 2 && fields[1] == "UNKNOWN" {
+		return nil, nil
+	}
+	if len(fields) != 6 || (fields[1] != "TCP4" && fields[1] != "TCP6") {
+		return nil, fmt.Errorf("invalid v1 header %q", line)
+	}
+	ip := net.ParseIP(fields[2])
+	if ip == nil || (fields[1] == "TCP4") != (ip.To4() != nil) {
+		return nil, fmt.Errorf("invalid v1 source address %q", fields[2])
+	}
+	port, err := strconv.ParseUint(fields[4], 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("invalid v1 source port %q", fields[4])
+	}
+	return &net.TCPAddr{IP: ip, Port: int(port)}, nil
+}
+
+func readProxyProtocolV2(r *bufio.Reader) (net.Addr, error) {
+	hdr := make([]byte, 16)
+	if _, err := io.ReadFull(r, hdr); err != nil {
+		return nil, fmt.Errorf("error reading v2 header: %w", err)
+	}
+	if hdr[12]>>4 != 2 {
+		return nil, fmt.Errorf("unsupported v2 version %d", hdr[12]>>4)
+	}
+	cmd := hdr[12] & 0x0f
+	family := hdr[13] >> 4
+	transport := hdr[13] & 0x0f
+	body := make([]byte, binary.BigEndian.Uint16(hdr[14:16]))
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, fmt.Errorf("error reading v2 addresses: %w", err)
+	}
+
+	switch cmd {
+	case 0x0: // LOCAL, sent by the load balancer itself
+		return nil, nil
+	case 0x1: // PROXY
+	default:
+		return nil, fmt.Errorf("unsupported v2 command %d", cmd)
+	}
+	// Only TCP over IPv4 and IPv6 carries a usable client address.
+	if transport != 0x1 {
+		return nil, nil
+	}
+	switch family {
+	case 0x1:
+		if len(body) < 12 {
+			return nil, errors.New("v2 ipv4 addresses too short")
+		}
+		return &net.TCPAddr{IP: net.IP(body[0:4]), Port: int(binary.BigEndian.Uint16(body[8:10]))}, nil
+	case 0x2:
+		if len(body) < 36 {
+			return nil, errors.New("v2 ipv6 addresses too short")
+		}
+		return &net.TCPAddr{IP: net.IP(body[0:16]), Port: int(binary.BigEndian.Uint16(body[32:34]))}, nil
+	default:
+		return nil, nil
+	}
+}
@@ -6,8 +6,11 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"net"
 	"net/http"
+	"net/url"
 	"os"
+	"strconv"
 	"time"
 
 	"github.com/ethereum/go-ethereum/common/math"
@@ -17,9 +20,15 @@ import (
 	"golang.org/x/sync/semaphore"
 )
 
+// SetLogLevel sets the default log level. Modules overridden with
+// SetModuleLogLevel keep their own level.
 func SetLogLevel(logLevel slog.Leveler) {
-	log.SetDefault(log.NewLogger(slog.NewJSONHandler(
-		os.Stdout, &slog.HandlerOptions{Level: logLevel})))
+	logLevels.setDefault(logLevel.Level())
+	log.SetDefault(log.NewLogger(&moduleLevelHandler{
+		inner: slog.NewJSONHandler(
+			os.Stdout, &slog.HandlerOptions{Level: log.LevelTrace}),
+		levels: logLevels,
+	}))
 }
 
 func Start(config *Config) (*Server, func(), error) {
@@ -92,15 +101,39 @@ func Start(config *Config) (*Server, func(), error) {
 	// is to clone these errors on every invocation. This is inefficient.
 	// We'd also have to make sure that errors.Is and errors.As continue
 	// to function properly on the cloned errors.
+	resetRejectionErrors()
 	if config.RateLimit.ErrorMessage != "" {
 		ErrOverRateLimit.Message = config.RateLimit.ErrorMessage
 	}
+	if err := configureRejectionErrors(config.Rejections); err != nil {
+		return nil, nil, err
+	}
 	if config.WhitelistErrorMessage != "" {
 		ErrMethodNotWhitelisted.Message = config.WhitelistErrorMessage
 	}
 	if config.BatchConfig.ErrorMessage != "" {
 		ErrTooManyBatchRequests.Message = config.BatchConfig.ErrorMessage
 	}
+	switch config.BatchConfig.ErrorStyle {
+	case "", BatchErrorStyleSingle, BatchErrorStyleArray:
+	default:
+		return nil, nil, fmt.Errorf("invalid batch error_style: %s", config.BatchConfig.ErrorStyle)
+	}
+	switch config.BatchConfig.MethodLimitAction {
+	case "", BatchMethodLimitActionEntries, BatchMethodLimitActionBatch:
+	default:
+		return nil, nil, fmt.Errorf("invalid batch method_limit_action: %s", config.BatchConfig.MethodLimitAction)
+	}
+	for method, limit := range config.BatchConfig.MethodLimits {
+		if limit <= 0 {
+			return nil, nil, fmt.Errorf("batch method limit for %s must be > 0", method)
+		}
+	}
+	for method, limit := range config.MaxParamsLength {
+		if limit < 0 {
+			return nil, nil, fmt.Errorf("max params length for %s must be >= 0", method)
+		}
+	}
 
 	if config.SenderRateLimit.Enabled {
 		if config.SenderRateLimit.Limit <= 0 {
@@ -123,6 +156,16 @@ func Start(config *Config) (*Server, func(), error) {
 	}
 	consensusRequestSemaphore := semaphore.NewWeighted(maxConcurrentConsensusRPCs)
 
+	var prioritySemaphore *PrioritySemaphore
+	if config.Priority.Enabled() {
+		prioritySemaphore = NewPrioritySemaphore(maxConcurrentRPCs)
+	}
+
+	if config.Server.BodyBufferSize < 0 || config.Server.BodyBufferMaxSize < 0 {
+		return nil, nil, errors.New("body_buffer_size and body_buffer_max_size must be >= 0")
+	}
+	bodyBuffers := newBufferPool(config.Server.BodyBufferSize, config.Server.BodyBufferMaxSize)
+
 	backendNames := make([]string, 0)
 	backendsByName := make(map[string]*Backend)
 	for name, cfg := range config.Backends {
@@ -162,9 +205,33 @@ func Start(config *Config) (*Server, func(), error) {
 		if config.BackendOptions.MaxErrorRateThreshold > 0 {
 			opts = append(opts, WithMaxErrorRateThreshold(config.BackendOptions.MaxErrorRateThreshold))
 		}
+		if config.BackendOptions.MaxRetryAfter > 0 {
+			opts = append(opts, WithMaxRetryAfter(time.Duration(config.BackendOptions.MaxRetryAfter)))
+		}
+		if config.BackendOptions.RewriteRequestIDs {
+			opts = append(opts, WithRequestIDRewriting())
+		}
+		if cfg.MaxRPS < 0 || cfg.MaxRPSBurst < 0 {
+			return nil, nil, fmt.Errorf("max_rps and max_rps_burst of backend %s must be >= 0", name)
+		}
 		if cfg.MaxRPS != 0 {
 			opts = append(opts, WithMaxRPS(cfg.MaxRPS))
 		}
+		if (cfg.MaxRPSBurst != 0 || cfg.MaxRPSWait) && !cfg.EnforceMaxRPS {
+			return nil, nil, fmt.Errorf("max_rps_burst and max_rps_wait of backend %s require enforce_max_rps", name)
+		}
+		if cfg.EnforceMaxRPS {
+			if cfg.MaxRPS == 0 {
+				return nil, nil, fmt.Errorf("enforce_max_rps of backend %s requires max_rps", name)
+			}
+			opts = append(opts, WithEnforceMaxRPS())
+		}
+		if cfg.MaxRPSBurst != 0 {
+			opts = append(opts, WithMaxRPSBurst(cfg.MaxRPSBurst))
+		}
+		if cfg.MaxRPSWait {
+			opts = append(opts, WithMaxRPSWait())
+		}
 		if cfg.MaxWSConns != 0 {
 			opts = append(opts, WithMaxWSConns(cfg.MaxWSConns))
 		}
@@ -186,6 +253,16 @@ func Start(config *Config) (*Server, func(), error) {
 			headers[headerName] = headerValue
 		}
 		opts = append(opts, WithHeaders(headers))
+		if cfg.HostHeader != "" {
+			opts = append(opts, WithHostHeader(cfg.HostHeader))
+		}
+		userAgent := config.BackendOptions.UserAgent
+		if cfg.UserAgent != "" {
+			userAgent = cfg.UserAgent
+		}
+		if userAgent != "" {
+			opts = append(opts, WithUserAgent(userAgent))
+		}
 
 		tlsConfig, err := configureBackendTLS(cfg)
 		if err != nil {
@@ -195,6 +272,18 @@ func Start(config *Config) (*Server, func(), error) {
 			log.Info("using custom TLS config for backend", "name", name)
 			opts = append(opts, WithTLSConfig(tlsConfig))
 		}
+		proxyConfig := config.BackendOptions.Proxy
+		if cfg.Proxy.URL != "" {
+			proxyConfig = cfg.Proxy
+		}
+		if proxyConfig.URL != "" {
+			proxyURL, err := configureBackendProxy(proxyConfig)
+			if err != nil {
+				return nil, nil, fmt.Errorf("invalid proxy for backend %s: %w", name, err)
+			}
+			log.Info("using proxy for backend", "name", name, "proxy", proxyURL.Redacted())
+			opts = append(opts, WithProxy(proxyURL))
+		}
 		if cfg.StripTrailingXFF {
 			opts = append(opts, WithStrippedTrailingXFF())
 		}
@@ -202,6 +291,9 @@ func Start(config *Config) (*Server, func(), error) {
 		opts = append(opts, WithConsensusSkipPeerCountCheck(cfg.ConsensusSkipPeerCountCheck))
 		opts = append(opts, WithConsensusForcedCandidate(cfg.ConsensusForcedCandidate))
 		opts = append(opts, WithWeight(cfg.Weight))
+		if len(cfg.ExcludedMethods) > 0 {
+			opts = append(opts, WithExcludedMethods(cfg.ExcludedMethods))
+		}
 
 		receiptsTarget, err := ReadFromEnvOrConfig(cfg.ConsensusReceiptsTarget)
 		if err != nil {
@@ -213,6 +305,16 @@ func Start(config *Config) (*Server, func(), error) {
 		}
 		opts = append(opts, WithConsensusReceiptTarget(receiptsTarget))
 
+		jsonRPCMode, err := validateJSONRPCMode(cfg.JSONRPCMode)
+		if err != nil {
+			return nil, nil, fmt.Errorf("backend %s: %w", name, err)
+		}
+		opts = append(opts, WithJSONRPCMode(jsonRPCMode, cfg.RejectMissingJSONRPC))
+		opts = append(opts, WithBodyBufferPool(bodyBuffers))
+		if prioritySemaphore != nil {
+			opts = append(opts, WithPrioritySemaphore(prioritySemaphore))
+		}
+
 		back := NewBackend(name, rpcURL, wsURL, rpcRequestSemaphore, consensusRequestSemaphore, opts...)
 		backendNames = append(backendNames, name)
 		backendsByName[name] = back
@@ -223,6 +325,11 @@ func Start(config *Config) (*Server, func(), error) {
 			"ws_url", wsURL)
 	}
 
+	invalidParams, err := newInvalidParamsPolicy(config.InvalidParams)
+	if err != nil {
+		return nil, nil, err
+	}
+
 	backendGroups := make(map[string]*BackendGroup)
 	for bgName, bg := range config.BackendGroups {
 		backends := make([]*Backend, 0)
@@ -269,14 +376,194 @@ func Start(config *Config) (*Server, func(), error) {
 			FallbackBackends:       fallbackBackends,
 			routingStrategy:        bg.RoutingStrategy,
 			multicallRPCErrorCheck: bg.MulticallRPCErrorCheck,
+			spilloverGroup:         bg.SpilloverGroup,
+			spilloverPercent:       bg.SpilloverPercent,
+			pinNonceReads:          bg.PinNonceReads,
+			queueTimeout:           time.Duration(bg.QueueTimeout),
+			sloTimeout:             time.Duration(bg.SLOTimeout),
+		}
+		selectorName := bg.BackendSelector
+		if selectorName == "" {
+			selectorName = defaultBackendSelector(bg)
+		}
+		selector, ok := lookupBackendSelector(selectorName)
+		if !ok {
+			return nil, nil, fmt.Errorf("undefined backend selector %s for backend group %s", selectorName, bgName)
+		}
+		backendGroups[bgName].selector = selector
+		if bg.PreferWarmConnections {
+			tie, ok := warmConnTie(selectorName)
+			if !ok {
+				return nil, nil, fmt.Errorf("prefer_warm_connections of backend group %s requires the %s or %s backend selector", bgName, RandomBackendSelector, WeightedBackendSelector)
+			}
+			backendGroups[bgName].warmConnTie = tie
+			for _, be := range backends {
+				be.TrackIdleConns()
+			}
+		}
+		if len(bg.MethodConcurrencyLimits) > 0 {
+			for method, limit := range bg.MethodConcurrencyLimits {
+				if limit <= 0 {
+					return nil, nil, fmt.Errorf("method_concurrency_limits of %s for backend group %s must be > 0", method, bgName)
+				}
+			}
+			backendGroups[bgName].methodLimiter = newMethodLimiter(bgName, bg.MethodConcurrencyLimits, bg.MethodConcurrencyReject)
+		}
+		if bg.Canary != nil {
+			canaryBackend := backendsByName[bg.Canary.Backend]
+			if canaryBackend == nil {
+				return nil, nil, fmt.Errorf("undefined canary backend %s for backend group %s", bg.Canary.Backend, bgName)
+			}
+			if bg.Canary.Method == "" {
+				return nil, nil, fmt.Errorf("canary of backend group %s has no method", bgName)
+			}
+			if bg.Canary.Percent < 0 || bg.Canary.Percent > 100 {
+				return nil, nil, fmt.Errorf("canary percent for backend group %s must be between 0 and 100", bgName)
+			}
+			if bg.Canary.Window < 0 {
+				return nil, nil, fmt.Errorf("canary window for backend group %s must be >= 0", bgName)
+			}
+			backendGroups[bgName].canary = newCanary(bgName, bg.Canary.Method, canaryBackend, bg.Canary.Percent, time.Duration(bg.Canary.Window))
+		}
+		if len(bg.AffinityMethods) > 0 {
+			ttls := make(map[string]time.Duration, len(bg.AffinityMethods))
+			for method, ttl := range bg.AffinityMethods {
+				if ttl <= 0 {
+					return nil, nil, fmt.Errorf("affinity ttl of %s for backend group %s must be > 0", method, bgName)
+				}
+				ttls[method] = time.Duration(ttl)
+			}
+			backendGroups[bgName].affinity = newBackendAffinity(ttls)
+		}
+		if bg.BatchFanoutConcurrency < 0 {
+			return nil, nil, fmt.Errorf("batch_fanout_concurrency for backend group %s must be >= 0", bgName)
+		}
+		backendGroups[bgName].batchFanoutConcurrency = bg.BatchFanoutConcurrency
+		backendGroups[bgName].invalidParams = invalidParams
+		if bg.NetVersion != "" {
+			if _, err := strconv.ParseUint(bg.NetVersion, 10, 64); err != nil {
+				return nil, nil, fmt.Errorf("net_version for backend group %s must be a decimal chain id", bgName)
+			}
+			backendGroups[bgName].netVersion = bg.NetVersion
+		}
+		if bg.CacheOnlyOnOutage {
+			if !config.Cache.Enabled {
+				return nil, nil, fmt.Errorf("cache_only_on_outage for backend group %s requires the cache to be enabled", bgName)
+			}
+			backendGroups[bgName].cacheOnlyOnOutage = true
+		}
+		if bg.CacheOnBackoff {
+			if !config.Cache.Enabled {
+				return nil, nil, fmt.Errorf("cache_on_backoff for backend group %s requires the cache to be enabled", bgName)
+			}
+			backendGroups[bgName].cacheOnBackoff = true
+		}
+		if bg.ConsensusMinBackends < 0 || bg.ConsensusMinBackends > len(bg.Backends) {
+			return nil, nil, fmt.Errorf("consensus_min_backends for backend group %s must be between 0 and its number of backends", bgName)
+		}
+		if bg.ConsensusMinFraction < 0 || bg.ConsensusMinFraction > 1 {
+			return nil, nil, fmt.Errorf("consensus_min_fraction for backend group %s must be between 0 and 1", bgName)
+		}
+		if bg.CacheOnlyMaxStale < 0 {
+			return nil, nil, fmt.Errorf("cache_only_max_stale for backend group %s must be >= 0", bgName)
+		}
+		if bg.SendRawTxDedupRedis {
+			if bg.SendRawTxDedupWindow <= 0 {
+				return nil, nil, fmt.Errorf("send_raw_tx_dedup_redis for backend group %s requires send_raw_tx_dedup_window", bgName)
+			}
+			if redisClient == nil {
+				return nil, nil, fmt.Errorf("send_raw_tx_dedup_redis for backend group %s requires a redis url", bgName)
+			}
+			keyPrefix := fmt.Sprintf("tx_dedup:%s:", bgName)
+			if prefix := config.Redis.ResolvedKeyPrefix(); prefix != "" {
+				keyPrefix = prefix + ":" + keyPrefix
+			}
+			// a claim is held for at most as long as a request is served
+			backendGroups[bgName].txDedup = newRedisTxDedup(time.Duration(bg.SendRawTxDedupWindow), requestTimeout(config), redisClient, keyPrefix)
+		} else if bg.SendRawTxDedupWindow > 0 {
+			backendGroups[bgName].txDedup = newTxDedup(time.Duration(bg.SendRawTxDedupWindow))
+		}
+		if bg.BalanceSnapshotMaxEntries < 0 {
+			return nil, nil, fmt.Errorf("balance_snapshot_max_entries for backend group %s must be >= 0", bgName)
+		}
+		if bg.BalanceSnapshot {
+			if bg.RoutingStrategy != ConsensusAwareRoutingStrategy {
+				return nil, nil, fmt.Errorf("balance_snapshot of backend group %s requires consensus aware routing to track the finalized block", bgName)
+			}
+			maxEntries := defaultBalanceSnapshotMaxEntries
+			if bg.BalanceSnapshotMaxEntries > 0 {
+				maxEntries = bg.BalanceSnapshotMaxEntries
+			}
+			backendGroups[bgName].balanceSnapshot = newBalanceSnapshot(maxEntries)
+		}
+		if len(bg.CoalesceMethods) > 0 {
+			backendGroups[bgName].coalescer = newRequestCoalescer(bgName, bg.CoalesceMethods, requestTimeout(config))
+		}
+		if bg.DriftSampleMethod != "" {
+			if bg.DriftSampleInterval < 0 {
+				return nil, nil, fmt.Errorf("drift_sample_interval for backend group %s must be >= 0", bgName)
+			}
+			backendGroups[bgName].DriftSampler = NewDriftSampler(
+				backendGroups[bgName],
+				bg.DriftSampleMethod,
+				bg.DriftSampleParams,
+				time.Duration(bg.DriftSampleInterval),
+			)
+		}
+	}
+
+	for bgName, bg := range config.BackendGroups {
+		if bg.SpilloverGroup == "" {
+			continue
+		}
+		if backendGroups[bg.SpilloverGroup] == nil {
+			return nil, nil, fmt.Errorf("undefined spillover group %s for backend group %s", bg.SpilloverGroup, bgName)
+		}
+		if bg.SpilloverGroup == bgName {
+			return nil, nil, fmt.Errorf("backend group %s cannot spill over to itself", bgName)
+		}
+		if bg.SpilloverPercent < 0 || bg.SpilloverPercent > 100 {
+			return nil, nil, fmt.Errorf("spillover_percent for backend group %s must be between 0 and 100", bgName)
+		}
+	}
+
+	for bgName, bg := range config.BackendGroups {
+		if bg.ReplicaGroup == "" {
+			continue
+		}
+		if backendGroups[bg.ReplicaGroup] == nil {
+			return nil, nil, fmt.Errorf("undefined replica group %s for backend group %s", bg.ReplicaGroup, bgName)
+		}
+		if bg.ReplicaGroup == bgName {
+			return nil, nil, fmt.Errorf("backend group %s cannot be its own replica group", bgName)
+		}
+		if len(bg.ReplicaMethods) > 0 && bg.RoutingStrategy != ConsensusAwareRoutingStrategy {
+			return nil, nil, fmt.Errorf("replica_methods of backend group %s requires consensus aware routing to track the head", bgName)
+		}
+		for _, method := range bg.ReplicaMethods {
+			if _, ok := pendingTagParamPositions[method]; !ok {
+				return nil, nil, fmt.Errorf("replica method %s of backend group %s has no block param", method, bgName)
+			}
 		}
+		backendGroups[bgName].replica = newReplicaRouter(bg.ReplicaGroup, bg.ReplicaMethods, bg.ReplicaAlwaysMethods, bg.ReplicaMinDepth)
+	}
+
+	wsBackendGroupName := config.WSBackendGroup
+	if config.Server.WSBackendGroup != "" {
+		if wsBackendGroupName != "" && wsBackendGroupName != config.Server.WSBackendGroup {
+			return nil, nil, fmt.Errorf(
+				"conflicting ws backend groups %s and %s",
+				wsBackendGroupName, config.Server.WSBackendGroup,
+			)
+		}
+		wsBackendGroupName = config.Server.WSBackendGroup
 	}
 
 	var wsBackendGroup *BackendGroup
-	if config.WSBackendGroup != "" {
-		wsBackendGroup = backendGroups[config.WSBackendGroup]
+	if wsBackendGroupName != "" {
+		wsBackendGroup = backendGroups[wsBackendGroupName]
 		if wsBackendGroup == nil {
-			return nil, nil, fmt.Errorf("ws backend group %s does not exist", config.WSBackendGroup)
+			return nil, nil, fmt.Errorf("ws backend group %s does not exist", wsBackendGroupName)
 		}
 	}
 
@@ -290,6 +577,18 @@ func Start(config *Config) (*Server, func(), error) {
 		}
 	}
 
+	paramRouter, err := newParamRouter(config.ParamRoutes)
+	if err != nil {
+		return nil, nil, err
+	}
+	for method, routes := range paramRouter {
+		for _, route := range routes {
+			if backendGroups[route.group] == nil {
+				return nil, nil, fmt.Errorf("undefined backend group %s in param routes of %s", route.group, method)
+			}
+		}
+	}
+
 	var resolvedAuth map[string]string
 
 	if config.Authentication != nil {
@@ -308,38 +607,86 @@ func Start(config *Config) (*Server, func(), error) {
 		rpcCache RPCCache
 	)
 	if config.Cache.Enabled {
+		ttl := defaultCacheTtl
+		if config.Cache.TTL != 0 {
+			ttl = time.Duration(config.Cache.TTL)
+		}
+		memoryCache, err := newConfiguredMemoryCache(config.Cache.Memory, ttl)
+		if err != nil {
+			return nil, nil, err
+		}
 		if redisClient == nil {
 			log.Warn("redis is not configured, using in-memory cache")
-			cache = newMemoryCache()
+			cache = memoryCache
 		} else {
-			ttl := defaultCacheTtl
-			if config.Cache.TTL != 0 {
-				ttl = time.Duration(config.Cache.TTL)
-			}
-			cache = newRedisCache(redisClient, redisReadClient, config.Redis.Namespace, ttl)
+			cache = newRedisCache(redisClient, redisReadClient, config.Redis.ResolvedKeyPrefix(), ttl)
 
 			if config.Redis.FallbackToMemory {
-				cache = newFallbackCache(cache, newMemoryCache())
+				cache = newFallbackCache(cache, memoryCache)
+			}
+		}
+		if err := validateDepthTTLConfig(config.Cache.DepthTTL); err != nil {
+			return nil, nil, fmt.Errorf("invalid depth_ttl: %w", err)
+		}
+		compressedCache, err := newCacheWithCompression(cache, config.Cache.Compression)
+		if err != nil {
+			return nil, nil, err
+		}
+		var cacheOpts []rpcCacheOpt
+		if config.Cache.CodeHashCalls.Enabled {
+			codeGroup := backendGroups[config.RPCMethodMappings["eth_getCode"]]
+			if codeGroup == nil {
+				return nil, nil, fmt.Errorf("code_hash_calls requires eth_getCode to be mapped to a backend group")
 			}
+			cacheOpts = append(cacheOpts, withCodeFetcher(func(ctx context.Context, req *RPCReq) (*RPCRes, error) {
+				res, _, err := codeGroup.Forward(ctx, []*RPCReq{req}, false)
+				if err != nil {
+					return nil, err
+				}
+				if len(res) != 1 {
+					return nil, ErrBackendUnexpectedJSONRPC
+				}
+				return res[0], nil
+			}))
+		}
+		var rpcCacheBackend Cache = compressedCache
+		if len(config.DomainCacheNamespaces) > 0 {
+			rpcCacheBackend, err = newNamespacedCache(compressedCache, config.DomainCacheNamespaces)
+			if err != nil {
+				return nil, nil, err
+			}
+		}
+		rpcCache = newRPCCache(rpcCacheBackend, config.Cache, cacheOpts...)
+
+		// the last responses are kept for both cache_only_on_outage and
+		// cache_on_backoff, and served by each only in its own condition
+		for bgName, bg := range config.BackendGroups {
+			if !bg.CacheOnlyOnOutage && !bg.CacheOnBackoff {
+				continue
+			}
+			maxStale := defaultCacheOnlyMaxStale
+			if bg.CacheOnlyMaxStale != 0 {
+				maxStale = time.Duration(bg.CacheOnlyMaxStale)
+			}
+			backendGroups[bgName].outageCache = newOutageCache(compressedCache, bgName, maxStale, config.Cache.NormalizeKeys)
 		}
-		rpcCache = newRPCCache(newCacheWithCompression(cache))
 	}
 
 	limiterFactory := func(dur time.Duration, max int, prefix string) FrontendRateLimiter {
 		if config.RateLimit.UseRedis {
-			limiter := NewRedisFrontendRateLimiter(redisClient, dur, max, prefix)
+			limiter := NewRedisFrontendRateLimiter(redisClient, config.Redis.ResolvedKeyPrefix(), dur, max, prefix)
 
 			if config.Redis.FallbackToMemory {
 				limiter = NewFallbackRateLimiter(
 					limiter,
-					NewMemoryFrontendRateLimit(dur, max),
+					NewMemoryFrontendRateLimit(dur, max, prefix),
 				)
 			}
 
 			return limiter
 		}
 
-		return NewMemoryFrontendRateLimit(dur, max)
+		return NewMemoryFrontendRateLimit(dur, max, prefix)
 	}
 
 	srv, err := NewServer(
@@ -374,45 +721,221 @@ func Start(config *Config) (*Server, func(), error) {
 		}
 	}
 
+	srv.listenDualStack = config.Server.ListenDualStack
+	srv.proxyProtocol = config.Server.ProxyProtocol
+	if len(config.Server.TLS.Certificates) > 0 {
+		srv.certificates, err = NewCertificateStore(config.Server.TLS)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	srv.maxHeaderCount = config.Server.MaxHeaderCount
+	if srv.maxHeaderCount == 0 {
+		srv.maxHeaderCount = defaultMaxHeaderCount
+	}
+	srv.maxHeaderBytes = config.Server.MaxHeaderBytes
+	if srv.maxHeaderBytes == 0 {
+		srv.maxHeaderBytes = defaultMaxHeaderBytes
+	}
+	srv.maxURLLength = config.Server.MaxURLLength
+	if srv.maxURLLength == 0 {
+		srv.maxURLLength = defaultMaxURLLength
+	}
+	srv.batchErrorStyle = config.BatchConfig.ErrorStyle
+	srv.batchErrorCode = config.BatchConfig.ErrorCode
+	srv.batchMethodLimits = config.BatchConfig.MethodLimits
+	srv.batchMethodLimitAction = config.BatchConfig.MethodLimitAction
+	srv.maxParamsLength = config.MaxParamsLength
+	srv.encodeBatchIncrementally = config.BatchConfig.IncrementalEncoding
+	srv.strictRequestFields = config.Server.StrictRequestFields
+	srv.strictContentType = config.Server.StrictContentType
+	srv.enableGetRPC = config.Server.EnableGetRPC
+	srv.enableVerboseHealthz = config.Server.EnableVerboseHealthz
+	srv.logRouting = config.Server.LogRouting
+	srv.invalidParams = invalidParams
+	srv.streamBackendResponses = config.Server.StreamBackendResponses
+	srv.geoIP, err = NewGeoIP(config.GeoIP)
+	if err != nil {
+		return nil, nil, err
+	}
+	var shutdownTracing func(context.Context) error
+	if config.OTel.Enabled {
+		tp, shutdown, err := newTracerProvider(config.OTel)
+		if err != nil {
+			return nil, nil, err
+		}
+		srv.tracer = tp.Tracer(tracerName)
+		shutdownTracing = shutdown
+	}
+	srv.config = config
+	srv.maxWSConns = config.Server.MaxWSConnections
+	srv.wsBackpressure, err = newWSBackpressure(config.Server.WSClientBufferSize, config.Server.WSBackpressurePolicy)
+	if err != nil {
+		return nil, nil, err
+	}
+	if config.Admin.Token != "" {
+		adminToken, err := ReadFromEnvOrConfig(config.Admin.Token)
+		if err != nil {
+			return nil, nil, err
+		}
+		srv.adminToken = adminToken
+	}
+	if config.Admin.SigningSecret != "" {
+		signingSecret, err := ReadFromEnvOrConfig(config.Admin.SigningSecret)
+		if err != nil {
+			return nil, nil, err
+		}
+		if config.Admin.SigningMaxSkew < 0 {
+			return nil, nil, errors.New("admin signing_max_skew must be >= 0")
+		}
+		srv.adminSigner = newAdminSigner(signingSecret, time.Duration(config.Admin.SigningMaxSkew))
+	}
+	if config.Admin.EnableCallAll && srv.adminToken == "" {
+		return nil, nil, errors.New("admin enable_call_all requires an admin token")
+	}
+	srv.enableCallAll = config.Admin.EnableCallAll
+	if config.GroupOverride.Enabled {
+		srv.groupOverrideKeys, err = groupOverrideKeySet(config.GroupOverride.Keys, config.Authentication)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(srv.groupOverrideKeys) == 0 && srv.adminToken == "" {
+			return nil, nil, errors.New("group_override requires keys or an admin token")
+		}
+		srv.enableGroupOverride = true
+	}
+	if config.Server.MaxConcurrentRPCsPerIP < 0 {
+		return nil, nil, errors.New("max_concurrent_rpcs_per_ip must be >= 0")
+	}
+	if config.Server.MaxConcurrentRPCsPerIP > 0 {
+		srv.ipConcurrency = newIPConcurrencyLimiter(config.Server.MaxConcurrentRPCsPerIP)
+	}
+	if config.Server.MaxHops < 0 {
+		return nil, nil, errors.New("max_hops must be >= 0")
+	}
+	srv.maxHops = config.Server.MaxHops
+	spendDur := time.Duration(config.SpendingLimit.Window)
+	var spendTracker SpendTracker = NewMemorySpendTracker(spendDur)
+	if config.RateLimit.UseRedis {
+		spendTracker = NewRedisSpendTracker(redisClient, config.Redis.ResolvedKeyPrefix(), spendDur)
+		if config.Redis.FallbackToMemory {
+			spendTracker = NewFallbackSpendTracker(spendTracker, NewMemorySpendTracker(spendDur))
+		}
+	}
+	srv.spendingLimiter, err = newSpendingLimiter(config.SpendingLimit, config.Authentication, spendTracker)
+	if err != nil {
+		return nil, nil, err
+	}
+	srv.responseFilters, err = newResponseFieldFilters(config.ResponseFieldFilters, config.DomainResponseFieldFilters)
+	if err != nil {
+		return nil, nil, err
+	}
+	srv.domainStrictRequestFields = config.DomainStrictRequestFields
+	srv.pendingToLatestMethods, err = pendingToLatestMethodSet(config.Server.PendingToLatestMethods)
+	if err != nil {
+		return nil, nil, err
+	}
+	srv.domainPendingToLatestMethods = make(map[string]map[string]bool, len(config.DomainPendingToLatest))
+	for domain, methods := range config.DomainPendingToLatest {
+		srv.domainPendingToLatestMethods[domain], err = pendingToLatestMethodSet(methods)
+		if err != nil {
+			return nil, nil, fmt.Errorf("domain %s: %w", domain, err)
+		}
+	}
+	srv.reorgCacheCooldown = time.Duration(config.Cache.ReorgCooldown)
+	reorgBypassMethods := config.Cache.ReorgBypassMethods
+	if len(reorgBypassMethods) == 0 {
+		reorgBypassMethods = defaultReorgBypassMethods
+	}
+	srv.reorgCacheBypassMethods = make(map[string]bool, len(reorgBypassMethods))
+	for _, method := range reorgBypassMethods {
+		srv.reorgCacheBypassMethods[method] = true
+	}
+	srv.priority = config.Priority
+	srv.enableETag = config.Cache.EnableETag
+	srv.minGasPrice = config.MinGasPrice
+	srv.paramRouter = paramRouter
+	srv.errorMapper, err = newErrorMapper(config.ErrorMappings)
+	if err != nil {
+		return nil, nil, err
+	}
+	srv.errorStatus, err = newErrorStatus(config.ErrorStatus)
+	if err != nil {
+		return nil, nil, err
+	}
+	srv.bodyBuffers = bodyBuffers
+	if config.DeadLetter.Enabled {
+		srv.deadLetter, err = NewDeadLetterLog(config.DeadLetter)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	if config.WASMHook.ModulePath != "" {
+		srv.wasmHook, err = NewWASMHook(context.Background(), config.WASMHook)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	srv.trustedProxies, err = parseIPNets(config.Server.TrustedProxies)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid trusted_proxies: %w", err)
+	}
+
+	srv.enableBackendNameHeader = config.Server.EnableBackendNameHeader
+	srv.backendNameHeaderTrustedIPs, err = parseIPNets(config.Server.BackendNameHeaderTrustedIPs)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid backend_name_header_trusted_ips: %w", err)
+	}
+
+	srv.enableErrorContext = config.Server.EnableErrorContext
+	srv.errorContextTrustedIPs, err = parseIPNets(config.Server.ErrorContextTrustedIPs)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid error_context_trusted_ips: %w", err)
+	}
+
+	srv.enableNoCacheHeader = config.Cache.EnableNoCacheHeader
+	srv.noCacheHeaderTrustedIPs, err = parseIPNets(config.Cache.NoCacheHeaderTrustedIPs)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid no_cache_header_trusted_ips: %w", err)
+	}
+
 	if config.Metrics.Enabled {
-		addr := fmt.Sprintf("%s:%d", config.Metrics.Host, config.Metrics.Port)
-		log.Info("starting metrics server", "addr", addr)
+		log.Info("starting metrics server", "host", config.Metrics.Host, "port", config.Metrics.Port)
 		go func() {
-			if err := http.ListenAndServe(addr, promhttp.Handler()); err != nil {
+			ln, err := Listen(config.Metrics.Host, config.Metrics.Port, config.Server.ListenDualStack)
+			if err == nil {
+				err = http.Serve(ln, promhttp.Handler())
+			}
+			if err != nil {
 				log.Error("error starting metrics server", "err", err)
 			}
 		}()
 	}
 
-	// To allow integration tests to cleanly come up, wait
-	// 10ms to give the below goroutines enough time to
-	// encounter an error creating their servers
-	errTimer := time.NewTimer(10 * time.Millisecond)
-
-	if config.Server.RPCPort != 0 {
-		go func() {
-			if err := srv.RPCListenAndServe(config.Server.RPCHost, config.Server.RPCPort); err != nil {
-				if errors.Is(err, http.ErrServerClosed) {
-					log.Info("RPC server shut down")
-					return
-				}
-				log.Crit("error starting RPC server", "err", err)
-			}
-		}()
+	if config.Warmup.ConsensusTimeout < 0 {
+		return nil, nil, errors.New("warmup consensus_timeout must be >= 0")
+	}
+	if len(config.Warmup.Methods) > 0 {
+		if config.Warmup.Timeout < 0 {
+			return nil, nil, errors.New("warmup timeout must be >= 0")
+		}
+		warmupBackends := make([]*Backend, 0, len(backendNames))
+		for _, name := range backendNames {
+			warmupBackends = append(warmupBackends, backendsByName[name])
+		}
+		WarmupBackends(warmupBackends, config.Warmup.Methods, time.Duration(config.Warmup.Timeout))
 	}
 
-	if config.Server.WSPort != 0 {
-		go func() {
-			if err := srv.WSListenAndServe(config.Server.WSHost, config.Server.WSPort); err != nil {
-				if errors.Is(err, http.ErrServerClosed) {
-					log.Info("WS server shut down")
-					return
-				}
-				log.Crit("error starting WS server", "err", err)
-			}
-		}()
-	} else {
-		log.Info("WS server not enabled (ws_port is set to 0)")
+	var selfPinger *SelfPinger
+	if config.SelfPing.Interval < 0 {
+		return nil, nil, errors.New("self_ping interval must be >= 0")
+	}
+	if config.SelfPing.Interval > 0 {
+		selfPinger, err = NewSelfPinger(time.Duration(config.SelfPing.Interval))
+		if err != nil {
+			return nil, nil, err
+		}
+		selfPinger.Start()
 	}
 
 	for bgName, bg := range backendGroups {
@@ -435,6 +958,19 @@ func Start(config *Config) (*Server, func(), error) {
 			if bgcfg.ConsensusBanPeriod > 0 {
 				copts = append(copts, WithBanPeriod(time.Duration(bgcfg.ConsensusBanPeriod)))
 			}
+			if bgcfg.ConsensusBanBackoffMultiplier != 0 || bgcfg.ConsensusMaxBanPeriod > 0 {
+				if bgcfg.ConsensusBanBackoffMultiplier != 0 && bgcfg.ConsensusBanBackoffMultiplier < 1 {
+					return nil, nil, fmt.Errorf("consensus_ban_backoff_multiplier must be >= 1 in backend group %s", bgName)
+				}
+				multiplier := bgcfg.ConsensusBanBackoffMultiplier
+				if multiplier == 0 {
+					multiplier = 1
+				}
+				copts = append(copts, WithBanBackoff(multiplier, time.Duration(bgcfg.ConsensusMaxBanPeriod)))
+			}
+			if bgcfg.ConsensusRecoveryPolls > 0 {
+				copts = append(copts, WithRecoveryPolls(bgcfg.ConsensusRecoveryPolls))
+			}
 			if bgcfg.ConsensusMaxUpdateThreshold > 0 {
 				copts = append(copts, WithMaxUpdateThreshold(time.Duration(bgcfg.ConsensusMaxUpdateThreshold)))
 			}
@@ -444,11 +980,54 @@ func Start(config *Config) (*Server, func(), error) {
 			if bgcfg.ConsensusMinPeerCount > 0 {
 				copts = append(copts, WithMinPeerCount(uint64(bgcfg.ConsensusMinPeerCount)))
 			}
+			if bgcfg.ConsensusMinBackends != 0 || bgcfg.ConsensusMinFraction != 0 {
+				copts = append(copts, WithQuorum(bgcfg.ConsensusMinBackends, bgcfg.ConsensusMinFraction))
+			}
 			if bgcfg.ConsensusMaxBlockRange > 0 {
 				copts = append(copts, WithMaxBlockRange(bgcfg.ConsensusMaxBlockRange))
 			}
-			if bgcfg.ConsensusPollerInterval > 0 {
-				copts = append(copts, WithPollerInterval(time.Duration(bgcfg.ConsensusPollerInterval)))
+			pollerInterval := bgcfg.ConsensusPollerInterval
+			if pollerInterval == 0 {
+				pollerInterval = config.BackendOptions.ConsensusPollerInterval
+			}
+			if pollerInterval > 0 {
+				copts = append(copts, WithPollerInterval(time.Duration(pollerInterval)))
+			}
+			if bgcfg.ConsensusHeadMethod != "" {
+				copts = append(copts, WithHeadProbe(
+					bgcfg.ConsensusHeadMethod,
+					bgcfg.ConsensusHeadParams,
+					bgcfg.ConsensusHeadNumberPath,
+					bgcfg.ConsensusHeadHashPath,
+				))
+			}
+			if bgcfg.ConsensusMaxHeadSkew > 0 {
+				copts = append(copts, WithMaxHeadSkew(time.Duration(bgcfg.ConsensusMaxHeadSkew)))
+			}
+			standbyHeadURL, err := ReadFromEnvOrConfig(bgcfg.ConsensusStandbyHeadURL)
+			if err != nil {
+				return nil, nil, err
+			}
+			if standbyHeadURL != "" {
+				standbyOpts := make([]BackendOpt, 0)
+				if config.BackendOptions.ResponseTimeoutSeconds != 0 {
+					standbyOpts = append(standbyOpts, WithTimeout(secondsToDuration(config.BackendOptions.ResponseTimeoutSeconds)))
+				}
+				if config.BackendOptions.UserAgent != "" {
+					standbyOpts = append(standbyOpts, WithUserAgent(config.BackendOptions.UserAgent))
+				}
+				standby := NewBackend(bgName+"_standby_head", standbyHeadURL, "", rpcRequestSemaphore, consensusRequestSemaphore, standbyOpts...)
+				copts = append(copts, WithStandbyHead(standby))
+			}
+
+			if config.Cache.Enabled && srv.reorgCacheCooldown > 0 {
+				copts = append(copts, WithListener(srv.startReorgCacheCooldown))
+			}
+			if config.Cache.Enabled && (len(config.Cache.BlockScopedMethods) > 0 || len(config.Cache.DepthTTL.Methods) > 0) {
+				copts = append(copts, WithHeadListener(rpcCache.SetHead))
+			}
+			if bg.replica != nil {
+				copts = append(copts, WithHeadListener(bg.replica.SetHead))
 			}
 
 			for _, be := range bgcfg.Backends {
@@ -493,12 +1072,70 @@ func Start(config *Config) (*Server, func(), error) {
 		}
 	}
 
+	// drift samplers are started once the config is known to be valid, so
+	// that a rejected one leaves nothing running
+	for _, bg := range backendGroups {
+		if bg.DriftSampler != nil {
+			bg.DriftSampler.Start()
+		}
+	}
+
+	if config.Warmup.ConsensusTimeout > 0 {
+		WaitForConsensus(backendGroups, time.Duration(config.Warmup.ConsensusTimeout))
+	}
+
+	// To allow integration tests to cleanly come up, wait
+	// 10ms to give the below goroutines enough time to
+	// encounter an error creating their servers
+	errTimer := time.NewTimer(10 * time.Millisecond)
+
+	if config.Server.RPCPort != 0 {
+		go func() {
+			if err := srv.RPCListenAndServe(config.Server.RPCHost, config.Server.RPCPort); err != nil {
+				if errors.Is(err, http.ErrServerClosed) {
+					log.Info("RPC server shut down")
+					return
+				}
+				log.Crit("error starting RPC server", "err", err)
+			}
+		}()
+	}
+
+	if config.Server.WSPort != 0 {
+		go func() {
+			if err := srv.WSListenAndServe(config.Server.WSHost, config.Server.WSPort); err != nil {
+				if errors.Is(err, http.ErrServerClosed) {
+					log.Info("WS server shut down")
+					return
+				}
+				log.Crit("error starting WS server", "err", err)
+			}
+		}()
+	} else {
+		log.Info("WS server not enabled (ws_port is set to 0)")
+	}
+
 	<-errTimer.C
 	log.Info("started proxyd")
 
 	shutdownFunc := func() {
 		log.Info("shutting down proxyd")
 		srv.Shutdown()
+		if selfPinger != nil {
+			selfPinger.Shutdown()
+		}
+		if srv.deadLetter != nil {
+			_ = srv.deadLetter.Close()
+		}
+		if srv.wasmHook != nil {
+			_ = srv.wasmHook.Close()
+		}
+		if srv.geoIP != nil {
+			_ = srv.geoIP.Close()
+		}
+		if shutdownTracing != nil {
+			_ = shutdownTracing(context.Background())
+		}
 		log.Info("goodbye")
 	}
 
@@ -520,19 +1157,125 @@ func validateReceiptsTarget(val string) (string, error) {
 	}
 }
 
+func validateJSONRPCMode(val JSONRPCMode) (JSONRPCMode, error) {
+	if val == "" {
+		val = JSONRPCModeStrict
+	}
+	switch val {
+	case JSONRPCModeStrict, JSONRPCModeOmit:
+		return val, nil
+	default:
+		return "", fmt.Errorf("invalid jsonrpc_mode: %s", val)
+	}
+}
+
+// parseIPNets parses a list of IPs and CIDRs. Bare IPs match only themselves.
+func parseIPNets(vals []string) ([]*net.IPNet, error) {
+	out := make([]*net.IPNet, 0, len(vals))
+	for _, val := range vals {
+		if _, ipNet, err := net.ParseCIDR(val); err == nil {
+			out = append(out, ipNet)
+			continue
+		}
+		ip := net.ParseIP(val)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid IP or CIDR: %s", val)
+		}
+		bits := 8 * net.IPv6len
+		if ip4 := ip.To4(); ip4 != nil {
+			ip, bits = ip4, 8*net.IPv4len
+		}
+		out = append(out, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+	}
+	return out, nil
+}
+
+func pendingToLatestMethodSet(methods []string) (map[string]bool, error) {
+	set := make(map[string]bool, len(methods))
+	for _, method := range methods {
+		if _, ok := pendingTagParamPositions[method]; !ok {
+			return nil, fmt.Errorf("pending_to_latest_methods: %s has no block param", method)
+		}
+		set[method] = true
+	}
+	return set, nil
+}
+
+func validateDepthTTLConfig(cfg DepthTTLConfig) error {
+	if len(cfg.Methods) == 0 {
+		return nil
+	}
+	for _, method := range cfg.Methods {
+		if _, ok := pendingTagParamPositions[method]; !ok {
+			return fmt.Errorf("%s has no block param", method)
+		}
+	}
+	if cfg.BaseTTL <= 0 {
+		return errors.New("base_ttl must be > 0")
+	}
+	if cfg.TTLPerBlock < 0 {
+		return errors.New("ttl_per_block must be >= 0")
+	}
+	if cfg.TTLPerBlock > 0 && cfg.MaxTTL < cfg.BaseTTL {
+		return errors.New("max_ttl must be >= base_ttl when ttl_per_block is set")
+	}
+	return nil
+}
+
 func secondsToDuration(seconds int) time.Duration {
 	return time.Duration(seconds) * time.Second
 }
 
+// requestTimeout returns the maximum time spent serving an HTTP request.
+func requestTimeout(config *Config) time.Duration {
+	if config.Server.TimeoutSeconds == 0 {
+		return defaultRPCTimeout
+	}
+	return secondsToDuration(config.Server.TimeoutSeconds)
+}
+
+func configureBackendProxy(cfg BackendProxyConfig) (*url.URL, error) {
+	rawURL, err := ReadFromEnvOrConfig(cfg.URL)
+	if err != nil {
+		return nil, err
+	}
+	proxyURL, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid url: %w", err)
+	}
+	switch proxyURL.Scheme {
+	case "http", "https", "socks5":
+	default:
+		return nil, fmt.Errorf("unsupported scheme %q, expected http, https or socks5", proxyURL.Scheme)
+	}
+
+	if cfg.Username != "" {
+		username, err := ReadFromEnvOrConfig(cfg.Username)
+		if err != nil {
+			return nil, err
+		}
+		password, err := ReadFromEnvOrConfig(cfg.Password)
+		if err != nil {
+			return nil, err
+		}
+		proxyURL.User = url.UserPassword(username, password)
+	}
+	return proxyURL, nil
+}
+
 func configureBackendTLS(cfg *BackendConfig) (*tls.Config, error) {
 	if cfg.CAFile == "" {
-		return nil, nil
+		if cfg.TLSServerName == "" {
+			return nil, nil
+		}
+		return &tls.Config{ServerName: cfg.TLSServerName}, nil
 	}
 
 	tlsConfig, err := CreateTLSClient(cfg.CAFile)
 	if err != nil {
 		return nil, err
 	}
+	tlsConfig.ServerName = cfg.TLSServerName
 
 	if cfg.ClientCertFile != "" && cfg.ClientKeyFile != "" {
 		cert, err := ParseKeyPair(cfg.ClientCertFile, cfg.ClientKeyFile)
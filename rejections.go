@@ -0,0 +1,58 @@
+package proxyd
+
+import (
+	"fmt"
+)
+
+// rejectionErrors are the errors answering requests proxyd rejects, by the
+// name of their reason in the rejections config.
+var rejectionErrors = map[string]*RPCErr{
+	"rate_limit":          ErrOverRateLimit,
+	"concurrency":         ErrMethodConcurrencyLimit,
+	"queue_timeout":       ErrQueueTimeout,
+	"no_healthy_backends": ErrNoBackends,
+}
+
+// defaultRejectionErrors keeps the errors as declared, so that configuring a
+// server doesn't leak into the next one started by the same process.
+var defaultRejectionErrors = func() map[string]RPCErr {
+	defaults := make(map[string]RPCErr, len(rejectionErrors))
+	for reason, err := range rejectionErrors {
+		defaults[reason] = *err
+	}
+	return defaults
+}()
+
+// resetRejectionErrors restores the declared code, message and HTTP status of
+// the rejection errors.
+func resetRejectionErrors() {
+	for reason, err := range rejectionErrors {
+		*err = defaultRejectionErrors[reason]
+	}
+}
+
+// configureRejectionErrors overrides the code, message and HTTP status of the
+// rejection errors. Like the other error messages, these are shared globals.
+func configureRejectionErrors(config RejectionsConfig) error {
+	for reason, override := range map[string]RejectionConfig{
+		"rate_limit":          config.RateLimit,
+		"concurrency":         config.Concurrency,
+		"queue_timeout":       config.QueueTimeout,
+		"no_healthy_backends": config.NoHealthyBackends,
+	} {
+		if override.HTTPStatus != 0 && (override.HTTPStatus < 400 || override.HTTPStatus > 599) {
+			return fmt.Errorf("http_status of %s rejections must be between 400 and 599", reason)
+		}
+		err := rejectionErrors[reason]
+		if override.Code != 0 {
+			err.Code = override.Code
+		}
+		if override.Message != "" {
+			err.Message = override.Message
+		}
+		if override.HTTPStatus != 0 {
+			err.HTTPErrorCode = override.HTTPStatus
+		}
+	}
+	return nil
+}
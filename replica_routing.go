@@ -0,0 +1,58 @@
+package proxyd
+
+import (
+	"sync/atomic"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// replicaRouter sends calls for blocks at least minDepth blocks below the
+// consensus head of a backend group to a replica group, typically of cheaper
+// nodes serving historical state. Calls for block tags such as "latest" or
+// "pending", for block hashes, or made before the head is known, stay on the
+// backend group. Calls to alwaysMethods, which need full state whatever
+// their params, always go to the replica group.
+type replicaRouter struct {
+	group         string
+	methods       map[string]bool
+	alwaysMethods map[string]bool
+	minDepth      uint64
+	head          atomic.Uint64
+}
+
+func newReplicaRouter(group string, methods, alwaysMethods []string, minDepth uint64) *replicaRouter {
+	r := &replicaRouter{
+		group:         group,
+		methods:       make(map[string]bool, len(methods)),
+		alwaysMethods: make(map[string]bool, len(alwaysMethods)),
+		minDepth:      minDepth,
+	}
+	for _, method := range methods {
+		r.methods[method] = true
+	}
+	for _, method := range alwaysMethods {
+		r.alwaysMethods[method] = true
+	}
+	return r
+}
+
+// SetHead moves the block that depths are measured from.
+func (r *replicaRouter) SetHead(blockNumber hexutil.Uint64) {
+	r.head.Store(uint64(blockNumber))
+}
+
+// Route returns the replica group if req is to an always method or for a
+// block deep enough, or an empty string otherwise.
+func (r *replicaRouter) Route(req *RPCReq) string {
+	if r.alwaysMethods[req.Method] {
+		return r.group
+	}
+	if !r.methods[req.Method] {
+		return ""
+	}
+	depth, ok := blockDepth(req, r.head.Load())
+	if !ok || depth < r.minDepth {
+		return ""
+	}
+	return r.group
+}
@@ -0,0 +1,98 @@
+package proxyd
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// requestCoalescer shares a single backend call between concurrent calls to
+// the configured methods with the same params, so that a burst of identical
+// reads, such as eth_blockNumber, reaches the backends once. The shared call
+// isn't tied to the client that started it, so that calls joining it aren't
+// failed when that client goes away: it runs for up to timeout, and each
+// caller stops waiting for it when its own context is done.
+type requestCoalescer struct {
+	group   string
+	methods map[string]bool
+	timeout time.Duration
+
+	mtx     sync.Mutex
+	flights map[string]*coalescedCall
+}
+
+// coalescedCall is a backend call in flight, whose outcome is set before done
+// is closed.
+type coalescedCall struct {
+	done     chan struct{}
+	res      *RPCRes
+	servedBy string
+	err      error
+}
+
+func newRequestCoalescer(group string, methods []string, timeout time.Duration) *requestCoalescer {
+	c := &requestCoalescer{
+		group:   group,
+		methods: make(map[string]bool, len(methods)),
+		timeout: timeout,
+		flights: make(map[string]*coalescedCall),
+	}
+	for _, method := range methods {
+		c.methods[method] = true
+	}
+	return c
+}
+
+// Coalesces returns whether reqs, forwarded together, may share a backend
+// call with identical ones.
+func (c *requestCoalescer) Coalesces(reqs []*RPCReq) bool {
+	return len(reqs) == 1 && c.methods[reqs[0].Method]
+}
+
+// Forward calls forward for req, unless an identical call is already in
+// flight, in which case it waits for that call and answers with a copy of its
+// response under the ID of req. forward is called with a context detached from
+// ctx, keeping its values.
+func (c *requestCoalescer) Forward(ctx context.Context, req *RPCReq, forward func(context.Context) ([]*RPCRes, string, error)) ([]*RPCRes, string, error) {
+	key := req.Method + "\x00" + string(req.Params)
+
+	c.mtx.Lock()
+	call, ok := c.flights[key]
+	if ok {
+		c.mtx.Unlock()
+		RecordCoalescedRequest(c.group, req.Method)
+	} else {
+		call = &coalescedCall{done: make(chan struct{})}
+		c.flights[key] = call
+		c.mtx.Unlock()
+		coalescingGroupsInFlight.WithLabelValues(c.group).Inc()
+
+		go func() {
+			forwardCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), c.timeout)
+			defer cancel()
+			var res []*RPCRes
+			res, call.servedBy, call.err = forward(forwardCtx)
+			if call.err == nil {
+				call.res = res[0]
+			}
+
+			c.mtx.Lock()
+			delete(c.flights, key)
+			c.mtx.Unlock()
+			coalescingGroupsInFlight.WithLabelValues(c.group).Dec()
+			close(call.done)
+		}()
+	}
+
+	select {
+	case <-call.done:
+	case <-ctx.Done():
+		return nil, "", ctx.Err()
+	}
+	if call.err != nil {
+		return nil, call.servedBy, call.err
+	}
+	res := *call.res
+	res.ID = req.ID
+	return []*RPCRes{&res}, call.servedBy, nil
+}
@@ -0,0 +1,131 @@
+package proxyd
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// responseFieldFilters strips fields from the results of successful calls to
+// some methods, for every domain or per X-Forwarded-Host. Paths are dot
+// separated: each segment names an object field, an array index, or "*" for
+// every field or element, and the last one names the fields removed.
+type responseFieldFilters struct {
+	methods       map[string][][]string
+	domainMethods map[string]map[string][][]string
+	// filtered holds the methods filtered for any domain.
+	filtered map[string]bool
+}
+
+func newResponseFieldFilters(methods map[string][]string, domainMethods map[string]map[string][]string) (*responseFieldFilters, error) {
+	if len(methods) == 0 && len(domainMethods) == 0 {
+		return nil, nil
+	}
+	f := &responseFieldFilters{
+		domainMethods: make(map[string]map[string][][]string, len(domainMethods)),
+		filtered:      make(map[string]bool),
+	}
+	var err error
+	if f.methods, err = f.parse(methods); err != nil {
+		return nil, err
+	}
+	for domain, methods := range domainMethods {
+		if f.domainMethods[domain], err = f.parse(methods); err != nil {
+			return nil, fmt.Errorf("domain %s: %w", domain, err)
+		}
+	}
+	return f, nil
+}
+
+func (f *responseFieldFilters) parse(methods map[string][]string) (map[string][][]string, error) {
+	parsed := make(map[string][][]string, len(methods))
+	for method, paths := range methods {
+		for _, path := range paths {
+			segments := strings.Split(path, ".")
+			for _, segment := range segments {
+				if segment == "" {
+					return nil, fmt.Errorf("invalid response field filter %q for %s", path, method)
+				}
+			}
+			parsed[method] = append(parsed[method], segments)
+		}
+		f.filtered[method] = true
+	}
+	return parsed, nil
+}
+
+// Filters returns whether the results of method may be filtered.
+func (f *responseFieldFilters) Filters(method string) bool {
+	return f != nil && f.filtered[method]
+}
+
+// Apply returns res with the fields configured for origin and method removed
+// from its result. Filters of a domain replace the others for that domain.
+// res itself isn't modified, since its result may be shared.
+func (f *responseFieldFilters) Apply(origin string, method string, res *RPCRes) *RPCRes {
+	if f == nil || res == nil || res.IsError() || res.Result == nil {
+		return res
+	}
+	methods := f.methods
+	if domainMethods, ok := f.domainMethods[origin]; ok && origin != "" {
+		methods = domainMethods
+	}
+	paths := methods[method]
+	if len(paths) == 0 {
+		return res
+	}
+
+	result := res.Result
+	if raw, ok := result.(json.RawMessage); ok {
+		if err := unmarshalJSONNumbers(raw, &result); err != nil {
+			return res
+		}
+	}
+	for _, path := range paths {
+		result = stripJSONPath(result, path)
+	}
+	filtered := *res
+	filtered.Result = result
+	return &filtered
+}
+
+// stripJSONPath returns a copy of v without the fields at path. Only the
+// objects and arrays along the path are copied.
+func stripJSONPath(v interface{}, path []string) interface{} {
+	segment := path[0]
+	switch v := v.(type) {
+	case map[string]interface{}:
+		stripped := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			if segment != "*" && key != segment {
+				stripped[key] = value
+				continue
+			}
+			if len(path) > 1 {
+				stripped[key] = stripJSONPath(value, path[1:])
+			}
+		}
+		return stripped
+	case []interface{}:
+		// array elements are traversed but never removed, so that indexes
+		// keep their meaning
+		if len(path) == 1 {
+			return v
+		}
+		index, err := strconv.Atoi(segment)
+		if segment != "*" && (err != nil || index < 0 || index >= len(v)) {
+			return v
+		}
+		stripped := make([]interface{}, len(v))
+		copy(stripped, v)
+		for i := range stripped {
+			if segment == "*" || i == index {
+				stripped[i] = stripJSONPath(stripped[i], path[1:])
+			}
+		}
+		return stripped
+	default:
+		return v
+	}
+}
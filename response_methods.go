@@ -0,0 +1,39 @@
+package proxyd
+
+import (
+	"context"
+)
+
+// responseMethods holds the method of each call of a request, to label
+// metrics recorded when the responses are written. Calls to methods that
+// aren't whitelisted are left MethodUnknown, to bound cardinality.
+type responseMethods struct {
+	methods []string
+}
+
+func withResponseMethods(ctx context.Context, calls int) context.Context {
+	rm := &responseMethods{methods: make([]string, calls)}
+	for i := range rm.methods {
+		rm.methods[i] = MethodUnknown
+	}
+	return context.WithValue(ctx, ContextKeyResponseMethods, rm) // nolint:staticcheck
+}
+
+// setResponseMethod records method as the method of call i of the request.
+func setResponseMethod(ctx context.Context, i int, method string) {
+	rm, ok := ctx.Value(ContextKeyResponseMethods).(*responseMethods)
+	if !ok || i >= len(rm.methods) {
+		return
+	}
+	rm.methods[i] = method
+}
+
+// RecordResponseSizeByMethod records the size of the response to call i of
+// the request, if its calls are tracked.
+func RecordResponseSizeByMethod(ctx context.Context, i int, size int) {
+	rm, ok := ctx.Value(ContextKeyResponseMethods).(*responseMethods)
+	if !ok || i >= len(rm.methods) {
+		return
+	}
+	responseSizesByMethod.WithLabelValues(rm.methods[i]).Observe(float64(size))
+}
@@ -0,0 +1,116 @@
+package proxyd
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// streamResponseThreshold is the size from which backend responses are
+// streamed. Smaller ones are buffered as usual, so that errors, which are
+// small, are still inspected for failover and error mapping.
+const streamResponseThreshold = 64 * 1024
+
+// responseStream copies the body of a backend response straight to the
+// client instead of buffering and parsing it. Only single calls that are
+// neither cached nor transformed are streamed.
+type responseStream struct {
+	w        http.ResponseWriter
+	claimed  atomic.Bool
+	failed   atomic.Bool
+	streamed atomic.Bool
+}
+
+func withResponseStream(ctx context.Context, w http.ResponseWriter) (context.Context, *responseStream) {
+	stream := &responseStream{w: w}
+	return context.WithValue(ctx, ContextKeyResponseStream, stream), stream // nolint:staticcheck
+}
+
+// withoutResponseStream makes responses forwarded with ctx buffered.
+func withoutResponseStream(ctx context.Context) context.Context {
+	if getResponseStream(ctx) == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, ContextKeyResponseStream, (*responseStream)(nil)) // nolint:staticcheck
+}
+
+func getResponseStream(ctx context.Context) *responseStream {
+	stream, _ := ctx.Value(ContextKeyResponseStream).(*responseStream)
+	return stream
+}
+
+// copy streams body to the client if it is at least streamResponseThreshold
+// long, and returns whether it did. Otherwise it returns a reader of the whole
+// body to parse instead. Once streaming started, the response can't be retried
+// or failed over, so an error while copying fails the client request.
+func (s *responseStream) copy(ctx context.Context, backend string, body io.Reader) (io.Reader, bool) {
+	br := bufio.NewReaderSize(body, streamResponseThreshold)
+	if _, err := br.Peek(streamResponseThreshold); err != nil {
+		return br, false
+	}
+	// hedged or canaried requests may get several responses at once
+	if !s.claimed.CompareAndSwap(false, true) {
+		return br, false
+	}
+
+	s.w.Header().Set("content-type", "application/json")
+	s.w.WriteHeader(http.StatusOK)
+	ww := &recordLenWriter{Writer: &flushWriter{w: s.w, rc: http.NewResponseController(s.w)}}
+	_, err := io.Copy(ww, br)
+	s.streamed.Store(true)
+	RecordStreamedResponse(backend)
+	httpResponseCodesTotal.WithLabelValues(strconv.Itoa(http.StatusOK)).Inc()
+	RecordResponsePayloadSize(ctx, ww.Len)
+	RecordResponseSizeByMethod(ctx, 0, ww.Len)
+	if err != nil {
+		s.failed.Store(true)
+		log.Error(
+			"error streaming backend response",
+			"name", backend,
+			"req_id", GetReqID(ctx),
+			"streamed_bytes", ww.Len,
+			"too_large", errors.Is(err, ErrLimitReaderOverLimit),
+			"err", err,
+		)
+	}
+	return nil, true
+}
+
+// flushWriter flushes each write to the client, rather than holding the last
+// bytes received from the backend until the response completes.
+type flushWriter struct {
+	w  http.ResponseWriter
+	rc *http.ResponseController
+}
+
+func (f *flushWriter) Write(p []byte) (int, error) {
+	n, err := f.w.Write(p)
+	if err != nil {
+		return n, err
+	}
+	if err := f.rc.Flush(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		return n, err
+	}
+	return n, nil
+}
+
+// canStreamResponses returns whether the responses of the backend to reqs may
+// be copied to the client as they are.
+func (b *Backend) canStreamResponses(reqs []*RPCReq, isBatch bool) bool {
+	return !isBatch && len(reqs) == 1 && !b.rewriteRequestIDs && !b.rejectMissingJSONRPC
+}
+
+// streamedRes stands in for a response already streamed to the client.
+func streamedRes(req *RPCReq) *RPCRes {
+	return &RPCRes{
+		JSONRPC:  JSONRPCVersion,
+		ID:       req.ID,
+		streamed: true,
+	}
+}
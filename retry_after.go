@@ -0,0 +1,58 @@
+package proxyd
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+)
+
+const defaultMaxRetryAfter = time.Minute
+
+// parseRetryAfter returns how long a Retry-After header, in seconds or as an
+// HTTP date, asks to wait from now. It returns 0 for missing or invalid values.
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if secs, err := strconv.ParseInt(value, 10, 64); err == nil {
+		if secs <= 0 {
+			return 0
+		}
+		return time.Duration(secs) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil && at.After(now) {
+		return at.Sub(now)
+	}
+	return 0
+}
+
+// backOffFor stops sending requests to the backend for d, capped at
+// maxRetryAfter, unless it is already backing off for longer.
+func (b *Backend) backOffFor(d time.Duration) {
+	if d > b.maxRetryAfter {
+		d = b.maxRetryAfter
+	}
+	until := time.Now().Add(d).UnixNano()
+	for {
+		cur := b.backOffUntil.Load()
+		if cur >= until {
+			return
+		}
+		if b.backOffUntil.CompareAndSwap(cur, until) {
+			break
+		}
+	}
+	log.Warn("backend asked to retry later, backing off",
+		"name", b.Name,
+		"duration", d,
+	)
+	RecordBackendRetryAfter(b.Name)
+}
+
+// IsBackingOff returns whether the backend asked proxyd to retry later with a
+// 429 response carrying a Retry-After header that hasn't elapsed yet.
+func (b *Backend) IsBackingOff() bool {
+	return time.Now().UnixNano() < b.backOffUntil.Load()
+}
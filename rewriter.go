@@ -87,7 +87,7 @@ func RewriteRequest(rctx RewriteContext, req *RPCReq, res *RPCRes) (RewriteResul
 
 func rewriteParam(rctx RewriteContext, req *RPCReq, res *RPCRes, pos int, required bool, blockNrOrHash bool) (RewriteResult, error) {
 	var p []interface{}
-	err := json.Unmarshal(req.Params, &p)
+	err := unmarshalJSONNumbers(req.Params, &p)
 	if err != nil {
 		return RewriteOverrideError, err
 	}
@@ -148,7 +148,7 @@ func rewriteParam(rctx RewriteContext, req *RPCReq, res *RPCRes, pos int, requir
 
 func rewriteRange(rctx RewriteContext, req *RPCReq, res *RPCRes, pos int) (RewriteResult, error) {
 	var p []map[string]interface{}
-	err := json.Unmarshal(req.Params, &p)
+	err := unmarshalJSONNumbers(req.Params, &p)
 	if err != nil {
 		return RewriteOverrideError, err
 	}
@@ -308,3 +308,62 @@ func rewriteTagBlockNumberOrHash(rctx RewriteContext, current *rpc.BlockNumberOr
 
 	return current, false, nil
 }
+
+// pendingTagParamPositions maps methods to the position of their block param,
+// for rewriting "pending" to "latest".
+var pendingTagParamPositions = map[string]int{
+	"eth_getBalance":                          1,
+	"eth_getCode":                             1,
+	"eth_getTransactionCount":                 1,
+	"eth_call":                                1,
+	"eth_estimateGas":                         1,
+	"eth_getStorageAt":                        2,
+	"eth_getProof":                            2,
+	"eth_getBlockByNumber":                    0,
+	"eth_getBlockTransactionCountByNumber":    0,
+	"eth_getTransactionByBlockNumberAndIndex": 0,
+}
+
+// RewritePendingToLatest replaces a "pending" block param of req, as a tag or
+// as the blockNumber of an EIP-1898 object, with "latest". It returns whether
+// the request was changed.
+func RewritePendingToLatest(req *RPCReq) (bool, error) {
+	pos, ok := pendingTagParamPositions[req.Method]
+	if !ok {
+		return false, nil
+	}
+	var p []json.RawMessage
+	if err := json.Unmarshal(req.Params, &p); err != nil {
+		return false, err
+	}
+	if len(p) <= pos {
+		return false, nil
+	}
+
+	var tag string
+	if err := json.Unmarshal(p[pos], &tag); err == nil {
+		if tag != "pending" {
+			return false, nil
+		}
+		p[pos] = json.RawMessage(`"latest"`)
+	} else {
+		var obj map[string]json.RawMessage
+		if err := json.Unmarshal(p[pos], &obj); err != nil {
+			return false, nil
+		}
+		if err := json.Unmarshal(obj["blockNumber"], &tag); err != nil || tag != "pending" {
+			return false, nil
+		}
+		obj["blockNumber"] = json.RawMessage(`"latest"`)
+		if p[pos], err = json.Marshal(obj); err != nil {
+			return false, err
+		}
+	}
+
+	params, err := json.Marshal(p)
+	if err != nil {
+		return false, err
+	}
+	req.Params = params
+	return true, nil
+}
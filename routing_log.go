@@ -0,0 +1,50 @@
+package proxyd
+
+import (
+	"context"
+
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// Rules logged with log_routing as deciding where a call went.
+const (
+	routeRuleMethodMapping   = "method_mapping"
+	routeRuleParamRoute      = "param_route"
+	routeRuleReplica         = "replica"
+	routeRuleSpillover       = "spillover"
+	routeRuleGroupOverride   = "group_override"
+	routeRuleEthCallOverride = "eth_call_override"
+	routeRuleEthAccounts     = "eth_accounts"
+	routeRuleNetVersion      = "net_version"
+	routeRuleCallAll         = "call_all"
+	routeRuleNotWhitelisted  = "not_whitelisted"
+)
+
+// routeMapping returns which rpc method mappings apply to requests from
+// origin: those of its domain, or the default ones.
+func (s *Server) routeMapping(origin string) string {
+	if origin != "" {
+		if _, ok := s.routing.Load().domainRPCMethodMappings[origin]; ok {
+			return "domain"
+		}
+	}
+	return "default"
+}
+
+// logRoute logs the backend group selected for a call and the rule that
+// selected it. Calls answered by proxyd itself have no backend group and are
+// logged as short-circuited.
+func (s *Server) logRoute(ctx context.Context, origin string, method string, group string, rule string) {
+	if !s.logRouting {
+		return
+	}
+	log.Info("routed RPC call",
+		"req_id", GetReqID(ctx),
+		"domain", origin,
+		"mapping", s.routeMapping(origin),
+		"method", method,
+		"backend_group", group,
+		"rule", rule,
+		"short_circuit", group == "",
+	)
+}
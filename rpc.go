@@ -1,9 +1,15 @@
 package proxyd
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
+	"math/big"
+	"sort"
 	"strings"
+	"time"
 )
 
 type RPCReq struct {
@@ -13,11 +19,33 @@ type RPCReq struct {
 	ID      json.RawMessage `json:"id"`
 }
 
+// versionlessRPCReq is the wire form of an RPCReq sent to backends
+// that reject the jsonrpc field.
+type versionlessRPCReq struct {
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params,omitempty"`
+	ID     json.RawMessage `json:"id,omitempty"`
+}
+
+func newVersionlessRPCReq(req *RPCReq) *versionlessRPCReq {
+	return &versionlessRPCReq{
+		Method: req.Method,
+		Params: req.Params,
+		ID:     req.ID,
+	}
+}
+
 type RPCRes struct {
 	JSONRPC string
 	Result  interface{}
 	Error   *RPCErr
 	ID      json.RawMessage
+
+	// streamed is set when the response was already copied to the client.
+	streamed bool
+	// cacheTTL is how long the Cache-Control header of the backend allows
+	// the response to be cached, if at all.
+	cacheTTL time.Duration
 }
 
 type rpcResJSON struct {
@@ -37,6 +65,18 @@ func (r *RPCRes) IsError() bool {
 	return r.Error != nil
 }
 
+// UnmarshalJSON decodes numbers in the result as json.Number, so that they
+// are re-encoded exactly however large they are.
+func (r *RPCRes) UnmarshalJSON(data []byte) error {
+	type rpcRes RPCRes
+	var res rpcRes
+	if err := unmarshalJSONNumbers(data, &res); err != nil {
+		return err
+	}
+	*r = RPCRes(res)
+	return nil
+}
+
 func (r *RPCRes) MarshalJSON() ([]byte, error) {
 	if r.Result == nil && r.Error == nil {
 		return json.Marshal(&nullResultRPCRes{
@@ -55,10 +95,10 @@ func (r *RPCRes) MarshalJSON() ([]byte, error) {
 }
 
 type RPCErr struct {
-	Code          int    `json:"code"`
-	Message       string `json:"message"`
-	Data          string `json:"data,omitempty"`
-	HTTPErrorCode int    `json:"-"`
+	Code          int         `json:"code"`
+	Message       string      `json:"message"`
+	Data          interface{} `json:"data,omitempty"`
+	HTTPErrorCode int         `json:"-"`
 }
 
 func (r *RPCErr) Error() string {
@@ -73,6 +113,31 @@ func (r *RPCErr) Clone() *RPCErr {
 	}
 }
 
+// idKey identifies id by its JSON type and value rather than its encoding, so
+// that an id re-encoded by a backend, e.g. 1.0 as 1, still matches the
+// request's, while 1 and "1" stay distinct.
+func idKey(id json.RawMessage) string {
+	dec := json.NewDecoder(bytes.NewReader(id))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return string(id)
+	}
+	switch v := v.(type) {
+	case string:
+		return "s:" + v
+	case json.Number:
+		if f, _, err := big.ParseFloat(v.String(), 10, 256, big.ToNearestEven); err == nil {
+			return "n:" + f.Text('g', -1)
+		}
+		return "n:" + v.String()
+	case nil:
+		return "null"
+	default:
+		return string(id)
+	}
+}
+
 func IsValidID(id json.RawMessage) bool {
 	// handle the case where the ID is a string
 	if strings.HasPrefix(string(id), "\"") && strings.HasSuffix(string(id), "\"") {
@@ -84,6 +149,21 @@ func IsValidID(id json.RawMessage) bool {
 	return len(id) > 0 && id[0] != '{' && id[0] != '['
 }
 
+// unmarshalJSONNumbers is json.Unmarshal, except that numbers decoded into
+// interface values are json.Number rather than float64, so that encoding them
+// again doesn't lose precision.
+func unmarshalJSONNumbers(data []byte, v interface{}) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(v); err != nil {
+		return err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return errors.New("invalid data after top-level value")
+	}
+	return nil
+}
+
 func ParseRPCReq(body []byte) (*RPCReq, error) {
 	req := new(RPCReq)
 	if err := json.Unmarshal(body, req); err != nil {
@@ -132,6 +212,35 @@ func ValidateRPCReq(req *RPCReq) error {
 	return nil
 }
 
+var rpcReqFields = map[string]bool{
+	"jsonrpc": true,
+	"id":      true,
+	"method":  true,
+	"params":  true,
+}
+
+// ValidateRPCReqFields rejects requests carrying top-level fields that are
+// not part of the JSON-RPC request object.
+func ValidateRPCReqFields(body []byte) error {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return ErrParseErr
+	}
+
+	var unknown []string
+	for name := range fields {
+		if !rpcReqFields[name] {
+			unknown = append(unknown, name)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return ErrInvalidRequest(fmt.Sprintf("unknown request fields: %s", strings.Join(unknown, ", ")))
+	}
+
+	return nil
+}
+
 func NewRPCErrorRes(id json.RawMessage, err error) *RPCRes {
 	var rpcErr *RPCErr
 	if rr, ok := err.(*RPCErr); ok {
@@ -87,3 +87,24 @@ func TestRPCResJSON(t *testing.T) {
 		})
 	}
 }
+
+func TestIDKey(t *testing.T) {
+	same := [][]string{
+		{`1`, `1.0`, `1e0`, `10e-1`},
+		{`"a"`, `"\u0061"`},
+		{`null`, ` null`},
+	}
+	for _, ids := range same {
+		for _, id := range ids[1:] {
+			require.Equal(t, idKey(json.RawMessage(ids[0])), idKey(json.RawMessage(id)), "%s and %s", ids[0], id)
+		}
+	}
+
+	distinct := []string{`1`, `"1"`, `2`, `"a"`, `null`, `"null"`, ``, `true`}
+	keys := make(map[string]string)
+	for _, id := range distinct {
+		key := idKey(json.RawMessage(id))
+		require.NotContains(t, keys, key, "%s and %s", keys[key], id)
+		keys[key] = id
+	}
+}
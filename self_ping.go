@@ -0,0 +1,95 @@
+package proxyd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// SelfPinger periodically times a request to a loopback handler served by
+// proxyd itself. The request never leaves the host, so a rising latency
+// points at contention within proxyd, such as GC pauses or scheduling delays,
+// rather than at the network or the backends.
+type SelfPinger struct {
+	interval time.Duration
+	url      string
+	client   *http.Client
+	srv      *http.Server
+
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+func NewSelfPinger(interval time.Duration) (*SelfPinger, error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, fmt.Errorf("error listening for self ping: %w", err)
+	}
+	srv := &http.Server{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}),
+		ReadHeaderTimeout: interval,
+	}
+	go func() {
+		if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Error("error serving self ping", "err", err)
+		}
+	}()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	return &SelfPinger{
+		interval: interval,
+		url:      "http://" + ln.Addr().String(),
+		client:   &http.Client{Timeout: interval},
+		srv:      srv,
+		ctx:      ctx,
+		cancel:   cancel,
+	}, nil
+}
+
+func (p *SelfPinger) Start() {
+	go func() {
+		ticker := time.NewTicker(p.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				p.Ping(p.ctx)
+			case <-p.ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+func (p *SelfPinger) Shutdown() {
+	p.cancel()
+	_ = p.srv.Close()
+}
+
+// Ping sends one request to the loopback handler and records its latency.
+func (p *SelfPinger) Ping(ctx context.Context) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.url, nil)
+	if err != nil {
+		return
+	}
+	start := time.Now()
+	res, err := p.client.Do(req)
+	if err != nil {
+		if ctx.Err() == nil {
+			RecordSelfPingError()
+			log.Warn("error sending self ping", "err", err)
+		}
+		return
+	}
+	_, _ = io.Copy(io.Discard, res.Body)
+	_ = res.Body.Close()
+	RecordSelfPing(time.Since(start))
+}
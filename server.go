@@ -1,22 +1,33 @@
 package proxyd
 
 import (
+	"bytes"
 	"context"
 	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/tls"
 	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"hash/fnv"
 	"io"
+	"log/slog"
 	"math"
 	"math/big"
+	"mime"
+	"net"
 	"net/http"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/core/txpool"
 	"github.com/ethereum/go-ethereum/core/types"
@@ -26,6 +37,7 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/rs/cors"
 	"github.com/syndtr/goleveldb/leveldb/opt"
+	"go.opentelemetry.io/otel/trace"
 )
 
 const (
@@ -34,12 +46,34 @@ const (
 	ContextKeyXForwardedFor      = "x_forwarded_for"
 	ContextKeyOpTxProxyAuth      = "op_txproxy_auth"
 	ContextKeyOrigin             = "x_forwarded_host"
+	ContextKeyPriority           = "priority"
+	ContextKeyBackendAttempts    = "backend_attempts"
+	ContextKeyBackendTime        = "backend_time"
+	ContextKeyQueueTimeout       = "queue_timeout"
+	ContextKeyNoCache            = "no_cache"
+	ContextKeyGeoInfo            = "geo_info"
+	ContextKeyAdmin              = "admin"
+	ContextKeyResponseMethods    = "response_methods"
+	ContextKeyResponseStream     = "response_stream"
+	ContextKeyGroupOverride      = "group_override"
+	ContextKeyHop                = "hop"
+	ContextKeyIdempotencyKey     = "idempotency_key"
+	ContextKeyTrustedClientIP    = "trusted_client_ip"
 	DefaultOpTxProxyAuthHeader   = "X-Optimism-Signature"
 	DefaultMaxBatchRPCCallsLimit = 100
 	MaxBatchRPCCallsHardLimit    = 1000
 	cacheStatusHdr               = "X-Proxyd-Cache-Status"
+	noCacheHdr                   = "X-Proxyd-No-Cache"
+	groupOverrideHdr             = "X-Proxyd-Group"
+	adminTimestampHdr            = "X-Proxyd-Timestamp"
+	adminSignatureHdr            = "X-Proxyd-Signature"
+	proxydHopHdr                 = "X-Proxyd-Hop"
+	idempotencyKeyHdr            = "Idempotency-Key"
 	defaultRPCTimeout            = 10 * time.Second
 	defaultBodySizeLimit         = 256 * opt.KiB
+	defaultMaxHeaderCount        = 100
+	defaultMaxHeaderBytes        = 64 * opt.KiB
+	defaultMaxURLLength          = 8 * opt.KiB
 	defaultWSHandshakeTimeout    = 10 * time.Second
 	defaultWSReadTimeout         = 2 * time.Minute
 	defaultWSWriteTimeout        = 10 * time.Second
@@ -54,34 +88,136 @@ const (
 var emptyArrayResponse = json.RawMessage("[]")
 
 type Server struct {
-	BackendGroups           map[string]*BackendGroup
-	wsBackendGroup          *BackendGroup
-	wsMethodWhitelist       *StringSet
-	rpcMethodMappings       map[string]string
-	domainRPCMethodMappings map[string]map[string]string
-	maxBodySize             int64
-	enableRequestLog        bool
-	maxRequestBodyLogLen    int
-	authenticatedPaths      map[string]string
-	timeout                 time.Duration
-	maxUpstreamBatchSize    int
-	maxBatchSize            int
-	enableServedByHeader    bool
-	upgrader                *websocket.Upgrader
-	mainLim                 FrontendRateLimiter
-	exemptLims              map[string]FrontendRateLimiter
-	overrideLims            map[string]FrontendRateLimiter
-	senderLim               FrontendRateLimiter
-	allowedChainIds         []*big.Int
-	limExemptOrigins        []*regexp.Regexp
-	limExemptUserAgents     []*regexp.Regexp
-	globallyLimitedMethods  map[string]bool
-	rpcServer               *http.Server
-	wsServer                *http.Server
-	cache                   RPCCache
-	srvMu                   sync.Mutex
-	rateLimitHeader         string
-	ethCallOverrideRules    []EthCallRule
+	BackendGroups          map[string]*BackendGroup
+	wsBackendGroup         *BackendGroup
+	wsMethodWhitelist      *StringSet
+	maxBodySize            int64
+	maxHeaderCount         int
+	maxHeaderBytes         int
+	maxURLLength           int
+	enableRequestLog       bool
+	maxRequestBodyLogLen   int
+	authenticatedPaths     map[string]string
+	timeout                time.Duration
+	maxUpstreamBatchSize   int
+	maxBatchSize           int
+	enableServedByHeader   bool
+	upgrader               *websocket.Upgrader
+	mainLim                FrontendRateLimiter
+	exemptLims             map[string]FrontendRateLimiter
+	overrideLims           map[string]FrontendRateLimiter
+	senderLim              FrontendRateLimiter
+	allowedChainIds        []*big.Int
+	limExemptOrigins       []*regexp.Regexp
+	limExemptUserAgents    []*regexp.Regexp
+	globallyLimitedMethods map[string]bool
+	rpcServer              *http.Server
+	wsServer               *http.Server
+	cache                  RPCCache
+	srvMu                  sync.Mutex
+	rateLimitHeader        string
+	ethCallOverrideRules   []EthCallRule
+	listenDualStack        bool
+	proxyProtocol          bool
+	certificates           *CertificateStore
+	batchErrorStyle        BatchErrorStyle
+	batchErrorCode         int
+	batchMethodLimits      map[string]int
+	batchMethodLimitAction BatchMethodLimitAction
+	maxParamsLength        map[string]int
+	// encodeBatchIncrementally writes batch responses one call at a time.
+	encodeBatchIncrementally bool
+
+	strictRequestFields       bool
+	domainStrictRequestFields map[string]bool
+
+	strictContentType bool
+
+	// enableGetRPC serves single calls encoded in the query of GET requests.
+	enableGetRPC bool
+
+	// enableVerboseHealthz serves the health of every backend on /healthz.
+	enableVerboseHealthz bool
+
+	// logRouting logs the backend group and routing rule selected per call.
+	logRouting bool
+	tracer     trace.Tracer
+	// streamBackendResponses copies large responses to single calls from
+	// backends to clients as they are received.
+	streamBackendResponses bool
+	invalidParams          *invalidParamsPolicy
+
+	pendingToLatestMethods       map[string]bool
+	domainPendingToLatestMethods map[string]map[string]bool
+
+	maxWSConns int64
+	wsConns    atomic.Int64
+	// wsBackpressure queues backend messages for each WS client, if set.
+	wsBackpressure *wsBackpressure
+
+	adminToken string
+	// adminSigner, if set, requires admin requests to be signed.
+	adminSigner *adminSigner
+	// enableCallAll serves proxyd_callAll to requests bearing adminToken.
+	enableCallAll bool
+	// groupOverrideKeys are the authentication aliases allowed to choose
+	// their backend group, along with adminToken, when enableGroupOverride.
+	enableGroupOverride bool
+	groupOverrideKeys   map[string]bool
+	// ipConcurrency caps the requests in flight per client IP, if set.
+	ipConcurrency *ipConcurrencyLimiter
+	// routing holds the rpc method mappings, swapped on reloads by reloader.
+	routing         atomic.Pointer[routingConfig]
+	reloader        *reloadCoordinator
+	configSourceMtx sync.Mutex
+	configSource    func() (*Config, error)
+	// responseFilters strips fields from the results of some methods, if set.
+	responseFilters *responseFieldFilters
+	// maxHops rejects requests that went through more proxyd instances, per
+	// their hop header, if set.
+	maxHops int
+	// spendingLimiter rejects the calls of API keys over budget, if set.
+	spendingLimiter *spendingLimiter
+	// config is the config the server was started with, exported by
+	// HandleConfig along with the reloaded routing.
+	config *Config
+
+	// trustedProxies are the peers whose X-Forwarded-For is believed when
+	// checking trusted IPs.
+	trustedProxies []*net.IPNet
+
+	enableBackendNameHeader     bool
+	backendNameHeaderTrustedIPs []*net.IPNet
+
+	reorgCacheCooldown      time.Duration
+	reorgCacheBypassMethods map[string]bool
+	reorgCacheBypassUntil   atomic.Int64
+
+	enableNoCacheHeader     bool
+	noCacheHeaderTrustedIPs []*net.IPNet
+
+	enableErrorContext     bool
+	errorContextTrustedIPs []*net.IPNet
+
+	priority PriorityConfig
+
+	enableETag bool
+
+	deadLetter *DeadLetterLog
+
+	wasmHook *WASMHook
+
+	minGasPrice *big.Int
+
+	paramRouter paramRouter
+
+	errorMapper errorMapper
+	errorStatus *errorStatus
+
+	// bodyBuffers holds the buffers request bodies are read into
+	bodyBuffers *bufferPool
+
+	geoIP *GeoIP
 }
 
 type limiterFunc func(method string) bool
@@ -186,21 +322,19 @@ func NewServer(
 		rateLimitHeader = rateLimitConfig.IPHeaderOverride
 	}
 
-	return &Server{
-		BackendGroups:           backendGroups,
-		wsBackendGroup:          wsBackendGroup,
-		wsMethodWhitelist:       wsMethodWhitelist,
-		rpcMethodMappings:       rpcMethodMappings,
-		domainRPCMethodMappings: domainRPCMethodMappings,
-		maxBodySize:             maxBodySize,
-		authenticatedPaths:      authenticatedPaths,
-		timeout:                 timeout,
-		maxUpstreamBatchSize:    maxUpstreamBatchSize,
-		enableServedByHeader:    enableServedByHeader,
-		cache:                   cache,
-		enableRequestLog:        enableRequestLog,
-		maxRequestBodyLogLen:    maxRequestBodyLogLen,
-		maxBatchSize:            maxBatchSize,
+	srv := &Server{
+		BackendGroups:        backendGroups,
+		wsBackendGroup:       wsBackendGroup,
+		wsMethodWhitelist:    wsMethodWhitelist,
+		maxBodySize:          maxBodySize,
+		authenticatedPaths:   authenticatedPaths,
+		timeout:              timeout,
+		maxUpstreamBatchSize: maxUpstreamBatchSize,
+		enableServedByHeader: enableServedByHeader,
+		cache:                cache,
+		enableRequestLog:     enableRequestLog,
+		maxRequestBodyLogLen: maxRequestBodyLogLen,
+		maxBatchSize:         maxBatchSize,
 		upgrader: &websocket.Upgrader{
 			HandshakeTimeout: defaultWSHandshakeTimeout,
 		},
@@ -214,26 +348,52 @@ func NewServer(
 		limExemptUserAgents:    limExemptUserAgents,
 		rateLimitHeader:        rateLimitHeader,
 		ethCallOverrideRules:   ethCallOverrideRules,
-	}, nil
+	}
+	srv.routing.Store(&routingConfig{
+		rpcMethodMappings:       rpcMethodMappings,
+		domainRPCMethodMappings: domainRPCMethodMappings,
+	})
+	srv.reloader = newReloadCoordinator(srv.reload)
+	return srv, nil
 }
 
 func (s *Server) RPCListenAndServe(host string, port int) error {
 	s.srvMu.Lock()
 	hdlr := mux.NewRouter()
 	hdlr.HandleFunc("/healthz", s.HandleHealthz).Methods("GET")
+	if s.adminToken != "" || s.adminSigner != nil {
+		hdlr.HandleFunc("/admin/cache/flush", s.HandleCacheFlush).Methods("POST")
+		hdlr.HandleFunc("/admin/loglevel", s.HandleLogLevel).Methods("POST")
+		hdlr.HandleFunc("/admin/config", s.HandleConfig).Methods("GET")
+	}
 	hdlr.HandleFunc("/", s.HandleRPC).Methods("POST")
 	hdlr.HandleFunc("/{authorization}", s.HandleRPC).Methods("POST")
+	if s.enableGetRPC {
+		hdlr.HandleFunc("/", s.HandleGetRPC).Methods("GET")
+		hdlr.HandleFunc("/{authorization}", s.HandleGetRPC).Methods("GET")
+	}
 	c := cors.New(cors.Options{
 		AllowedOrigins: []string{"*"},
 	})
-	addr := fmt.Sprintf("%s:%d", host, port)
+	ln, err := Listen(host, port, s.listenDualStack)
+	if err != nil {
+		s.srvMu.Unlock()
+		return err
+	}
+	if s.proxyProtocol {
+		ln = &proxyProtocolListener{ln}
+	}
+	if s.certificates != nil {
+		ln = tls.NewListener(ln, s.certificates.TLSConfig())
+	}
 	s.rpcServer = &http.Server{
-		Handler: instrumentedHdlr(c.Handler(hdlr)),
-		Addr:    addr,
+		Handler:        instrumentedHdlr(s.limitRequestHeaders(c.Handler(hdlr))),
+		Addr:           ln.Addr().String(),
+		MaxHeaderBytes: s.maxHeaderBytes,
 	}
-	log.Info("starting HTTP server", "addr", addr)
+	log.Info("starting HTTP server", "addr", s.rpcServer.Addr)
 	s.srvMu.Unlock()
-	return s.rpcServer.ListenAndServe()
+	return s.rpcServer.Serve(ln)
 }
 
 func (s *Server) WSListenAndServe(host string, port int) error {
@@ -244,14 +404,45 @@ func (s *Server) WSListenAndServe(host string, port int) error {
 	c := cors.New(cors.Options{
 		AllowedOrigins: []string{"*"},
 	})
-	addr := fmt.Sprintf("%s:%d", host, port)
+	ln, err := Listen(host, port, s.listenDualStack)
+	if err != nil {
+		s.srvMu.Unlock()
+		return err
+	}
+	if s.proxyProtocol {
+		ln = &proxyProtocolListener{ln}
+	}
+	if s.certificates != nil {
+		ln = tls.NewListener(ln, s.certificates.TLSConfig())
+	}
 	s.wsServer = &http.Server{
-		Handler: instrumentedHdlr(c.Handler(hdlr)),
-		Addr:    addr,
+		Handler:        instrumentedHdlr(s.limitRequestHeaders(c.Handler(hdlr))),
+		Addr:           ln.Addr().String(),
+		MaxHeaderBytes: s.maxHeaderBytes,
 	}
-	log.Info("starting WS server", "addr", addr)
+	log.Info("starting WS server", "addr", s.wsServer.Addr)
 	s.srvMu.Unlock()
-	return s.wsServer.ListenAndServe()
+	return s.wsServer.Serve(ln)
+}
+
+// Listen opens a TCP listener on host:port. The host may be a hostname, an
+// IPv4 literal, or a bare or bracketed IPv6 literal. Listeners on IPv6 hosts
+// only accept IPv6 connections unless dualStack is set.
+func Listen(host string, port int, dualStack bool) (net.Listener, error) {
+	host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+	network := "tcp"
+	if ip := net.ParseIP(host); ip != nil && ip.To4() == nil && !dualStack {
+		network = "tcp6"
+	}
+	return net.Listen(network, net.JoinHostPort(host, strconv.Itoa(port)))
+}
+
+// ReloadTLSCertificates reads the certificate files of the listeners again.
+func (s *Server) ReloadTLSCertificates() error {
+	if s.certificates == nil {
+		return nil
+	}
+	return s.certificates.Reload()
 }
 
 func (s *Server) Shutdown() {
@@ -269,14 +460,139 @@ func (s *Server) Shutdown() {
 }
 
 func (s *Server) HandleHealthz(w http.ResponseWriter, r *http.Request) {
+	if s.enableVerboseHealthz && r.URL.Query().Get("verbose") == "true" {
+		s.handleVerboseHealthz(w)
+		return
+	}
 	_, _ = w.Write([]byte("OK"))
 }
 
+type cacheFlushRequest struct {
+	Methods []string `json:"methods"`
+	Redis   bool     `json:"redis"`
+}
+
+type cacheFlushResponse struct {
+	Removed int `json:"removed"`
+}
+
+// HandleCacheFlush removes cached RPC responses. The in-memory cache is always
+// flushed; redis is only flushed when the request asks for it.
+func (s *Server) HandleCacheFlush(w http.ResponseWriter, r *http.Request) {
+	if !s.authorizeAdmin(w, r) {
+		return
+	}
+
+	var req cacheFlushRequest
+	body, err := io.ReadAll(LimitReader(r.Body, s.maxBodySize))
+	if err != nil {
+		http.Error(w, "error reading request body", http.StatusBadRequest)
+		return
+	}
+	if len(bytes.TrimSpace(body)) > 0 {
+		if err := json.Unmarshal(body, &req); err != nil {
+			http.Error(w, "invalid request body", http.StatusBadRequest)
+			return
+		}
+	}
+
+	removed, err := s.cache.Flush(r.Context(), req.Methods, req.Redis)
+	if err != nil {
+		log.Error("error flushing cache", "removed", removed, "err", err)
+		http.Error(w, "error flushing cache", http.StatusInternalServerError)
+		return
+	}
+	log.Info("flushed cache", "methods", req.Methods, "redis", req.Redis, "removed", removed)
+
+	w.Header().Set("content-type", "application/json")
+	_ = json.NewEncoder(w).Encode(cacheFlushResponse{Removed: removed})
+}
+
+type logLevelRequest struct {
+	Module string `json:"module"`
+	// Level is empty to reset the module to the default level.
+	Level string `json:"level"`
+}
+
+type logLevelResponse struct {
+	Default string            `json:"default"`
+	Modules map[string]string `json:"modules"`
+}
+
+// HandleLogLevel changes the log level of a module at runtime.
+func (s *Server) HandleLogLevel(w http.ResponseWriter, r *http.Request) {
+	if !s.authorizeAdmin(w, r) {
+		return
+	}
+
+	var req logLevelRequest
+	body, err := io.ReadAll(LimitReader(r.Body, s.maxBodySize))
+	if err != nil {
+		http.Error(w, "error reading request body", http.StatusBadRequest)
+		return
+	}
+	if err := json.Unmarshal(body, &req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if req.Level == "" {
+		err = ResetModuleLogLevel(req.Module)
+	} else {
+		var level slog.Level
+		level, err = LevelFromString(req.Level)
+		if err == nil {
+			err = SetModuleLogLevel(req.Module, level)
+		}
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	log.Info("changed module log level", "module", req.Module, "level", req.Level)
+
+	var res logLevelResponse
+	res.Default, res.Modules = logLevels.snapshot()
+	w.Header().Set("content-type", "application/json")
+	_ = json.NewEncoder(w).Encode(res)
+}
+
+// authorizeAdmin checks the bearer token and the signature of an admin
+// request, whichever are configured, answering it with a 401 if they don't
+// match.
+func (s *Server) authorizeAdmin(w http.ResponseWriter, r *http.Request) bool {
+	if s.adminToken != "" && !s.isAdminRequest(r) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return false
+	}
+	if s.adminSigner != nil {
+		if err := s.adminSigner.verify(r, s.maxBodySize, time.Now()); err != nil {
+			log.Warn("rejecting unsigned admin request", "path", r.URL.Path, "err", err)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return false
+		}
+	}
+	return true
+}
+
+// isAdminRequest reports whether r bears the admin token.
+func (s *Server) isAdminRequest(r *http.Request) bool {
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return s.adminToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(s.adminToken)) == 1
+}
+
 func (s *Server) HandleRPC(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
 	ctx := s.populateContext(w, r)
 	if ctx == nil {
 		return
 	}
+	ctx, backendTime := withBackendTime(ctx)
+	defer func() {
+		RecordProxydOverhead(time.Since(start) - backendTime.Elapsed())
+	}()
+	ctx, span := s.startRequestSpan(ctx, r)
+	defer span.End()
 	var cancel context.CancelFunc
 	ctx, cancel = context.WithTimeout(ctx, s.timeout)
 	defer cancel()
@@ -290,7 +606,37 @@ func (s *Server) HandleRPC(w http.ResponseWriter, r *http.Request) {
 	isUnlimitedUserAgent := s.isUnlimitedUserAgent(userAgent)
 
 	if xff == "" {
-		writeRPCError(ctx, w, nil, ErrInvalidRequest("request does not include a remote IP"))
+		s.writeRPCError(ctx, w, nil, ErrInvalidRequest("request does not include a remote IP"))
+		return
+	}
+
+	if s.maxHops > 0 && GetHop(ctx) > s.maxHops {
+		log.Warn("rejecting request over max hops", "hop", GetHop(ctx), "remote_ip", xff, "req_id", GetReqID(ctx))
+		RecordRPCError(ctx, BackendProxyd, MethodUnknown, ErrMaxHopsExceeded)
+		s.writeRPCError(ctx, w, nil, ErrMaxHopsExceeded)
+		return
+	}
+
+	if s.ipConcurrency != nil {
+		if !s.ipConcurrency.acquire(xff) {
+			log.Warn("rejecting request over client IP concurrency limit", "remote_ip", xff, "req_id", GetReqID(ctx))
+			RecordRPCError(ctx, BackendProxyd, MethodUnknown, ErrOverIPConcurrencyLimit)
+			s.writeRPCError(ctx, w, nil, ErrOverIPConcurrencyLimit)
+			return
+		}
+		defer s.ipConcurrency.release(xff)
+	}
+
+	if s.strictContentType && !isJSONContentType(r.Header.Get("Content-Type")) {
+		RecordRPCError(ctx, BackendProxyd, MethodUnknown, ErrUnsupportedContentType)
+		s.writeRPCError(ctx, w, nil, ErrUnsupportedContentType)
+		return
+	}
+
+	ctx, err := s.withGroupOverride(ctx, r)
+	if err != nil {
+		RecordRPCError(ctx, BackendProxyd, MethodUnknown, err)
+		s.writeRPCError(ctx, w, nil, err)
 		return
 	}
 
@@ -365,26 +711,28 @@ func (s *Server) HandleRPC(w http.ResponseWriter, r *http.Request) {
 		"remote_ip", xff,
 	)
 
-	body, err := io.ReadAll(LimitReader(r.Body, s.maxBodySize))
+	bodyBuf, err := s.bodyBuffers.readAll(LimitReader(r.Body, s.maxBodySize))
+	defer s.bodyBuffers.put(bodyBuf)
+	body := bodyBuf.Bytes()
 	if errors.Is(err, ErrLimitReaderOverLimit) {
 		log.Error("request body too large", "req_id", GetReqID(ctx))
 		RecordRPCError(ctx, BackendProxyd, MethodUnknown, ErrRequestBodyTooLarge)
-		writeRPCError(ctx, w, nil, ErrRequestBodyTooLarge)
+		s.writeRPCError(ctx, w, nil, ErrRequestBodyTooLarge)
 		return
 	}
 	if err != nil {
 		log.Error("error reading request body", "err", err)
-		writeRPCError(ctx, w, nil, ErrInternal)
+		s.writeRPCError(ctx, w, nil, ErrInternal)
 		return
 	}
 	RecordRequestPayloadSize(ctx, len(body))
 
 	if s.enableRequestLog {
-		log.Info("Raw RPC request",
+		log.Info("Raw RPC request", append([]interface{}{
 			"body", truncate(string(body), s.maxRequestBodyLogLen),
 			"req_id", GetReqID(ctx),
 			"auth", GetAuthCtx(ctx),
-		)
+		}, geoLogFields(ctx)...)...)
 	}
 
 	if IsBatch(body) {
@@ -392,7 +740,7 @@ func (s *Server) HandleRPC(w http.ResponseWriter, r *http.Request) {
 		if err != nil {
 			log.Error("error parsing batch RPC request", "err", err)
 			RecordRPCError(ctx, BackendProxyd, MethodUnknown, err)
-			writeRPCError(ctx, w, nil, ErrParseErr)
+			s.writeRPCError(ctx, w, nil, ErrParseErr)
 			return
 		}
 
@@ -400,53 +748,123 @@ func (s *Server) HandleRPC(w http.ResponseWriter, r *http.Request) {
 
 		if len(reqs) > s.maxBatchSize {
 			RecordRPCError(ctx, BackendProxyd, MethodUnknown, ErrTooManyBatchRequests)
-			writeRPCError(ctx, w, nil, ErrTooManyBatchRequests)
+			s.writeOversizedBatchError(ctx, w, reqs)
 			return
 		}
 
 		if len(reqs) == 0 {
-			writeRPCError(ctx, w, nil, ErrInvalidRequest("must specify at least one batch call"))
+			err := ErrInvalidRequest("must specify at least one batch call")
+			RecordRPCError(ctx, BackendProxyd, MethodUnknown, err)
+			s.writeRPCError(ctx, w, nil, err)
 			return
 		}
 
+		if s.batchMethodLimitAction == BatchMethodLimitActionBatch && s.exceedsBatchMethodLimits(reqs) {
+			RecordRPCError(ctx, BackendProxyd, MethodUnknown, ErrTooManyMethodCallsInBatch)
+			s.writeRPCError(ctx, w, nil, ErrTooManyMethodCallsInBatch)
+			return
+		}
+
+		ctx = withResponseMethods(ctx, len(reqs))
+		span.SetAttributes(attrMethod.String("<batch>"), attrBatchSize.Int(len(reqs)))
 		batchRes, batchContainsCached, servedBy, err := s.handleBatchRPC(ctx, reqs, isLimited, true, origin)
+		span.SetAttributes(attrCacheHit.Bool(batchContainsCached), attrServedBy.String(servedBy))
 		if err == context.DeadlineExceeded {
-			writeRPCError(ctx, w, nil, ErrGatewayTimeout)
+			s.writeRPCError(ctx, w, nil, ErrGatewayTimeout)
 			return
 		}
 		if errors.Is(err, ErrConsensusGetReceiptsCantBeBatched) ||
 			errors.Is(err, ErrConsensusGetReceiptsInvalidTarget) {
-			writeRPCError(ctx, w, nil, ErrInvalidRequest(err.Error()))
+			s.writeRPCError(ctx, w, nil, ErrInvalidRequest(err.Error()))
 			return
 		}
 		if err != nil {
-			writeRPCError(ctx, w, nil, ErrInternal)
+			s.writeRPCError(ctx, w, nil, ErrInternal)
 			return
 		}
 		if s.enableServedByHeader {
 			w.Header().Set("x-served-by", servedBy)
 		}
+		s.setBackendNameHeader(ctx, w, servedBy)
 		setCacheHeader(w, batchContainsCached)
-		writeBatchRPCRes(ctx, w, batchRes)
+		if s.encodeBatchIncrementally {
+			writeBatchRPCResIncrementally(ctx, w, batchRes)
+		} else {
+			writeBatchRPCRes(ctx, w, batchRes)
+		}
 		return
 	}
 
 	rawBody := json.RawMessage(body)
+	ctx = withResponseMethods(ctx, 1)
+	var stream *responseStream
+	if s.streamBackendResponses {
+		ctx, stream = withResponseStream(ctx, w)
+	}
 	backendRes, cached, servedBy, err := s.handleBatchRPC(ctx, []json.RawMessage{rawBody}, isLimited, false, origin)
+	if stream != nil && stream.streamed.Load() {
+		if stream.failed.Load() {
+			// abort the connection so the client doesn't take a truncated
+			// body for a complete one
+			panic(http.ErrAbortHandler)
+		}
+		return
+	}
+	span.SetAttributes(attrCacheHit.Bool(cached), attrServedBy.String(servedBy))
 	if err != nil {
 		if errors.Is(err, ErrConsensusGetReceiptsCantBeBatched) ||
 			errors.Is(err, ErrConsensusGetReceiptsInvalidTarget) {
-			writeRPCError(ctx, w, nil, ErrInvalidRequest(err.Error()))
+			s.writeRPCError(ctx, w, nil, ErrInvalidRequest(err.Error()))
 			return
 		}
-		writeRPCError(ctx, w, nil, ErrInternal)
+		s.writeRPCError(ctx, w, nil, ErrInternal)
 		return
 	}
 	if s.enableServedByHeader {
 		w.Header().Set("x-served-by", servedBy)
 	}
+	s.setBackendNameHeader(ctx, w, servedBy)
 	setCacheHeader(w, cached)
-	writeRPCRes(ctx, w, backendRes[0])
+	if s.enableETag {
+		if etag := s.responseETag(body, backendRes[0]); etag != "" {
+			w.Header().Set("ETag", etag)
+			if etagMatches(r.Header.Get("If-None-Match"), etag) {
+				w.WriteHeader(http.StatusNotModified)
+				httpResponseCodesTotal.WithLabelValues(strconv.Itoa(http.StatusNotModified)).Inc()
+				return
+			}
+		}
+	}
+	s.writeRPCRes(ctx, w, backendRes[0])
+}
+
+// responseETag returns the ETag of a successful response to a cacheable
+// method, computed from its result so that it doesn't depend on the request ID.
+func (s *Server) responseETag(body []byte, res *RPCRes) string {
+	if res.IsError() || res.Result == nil {
+		return ""
+	}
+	req, err := ParseRPCReq(body)
+	if err != nil || !s.cache.IsCacheable(req.Method) {
+		return ""
+	}
+	result, err := json.Marshal(res.Result)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(result)
+	return fmt.Sprintf("\"%x\"", sum[:16])
+}
+
+// etagMatches reports whether an If-None-Match header value matches etag.
+func etagMatches(ifNoneMatch string, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
 }
 
 func (s *Server) handleBatchRPC(ctx context.Context, reqs []json.RawMessage, isLimited limiterFunc, isBatch bool, origin string) ([]*RPCRes, bool, string, error) {
@@ -467,6 +885,7 @@ func (s *Server) handleBatchRPC(ctx context.Context, reqs []json.RawMessage, isL
 	responses := make([]*RPCRes, len(reqs))
 	batches := make(map[batchGroup][]batchElem)
 	ids := make(map[string]int, len(reqs))
+	methodCounts := make(map[string]int)
 
 	for i := range reqs {
 		parsedReq, err := ParseRPCReq(reqs[i])
@@ -491,9 +910,38 @@ func (s *Server) handleBatchRPC(ctx context.Context, reqs []json.RawMessage, isL
 			responses[i] = NewRPCErrorRes(nil, err)
 			continue
 		}
+		if _, ok := rpcMethodMappings[parsedReq.Method]; ok {
+			setResponseMethod(ctx, i, parsedReq.Method)
+		}
+		if !isBatch {
+			trace.SpanFromContext(ctx).SetAttributes(attrMethod.String(parsedReq.Method))
+		}
+
+		if s.isStrictRequestFields(origin) {
+			if err := ValidateRPCReqFields(reqs[i]); err != nil {
+				RecordRPCError(ctx, BackendProxyd, MethodUnknown, err)
+				responses[i] = NewRPCErrorRes(nil, err)
+				continue
+			}
+		}
+
+		if err := s.checkParamsLength(parsedReq); err != nil {
+			RecordRPCError(ctx, BackendProxyd, parsedReq.Method, err)
+			responses[i] = NewRPCErrorRes(parsedReq.ID, err)
+			continue
+		}
+
+		if s.wasmHook != nil {
+			if err := s.wasmHook.Apply(ctx, parsedReq); err != nil {
+				RecordRPCError(ctx, BackendProxyd, MethodUnknown, err)
+				responses[i] = NewRPCErrorRes(parsedReq.ID, err)
+				continue
+			}
+		}
 
 		if parsedReq.Method == "eth_accounts" {
 			RecordRPCForward(ctx, BackendProxyd, "eth_accounts", RPCRequestSourceHTTP)
+			s.logRoute(ctx, origin, parsedReq.Method, "", routeRuleEthAccounts)
 			responses[i] = NewRPCRes(parsedReq.ID, emptyArrayResponse)
 			continue
 		}
@@ -501,11 +949,19 @@ func (s *Server) handleBatchRPC(ctx context.Context, reqs []json.RawMessage, isL
 		if parsedReq.Method == "eth_call" {
 			if result := s.checkEthCallOverride(ctx, parsedReq); result != nil {
 				RecordRPCForward(ctx, BackendProxyd, "eth_call", RPCRequestSourceHTTP)
+				s.logRoute(ctx, origin, parsedReq.Method, "", routeRuleEthCallOverride)
 				responses[i] = NewRPCRes(parsedReq.ID, result)
 				continue
 			}
 		}
 
+		if parsedReq.Method == CallAllMethod && s.enableCallAll {
+			RecordRPCForward(ctx, BackendProxyd, CallAllMethod, RPCRequestSourceHTTP)
+			s.logRoute(ctx, origin, parsedReq.Method, "", routeRuleCallAll)
+			responses[i] = s.callAll(ctx, parsedReq, rpcMethodMappings)
+			continue
+		}
+
 		group := rpcMethodMappings[parsedReq.Method]
 		if group == "" {
 			// use unknown below to prevent DOS vector that fills up memory
@@ -517,10 +973,51 @@ func (s *Server) handleBatchRPC(ctx context.Context, reqs []json.RawMessage, isL
 				"method", parsedReq.Method,
 			)
 			RecordRPCError(ctx, BackendProxyd, MethodUnknown, ErrMethodNotWhitelisted)
+			s.logRoute(ctx, origin, parsedReq.Method, "", routeRuleNotWhitelisted)
 			responses[i] = NewRPCErrorRes(parsedReq.ID, ErrMethodNotWhitelisted)
 			continue
 		}
 
+		if parsedReq.Method == "net_version" {
+			if bg := s.BackendGroups[group]; bg != nil && bg.netVersion != "" {
+				RecordRPCForward(ctx, BackendProxyd, parsedReq.Method, RPCRequestSourceHTTP)
+				s.logRoute(ctx, origin, parsedReq.Method, "", routeRuleNetVersion)
+				responses[i] = NewRPCRes(parsedReq.ID, bg.netVersion)
+				continue
+			}
+		}
+
+		rule := routeRuleMethodMapping
+
+		if s.isPendingToLatest(origin, parsedReq.Method) {
+			if _, err := RewritePendingToLatest(parsedReq); err != nil {
+				log.Debug("error rewriting pending block tag", "err", err, "req_id", GetReqID(ctx))
+			}
+		}
+
+		if routed := s.paramRouter.Route(parsedReq); routed != "" {
+			RecordParamRouted(parsedReq.Method, routed)
+			group = routed
+			rule = routeRuleParamRoute
+		}
+
+		if bg := s.BackendGroups[group]; bg != nil && bg.replica != nil {
+			if routed := bg.replica.Route(parsedReq); routed != "" {
+				RecordReplicaRouted(parsedReq.Method, routed)
+				group = routed
+				rule = routeRuleReplica
+			}
+		}
+
+		if limit, ok := s.batchMethodLimits[parsedReq.Method]; ok && isBatch {
+			methodCounts[parsedReq.Method]++
+			if methodCounts[parsedReq.Method] > limit {
+				RecordRPCError(ctx, BackendProxyd, parsedReq.Method, ErrTooManyMethodCallsInBatch)
+				responses[i] = NewRPCErrorRes(parsedReq.ID, ErrTooManyMethodCallsInBatch)
+				continue
+			}
+		}
+
 		// Check rate limit (method override if exists, otherwise base rate)
 		if isLimited(parsedReq.Method) {
 			log.Debug(
@@ -534,6 +1031,26 @@ func (s *Server) handleBatchRPC(ctx context.Context, reqs []json.RawMessage, isL
 			continue
 		}
 
+		if !s.spendingLimiter.Charge(ctx, parsedReq.Method) {
+			log.Debug(
+				"spending limited RPC",
+				"req_id", GetReqID(ctx),
+				"auth", GetAuthCtx(ctx),
+				"method", parsedReq.Method,
+			)
+			RecordRPCError(ctx, BackendProxyd, parsedReq.Method, ErrOverSpendingLimit)
+			responses[i] = NewRPCErrorRes(parsedReq.ID, ErrOverSpendingLimit)
+			continue
+		}
+
+		if parsedReq.Method == "eth_sendRawTransaction" && s.minGasPrice != nil {
+			if err := s.checkMinGasPrice(ctx, parsedReq); err != nil {
+				RecordRPCError(ctx, BackendProxyd, parsedReq.Method, err)
+				responses[i] = NewRPCErrorRes(parsedReq.ID, err)
+				continue
+			}
+		}
+
 		// Apply a sender-based rate limit if it is enabled. Note that sender-based rate
 		// limits apply regardless of origin or user-agent. As such, they don't use the
 		// isLimited method.
@@ -545,7 +1062,17 @@ func (s *Server) handleBatchRPC(ctx context.Context, reqs []json.RawMessage, isL
 			}
 		}
 
-		id := string(parsedReq.ID)
+		if spillover := s.selectSpilloverGroup(ctx, group, i); spillover != group {
+			group = spillover
+			rule = routeRuleSpillover
+		}
+		if override := GetGroupOverride(ctx); override != "" {
+			group = override
+			rule = routeRuleGroupOverride
+		}
+		s.logRoute(ctx, origin, parsedReq.Method, group, rule)
+
+		id := idKey(parsedReq.ID)
 		// If this is a duplicate Request ID, move the Request to a new batchGroup
 		ids[id]++
 		batchGroupID := ids[id]
@@ -554,12 +1081,43 @@ func (s *Server) handleBatchRPC(ctx context.Context, reqs []json.RawMessage, isL
 	}
 
 	servedBy := make(map[string]bool, 0)
+	var servedByMtx sync.Mutex
 	var cached bool
+	noCache := s.isNoCacheRequested(ctx)
+	errorContext := s.isErrorContextTrusted(ctx)
 	for group, batch := range batches {
 		var cacheMisses []batchElem
+		bg := s.BackendGroups[group.backendGroup]
+		txDedup := bg.txDedup
 
 		for _, req := range batch {
-			backendRes, _ := s.cache.GetRPC(ctx, req.Req)
+			if txDedup != nil {
+				if dedupRes := txDedup.Get(ctx, req); dedupRes != nil {
+					RecordSendRawTxDedupHit(group.backendGroup)
+					responses[req.Index] = dedupRes
+					continue
+				}
+			}
+			if bg.balanceSnapshot != nil && bg.Consensus != nil {
+				if snapshotRes := bg.balanceSnapshot.Get(req.Req, bg.Consensus.GetFinalizedBlockNumber()); snapshotRes != nil {
+					RecordBalanceSnapshotHit(group.backendGroup)
+					responses[req.Index] = snapshotRes
+					continue
+				}
+			}
+			if noCache {
+				cacheMisses = append(cacheMisses, req)
+				continue
+			}
+			if s.isReorgCacheBypassed(req.Req.Method) {
+				RecordCacheReorgBypass(req.Req.Method)
+				cacheMisses = append(cacheMisses, req)
+				continue
+			}
+			cacheCtx, cacheSpan := startChildSpan(ctx, "proxyd.cache", trace.SpanKindInternal, attrMethod.String(req.Req.Method))
+			backendRes, _ := s.cache.GetRPC(cacheCtx, req.Req)
+			cacheSpan.SetAttributes(attrCacheHit.Bool(backendRes != nil))
+			cacheSpan.End()
 			if backendRes != nil {
 				responses[req.Index] = backendRes
 				cached = true
@@ -568,8 +1126,25 @@ func (s *Server) handleBatchRPC(ctx context.Context, reqs []json.RawMessage, isL
 			}
 		}
 
+		if bg.cacheOnBackoff && !noCache && len(cacheMisses) > 0 && bg.preferredBackendBackingOff() {
+			var backoffCached bool
+			cacheMisses, backoffCached = s.serveFromBackoffCache(ctx, bg, cacheMisses, responses)
+			cached = cached || backoffCached
+		}
+
+		if bg.cacheOnlyOnOutage && len(cacheMisses) > 0 && !bg.hasHealthyBackends() {
+			if serveFromOutageCache(ctx, bg, cacheMisses, responses) {
+				cached = true
+			}
+			if txDedup != nil {
+				txDedup.Release(ctx, cacheMisses)
+			}
+			continue
+		}
+
 		// Create minibatches - each minibatch must be no larger than the maxUpstreamBatchSize
 		numBatches := int(math.Ceil(float64(len(cacheMisses)) / float64(s.maxUpstreamBatchSize)))
+		fanout := newBatchFanout(bg.batchFanoutConcurrency)
 		for i := 0; i < numBatches; i++ {
 			if ctx.Err() == context.DeadlineExceeded {
 				log.Info("short-circuiting batch RPC",
@@ -578,45 +1153,34 @@ func (s *Server) handleBatchRPC(ctx context.Context, reqs []json.RawMessage, isL
 					"batch_index", i,
 				)
 				batchRPCShortCircuitsTotal.Inc()
+				_ = fanout.wait()
+				if txDedup != nil {
+					txDedup.Release(ctx, cacheMisses[i*s.maxUpstreamBatchSize:])
+				}
 				return nil, false, "", context.DeadlineExceeded
 			}
 
 			start := i * s.maxUpstreamBatchSize
 			end := int(math.Min(float64(start+s.maxUpstreamBatchSize), float64(len(cacheMisses))))
 			elems := cacheMisses[start:end]
-			res, sb, err := s.BackendGroups[group.backendGroup].Forward(ctx, createBatchRequest(elems), isBatch)
-			servedBy[sb] = true
-			if err != nil {
-				if errors.Is(err, ErrConsensusGetReceiptsCantBeBatched) ||
-					errors.Is(err, ErrConsensusGetReceiptsInvalidTarget) {
-					return nil, false, "", err
-				}
-				log.Error(
-					"error forwarding RPC batch",
-					"batch_size", len(elems),
-					"backend_group", group,
-					"req_id", GetReqID(ctx),
-					"err", err,
-				)
-				res = nil
-				for _, elem := range elems {
-					res = append(res, NewRPCErrorRes(elem.Req.ID, err))
-				}
-			}
+			fanout.run(func() error {
+				sb, batchCached, err := s.forwardMinibatch(ctx, bg, elems, isBatch, noCache, errorContext, responses)
+				servedByMtx.Lock()
+				servedBy[sb] = true
+				cached = cached || batchCached
+				servedByMtx.Unlock()
+				return err
+			})
+		}
+		if err := fanout.wait(); err != nil {
+			return nil, false, "", err
+		}
+	}
 
-			for i := range elems {
-				responses[elems[i].Index] = res[i]
-
-				// TODO(inphi): batch put these
-				if res[i].Error == nil && res[i].Result != nil {
-					if err := s.cache.PutRPC(ctx, elems[i].Req, res[i]); err != nil {
-						log.Warn(
-							"cache put error",
-							"req_id", GetReqID(ctx),
-							"err", err,
-						)
-					}
-				}
+	if s.responseFilters != nil {
+		for _, batch := range batches {
+			for _, elem := range batch {
+				responses[elem.Index] = s.responseFilters.Apply(origin, elem.Req.Method, responses[elem.Index])
 			}
 		}
 	}
@@ -632,6 +1196,233 @@ func (s *Server) handleBatchRPC(ctx context.Context, reqs []json.RawMessage, isL
 	return responses, cached, servedByString, nil
 }
 
+// forwardMinibatch forwards elems to bg and sets their responses. It returns
+// which backend served them, whether they were served from the outage cache,
+// and an error if the whole request should fail.
+func (s *Server) forwardMinibatch(
+	ctx context.Context,
+	bg *BackendGroup,
+	elems []batchElem,
+	isBatch bool,
+	noCache bool,
+	errorContext bool,
+	responses []*RPCRes,
+) (string, bool, error) {
+	batchReqs := createBatchRequest(elems)
+	forwardCtx := s.withPriority(ctx, batchReqs)
+	var attempts *backendAttempts
+	if s.deadLetter != nil || errorContext {
+		forwardCtx, attempts = withBackendAttempts(forwardCtx)
+	}
+	if !s.canStreamResponse(bg, batchReqs, isBatch) {
+		forwardCtx = withoutResponseStream(forwardCtx)
+	}
+	var res []*RPCRes
+	var sb string
+	var err error
+	if bg.coalescer != nil && bg.coalescer.Coalesces(batchReqs) {
+		res, sb, err = bg.coalescer.Forward(forwardCtx, batchReqs[0], func(ctx context.Context) ([]*RPCRes, string, error) {
+			return bg.Forward(ctx, batchReqs, isBatch)
+		})
+	} else {
+		res, sb, err = bg.Forward(forwardCtx, batchReqs, isBatch)
+	}
+	forwardFailed := err != nil
+	if err != nil {
+		if errors.Is(err, ErrConsensusGetReceiptsCantBeBatched) ||
+			errors.Is(err, ErrConsensusGetReceiptsInvalidTarget) {
+			if bg.txDedup != nil {
+				bg.txDedup.Release(ctx, elems)
+			}
+			return sb, false, err
+		}
+		if bg.cacheOnlyOnOutage && errors.Is(err, ErrNoBackends) {
+			if bg.txDedup != nil {
+				bg.txDedup.Release(ctx, elems)
+			}
+			return sb, serveFromOutageCache(ctx, bg, elems, responses), nil
+		}
+		log.Error(
+			"error forwarding RPC batch",
+			"batch_size", len(elems),
+			"backend_group", bg.Name,
+			"req_id", GetReqID(ctx),
+			"err", err,
+		)
+		res = nil
+		for _, elem := range elems {
+			res = append(res, NewRPCErrorRes(elem.Req.ID, err))
+		}
+		if s.deadLetter != nil {
+			for i, elem := range elems {
+				s.deadLetter.Record(ctx, elem.Req, res[i], attempts.list())
+			}
+		}
+	}
+
+	for i := range elems {
+		if bg.txDedup != nil {
+			res[i] = bg.txDedup.Observe(ctx, elems[i], res[i])
+		}
+		if bg.balanceSnapshot != nil && bg.Consensus != nil {
+			bg.balanceSnapshot.Observe(elems[i].Req, bg.Consensus.GetFinalizedBlockNumber(), res[i])
+		}
+		res[i] = s.errorMapper.Map(res[i])
+		if forwardFailed && errorContext {
+			res[i] = withErrorContext(res[i], bg.Name, attempts.list())
+		}
+		responses[elems[i].Index] = res[i]
+
+		// TODO(inphi): batch put these
+		if res[i].Error == nil && res[i].Result != nil && !noCache && !s.isReorgCacheBypassed(elems[i].Req.Method) {
+			if err := s.cache.PutRPC(ctx, elems[i].Req, res[i]); err != nil {
+				log.Warn(
+					"cache put error",
+					"req_id", GetReqID(ctx),
+					"err", err,
+				)
+			}
+			if bg.outageCache != nil && s.cache.IsCacheable(elems[i].Req.Method) {
+				bg.outageCache.Put(ctx, elems[i].Req, res[i])
+			}
+		}
+	}
+	return sb, false, nil
+}
+
+// canStreamResponse returns whether the response to reqs may be streamed to
+// the client by the backend serving it, rather than buffered to be cached or
+// transformed.
+func (s *Server) canStreamResponse(bg *BackendGroup, reqs []*RPCReq, isBatch bool) bool {
+	if isBatch || len(reqs) != 1 {
+		return false
+	}
+	if bg.coalescer != nil && bg.coalescer.Coalesces(reqs) {
+		return false
+	}
+	if s.responseFilters.Filters(reqs[0].Method) {
+		return false
+	}
+	return !s.cache.IsCacheable(reqs[0].Method) && bg.txDedup == nil && bg.balanceSnapshot == nil
+}
+
+// withPriority sets the priority level of a forwarded batch, which is the
+// highest level of its API key and methods.
+func (s *Server) withPriority(ctx context.Context, reqs []*RPCReq) context.Context {
+	if !s.priority.Enabled() {
+		return ctx
+	}
+	priority := s.priority.Default
+	if keyPriority, ok := s.priority.Keys[GetAuthCtx(ctx)]; ok {
+		priority = keyPriority
+	}
+	for _, req := range reqs {
+		if methodPriority, ok := s.priority.Methods[req.Method]; ok && methodPriority > priority {
+			priority = methodPriority
+		}
+	}
+	return context.WithValue(ctx, ContextKeyPriority, priority) // nolint:staticcheck
+}
+
+// startReorgCacheCooldown bypasses the cache for reorg sensitive methods until
+// the cooldown elapses. It is registered as a consensus poller listener.
+func (s *Server) startReorgCacheCooldown() {
+	until := time.Now().Add(s.reorgCacheCooldown)
+	s.reorgCacheBypassUntil.Store(until.UnixNano())
+	log.Warn("reorg detected, bypassing cache", "until", until)
+}
+
+func (s *Server) isReorgCacheBypassed(method string) bool {
+	if !s.reorgCacheBypassMethods[method] {
+		return false
+	}
+	return time.Now().UnixNano() < s.reorgCacheBypassUntil.Load()
+}
+
+// setBackendNameHeader sets X-Backend-Name to the comma-separated backends
+// found in servedBy, whose entries have the form "<group>/<backend>".
+func (s *Server) setBackendNameHeader(ctx context.Context, w http.ResponseWriter, servedBy string) {
+	if !s.enableBackendNameHeader || !s.isBackendNameHeaderTrusted(ctx) {
+		return
+	}
+
+	seen := make(map[string]bool)
+	names := make([]string, 0)
+	for _, sb := range strings.Split(servedBy, ", ") {
+		_, name, ok := strings.Cut(sb, "/")
+		if !ok || name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		names = append(names, name)
+	}
+	if len(names) == 0 {
+		return
+	}
+	sort.Strings(names)
+	w.Header().Set("X-Backend-Name", strings.Join(names, ","))
+}
+
+func (s *Server) isBackendNameHeaderTrusted(ctx context.Context) bool {
+	return isTrustedPeer(ctx, s.backendNameHeaderTrustedIPs)
+}
+
+// isNoCacheRequested reports whether the request asked to skip the cache with
+// X-Proxyd-No-Cache and is allowed to.
+func (s *Server) isNoCacheRequested(ctx context.Context) bool {
+	noCache, _ := ctx.Value(ContextKeyNoCache).(bool)
+	return noCache && s.enableNoCacheHeader && isTrustedPeer(ctx, s.noCacheHeaderTrustedIPs)
+}
+
+// isErrorContextTrusted reports whether errors returned to the request may
+// carry an ErrorContext.
+func (s *Server) isErrorContextTrusted(ctx context.Context) bool {
+	return s.enableErrorContext && isTrustedPeer(ctx, s.errorContextTrustedIPs)
+}
+
+// trustedClientIP returns the IP of the client of r for the trusted IPs
+// checks. Unlike the rate limit IP, it can't be set by the client: it is the
+// peer address, which is the PROXY protocol source address when enabled, or
+// for peers that are trusted proxies, the last X-Forwarded-For entry, which
+// the proxy appended.
+func (s *Server) trustedClientIP(r *http.Request) string {
+	peer, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return ""
+	}
+	if !containsIP(s.trustedProxies, net.ParseIP(peer)) {
+		return peer
+	}
+	xff := r.Header.Values("X-Forwarded-For")
+	if len(xff) == 0 {
+		return peer
+	}
+	entries := strings.Split(xff[len(xff)-1], ",")
+	return strings.TrimSpace(entries[len(entries)-1])
+}
+
+// isTrustedPeer reports whether the trusted client IP of the request is
+// within trustedIPs. All clients are trusted when trustedIPs is empty.
+func isTrustedPeer(ctx context.Context, trustedIPs []*net.IPNet) bool {
+	if len(trustedIPs) == 0 {
+		return true
+	}
+	ip, _ := ctx.Value(ContextKeyTrustedClientIP).(string)
+	return containsIP(trustedIPs, net.ParseIP(ip))
+}
+
+func containsIP(ipNets []*net.IPNet, ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+	for _, ipNet := range ipNets {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
 func (s *Server) HandleWS(w http.ResponseWriter, r *http.Request) {
 	ctx := s.populateContext(w, r)
 	if ctx == nil {
@@ -640,8 +1431,16 @@ func (s *Server) HandleWS(w http.ResponseWriter, r *http.Request) {
 
 	log.Info("received WS connection", "req_id", GetReqID(ctx))
 
+	if !s.acquireWSConn() {
+		log.Warn("rejecting WS connection over limit", "auth", GetAuthCtx(ctx), "req_id", GetReqID(ctx), "max_ws_connections", s.maxWSConns)
+		RecordRPCError(ctx, BackendProxyd, MethodUnknown, ErrTooManyWSConnections)
+		s.writeRPCError(ctx, w, nil, ErrTooManyWSConnections)
+		return
+	}
+
 	clientConn, err := s.upgrader.Upgrade(w, r, nil)
 	if err != nil {
+		s.releaseWSConn()
 		log.Error("error upgrading client conn", "auth", GetAuthCtx(ctx), "req_id", GetReqID(ctx), "err", err)
 		return
 	}
@@ -654,9 +1453,14 @@ func (s *Server) HandleWS(w http.ResponseWriter, r *http.Request) {
 		}
 		log.Error("error dialing ws backend", "auth", GetAuthCtx(ctx), "req_id", GetReqID(ctx), "err", err)
 		clientConn.Close()
+		s.releaseWSConn()
 		return
 	}
 
+	if s.wsBackpressure != nil {
+		proxier.queue = newWSClientQueue(s.wsBackpressure)
+	}
+
 	activeClientWsConnsGauge.WithLabelValues(GetAuthCtx(ctx)).Inc()
 	go func() {
 		// Below call blocks so run it in a goroutine.
@@ -664,23 +1468,49 @@ func (s *Server) HandleWS(w http.ResponseWriter, r *http.Request) {
 			log.Error("error proxying websocket", "auth", GetAuthCtx(ctx), "req_id", GetReqID(ctx), "err", err)
 		}
 		activeClientWsConnsGauge.WithLabelValues(GetAuthCtx(ctx)).Dec()
+		s.releaseWSConn()
 	}()
 
 	log.Info("accepted WS connection", "auth", GetAuthCtx(ctx), "req_id", GetReqID(ctx))
 }
 
+// acquireWSConn reserves a slot for a new client WS connection, returning
+// false if max_ws_connections connections are already open.
+func (s *Server) acquireWSConn() bool {
+	n := s.wsConns.Add(1)
+	if s.maxWSConns > 0 && n > s.maxWSConns {
+		s.wsConns.Add(-1)
+		return false
+	}
+	wsConnsGauge.Set(float64(n))
+	return true
+}
+
+func (s *Server) releaseWSConn() {
+	wsConnsGauge.Set(float64(s.wsConns.Add(-1)))
+}
+
 func (s *Server) populateContext(w http.ResponseWriter, r *http.Request) context.Context {
 	vars := mux.Vars(r)
 	authorization := vars["authorization"]
-	xff := r.Header.Get(s.rateLimitHeader)
+	var xff string
+	if !s.proxyProtocol {
+		xff = r.Header.Get(s.rateLimitHeader)
+	}
 	if xff == "" {
-		ipPort := strings.Split(r.RemoteAddr, ":")
-		if len(ipPort) == 2 {
-			xff = ipPort[0]
+		if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+			xff = host
 		}
 	}
 
-	ctx := context.WithValue(r.Context(), ContextKeyXForwardedFor, xff) // nolint:staticcheck
+	ctx := context.WithValue(r.Context(), ContextKeyXForwardedFor, xff)           // nolint:staticcheck
+	ctx = context.WithValue(ctx, ContextKeyTrustedClientIP, s.trustedClientIP(r)) // nolint:staticcheck
+
+	if s.geoIP != nil {
+		info := s.geoIP.Lookup(xff)
+		RecordClientCountry(info.Country)
+		ctx = context.WithValue(ctx, ContextKeyGeoInfo, info) // nolint:staticcheck
+	}
 
 	opTxProxyAuth := r.Header.Get(DefaultOpTxProxyAuthHeader)
 	if opTxProxyAuth != "" {
@@ -701,6 +1531,22 @@ func (s *Server) populateContext(w http.ResponseWriter, r *http.Request) context
 	origin := r.Header.Get("X-Forwarded-Host")
 	ctx = context.WithValue(ctx, ContextKeyOrigin, origin) // nolint:staticcheck
 
+	if hop, err := strconv.Atoi(r.Header.Get(proxydHopHdr)); err == nil && hop > 0 {
+		ctx = context.WithValue(ctx, ContextKeyHop, hop) // nolint:staticcheck
+	}
+
+	if s.enableCallAll && s.isAdminRequest(r) {
+		ctx = context.WithValue(ctx, ContextKeyAdmin, true) // nolint:staticcheck
+	}
+
+	if strings.EqualFold(r.Header.Get(noCacheHdr), "true") {
+		ctx = context.WithValue(ctx, ContextKeyNoCache, true) // nolint:staticcheck
+	}
+
+	if key := r.Header.Get(idempotencyKeyHdr); key != "" {
+		ctx = context.WithValue(ctx, ContextKeyIdempotencyKey, key) // nolint:staticcheck
+	}
+
 	// Host and X-Forwarded-Host are domain name, such as: bsc-mainnet-builder-ap.nodereal.io
 	txSource := firstNonEmpty(
 		r.Host,
@@ -746,42 +1592,127 @@ func (s *Server) isGlobalLimit(method string) bool {
 	return s.globallyLimitedMethods[method]
 }
 
+// selectSpilloverGroup routes a share of the requests mapped to group to its
+// spillover group. The choice is derived from the request ID and the index of
+// the element in the batch so that it is stable for a given request.
+func (s *Server) selectSpilloverGroup(ctx context.Context, group string, index int) string {
+	bg := s.BackendGroups[group]
+	if bg == nil || bg.spilloverGroup == "" || bg.spilloverPercent == 0 {
+		return group
+	}
+
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(GetReqID(ctx)))
+	_, _ = h.Write([]byte(strconv.Itoa(index)))
+	if int(h.Sum32()%100) < bg.spilloverPercent {
+		RecordBackendGroupSpillover(bg, bg.spilloverGroup)
+		return bg.spilloverGroup
+	}
+	RecordBackendGroupSpillover(bg, group)
+	return group
+}
+
+func (s *Server) isStrictRequestFields(origin string) bool {
+	if origin != "" {
+		if strict, ok := s.domainStrictRequestFields[origin]; ok {
+			return strict
+		}
+	}
+	return s.strictRequestFields
+}
+
+func (s *Server) isPendingToLatest(origin string, method string) bool {
+	if origin != "" {
+		if methods, ok := s.domainPendingToLatestMethods[origin]; ok {
+			return methods[method]
+		}
+	}
+	return s.pendingToLatestMethods[method]
+}
+
 func (s *Server) getRPCMethodMappings(origin string) map[string]string {
+	routing := s.routing.Load()
 	// Check if there's a domain-specific mapping for this origin
 	if origin != "" {
-		if mapping, ok := s.domainRPCMethodMappings[origin]; ok {
+		if mapping, ok := routing.domainRPCMethodMappings[origin]; ok {
 			return mapping
 		}
 	}
 	// Fallback to default mappings
-	return s.rpcMethodMappings
+	return routing.rpcMethodMappings
+}
+
+// checkMinGasPrice rejects transactions whose effective gas price, with the
+// zero base fee of BSC, is below the minimum. Undecodable transactions pass,
+// unless the invalid params policy rejects them.
+func (s *Server) checkMinGasPrice(ctx context.Context, req *RPCReq) error {
+	var params []string
+	if err := json.Unmarshal(req.Params, &params); err != nil || len(params) != 1 {
+		return s.undecodableTransaction(req, "missing value for required argument 0")
+	}
+	var data hexutil.Bytes
+	if err := data.UnmarshalText([]byte(params[0])); err != nil {
+		return s.undecodableTransaction(req, err.Error())
+	}
+	tx := new(types.Transaction)
+	if err := tx.UnmarshalBinary(data); err != nil {
+		log.Debug("could not unmarshal transaction for gas price check", "err", err, "req_id", GetReqID(ctx))
+		return s.undecodableTransaction(req, err.Error())
+	}
+
+	gasPrice, err := tx.EffectiveGasTip(common.Big0)
+	if err != nil {
+		return nil
+	}
+	if gasPrice.Cmp(s.minGasPrice) < 0 {
+		log.Debug("rejecting underpriced transaction",
+			"req_id", GetReqID(ctx),
+			"gas_price", gasPrice,
+			"min_gas_price", s.minGasPrice,
+		)
+		return &RPCErr{
+			Code:          ErrTxUnderpriced.Code,
+			Message:       fmt.Sprintf("%s: %s < %s", ErrTxUnderpriced.Message, gasPrice, s.minGasPrice),
+			HTTPErrorCode: ErrTxUnderpriced.HTTPErrorCode,
+		}
+	}
+	return nil
+}
+
+// undecodableTransaction returns the error answering a transaction that failed
+// to decode for the min gas price check, or nil to forward it.
+func (s *Server) undecodableTransaction(req *RPCReq, msg string) error {
+	if s.invalidParams.forward(req.Method, true) {
+		return nil
+	}
+	return ErrInvalidParams(msg)
 }
 
 func (s *Server) rateLimitSender(ctx context.Context, req *RPCReq) error {
 	var params []string
 	if err := json.Unmarshal(req.Params, &params); err != nil {
 		log.Debug("error unmarshalling raw transaction params", "err", err, "req_Id", GetReqID(ctx))
-		return ErrParseErr
+		return s.unparsableSenderParams(req, ErrParseErr)
 	}
 
 	if len(params) != 1 {
 		log.Debug("raw transaction request has invalid number of params", "req_id", GetReqID(ctx))
 		// The error below is identical to the one Geth responds with.
-		return ErrInvalidParams("missing value for required argument 0")
+		return s.unparsableSenderParams(req, ErrInvalidParams("missing value for required argument 0"))
 	}
 
 	var data hexutil.Bytes
 	if err := data.UnmarshalText([]byte(params[0])); err != nil {
 		log.Debug("error decoding raw tx data", "err", err, "req_id", GetReqID(ctx))
 		// Geth returns the raw error from UnmarshalText.
-		return ErrInvalidParams(err.Error())
+		return s.unparsableSenderParams(req, ErrInvalidParams(err.Error()))
 	}
 
 	// Inflates a types.Transaction object from the transaction's raw bytes.
 	tx := new(types.Transaction)
 	if err := tx.UnmarshalBinary(data); err != nil {
 		log.Debug("could not unmarshal transaction", "err", err, "req_id", GetReqID(ctx))
-		return ErrInvalidParams(err.Error())
+		return s.unparsableSenderParams(req, ErrInvalidParams(err.Error()))
 	}
 
 	// Check if the transaction is for the expected chain,
@@ -810,6 +1741,15 @@ func (s *Server) rateLimitSender(ctx context.Context, req *RPCReq) error {
 	return nil
 }
 
+// unparsableSenderParams returns err, or nil to forward the transaction
+// without rate limiting its sender when the invalid params policy forwards it.
+func (s *Server) unparsableSenderParams(req *RPCReq, err error) error {
+	if s.invalidParams.forward(req.Method, false) {
+		return nil
+	}
+	return err
+}
+
 func (s *Server) isAllowedChainId(chainId *big.Int) bool {
 	if len(s.allowedChainIds) == 0 {
 		return true
@@ -830,23 +1770,89 @@ func setCacheHeader(w http.ResponseWriter, cached bool) {
 	}
 }
 
-func writeRPCError(ctx context.Context, w http.ResponseWriter, id json.RawMessage, err error) {
+func (s *Server) exceedsBatchMethodLimits(reqs []json.RawMessage) bool {
+	if len(s.batchMethodLimits) == 0 {
+		return false
+	}
+	counts := make(map[string]int)
+	for _, raw := range reqs {
+		req, err := ParseRPCReq(raw)
+		if err != nil {
+			continue
+		}
+		if limit, ok := s.batchMethodLimits[req.Method]; ok {
+			counts[req.Method]++
+			if counts[req.Method] > limit {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// checkParamsLength rejects calls with a params array longer than the limit of
+// their method. Params that aren't an array are left for the backend to check.
+func (s *Server) checkParamsLength(req *RPCReq) error {
+	limit, ok := s.maxParamsLength[req.Method]
+	if !ok {
+		return nil
+	}
+	var params []json.RawMessage
+	if err := json.Unmarshal(req.Params, &params); err != nil {
+		return nil
+	}
+	if len(params) > limit {
+		return ErrInvalidParams(fmt.Sprintf("too many params for %s, at most %d allowed", req.Method, limit))
+	}
+	return nil
+}
+
+func (s *Server) writeOversizedBatchError(ctx context.Context, w http.ResponseWriter, reqs []json.RawMessage) {
+	rpcErr := *ErrTooManyBatchRequests
+	rpcErr.Message = strings.NewReplacer(
+		"{limit}", strconv.Itoa(s.maxBatchSize),
+		"{size}", strconv.Itoa(len(reqs)),
+	).Replace(rpcErr.Message)
+	if s.batchErrorCode != 0 {
+		rpcErr.Code = s.batchErrorCode
+	}
+
+	if s.batchErrorStyle != BatchErrorStyleArray {
+		s.writeRPCError(ctx, w, nil, &rpcErr)
+		return
+	}
+
+	res := make([]*RPCRes, len(reqs))
+	for i, raw := range reqs {
+		var id json.RawMessage
+		if req, err := ParseRPCReq(raw); err == nil {
+			id = req.ID
+		}
+		res[i] = NewRPCErrorRes(id, &rpcErr)
+	}
+	writeBatchRPCRes(ctx, w, res)
+}
+
+func (s *Server) writeRPCError(ctx context.Context, w http.ResponseWriter, id json.RawMessage, err error) {
 	var res *RPCRes
 	if r, ok := err.(*RPCErr); ok {
 		res = NewRPCErrorRes(id, r)
 	} else {
 		res = NewRPCErrorRes(id, ErrInternal)
 	}
-	writeRPCRes(ctx, w, res)
+	s.writeRPCRes(ctx, w, res)
 }
 
-func writeRPCRes(ctx context.Context, w http.ResponseWriter, res *RPCRes) {
+func (s *Server) writeRPCRes(ctx context.Context, w http.ResponseWriter, res *RPCRes) {
 	statusCode := 200
-	if res.IsError() && res.Error.HTTPErrorCode != 0 {
-		statusCode = res.Error.HTTPErrorCode
+	if res.IsError() {
+		statusCode = s.errorStatus.status(res.Error)
 	}
 
 	w.Header().Set("content-type", "application/json")
+	if res.IsError() && res.Error.Code == ErrQueueTimeout.Code {
+		w.Header().Set("Retry-After", "1")
+	}
 	w.WriteHeader(statusCode)
 	ww := &recordLenWriter{Writer: w}
 	enc := json.NewEncoder(ww)
@@ -857,14 +1863,35 @@ func writeRPCRes(ctx context.Context, w http.ResponseWriter, res *RPCRes) {
 	}
 	httpResponseCodesTotal.WithLabelValues(strconv.Itoa(statusCode)).Inc()
 	RecordResponsePayloadSize(ctx, ww.Len)
+	RecordResponseSizeByMethod(ctx, 0, ww.Len)
 }
 
 func writeBatchRPCRes(ctx context.Context, w http.ResponseWriter, res []*RPCRes) {
 	w.Header().Set("content-type", "application/json")
 	w.WriteHeader(200)
+	var buf bytes.Buffer
+	if err := encodeBatchRPCRes(ctx, &buf, res, false); err != nil {
+		log.Error("error encoding batch rpc response", "err", err)
+		RecordRPCError(ctx, BackendProxyd, MethodUnknown, err)
+		return
+	}
 	ww := &recordLenWriter{Writer: w}
-	enc := json.NewEncoder(ww)
-	if err := enc.Encode(res); err != nil {
+	if _, err := ww.Write(buf.Bytes()); err != nil {
+		log.Error("error writing batch rpc response", "err", err)
+		RecordRPCError(ctx, BackendProxyd, MethodUnknown, err)
+		return
+	}
+	RecordResponsePayloadSize(ctx, ww.Len)
+}
+
+// writeBatchRPCResIncrementally writes res to w one call at a time once all of
+// them are resolved, so that only the encoding of a single call is held in
+// memory on top of the responses. Written responses are released as it goes.
+func writeBatchRPCResIncrementally(ctx context.Context, w http.ResponseWriter, res []*RPCRes) {
+	w.Header().Set("content-type", "application/json")
+	w.WriteHeader(200)
+	ww := &recordLenWriter{Writer: w}
+	if err := encodeBatchRPCRes(ctx, ww, res, true); err != nil {
 		log.Error("error writing batch rpc response", "err", err)
 		RecordRPCError(ctx, BackendProxyd, MethodUnknown, err)
 		return
@@ -872,6 +1899,65 @@ func writeBatchRPCRes(ctx context.Context, w http.ResponseWriter, res []*RPCRes)
 	RecordResponsePayloadSize(ctx, ww.Len)
 }
 
+// encodeBatchRPCRes writes res to w as a JSON array one call at a time,
+// recording the size of each by method. If release is set, written responses
+// are dropped from res.
+func encodeBatchRPCRes(ctx context.Context, w io.Writer, res []*RPCRes, release bool) error {
+	if _, err := w.Write([]byte("[")); err != nil {
+		return err
+	}
+	for i := range res {
+		elem, err := json.Marshal(res[i])
+		if err != nil {
+			return err
+		}
+		RecordResponseSizeByMethod(ctx, i, len(elem))
+		if release {
+			res[i] = nil
+		}
+		if i > 0 {
+			if _, err := w.Write([]byte(",")); err != nil {
+				return err
+			}
+		}
+		if _, err := w.Write(elem); err != nil {
+			return err
+		}
+	}
+	_, err := w.Write([]byte("]\n"))
+	return err
+}
+
+// isJSONContentType reports whether contentType is application/json, with
+// any parameters such as charset.
+func isJSONContentType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	return err == nil && mediaType == "application/json"
+}
+
+// limitRequestHeaders rejects requests whose URL or headers are over the
+// configured limits before they are handled.
+func (s *Server) limitRequestHeaders(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(r.RequestURI) > s.maxURLLength {
+			s.writeRPCError(r.Context(), w, nil, ErrRequestURITooLong)
+			return
+		}
+		var count, size int
+		for name, values := range r.Header {
+			count += len(values)
+			for _, value := range values {
+				size += len(name) + len(value)
+			}
+		}
+		if count > s.maxHeaderCount || size > s.maxHeaderBytes {
+			s.writeRPCError(r.Context(), w, nil, ErrRequestHeadersTooLarge)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
 func instrumentedHdlr(h http.Handler) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		respTimer := prometheus.NewTimer(httpRequestDurationSumm)
@@ -905,6 +1991,14 @@ func GetOpTxProxyAuthHeader(ctx context.Context) string {
 	return auth
 }
 
+func GetIdempotencyKey(ctx context.Context) string {
+	key, ok := ctx.Value(ContextKeyIdempotencyKey).(string)
+	if !ok {
+		return ""
+	}
+	return key
+}
+
 func GetReqID(ctx context.Context) string {
 	reqId, ok := ctx.Value(ContextKeyReqID).(string)
 	if !ok {
@@ -913,6 +2007,14 @@ func GetReqID(ctx context.Context) string {
 	return reqId
 }
 
+func GetPriority(ctx context.Context) int {
+	priority, ok := ctx.Value(ContextKeyPriority).(int)
+	if !ok {
+		return 0
+	}
+	return priority
+}
+
 func GetXForwardedFor(ctx context.Context) string {
 	xff, ok := ctx.Value(ContextKeyXForwardedFor).(string)
 	if !ok {
@@ -921,6 +2023,16 @@ func GetXForwardedFor(ctx context.Context) string {
 	return xff
 }
 
+// GetHop returns how many proxyd instances forwarded the request before this
+// one, per its hop header.
+func GetHop(ctx context.Context) int {
+	hop, ok := ctx.Value(ContextKeyHop).(int)
+	if !ok {
+		return 0
+	}
+	return hop
+}
+
 func GetTxSource(ctx context.Context) string {
 	source, ok := ctx.Value(XTxSource).(string)
 	if !ok {
@@ -950,6 +2062,16 @@ func (n *NoopRPCCache) PutRPC(context.Context, *RPCReq, *RPCRes) error {
 	return nil
 }
 
+func (n *NoopRPCCache) Flush(context.Context, []string, bool) (int, error) {
+	return 0, nil
+}
+
+func (n *NoopRPCCache) IsCacheable(string) bool {
+	return false
+}
+
+func (n *NoopRPCCache) SetHead(hexutil.Uint64) {}
+
 func truncate(str string, maxLen int) string {
 	if maxLen == 0 {
 		maxLen = maxRequestBodyLogLen
@@ -0,0 +1,188 @@
+package proxyd
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/redis/go-redis/v9"
+)
+
+// SpendTracker accumulates what each key spends over fixed windows.
+type SpendTracker interface {
+	// Spend adds cost to the spending of key in the current window, unless
+	// key already spent budget or more in it. It returns whether the cost was
+	// taken, or an error if a failure occurred in the backing store.
+	Spend(ctx context.Context, key string, cost int64, budget int64) (bool, error)
+}
+
+// MemorySpendTracker keeps spending in local memory, starting every key over
+// at each window like MemoryFrontendRateLimiter.
+type MemorySpendTracker struct {
+	dur     time.Duration
+	mtx     sync.Mutex
+	truncTS int64
+	spent   map[string]int64
+}
+
+func NewMemorySpendTracker(dur time.Duration) SpendTracker {
+	return &MemorySpendTracker{
+		dur:   dur,
+		spent: make(map[string]int64),
+	}
+}
+
+func (m *MemorySpendTracker) Spend(ctx context.Context, key string, cost int64, budget int64) (bool, error) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	if truncTS := truncateNow(m.dur); truncTS != m.truncTS {
+		m.truncTS = truncTS
+		m.spent = make(map[string]int64)
+	}
+	if m.spent[key] >= budget {
+		return false, nil
+	}
+	m.spent[key] += cost
+	return true, nil
+}
+
+// RedisSpendTracker keeps spending in Redis, so that every proxyd instance
+// sharing it enforces the same budgets.
+type RedisSpendTracker struct {
+	r         redis.UniversalClient
+	keyPrefix string
+	dur       time.Duration
+}
+
+func NewRedisSpendTracker(r redis.UniversalClient, keyPrefix string, dur time.Duration) SpendTracker {
+	return &RedisSpendTracker{
+		r:         r,
+		keyPrefix: keyPrefix,
+		dur:       dur,
+	}
+}
+
+func (r *RedisSpendTracker) Spend(ctx context.Context, key string, cost int64, budget int64) (bool, error) {
+	var incr *redis.IntCmd
+	fullKey := fmt.Sprintf("spending:%s:%d", key, truncateNow(r.dur))
+	if r.keyPrefix != "" {
+		fullKey = r.keyPrefix + ":" + fullKey
+	}
+	_, err := r.r.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		incr = pipe.IncrBy(ctx, fullKey, cost)
+		pipe.PExpire(ctx, fullKey, r.dur-time.Millisecond)
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+	if incr.Val()-cost < budget {
+		return true, nil
+	}
+	// the budget was already spent, so the cost isn't taken
+	if err := r.r.DecrBy(ctx, fullKey, cost).Err(); err != nil {
+		log.Warn("error refunding rejected spending", "key", key, "err", err)
+	}
+	return false, nil
+}
+
+// FallbackSpendTracker uses the secondary tracker when the primary one fails,
+// like FallbackRateLimiter.
+type FallbackSpendTracker struct {
+	primary   SpendTracker
+	secondary SpendTracker
+}
+
+func NewFallbackSpendTracker(primary SpendTracker, secondary SpendTracker) SpendTracker {
+	return &FallbackSpendTracker{
+		primary:   primary,
+		secondary: secondary,
+	}
+}
+
+func (f *FallbackSpendTracker) Spend(ctx context.Context, key string, cost int64, budget int64) (bool, error) {
+	ok, err := f.primary.Spend(ctx, key, cost, budget)
+	if err != nil {
+		log.Debug("falling back to the secondary spend tracker", "err", err)
+		return f.secondary.Spend(ctx, key, cost, budget)
+	}
+	return ok, nil
+}
+
+// spendingLimiter charges each call of an API key with a budget the cost of
+// its method, and rejects the calls of keys that spent their budget in the
+// current window.
+type spendingLimiter struct {
+	tracker     SpendTracker
+	budgets     map[string]int64
+	methodCosts map[string]int64
+	defaultCost int64
+}
+
+func newSpendingLimiter(config SpendingLimitConfig, authentication map[string]string, tracker SpendTracker) (*spendingLimiter, error) {
+	if len(config.Budgets) == 0 {
+		return nil, nil
+	}
+	if config.Window <= 0 {
+		return nil, fmt.Errorf("spending_limit window must be > 0")
+	}
+	aliases := make(map[string]bool, len(authentication))
+	for _, alias := range authentication {
+		aliases[alias] = true
+	}
+	for key, budget := range config.Budgets {
+		if !aliases[key] {
+			return nil, fmt.Errorf("spending_limit budget of %s is not for an authentication alias", key)
+		}
+		if budget <= 0 {
+			return nil, fmt.Errorf("spending_limit budget of %s must be > 0", key)
+		}
+	}
+	for method, cost := range config.MethodCosts {
+		if cost < 0 {
+			return nil, fmt.Errorf("spending_limit cost of %s must be >= 0", method)
+		}
+	}
+	if config.DefaultCost < 0 {
+		return nil, fmt.Errorf("spending_limit default_cost must be >= 0")
+	}
+	defaultCost := config.DefaultCost
+	if defaultCost == 0 {
+		defaultCost = 1
+	}
+	return &spendingLimiter{
+		tracker:     tracker,
+		budgets:     config.Budgets,
+		methodCosts: config.MethodCosts,
+		defaultCost: defaultCost,
+	}, nil
+}
+
+// Charge charges the API key of ctx with the cost of method. It returns false
+// if the key spent its budget, or its spending can't be tracked.
+func (l *spendingLimiter) Charge(ctx context.Context, method string) bool {
+	if l == nil {
+		return true
+	}
+	key := GetAuthCtx(ctx)
+	budget, ok := l.budgets[key]
+	if !ok {
+		return true
+	}
+	cost, ok := l.methodCosts[method]
+	if !ok {
+		cost = l.defaultCost
+	}
+	if cost == 0 {
+		return true
+	}
+	ok, err := l.tracker.Spend(ctx, key, cost, budget)
+	if err != nil {
+		log.Warn("error tracking spending", "key", key, "err", err)
+		return false
+	}
+	RecordSpending(key, cost, ok)
+	return ok
+}
@@ -4,7 +4,10 @@ import (
 	"crypto/tls"
 	"crypto/x509"
 	"errors"
+	"fmt"
 	"os"
+	"strings"
+	"sync"
 )
 
 func CreateTLSClient(ca string) (*tls.Config, error) {
@@ -31,3 +34,136 @@ func ParseKeyPair(crt, key string) (tls.Certificate, error) {
 	}
 	return cert, nil
 }
+
+// CertificateStore serves the certificates configured for each server name,
+// selecting them by the SNI of incoming TLS connections.
+type CertificateStore struct {
+	config       ServerTLSConfig
+	minVersion   uint16
+	cipherSuites []uint16
+
+	mu    sync.RWMutex
+	certs map[string]*tls.Certificate
+}
+
+func NewCertificateStore(config ServerTLSConfig) (*CertificateStore, error) {
+	if config.DefaultCertificate != "" {
+		if _, ok := config.Certificates[config.DefaultCertificate]; !ok {
+			return nil, fmt.Errorf("undefined default_certificate %s", config.DefaultCertificate)
+		}
+	}
+	minVersion, err := parseTLSVersion(config.MinTLSVersion)
+	if err != nil {
+		return nil, err
+	}
+	cipherSuites, err := parseCipherSuites(config.CipherSuites)
+	if err != nil {
+		return nil, err
+	}
+	s := &CertificateStore{
+		config:       config,
+		minVersion:   minVersion,
+		cipherSuites: cipherSuites,
+	}
+	if err := s.Reload(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// parseTLSVersion parses a version such as "1.2", defaulting to TLS 1.2.
+func parseTLSVersion(version string) (uint16, error) {
+	if version == "" {
+		return tls.VersionTLS12, nil
+	}
+	v, ok := tlsVersions[strings.TrimPrefix(strings.ToUpper(version), "TLS")]
+	if !ok {
+		return 0, fmt.Errorf("invalid min_tls_version %s, must be one of 1.0, 1.1, 1.2 or 1.3", version)
+	}
+	return v, nil
+}
+
+// parseCipherSuites parses the names of cipher suites as in the Go crypto/tls
+// package, such as TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256. Suites with known
+// weaknesses are refused, as are TLS 1.3 suites, which can't be configured.
+func parseCipherSuites(names []string) ([]uint16, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+	byName := make(map[string]*tls.CipherSuite)
+	for _, suite := range tls.CipherSuites() {
+		byName[suite.Name] = suite
+	}
+	insecure := make(map[string]bool)
+	for _, suite := range tls.InsecureCipherSuites() {
+		insecure[suite.Name] = true
+	}
+
+	ids := make([]uint16, 0, len(names))
+	for _, name := range names {
+		suite, ok := byName[name]
+		switch {
+		case insecure[name]:
+			return nil, fmt.Errorf("insecure cipher suite %s", name)
+		case !ok:
+			return nil, fmt.Errorf("unknown cipher suite %s", name)
+		case len(suite.SupportedVersions) == 1 && suite.SupportedVersions[0] == tls.VersionTLS13:
+			return nil, fmt.Errorf("cipher suite %s is a TLS 1.3 suite, which can't be configured", name)
+		}
+		ids = append(ids, suite.ID)
+	}
+	return ids, nil
+}
+
+// TLSConfig returns the configuration of the TLS listeners.
+func (s *CertificateStore) TLSConfig() *tls.Config {
+	return &tls.Config{
+		GetCertificate: s.GetCertificate,
+		MinVersion:     s.minVersion,
+		CipherSuites:   s.cipherSuites,
+	}
+}
+
+// Reload reads the certificate files again. If any of them fails to load, the
+// certificates in use are kept.
+func (s *CertificateStore) Reload() error {
+	certs := make(map[string]*tls.Certificate, len(s.config.Certificates))
+	for name, certConfig := range s.config.Certificates {
+		cert, err := ParseKeyPair(certConfig.CertFile, certConfig.KeyFile)
+		if err != nil {
+			return fmt.Errorf("error loading certificate for %s: %w", name, err)
+		}
+		certs[strings.ToLower(name)] = &cert
+	}
+	s.mu.Lock()
+	s.certs = certs
+	s.mu.Unlock()
+	return nil
+}
+
+// GetCertificate returns the certificate of the exact server name, else of a
+// matching wildcard name, else the default certificate.
+func (s *CertificateStore) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	name := strings.ToLower(strings.TrimSuffix(hello.ServerName, "."))
+	if cert, ok := s.certs[name]; ok {
+		return cert, nil
+	}
+	if _, parent, ok := strings.Cut(name, "."); ok {
+		if cert, ok := s.certs["*."+parent]; ok {
+			return cert, nil
+		}
+	}
+	if cert, ok := s.certs[strings.ToLower(s.config.DefaultCertificate)]; ok {
+		return cert, nil
+	}
+	return nil, fmt.Errorf("no certificate for server name %q", hello.ServerName)
+}
@@ -0,0 +1,128 @@
+package proxyd
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	tracerName             = "github.com/ethereum-optimism/infra/proxyd"
+	defaultOTelServiceName = "proxyd"
+)
+
+var (
+	attrMethod    = attribute.Key("rpc.method")
+	attrDomain    = attribute.Key("proxyd.domain")
+	attrReqID     = attribute.Key("proxyd.req_id")
+	attrBackend   = attribute.Key("proxyd.backend")
+	attrBatchSize = attribute.Key("proxyd.batch_size")
+	attrCacheHit  = attribute.Key("proxyd.cache_hit")
+	attrServedBy  = attribute.Key("proxyd.served_by")
+)
+
+var traceContext = propagation.TraceContext{}
+
+// newTracerProvider returns the tracer provider spans are exported with, and
+// a function flushing and stopping it. Without an endpoint, the globally
+// registered provider is used, such as that of a program embedding proxyd.
+func newTracerProvider(cfg OTelConfig) (trace.TracerProvider, func(context.Context) error, error) {
+	if cfg.Endpoint == "" {
+		return otel.GetTracerProvider(), func(context.Context) error { return nil }, nil
+	}
+	if cfg.SampleRatio < 0 || cfg.SampleRatio > 1 {
+		return nil, nil, fmt.Errorf("otel sample_ratio must be between 0 and 1")
+	}
+
+	opts := []otlptracehttp.Option{
+		otlptracehttp.WithEndpoint(cfg.Endpoint),
+		otlptracehttp.WithHeaders(cfg.Headers),
+	}
+	if cfg.Insecure {
+		opts = append(opts, otlptracehttp.WithInsecure())
+	}
+	exporter, err := otlptracehttp.New(context.Background(), opts...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error creating otlp exporter: %w", err)
+	}
+
+	serviceName := cfg.ServiceName
+	if serviceName == "" {
+		serviceName = defaultOTelServiceName
+	}
+	sampleRatio := 1.0
+	if cfg.SampleRatio != 0 {
+		sampleRatio = cfg.SampleRatio
+	}
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewSchemaless(semconv.ServiceName(serviceName))),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(sampleRatio))),
+	)
+	return tp, tp.Shutdown, nil
+}
+
+// startRequestSpan starts the span of an HTTP request, continuing the trace
+// of the client's traceparent header if any.
+func (s *Server) startRequestSpan(ctx context.Context, r *http.Request) (context.Context, trace.Span) {
+	if s.tracer == nil {
+		return ctx, trace.SpanFromContext(ctx)
+	}
+	ctx = traceContext.Extract(ctx, propagation.HeaderCarrier(r.Header))
+	return s.tracer.Start(ctx, "proxyd.rpc",
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			attrDomain.String(r.Header.Get("X-Forwarded-Host")),
+			attrReqID.String(GetReqID(ctx)),
+		),
+	)
+}
+
+// startChildSpan starts a span under the request span of ctx. It starts none
+// when the request isn't traced.
+func startChildSpan(ctx context.Context, name string, kind trace.SpanKind, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	parent := trace.SpanFromContext(ctx)
+	if !parent.IsRecording() {
+		return ctx, parent
+	}
+	return parent.TracerProvider().Tracer(tracerName).Start(ctx, name,
+		trace.WithSpanKind(kind),
+		trace.WithAttributes(attrs...),
+	)
+}
+
+// startBackendSpan starts the span of a request to a backend.
+func startBackendSpan(ctx context.Context, backend string, reqs []*RPCReq, isBatch bool) (context.Context, trace.Span) {
+	method := reqs[0].Method
+	if isBatch {
+		method = "<batch>"
+	}
+	return startChildSpan(ctx, "proxyd.backend", trace.SpanKindClient,
+		attrBackend.String(backend),
+		attrMethod.String(method),
+		attrBatchSize.Int(len(reqs)),
+	)
+}
+
+// injectTraceContext sets the traceparent header of a request to a backend.
+func injectTraceContext(ctx context.Context, header http.Header) {
+	traceContext.Inject(ctx, propagation.HeaderCarrier(header))
+}
+
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
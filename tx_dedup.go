@@ -0,0 +1,247 @@
+package proxyd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	alreadyKnownErrMsg = "already known"
+	// txDedupPending marks in Redis a send being broadcast by a replica. It
+	// can't be mistaken for a result, which is stored as JSON.
+	txDedupPending = "pending"
+	// txDedupRedisTimeout bounds storing and releasing claims, which outlive
+	// the request so that a client giving up doesn't leave its claim behind.
+	txDedupRedisTimeout = 2 * time.Second
+)
+
+// txDedup remembers the responses to eth_sendRawTransaction for a short
+// window, so that retried sends of the same raw transaction aren't broadcast
+// again. Sends are keyed by their transaction hash, or by the Idempotency-Key
+// header of the request when there is one.
+//
+// With Redis, the window is shared by every proxyd instance using it: the
+// first instance to claim a key broadcasts the send, and the others answer
+// with its response once it is stored, or with ErrSendRawTxInProgress until
+// then. A claim expires after pendingTTL, so that a send whose claim couldn't
+// be released doesn't block its retries for the whole window. Local memory is
+// used when Redis fails.
+type txDedup struct {
+	ttl       time.Duration
+	mu        sync.Mutex
+	entries   map[string]txDedupEntry
+	lastSweep time.Time
+
+	redis      redis.UniversalClient
+	keyPrefix  string
+	pendingTTL time.Duration
+}
+
+type txDedupEntry struct {
+	result    interface{}
+	expiresAt time.Time
+}
+
+func newTxDedup(ttl time.Duration) *txDedup {
+	return &txDedup{
+		ttl:       ttl,
+		entries:   make(map[string]txDedupEntry),
+		lastSweep: time.Now(),
+	}
+}
+
+func newRedisTxDedup(ttl time.Duration, pendingTTL time.Duration, r redis.UniversalClient, keyPrefix string) *txDedup {
+	d := newTxDedup(ttl)
+	d.redis = r
+	d.keyPrefix = keyPrefix
+	d.pendingTTL = min(pendingTTL, ttl)
+	return d
+}
+
+// rawTxHash returns the hash of the raw transaction sent by req, which is also
+// the transaction hash.
+func rawTxHash(req *RPCReq) (common.Hash, bool) {
+	if req.Method != "eth_sendRawTransaction" {
+		return common.Hash{}, false
+	}
+	var params []string
+	if err := json.Unmarshal(req.Params, &params); err != nil || len(params) != 1 {
+		return common.Hash{}, false
+	}
+	var data hexutil.Bytes
+	if err := data.UnmarshalText([]byte(params[0])); err != nil || len(data) == 0 {
+		return common.Hash{}, false
+	}
+	return crypto.Keccak256Hash(data), true
+}
+
+// key returns the key deduping the send of elem, and its transaction hash. An
+// idempotency key covers a whole request, so it is scoped to the position of
+// the send in the batch, and to the API key that sent it.
+func (d *txDedup) key(ctx context.Context, elem batchElem) (string, common.Hash, bool) {
+	hash, ok := rawTxHash(elem.Req)
+	if !ok {
+		return "", common.Hash{}, false
+	}
+	if idempotencyKey := GetIdempotencyKey(ctx); idempotencyKey != "" {
+		return fmt.Sprintf("idempotency:%s:%s:%d", GetAuthCtx(ctx), idempotencyKey, elem.Index), hash, true
+	}
+	return hash.Hex(), hash, true
+}
+
+func (d *txDedup) redisKey(key string) string {
+	return d.keyPrefix + key
+}
+
+// Get returns the remembered response to a send of the same raw transaction
+// within the window, if any. With Redis, a send that isn't remembered is
+// claimed, and must be passed to Observe once forwarded, or to Release if it
+// isn't.
+func (d *txDedup) Get(ctx context.Context, elem batchElem) *RPCRes {
+	key, _, ok := d.key(ctx, elem)
+	if !ok {
+		return nil
+	}
+	if d.redis != nil {
+		res, err := d.claim(ctx, key, elem.Req)
+		if err == nil {
+			return res
+		}
+		log.Warn("error deduping transaction in redis", "key", key, "err", err)
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	entry, ok := d.entries[key]
+	if !ok || time.Now().After(entry.expiresAt) {
+		return nil
+	}
+	return &RPCRes{
+		JSONRPC: JSONRPCVersion,
+		Result:  entry.result,
+		ID:      elem.Req.ID,
+	}
+}
+
+// claim atomically marks key as pending unless it already is, in which case
+// the response stored by the replica holding it is returned.
+func (d *txDedup) claim(ctx context.Context, key string, req *RPCReq) (*RPCRes, error) {
+	claimed, err := d.redis.SetNX(ctx, d.redisKey(key), txDedupPending, d.pendingTTL).Result()
+	if err != nil {
+		return nil, err
+	}
+	if claimed {
+		return nil, nil
+	}
+	val, err := d.redis.Get(ctx, d.redisKey(key)).Result()
+	if err == redis.Nil {
+		// the claim expired or was released in between, so the send is
+		// broadcast again rather than rejected
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if val == txDedupPending {
+		return NewRPCErrorRes(req.ID, ErrSendRawTxInProgress), nil
+	}
+	return &RPCRes{
+		JSONRPC: JSONRPCVersion,
+		Result:  json.RawMessage(val),
+		ID:      req.ID,
+	}, nil
+}
+
+// Observe remembers a successful send and returns the response to serve. An
+// "already known" error means an earlier send reached the backend, so it is
+// answered with the transaction hash like the original send was. With Redis,
+// the claim of a failed send is released so that it can be retried.
+func (d *txDedup) Observe(ctx context.Context, elem batchElem, res *RPCRes) *RPCRes {
+	key, hash, ok := d.key(ctx, elem)
+	if !ok {
+		return res
+	}
+	if res.IsError() {
+		if !strings.Contains(strings.ToLower(res.Error.Message), alreadyKnownErrMsg) {
+			d.release(ctx, key)
+			return res
+		}
+		res = &RPCRes{
+			JSONRPC: JSONRPCVersion,
+			Result:  hash.Hex(),
+			ID:      res.ID,
+		}
+	}
+	if res.Result == nil {
+		d.release(ctx, key)
+		return res
+	}
+	if d.redis != nil {
+		err := d.store(ctx, key, res.Result)
+		if err == nil {
+			return res
+		}
+		log.Warn("error storing deduped transaction in redis", "key", key, "err", err)
+	}
+
+	now := time.Now()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if now.Sub(d.lastSweep) > d.ttl {
+		for h, entry := range d.entries {
+			if now.After(entry.expiresAt) {
+				delete(d.entries, h)
+			}
+		}
+		d.lastSweep = now
+	}
+	d.entries[key] = txDedupEntry{
+		result:    res.Result,
+		expiresAt: now.Add(d.ttl),
+	}
+	return res
+}
+
+// Release releases the claims of sends that are answered without being
+// forwarded, so that they can be retried.
+func (d *txDedup) Release(ctx context.Context, elems []batchElem) {
+	if d.redis == nil {
+		return
+	}
+	for _, elem := range elems {
+		if key, _, ok := d.key(ctx, elem); ok {
+			d.release(ctx, key)
+		}
+	}
+}
+
+func (d *txDedup) store(ctx context.Context, key string, result interface{}) error {
+	val, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), txDedupRedisTimeout)
+	defer cancel()
+	return d.redis.Set(ctx, d.redisKey(key), val, d.ttl).Err()
+}
+
+func (d *txDedup) release(ctx context.Context, key string) {
+	if d.redis == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), txDedupRedisTimeout)
+	defer cancel()
+	if err := d.redis.Del(ctx, d.redisKey(key)).Err(); err != nil {
+		log.Warn("error releasing deduped transaction in redis", "key", key, "err", err)
+	}
+}
@@ -0,0 +1,93 @@
+package proxyd
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+)
+
+const defaultWarmupTimeout = 5 * time.Second
+
+// WarmupBackends sends each of methods, without params, to every backend so
+// that connections are established before serving traffic. It returns once
+// all backends answered or timeout elapsed, and failures are only logged.
+func WarmupBackends(backends []*Backend, methods []string, timeout time.Duration) {
+	if len(methods) == 0 || len(backends) == 0 {
+		return
+	}
+	if timeout == 0 {
+		timeout = defaultWarmupTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	start := time.Now()
+	var wg sync.WaitGroup
+	for _, be := range backends {
+		wg.Add(1)
+		go func(be *Backend) {
+			defer wg.Done()
+			for _, method := range methods {
+				req := &RPCReq{
+					JSONRPC: JSONRPCVersion,
+					Method:  method,
+					Params:  json.RawMessage("[]"),
+					ID:      json.RawMessage("1"),
+				}
+				// Warmup requests use the consensus semaphore so as not to
+				// count against max_concurrent_rpcs.
+				res, err := be.doForward(ctx, []*RPCReq{req}, false, be.consensusSemaphore)
+				if err == nil && res[0].IsError() {
+					err = res[0].Error
+				}
+				if err != nil {
+					log.Warn("error warming up backend",
+						"backend_name", be.Name,
+						"method", method,
+						"err", err,
+					)
+				}
+			}
+		}(be)
+	}
+	wg.Wait()
+	log.Info("warmed up backends", "backends", len(backends), "duration", time.Since(start))
+}
+
+// consensusWarmupInterval is how often WaitForConsensus checks the groups.
+const consensusWarmupInterval = 50 * time.Millisecond
+
+// WaitForConsensus returns once every consensus aware group among
+// backendGroups has a consensus head, so that block tags can be rewritten, or
+// once timeout elapsed. In that case the groups without a head are logged and
+// proxyd serves anyway.
+func WaitForConsensus(backendGroups map[string]*BackendGroup, timeout time.Duration) bool {
+	start := time.Now()
+	deadline := start.Add(timeout)
+	for {
+		var waiting []string
+		for name, bg := range backendGroups {
+			if bg.Consensus != nil && bg.Consensus.GetLatestBlockNumber() == 0 {
+				waiting = append(waiting, name)
+			}
+		}
+		if len(waiting) == 0 {
+			log.Info("consensus established", "duration", time.Since(start))
+			return true
+		}
+		if time.Now().After(deadline) {
+			sort.Strings(waiting)
+			log.Warn("serving without consensus after warmup timeout",
+				"backend_groups", strings.Join(waiting, ", "),
+				"timeout", timeout,
+			)
+			return false
+		}
+		time.Sleep(consensusWarmupInterval)
+	}
+}
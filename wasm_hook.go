@@ -0,0 +1,182 @@
+package proxyd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+)
+
+const (
+	defaultWASMHookTimeout = 10 * time.Millisecond
+	// wasmHookMaxMemoryPages caps the memory of a module at 16MiB.
+	wasmHookMaxMemoryPages = 256
+
+	wasmHookActionPass    = "pass"
+	wasmHookActionReject  = "reject"
+	wasmHookActionRewrite = "rewrite"
+	wasmHookActionError   = "error"
+)
+
+// WASMHook runs a sandboxed WebAssembly module on requests. The module must
+// export its memory and two functions:
+//
+//	alloc(size i32) i32
+//	handle(ptr i32, len i32) i64
+//
+// The request is written as JSON to memory returned by alloc, and handle
+// returns where its JSON action lies in memory, packed as ptr<<32 | len. The
+// action is one of:
+//
+//	{"action": "pass"}
+//	{"action": "reject", "code": -32000, "message": "..."}
+//	{"action": "rewrite", "method": "...", "params": [...]}
+//
+// Each request gets a fresh module instance, so no state leaks across them.
+// Modules may import WASI, which is given no filesystem, env or network.
+type WASMHook struct {
+	runtime  wazero.Runtime
+	module   wazero.CompiledModule
+	timeout  time.Duration
+	failOpen bool
+}
+
+type wasmHookAction struct {
+	Action  string          `json:"action"`
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params"`
+}
+
+func NewWASMHook(ctx context.Context, config WASMHookConfig) (*WASMHook, error) {
+	code, err := os.ReadFile(config.ModulePath)
+	if err != nil {
+		return nil, fmt.Errorf("error reading wasm hook module: %w", err)
+	}
+
+	runtime := wazero.NewRuntimeWithConfig(ctx, wazero.NewRuntimeConfig().
+		WithCloseOnContextDone(true).
+		WithMemoryLimitPages(wasmHookMaxMemoryPages))
+	if _, err := wasi_snapshot_preview1.Instantiate(ctx, runtime); err != nil {
+		_ = runtime.Close(ctx)
+		return nil, fmt.Errorf("error instantiating wasi: %w", err)
+	}
+	module, err := runtime.CompileModule(ctx, code)
+	if err != nil {
+		_ = runtime.Close(ctx)
+		return nil, fmt.Errorf("error compiling wasm hook module: %w", err)
+	}
+	funcs := module.ExportedFunctions()
+	if funcs["alloc"] == nil || funcs["handle"] == nil || module.ExportedMemories()["memory"] == nil {
+		_ = runtime.Close(ctx)
+		return nil, errors.New("wasm hook module must export memory, alloc and handle")
+	}
+
+	timeout := time.Duration(config.Timeout)
+	if timeout == 0 {
+		timeout = defaultWASMHookTimeout
+	}
+	return &WASMHook{
+		runtime:  runtime,
+		module:   module,
+		timeout:  timeout,
+		failOpen: config.FailOpen,
+	}, nil
+}
+
+// Apply runs the module on req, rewriting req in place if the module asks to.
+// It returns an RPCErr if the request must not be forwarded.
+func (h *WASMHook) Apply(ctx context.Context, req *RPCReq) error {
+	start := time.Now()
+	action, err := h.run(ctx, req)
+	if err != nil {
+		RecordWASMHook(wasmHookActionError, time.Since(start))
+		log.Warn("error running wasm hook", "req_id", GetReqID(ctx), "method", req.Method, "err", err)
+		if h.failOpen {
+			return nil
+		}
+		return ErrWASMHookFailed
+	}
+	RecordWASMHook(action.Action, time.Since(start))
+
+	switch action.Action {
+	case wasmHookActionReject:
+		rpcErr := &RPCErr{
+			Code:          ErrRejectedByWASMHook.Code,
+			Message:       ErrRejectedByWASMHook.Message,
+			HTTPErrorCode: ErrRejectedByWASMHook.HTTPErrorCode,
+		}
+		if action.Code != 0 {
+			rpcErr.Code = action.Code
+		}
+		if action.Message != "" {
+			rpcErr.Message = action.Message
+		}
+		return rpcErr
+	case wasmHookActionRewrite:
+		if action.Method != "" {
+			req.Method = action.Method
+		}
+		if action.Params != nil {
+			req.Params = action.Params
+		}
+	}
+	return nil
+}
+
+func (h *WASMHook) run(ctx context.Context, req *RPCReq) (*wasmHookAction, error) {
+	in, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, h.timeout)
+	defer cancel()
+
+	// An empty name lets instances run concurrently.
+	mod, err := h.runtime.InstantiateModule(ctx, h.module, wazero.NewModuleConfig().WithName(""))
+	if err != nil {
+		return nil, fmt.Errorf("error instantiating module: %w", err)
+	}
+	defer mod.Close(context.Background())
+
+	results, err := mod.ExportedFunction("alloc").Call(ctx, uint64(len(in)))
+	if err != nil {
+		return nil, fmt.Errorf("error calling alloc: %w", err)
+	}
+	ptr := uint32(results[0])
+	if !mod.Memory().Write(ptr, in) {
+		return nil, errors.New("alloc returned out of range memory")
+	}
+
+	results, err = mod.ExportedFunction("handle").Call(ctx, uint64(ptr), uint64(len(in)))
+	if err != nil {
+		return nil, fmt.Errorf("error calling handle: %w", err)
+	}
+	out, ok := mod.Memory().Read(uint32(results[0]>>32), uint32(results[0]))
+	if !ok {
+		return nil, errors.New("handle returned out of range memory")
+	}
+
+	action := new(wasmHookAction)
+	if err := json.Unmarshal(out, action); err != nil {
+		return nil, fmt.Errorf("error decoding action: %w", err)
+	}
+	switch action.Action {
+	case wasmHookActionPass, wasmHookActionReject, wasmHookActionRewrite:
+	default:
+		return nil, fmt.Errorf("unknown action %q", action.Action)
+	}
+	return action, nil
+}
+
+func (h *WASMHook) Close() error {
+	return h.runtime.Close(context.Background())
+}
@@ -0,0 +1,174 @@
+package proxyd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/gorilla/websocket"
+)
+
+var errWSClientTooSlow = errors.New("ws client too slow to keep up with its backend")
+
+// wsBackpressure sets how many backend messages are queued for each WS
+// client and what happens when a client's queue is full.
+type wsBackpressure struct {
+	size   int
+	policy WSBackpressurePolicy
+}
+
+func newWSBackpressure(size int, policy WSBackpressurePolicy) (*wsBackpressure, error) {
+	if size < 0 {
+		return nil, errors.New("ws_client_buffer_size must be >= 0")
+	}
+	switch policy {
+	case "":
+		policy = WSBackpressureDropOldest
+	case WSBackpressureDropOldest, WSBackpressureDisconnect:
+	default:
+		return nil, fmt.Errorf("invalid ws_backpressure_policy: %s", policy)
+	}
+	if size == 0 {
+		return nil, nil
+	}
+	return &wsBackpressure{size: size, policy: policy}, nil
+}
+
+type wsMessage struct {
+	msgType      int
+	data         []byte
+	notification bool
+}
+
+// wsClientQueue holds the backend messages not yet written to a client, so
+// that reading from the backend doesn't wait on the client.
+type wsClientQueue struct {
+	mtx    sync.Mutex
+	msgs   []wsMessage
+	size   int
+	policy WSBackpressurePolicy
+	ready  chan struct{}
+	done   chan struct{}
+	once   sync.Once
+}
+
+func newWSClientQueue(bp *wsBackpressure) *wsClientQueue {
+	return &wsClientQueue{
+		msgs:   make([]wsMessage, 0, bp.size),
+		size:   bp.size,
+		policy: bp.policy,
+		ready:  make(chan struct{}, 1),
+		done:   make(chan struct{}),
+	}
+}
+
+// push queues msg and returns how many notifications were dropped to make
+// room for it, or false if the client has to be disconnected. Responses to
+// calls are never dropped: with the drop_oldest policy, a full queue drops
+// its oldest notification, or msg itself if it is one and there is none
+// queued. A response to a queue holding only responses disconnects the
+// client, so that the queue never grows past its size.
+func (q *wsClientQueue) push(msg wsMessage) (int, bool) {
+	q.mtx.Lock()
+	defer q.mtx.Unlock()
+
+	dropped := 0
+	if len(q.msgs) >= q.size {
+		if q.policy == WSBackpressureDisconnect {
+			return 0, false
+		}
+		oldest := -1
+		for i, queued := range q.msgs {
+			if queued.notification {
+				oldest = i
+				break
+			}
+		}
+		switch {
+		case oldest >= 0:
+			q.msgs = append(q.msgs[:oldest], q.msgs[oldest+1:]...)
+			dropped++
+		case msg.notification:
+			return 1, true
+		default:
+			return 0, false
+		}
+	}
+	q.msgs = append(q.msgs, msg)
+	select {
+	case q.ready <- struct{}{}:
+	default:
+	}
+	return dropped, true
+}
+
+// pop waits for queued messages and returns them all, or nil once the queue
+// is closed.
+func (q *wsClientQueue) pop() []wsMessage {
+	for {
+		q.mtx.Lock()
+		msgs := q.msgs
+		if len(msgs) > 0 {
+			q.msgs = make([]wsMessage, 0, q.size)
+		}
+		q.mtx.Unlock()
+		if len(msgs) > 0 {
+			return msgs
+		}
+		select {
+		case <-q.ready:
+		case <-q.done:
+			return nil
+		}
+	}
+}
+
+func (q *wsClientQueue) close() {
+	q.once.Do(func() {
+		close(q.done)
+	})
+}
+
+// sendClient writes a backend message to the client, through its queue when
+// backpressure is configured.
+func (w *WSProxier) sendClient(ctx context.Context, msgType int, msg []byte, res *RPCRes) error {
+	if w.queue == nil {
+		return w.writeClientConn(msgType, msg)
+	}
+	notification := res != nil && (len(res.ID) == 0 || string(res.ID) == "null") && !res.IsError()
+	dropped, ok := w.queue.push(wsMessage{msgType: msgType, data: msg, notification: notification})
+	if !ok {
+		log.Warn("disconnecting ws client too slow to keep up",
+			"backend", w.backend.Name,
+			"auth", GetAuthCtx(ctx),
+			"req_id", GetReqID(ctx),
+		)
+		RecordWSBackpressureDisconnect(ctx, w.backend.Name)
+		closeMsg := websocket.FormatCloseMessage(websocket.ClosePolicyViolation, errWSClientTooSlow.Error())
+		_ = w.clientConn.WriteControl(websocket.CloseMessage, closeMsg, time.Now().Add(time.Second))
+		return errWSClientTooSlow
+	}
+	if dropped > 0 {
+		RecordWSDroppedNotifications(ctx, w.backend.Name, dropped)
+	}
+	return nil
+}
+
+// clientWriter writes the queued backend messages to the client.
+func (w *WSProxier) clientWriter(errC chan error) {
+	for {
+		msgs := w.queue.pop()
+		if msgs == nil {
+			return
+		}
+		for _, msg := range msgs {
+			if err := w.writeClientConn(msg.msgType, msg.data); err != nil {
+				errC <- err
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package proxyd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWSClientQueueDropOldest(t *testing.T) {
+	q := newWSClientQueue(&wsBackpressure{size: 2, policy: WSBackpressureDropOldest})
+	notification := wsMessage{data: []byte("notification"), notification: true}
+	response := wsMessage{data: []byte("response")}
+
+	dropped, ok := q.push(notification)
+	require.True(t, ok)
+	require.Zero(t, dropped)
+	dropped, ok = q.push(response)
+	require.True(t, ok)
+	require.Zero(t, dropped)
+
+	// the notification makes room for the response
+	dropped, ok = q.push(response)
+	require.True(t, ok)
+	require.Equal(t, 1, dropped)
+
+	// with only responses queued, notifications are dropped and responses
+	// disconnect the client rather than growing the queue
+	dropped, ok = q.push(notification)
+	require.True(t, ok)
+	require.Equal(t, 1, dropped)
+	_, ok = q.push(response)
+	require.False(t, ok)
+	require.Len(t, q.msgs, 2)
+}